@@ -32,6 +32,9 @@ func (e *HubExecutor) Execute(ctx context.Context, reqCtx *a2asrv.RequestContext
 	if targetAgent == "" {
 		return e.writeFailure(ctx, reqCtx, queue, "metadata.targetAgent required")
 	}
+	if !FromSDKMessage(reqCtx.Message).HasContent() {
+		return e.writeFailure(ctx, reqCtx, queue, types.ErrEmptyMessage.Error())
+	}
 
 	// Find agent in registry
 	agentInfo, ok := e.server.Registry().Get(targetAgent)
@@ -55,12 +58,33 @@ func (e *HubExecutor) Execute(ctx context.Context, reqCtx *a2asrv.RequestContext
 
 	// Convert RequestContext to internal ExecutionContext
 	execCtx := e.toExecutionContext(reqCtx)
+	workingDir, err := e.server.ResolveWorkingDir(targetAgent, execCtx.UserMessage, hub.ExtractWorkingDir(execCtx.UserMessage.Metadata))
+	if err != nil {
+		return e.writeFailure(ctx, reqCtx, queue, err.Error())
+	}
+	execCtx.WorkingDir = workingDir
+
+	// Record the turn so a later send with the same context id continues it; this
+	// creates the context when the id is new
+	contexts := e.server.Contexts()
+	_ = contexts.AddMessage(reqCtx.ContextID, execCtx.UserMessage)
 
 	// Execute agent
 	result, err := agentInfo.Agent.Execute(execCtx)
 	if err != nil {
 		return e.writeFailure(ctx, reqCtx, queue, err.Error())
 	}
+	if result.Task.Status.Message != nil {
+		reply := *result.Task.Status.Message
+		reply.ContextID = reqCtx.ContextID
+		reply.TaskID = string(reqCtx.TaskID)
+		reply.Metadata = make(map[string]any, len(reply.Metadata)+1)
+		for key, value := range result.Task.Status.Message.Metadata {
+			reply.Metadata[key] = value
+		}
+		reply.Metadata["agentId"] = targetAgent
+		_ = contexts.AddMessage(reqCtx.ContextID, reply)
+	}
 
 	// Write completion status with response message
 	var responseMsg *sdka2a.Message
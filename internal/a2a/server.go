@@ -7,6 +7,7 @@ import (
 
 	"agents-hub/internal/hub"
 	"agents-hub/internal/types"
+	"agents-hub/internal/version"
 
 	sdka2a "github.com/a2aproject/a2a-go/a2a"
 	"github.com/a2aproject/a2a-go/a2asrv"
@@ -119,7 +120,7 @@ func (s *A2AServer) buildHubAgentCard() *sdka2a.AgentCard {
 		Name:            "Agents Hub",
 		Description:     "Multi-agent orchestration hub supporting A2A protocol",
 		URL:             a2aURL,
-		Version:         "1.0.0",
+		Version:         version.Get().Version,
 		ProtocolVersion: "1.0",
 		Provider: &sdka2a.AgentProvider{
 			Org: "Local",
@@ -0,0 +1,158 @@
+package agents
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrLoginRequired reports that a CLI stopped to ask for a login instead of answering
+var ErrLoginRequired = errors.New("agent requires login")
+
+// maxAuthLineBytes bounds the unterminated line kept for matching
+const maxAuthLineBytes = 4096
+
+// The default patterns match the CLIs' own prompt lines from their start, so an answer
+// that mentions logging in mid-sentence doesn't look like a prompt
+
+func claudeAuthPatterns() []string {
+	return []string{
+		`(?i)^\s*invalid api key\b`,
+		`(?i)^\s*(error: )?please run /login\b`,
+	}
+}
+
+func codexAuthPatterns() []string {
+	return []string{
+		`(?i)^\s*(error: )?not logged in\b`,
+		`(?i)^\s*(error: )?please (run )?codex login\b`,
+	}
+}
+
+func geminiAuthPatterns() []string {
+	return []string{
+		`(?i)^\s*code assist login required\b`,
+		`(?i)^\s*(otherwise navigate to:\s*)?https://accounts\.google\.com/o/oauth2/\S*\s*$`,
+		`(?i)^\s*please visit the following url to authori[sz]e\b`,
+		`(?i)^\s*waiting for auth`,
+	}
+}
+
+// resolveAuthPatterns adds the regex from the first set env key to the defaults
+func resolveAuthPatterns(defaults []string, envKeys ...string) []string {
+	for _, key := range envKeys {
+		if val := strings.TrimSpace(os.Getenv(key)); val != "" {
+			return append(append([]string{}, defaults...), val)
+		}
+	}
+	return defaults
+}
+
+func compilePatterns(patterns []string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled
+}
+
+// loginError describes a detected login prompt, with the agent's hint when it has one
+func (a *CLIAgent) loginError() error {
+	if a.config.LoginHint == "" {
+		return ErrLoginRequired
+	}
+	return fmt.Errorf("%w: %s", ErrLoginRequired, a.config.LoginHint)
+}
+
+// authWatcher scans a command's output for login prompts and calls onMatch (typically
+// the command's cancel func) on the first hit so a blocked CLI doesn't run to its
+// timeout. Partial lines are checked too, since prompts often wait without a newline.
+type authWatcher struct {
+	patterns []*regexp.Regexp
+	onMatch  func()
+	matched  atomic.Bool
+}
+
+func (a *CLIAgent) newAuthWatcher(onMatch func()) *authWatcher {
+	return &authWatcher{patterns: a.authPatterns, onMatch: onMatch}
+}
+
+// Stdout returns the writer for the command's stdout. It stops watching at the first
+// line that isn't a login prompt: from there on stdout is the answer, which may quote
+// one.
+func (w *authWatcher) Stdout() io.Writer {
+	return &authStream{watcher: w, untilAnswer: true}
+}
+
+// Stderr returns the writer for the command's stderr, which is watched throughout
+func (w *authWatcher) Stderr() io.Writer {
+	return &authStream{watcher: w}
+}
+
+// authStream is one output stream of a watched command
+type authStream struct {
+	watcher     *authWatcher
+	untilAnswer bool
+	mu          sync.Mutex
+	line        []byte
+	answered    bool
+}
+
+func (s *authStream) Write(p []byte) (int, error) {
+	w := s.watcher
+	if len(w.patterns) == 0 || w.matched.Load() {
+		return len(p), nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.answered {
+		return len(p), nil
+	}
+	s.line = append(s.line, p...)
+	for {
+		i := bytes.IndexByte(s.line, '\n')
+		if i < 0 {
+			break
+		}
+		line := s.line[:i]
+		if w.check(line) {
+			return len(p), nil
+		}
+		if s.untilAnswer && len(bytes.TrimSpace(line)) > 0 {
+			s.answered = true
+			s.line = nil
+			return len(p), nil
+		}
+		s.line = s.line[i+1:]
+	}
+	if len(s.line) > maxAuthLineBytes {
+		s.line = s.line[len(s.line)-maxAuthLineBytes:]
+	}
+	w.check(s.line)
+	return len(p), nil
+}
+
+func (w *authWatcher) check(line []byte) bool {
+	for _, pattern := range w.patterns {
+		if pattern.Match(line) {
+			if !w.matched.Swap(true) {
+				w.onMatch()
+			}
+			return true
+		}
+	}
+	return false
+}
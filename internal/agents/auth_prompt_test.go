@@ -0,0 +1,43 @@
+package agents
+
+import "testing"
+
+func TestAuthWatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		stdout   []string // writes, in order
+		stderr   []string
+		want     bool
+	}{
+		{"claude prompt on stdout", claudeAuthPatterns(), []string{"Invalid API key · Please run /login\n"}, nil, true},
+		{"codex prompt on stderr", codexAuthPatterns(), nil, []string{"starting\n", "Error: Not logged in\n"}, true},
+		{"prompt without a newline", geminiAuthPatterns(), []string{"Waiting for authentication..."}, nil, true},
+		{"prompt split across writes", claudeAuthPatterns(), []string{"Please ru", "n /login"}, nil, true},
+		{"gemini oauth url", geminiAuthPatterns(), nil, []string{"https://accounts.google.com/o/oauth2/v2/auth?client_id=x\n"}, true},
+		{"answer quoting a prompt later", claudeAuthPatterns(), []string{"Here is the fix.\n", "Please run /login first.\n"}, nil, false},
+		{"answer in one write", codexAuthPatterns(), []string{"Done.\nNot logged in users see a banner\n"}, nil, false},
+		{"mention mid-line on stderr", codexAuthPatterns(), nil, []string{"warning: the user is not logged in to the app\n"}, false},
+		{"blank lines before the prompt", claudeAuthPatterns(), []string{"\n\n", "Invalid API key\n"}, nil, true},
+		{"no patterns", nil, []string{"Please run /login\n"}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := 0
+			watcher := &authWatcher{patterns: compilePatterns(tt.patterns), onMatch: func() { matches++ }}
+			stdout, stderr := watcher.Stdout(), watcher.Stderr()
+			for _, p := range tt.stdout {
+				_, _ = stdout.Write([]byte(p))
+			}
+			for _, p := range tt.stderr {
+				_, _ = stderr.Write([]byte(p))
+			}
+			if got := watcher.matched.Load(); got != tt.want {
+				t.Fatalf("matched = %v, want %v", got, tt.want)
+			}
+			if tt.want && matches != 1 {
+				t.Fatalf("onMatch called %d times, want 1", matches)
+			}
+		})
+	}
+}
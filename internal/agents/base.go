@@ -15,3 +15,11 @@ type Agent interface {
 	Execute(ctx types.ExecutionContext) (types.ExecutionResult, error)
 	Cancel(taskID string) (bool, error)
 }
+
+// DirectoryRequester is an agent whose per-message config can pick the directories
+// it works in, overriding the one the hub passes. RequestedDirs returns that working
+// directory ("" when the hub's is used) and any extra directories it may write to,
+// so the hub can check them against the agent's allowed root.
+type DirectoryRequester interface {
+	RequestedDirs(msg types.Message) (workingDir string, extra []string)
+}
@@ -2,6 +2,7 @@ package agents
 
 import (
 	"strings"
+	"sync/atomic"
 
 	"agents-hub/internal/types"
 )
@@ -9,7 +10,7 @@ import (
 // ClaudeAgent wraps Claude CLI with enhanced configuration
 type ClaudeAgent struct {
 	*CLIAgent
-	defaultConfig types.ClaudeConfig
+	defaultConfig atomic.Pointer[types.ClaudeConfig]
 }
 
 // NewClaudeAgent creates a new Claude agent with skills and capabilities
@@ -32,17 +33,21 @@ func NewClaudeAgent(baseURL string) *ClaudeAgent {
 		HealthArgs: []string{"--version"},
 		Args:       []string{"-p", "{prompt}", "--output-format", "text"}, // Base args (used when no config)
 		Card:       card,
+		DataParts:  resolveDataParts("CLAUDE_DATA_PARTS"),
+		// Claude Code has no flag for this; it reads the limit from the environment
+		MaxTokensEnv: "CLAUDE_CODE_MAX_OUTPUT_TOKENS",
+		AuthPatterns: resolveAuthPatterns(claudeAuthPatterns(), "CLAUDE_AUTH_PATTERN"),
+		LoginHint:    "run `claude` and use /login",
 	})
 
-	return &ClaudeAgent{
-		CLIAgent:      cliAgent,
-		defaultConfig: types.ClaudeConfig{},
-	}
+	agent := &ClaudeAgent{CLIAgent: cliAgent}
+	agent.defaultConfig.Store(&types.ClaudeConfig{})
+	return agent
 }
 
 // SetDefaultConfig sets the default configuration for this agent
 func (a *ClaudeAgent) SetDefaultConfig(config types.ClaudeConfig) {
-	a.defaultConfig = config
+	a.defaultConfig.Store(&config)
 }
 
 // Execute runs Claude with dynamic arguments based on config
@@ -56,13 +61,18 @@ func (a *ClaudeAgent) Execute(ctx types.ExecutionContext) (types.ExecutionResult
 func (a *ClaudeAgent) ExecuteStreaming(ctx types.ExecutionContext, output chan<- types.StreamEvent, input <-chan string) error {
 	config := a.extractClaudeConfig(ctx)
 	args := a.buildArgs(config)
-	return a.CLIAgent.ExecuteStreamingWithArgs(ctx, args, output, input)
+	jsonEvents := a.JSONStream()
+	if jsonEvents {
+		// stream-json needs --verbose in print mode
+		args = append(args[:len(args)-1], "stream-json", "--verbose")
+	}
+	return a.CLIAgent.executeStreaming(ctx, args, jsonEvents, output, input)
 }
 
 // extractClaudeConfig gets ClaudeConfig from execution context metadata or defaults
 func (a *ClaudeAgent) extractClaudeConfig(ctx types.ExecutionContext) types.ClaudeConfig {
 	// Start with default config
-	config := a.defaultConfig
+	config := *a.defaultConfig.Load()
 
 	// Check if config is passed in message metadata
 	if ctx.UserMessage.Metadata != nil {
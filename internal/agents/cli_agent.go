@@ -4,11 +4,19 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"agents-hub/internal/types"
 
@@ -24,26 +32,31 @@ type CLIConfig struct {
 	HealthArgs     []string
 	Card           types.AgentCard
 	PromptPatterns []string
+	DataParts      string   // DataPartsInline (default), DataPartsFile or DataPartsIgnore
+	MaxTokensEnv   string   // env var the CLI reads its output token limit from
+	MaxTokensFlag  bool     // the wrapping agent passes the output token limit as a flag
+	AuthPatterns   []string // output that means the CLI is waiting for a login (non-PTY runs only)
+	LoginHint      string   // how to log in, shown when an auth pattern matches
 }
 
 type CLIAgent struct {
-	config         CLIConfig
-	promptPatterns []*regexp.Regexp
+	config          CLIConfig
+	promptPatterns  []*regexp.Regexp
+	authPatterns    []*regexp.Regexp
+	maxOutputTokens atomic.Int64
+	healthProbe     atomic.Bool
+	jsonStream      atomic.Bool                    // set by agents whose CLI has a JSON event protocol
+	env             atomic.Pointer[[]string]       // extra "KEY=VALUE" entries
+	running         sync.Map                       // task ID -> *context.CancelFunc of its process
+	launchLog       atomic.Pointer[func([]string)] // see SetLaunchLogger
 }
 
 func NewCLIAgent(cfg CLIConfig) *CLIAgent {
-	compiled := make([]*regexp.Regexp, 0, len(cfg.PromptPatterns))
-	for _, pattern := range cfg.PromptPatterns {
-		if pattern == "" {
-			continue
-		}
-		re, err := regexp.Compile(pattern)
-		if err != nil {
-			continue
-		}
-		compiled = append(compiled, re)
+	return &CLIAgent{
+		config:         cfg,
+		promptPatterns: compilePatterns(cfg.PromptPatterns),
+		authPatterns:   compilePatterns(cfg.AuthPatterns),
 	}
-	return &CLIAgent{config: cfg, promptPatterns: compiled}
 }
 
 func (a *CLIAgent) ID() string   { return a.config.AgentID }
@@ -55,11 +68,15 @@ func (a *CLIAgent) Shutdown() error   { return nil }
 func (a *CLIAgent) GetCard() (types.AgentCard, error) { return a.config.Card, nil }
 
 func (a *CLIAgent) GetCapabilities() types.RuntimeCapabilities {
+	inputModes := []string{"text/plain"}
+	if a.config.DataParts != DataPartsIgnore {
+		inputModes = append(inputModes, "application/json")
+	}
 	return types.RuntimeCapabilities{
 		SupportsStreaming:    true,
-		SupportsCancellation: false,
+		SupportsCancellation: true,
 		MaxConcurrentTasks:   1,
-		SupportedInputModes:  []string{"text/plain"},
+		SupportedInputModes:  inputModes,
 		SupportedOutputModes: []string{"text/plain"},
 	}
 }
@@ -67,9 +84,16 @@ func (a *CLIAgent) GetCapabilities() types.RuntimeCapabilities {
 func (a *CLIAgent) CheckHealth() (types.AgentHealth, error) {
 	start := time.Now()
 	cmd := exec.Command(a.config.Exec, a.config.HealthArgs...)
+	a.applyEnv(cmd)
 	if err := cmd.Run(); err != nil {
 		return types.AgentHealth{Status: "unhealthy", LastCheck: time.Now().UTC()}, err
 	}
+	if a.HealthProbe() {
+		// The binary runs, but it only counts as healthy if a real prompt gets an answer
+		if err := a.probe(); err != nil {
+			return types.AgentHealth{Status: "degraded", LastCheck: time.Now().UTC(), LatencyMs: time.Since(start).Milliseconds(), ErrorMessage: "health probe failed: " + err.Error()}, nil
+		}
+	}
 	return types.AgentHealth{Status: "healthy", LastCheck: time.Now().UTC(), LatencyMs: time.Since(start).Milliseconds()}, nil
 }
 
@@ -77,7 +101,11 @@ func (a *CLIAgent) CheckHealth() (types.AgentHealth, error) {
 const DefaultAgentTimeout = 10 * time.Minute
 
 func (a *CLIAgent) Execute(ctx types.ExecutionContext) (types.ExecutionResult, error) {
-	prompt := extractPromptWithHistory(ctx.UserMessage, ctx.PreviousHistory)
+	prompt, cleanup, err := a.buildPrompt(ctx)
+	if err != nil {
+		return types.ExecutionResult{}, err
+	}
+	defer cleanup()
 	if prompt == "" {
 		return types.ExecutionResult{}, errors.New("empty prompt")
 	}
@@ -97,22 +125,32 @@ func (a *CLIAgent) Execute(ctx types.ExecutionContext) (types.ExecutionResult, e
 	}
 	execCtx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
+	defer a.track(ctx.TaskID, cancel)()
+	a.logLaunch(a.config.Args)
 	command := exec.CommandContext(execCtx, a.config.Exec, args...)
 	applyExecutionContext(command, ctx)
+	a.applyEnv(command)
+	a.applyOutputLimit(command)
+	applyCancel(command)
 	stdin, _ := command.StdinPipe()
 	stdin.Close()
 
-	var out bytes.Buffer
+	out := &cappedBuffer{limit: a.outputByteCap(), onLimit: cancel}
 	var stderr bytes.Buffer
-	command.Stdout = &out
-	command.Stderr = &stderr
-	if err := command.Run(); err != nil {
+	auth := a.newAuthWatcher(cancel)
+	command.Stdout = io.MultiWriter(out, auth.Stdout())
+	command.Stderr = io.MultiWriter(&stderr, auth.Stderr())
+	err = command.Run()
+	if auth.matched.Load() {
+		return types.ExecutionResult{}, a.loginError()
+	}
+	if err != nil && !out.truncated.Load() {
 		if stderr.Len() > 0 {
 			return types.ExecutionResult{}, errors.New(strings.TrimSpace(stderr.String()))
 		}
 		return types.ExecutionResult{}, err
 	}
-	text := strings.TrimSpace(out.String())
+	text := out.Text()
 
 	response := types.Message{
 		Kind:      "message",
@@ -137,100 +175,46 @@ func (a *CLIAgent) Execute(ctx types.ExecutionContext) (types.ExecutionResult, e
 	return types.ExecutionResult{Task: task, Artifacts: nil, FinalState: types.TaskStateCompleted}, nil
 }
 
+// Cancel stops the process running taskID. It reports false when no such run is in flight.
 func (a *CLIAgent) Cancel(taskID string) (bool, error) {
-	return false, nil
-}
-
-// ExecuteStreaming runs the agent with real-time output streaming and interactive input
-func (a *CLIAgent) ExecuteStreaming(ctx types.ExecutionContext, output chan<- types.StreamEvent, input <-chan string) error {
-	prompt := extractPromptWithHistory(ctx.UserMessage, ctx.PreviousHistory)
-	if prompt == "" {
-		output <- types.StreamEvent{Kind: "error", Text: "empty prompt", AgentID: a.ID(), TaskID: ctx.TaskID, Timestamp: time.Now().UTC()}
-		return errors.New("empty prompt")
+	cancel, ok := a.running.Load(taskID)
+	if !ok {
+		return false, nil
 	}
+	(*cancel.(*context.CancelFunc))()
+	return true, nil
+}
 
-	args := make([]string, 0, len(a.config.Args)+1)
-	for _, arg := range a.config.Args {
-		if arg == "{prompt}" {
-			args = append(args, prompt)
-			continue
+// cancelGrace is how long a canceled or timed-out CLI gets to exit after an interrupt
+// before it is killed
+const cancelGrace = 5 * time.Second
+
+// applyCancel makes a canceled run interrupt the CLI first, so it can stop its own
+// tools, and kill it if it hasn't exited after cancelGrace. WaitDelay also keeps
+// children that outlive the CLI from holding its output open.
+func applyCancel(command *exec.Cmd) {
+	command.Cancel = func() error {
+		if err := command.Process.Signal(os.Interrupt); err != nil {
+			return command.Process.Kill()
 		}
-		args = append(args, arg)
-	}
-
-	// Always use a timeout - default to 10 minutes if none specified
-	timeout := ctx.Timeout
-	if timeout <= 0 {
-		timeout = DefaultAgentTimeout
-	}
-	execCtx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	command := exec.CommandContext(execCtx, a.config.Exec, args...)
-	applyExecutionContext(command, ctx)
-
-	// Start with PTY for interactive mode
-	ptmx, err := pty.Start(command)
-	if err != nil {
-		output <- types.StreamEvent{Kind: "error", Text: err.Error(), AgentID: a.ID(), TaskID: ctx.TaskID, Timestamp: time.Now().UTC()}
-		return err
+		return nil
 	}
-	defer ptmx.Close()
-
-	// Channel to signal completion
-	done := make(chan struct{})
-
-	// Goroutine: Read output and send to channel
-	go func() {
-		defer close(done)
-		scanner := bufio.NewScanner(ptmx)
-		scanner.Split(scanLinesAnyCRLF)
-		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
-		for scanner.Scan() {
-			line := scanner.Text()
-			kind := "output"
-			if a.isPrompt(line) {
-				kind = "prompt"
-			}
-			output <- types.StreamEvent{
-				Kind:      kind,
-				Text:      line,
-				AgentID:   a.ID(),
-				TaskID:    ctx.TaskID,
-				Timestamp: time.Now().UTC(),
-			}
-		}
-		if err := scanner.Err(); err != nil {
-			output <- types.StreamEvent{Kind: "error", Text: err.Error(), AgentID: a.ID(), TaskID: ctx.TaskID, Timestamp: time.Now().UTC()}
-		}
-	}()
-
-	// Goroutine: Forward user input to PTY
-	go func() {
-		for {
-			select {
-			case text, ok := <-input:
-				if !ok {
-					return
-				}
-				_, _ = ptmx.Write([]byte(text + "\n"))
-			case <-done:
-				return
-			}
-		}
-	}()
+	command.WaitDelay = cancelGrace
+}
 
-	// Wait for completion
-	if err := command.Wait(); err != nil {
-		output <- types.StreamEvent{Kind: "error", Text: err.Error(), AgentID: a.ID(), TaskID: ctx.TaskID, Timestamp: time.Now().UTC()}
-		return err
+// track makes a run cancelable by task ID until the returned func is called
+func (a *CLIAgent) track(taskID string, cancel context.CancelFunc) func() {
+	if taskID == "" {
+		return func() {}
 	}
+	handle := &cancel
+	a.running.Store(taskID, handle)
+	return func() { a.running.CompareAndDelete(taskID, handle) }
+}
 
-	// Wait for output reading to finish
-	<-done
-
-	output <- types.StreamEvent{Kind: "complete", AgentID: a.ID(), TaskID: ctx.TaskID, Timestamp: time.Now().UTC()}
-	return nil
+// ExecuteStreaming runs the agent with real-time output streaming and interactive input
+func (a *CLIAgent) ExecuteStreaming(ctx types.ExecutionContext, output chan<- types.StreamEvent, input <-chan string) error {
+	return a.executeStreaming(ctx, a.config.Args, false, output, input)
 }
 
 func (a *CLIAgent) ExecPath() string {
@@ -239,7 +223,11 @@ func (a *CLIAgent) ExecPath() string {
 
 // ExecuteWithArgs runs the agent with custom arguments (for agent extensions)
 func (a *CLIAgent) ExecuteWithArgs(ctx types.ExecutionContext, customArgs []string) (types.ExecutionResult, error) {
-	prompt := extractPromptWithHistory(ctx.UserMessage, ctx.PreviousHistory)
+	prompt, cleanup, err := a.buildPrompt(ctx)
+	if err != nil {
+		return types.ExecutionResult{}, err
+	}
+	defer cleanup()
 	if prompt == "" {
 		return types.ExecutionResult{}, errors.New("empty prompt")
 	}
@@ -259,22 +247,32 @@ func (a *CLIAgent) ExecuteWithArgs(ctx types.ExecutionContext, customArgs []stri
 	}
 	execCtx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
+	defer a.track(ctx.TaskID, cancel)()
+	a.logLaunch(customArgs)
 	command := exec.CommandContext(execCtx, a.config.Exec, args...)
 	applyExecutionContext(command, ctx)
+	a.applyEnv(command)
+	a.applyOutputLimit(command)
+	applyCancel(command)
 	stdin, _ := command.StdinPipe()
 	stdin.Close()
 
-	var out bytes.Buffer
+	out := &cappedBuffer{limit: a.outputByteCap(), onLimit: cancel}
 	var stderr bytes.Buffer
-	command.Stdout = &out
-	command.Stderr = &stderr
-	if err := command.Run(); err != nil {
+	auth := a.newAuthWatcher(cancel)
+	command.Stdout = io.MultiWriter(out, auth.Stdout())
+	command.Stderr = io.MultiWriter(&stderr, auth.Stderr())
+	err = command.Run()
+	if auth.matched.Load() {
+		return types.ExecutionResult{}, a.loginError()
+	}
+	if err != nil && !out.truncated.Load() {
 		if stderr.Len() > 0 {
 			return types.ExecutionResult{}, errors.New(strings.TrimSpace(stderr.String()))
 		}
 		return types.ExecutionResult{}, err
 	}
-	text := strings.TrimSpace(out.String())
+	text := out.Text()
 
 	response := types.Message{
 		Kind:      "message",
@@ -301,7 +299,19 @@ func (a *CLIAgent) ExecuteWithArgs(ctx types.ExecutionContext, customArgs []stri
 
 // ExecuteStreamingWithArgs runs the agent with custom arguments and real-time streaming
 func (a *CLIAgent) ExecuteStreamingWithArgs(ctx types.ExecutionContext, customArgs []string, output chan<- types.StreamEvent, input <-chan string) error {
-	prompt := extractPromptWithHistory(ctx.UserMessage, ctx.PreviousHistory)
+	return a.executeStreaming(ctx, customArgs, false, output, input)
+}
+
+// executeStreaming runs the CLI on a PTY and streams its lines. With jsonEvents the
+// lines are parsed as a JSON event protocol (see parseJSONStreamLine); lines that
+// don't parse are streamed as plain output.
+func (a *CLIAgent) executeStreaming(ctx types.ExecutionContext, customArgs []string, jsonEvents bool, output chan<- types.StreamEvent, input <-chan string) error {
+	prompt, cleanup, err := a.buildPrompt(ctx)
+	if err != nil {
+		output <- types.StreamEvent{Kind: "error", Text: err.Error(), AgentID: a.ID(), TaskID: ctx.TaskID, Timestamp: time.Now().UTC()}
+		return err
+	}
+	defer cleanup()
 	if prompt == "" {
 		output <- types.StreamEvent{Kind: "error", Text: "empty prompt", AgentID: a.ID(), TaskID: ctx.TaskID, Timestamp: time.Now().UTC()}
 		return errors.New("empty prompt")
@@ -323,9 +333,14 @@ func (a *CLIAgent) ExecuteStreamingWithArgs(ctx types.ExecutionContext, customAr
 	}
 	execCtx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
+	defer a.track(ctx.TaskID, cancel)()
 
+	a.logLaunch(customArgs)
 	command := exec.CommandContext(execCtx, a.config.Exec, args...)
 	applyExecutionContext(command, ctx)
+	a.applyEnv(command)
+	a.applyOutputLimit(command)
+	applyCancel(command)
 
 	// Start with PTY for interactive mode
 	ptmx, err := pty.Start(command)
@@ -339,13 +354,40 @@ func (a *CLIAgent) ExecuteStreamingWithArgs(ctx types.ExecutionContext, customAr
 	done := make(chan struct{})
 
 	// Goroutine: Read output and send to channel
+	byteCap := a.outputByteCap()
+	var truncated atomic.Bool
 	go func() {
 		defer close(done)
-		scanner := bufio.NewScanner(ptmx)
-		scanner.Split(scanLinesAnyCRLF)
-		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		scanner := bufio.NewScanner(ptyReader{ptmx})
+		if jsonEvents {
+			// A JSON event only parses whole, so it isn't chunked like plain output
+			scanner.Split(scanJSONLines(maxJSONLineBytes))
+			scanner.Buffer(make([]byte, 0, 64*1024), maxJSONLineBytes+1)
+		} else {
+			scanner.Split(scanLinesAnyCRLF)
+			scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		}
+		written := 0
 		for scanner.Scan() {
-			line := scanner.Text()
+			line := streamLineText(scanner.Bytes())
+			if byteCap > 0 {
+				written += len(line) + 1
+				if written > byteCap {
+					truncated.Store(true)
+					output <- types.StreamEvent{Kind: "output", Text: truncationNotice(byteCap), AgentID: a.ID(), TaskID: ctx.TaskID, Timestamp: time.Now().UTC()}
+					cancel()
+					return
+				}
+			}
+			if jsonEvents {
+				if events, ok := parseJSONStreamLine(line); ok {
+					for _, event := range events {
+						event.AgentID, event.TaskID, event.Timestamp = a.ID(), ctx.TaskID, time.Now().UTC()
+						output <- event
+					}
+					continue
+				}
+			}
 			kind := "output"
 			if a.isPrompt(line) {
 				kind = "prompt"
@@ -358,7 +400,10 @@ func (a *CLIAgent) ExecuteStreamingWithArgs(ctx types.ExecutionContext, customAr
 				Timestamp: time.Now().UTC(),
 			}
 		}
-		if err := scanner.Err(); err != nil {
+		if err := scanner.Err(); errors.Is(err, bufio.ErrTooLong) {
+			// Should not happen with chunked lines, but never fail the stream over it
+			output <- types.StreamEvent{Kind: "output", Text: "[output line too long, truncated]", AgentID: a.ID(), TaskID: ctx.TaskID, Timestamp: time.Now().UTC()}
+		} else if err != nil {
 			output <- types.StreamEvent{Kind: "error", Text: err.Error(), AgentID: a.ID(), TaskID: ctx.TaskID, Timestamp: time.Now().UTC()}
 		}
 	}()
@@ -379,7 +424,7 @@ func (a *CLIAgent) ExecuteStreamingWithArgs(ctx types.ExecutionContext, customAr
 	}()
 
 	// Wait for completion
-	if err := command.Wait(); err != nil {
+	if err := command.Wait(); err != nil && !truncated.Load() {
 		output <- types.StreamEvent{Kind: "error", Text: err.Error(), AgentID: a.ID(), TaskID: ctx.TaskID, Timestamp: time.Now().UTC()}
 		return err
 	}
@@ -476,6 +521,20 @@ func (a *CLIAgent) isPrompt(line string) bool {
 	return false
 }
 
+// ptyReader reports the EIO Linux returns once the child side of a PTY is closed
+// as a plain EOF, so a finished command doesn't end its stream with a read error
+type ptyReader struct {
+	r io.Reader
+}
+
+func (p ptyReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if errors.Is(err, syscall.EIO) {
+		err = io.EOF
+	}
+	return n, err
+}
+
 func scanLinesAnyCRLF(data []byte, atEOF bool) (advance int, token []byte, err error) {
 	if atEOF && len(data) == 0 {
 		return 0, nil, nil
@@ -494,9 +553,87 @@ func scanLinesAnyCRLF(data []byte, atEOF bool) (advance int, token []byte, err e
 	if atEOF {
 		return len(data), dropTrailingCR(data), nil
 	}
+	// A line longer than the chunk size (a binary blob, minified output) is emitted in
+	// pieces instead of growing the scanner buffer until it fails with ErrTooLong
+	if len(data) > maxStreamLineBytes {
+		n := maxStreamLineBytes
+		for n > maxStreamLineBytes-utf8.UTFMax && !utf8.RuneStart(data[n]) {
+			n--
+		}
+		return n, data[:n], nil
+	}
 	return 0, nil, nil
 }
 
+// maxStreamLineBytes is the longest line emitted as a single stream event
+const maxStreamLineBytes = 64 * 1024
+
+// maxJSONLineBytes is the longest line scanned whole in JSON stream mode
+const maxJSONLineBytes = 8 << 20
+
+// scanJSONLines splits lines like scanLinesAnyCRLF but never chunks one. A line longer
+// than max is dropped and a notice takes its place.
+func scanJSONLines(max int) bufio.SplitFunc {
+	dropping := false
+	notice := fmt.Sprintf("[output line over %d bytes dropped]", max)
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			if dropping {
+				dropping = false
+				return 0, []byte(notice), nil
+			}
+			return 0, nil, nil
+		}
+		end := bytes.IndexAny(data, "\r\n")
+		if end == len(data)-1 && data[end] == '\r' && !atEOF && len(data) <= max {
+			// A \n may follow in the next read; don't end the line twice
+			return 0, nil, nil
+		}
+		if end >= 0 || atEOF {
+			advance, token, err := scanLinesAnyCRLF(data, atEOF)
+			if dropping {
+				dropping = false
+				token = []byte(notice)
+			}
+			return advance, token, err
+		}
+		if len(data) > max {
+			dropping = true
+			return len(data), nil, nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// streamLineText converts a line of CLI output to text. Clearly binary data is
+// base64-encoded so it survives the trip to clients; stray invalid bytes in otherwise
+// textual output are dropped rather than shown as replacement characters.
+func streamLineText(line []byte) string {
+	if looksBinary(line) {
+		return fmt.Sprintf("[binary output, %d bytes, base64] %s", len(line), base64.StdEncoding.EncodeToString(line))
+	}
+	return strings.ToValidUTF8(string(line), "")
+}
+
+// looksBinary reports whether line contains NUL bytes or is mostly invalid UTF-8
+func looksBinary(line []byte) bool {
+	if bytes.IndexByte(line, 0) >= 0 {
+		return true
+	}
+	if utf8.Valid(line) {
+		return false
+	}
+	invalid := 0
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRune(line[i:])
+		if r == utf8.RuneError && size == 1 {
+			invalid++
+		}
+		i += size
+	}
+	return invalid*2 > len(line)
+}
+
 func dropTrailingCR(data []byte) []byte {
 	if len(data) > 0 && data[len(data)-1] == '\r' {
 		return data[:len(data)-1]
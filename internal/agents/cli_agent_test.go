@@ -0,0 +1,106 @@
+package agents
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf8"
+)
+
+func TestScanJSONLines(t *testing.T) {
+	const max = 1024
+	long := `{"type":"item","text":"` + strings.Repeat("x", 200*1024) + `"}`
+	tests := []struct {
+		name  string
+		input string
+		max   int
+		want  []string
+		slow  bool // read one byte at a time
+	}{
+		{"lines", "{\"a\":1}\n{\"b\":2}\r\n{\"c\":3}", max, []string{`{"a":1}`, `{"b":2}`, `{"c":3}`}, true},
+		{"line over the plain chunk size stays whole", long + "\n{}\n", maxJSONLineBytes, []string{long, "{}"}, false},
+		{
+			"line over the limit is dropped",
+			"{}\n" + strings.Repeat("y", 5*max) + "\r\n{\"after\":true}\n",
+			max,
+			[]string{"{}", "[output line over 1024 bytes dropped]", `{"after":true}`},
+			true,
+		},
+		{"unterminated line over the limit", "{}\n" + strings.Repeat("z", 3*max), max, []string{"{}", "[output line over 1024 bytes dropped]"}, false},
+		{"dropped bytes end at EOF", "{}\n" + strings.Repeat("z", 2*(max+1)), max, []string{"{}", "[output line over 1024 bytes dropped]"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reader io.Reader = strings.NewReader(tt.input)
+			if tt.slow {
+				reader = iotest.OneByteReader(reader)
+			}
+			scanner := bufio.NewScanner(reader)
+			scanner.Split(scanJSONLines(tt.max))
+			scanner.Buffer(make([]byte, 0, 64), tt.max+1)
+			var got []string
+			for scanner.Scan() {
+				got = append(got, scanner.Text())
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %d lines %.80q, want %d lines %.80q", len(got), got, len(tt.want), tt.want)
+			}
+		})
+	}
+}
+
+func TestScanLinesAnyCRLFChunksLongLines(t *testing.T) {
+	// A 3-byte rune straddles the chunk boundary, so the cut must move back to it
+	long := strings.Repeat("a", maxStreamLineBytes-1) + "€" + strings.Repeat("b", maxStreamLineBytes)
+	scanner := bufio.NewScanner(strings.NewReader("short\r\n" + long + "\rlast"))
+	scanner.Split(scanLinesAnyCRLF)
+	scanner.Buffer(make([]byte, 0, 4096), 4*maxStreamLineBytes)
+	var got []string
+	for scanner.Scan() {
+		got = append(got, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) < 4 || got[0] != "short" || got[len(got)-1] != "last" {
+		t.Fatalf("got %d lines, want short, the chunks and last", len(got))
+	}
+	chunks := got[1 : len(got)-1]
+	for _, chunk := range chunks {
+		if len(chunk) > maxStreamLineBytes || !utf8.ValidString(chunk) {
+			t.Fatalf("chunk of %d bytes (valid UTF-8: %v), want at most %d valid bytes", len(chunk), utf8.ValidString(chunk), maxStreamLineBytes)
+		}
+	}
+	if strings.Join(chunks, "") != long {
+		t.Fatal("chunks don't add up to the original line")
+	}
+}
+
+func TestStreamLineText(t *testing.T) {
+	binary := []byte{0x89, 'P', 'N', 'G', 0, 0xff, 0xfe}
+	tests := []struct {
+		name string
+		line []byte
+		want string
+	}{
+		{"text", []byte("hello"), "hello"},
+		{"utf-8", []byte("héllo €"), "héllo €"},
+		{"stray invalid byte is dropped", []byte("caf\xe9 au lait"), "caf au lait"},
+		{"NUL is binary", binary, "[binary output, 7 bytes, base64] " + base64.StdEncoding.EncodeToString(binary)},
+		{"mostly invalid is binary", []byte{0xff, 0xfe, 0xfd, 'a'}, "[binary output, 4 bytes, base64] //79YQ=="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := streamLineText(tt.line); got != tt.want {
+				t.Fatalf("streamLineText = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
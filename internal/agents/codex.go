@@ -2,7 +2,9 @@ package agents
 
 import (
 	"fmt"
+	"os"
 	"strings"
+	"sync/atomic"
 
 	"agents-hub/internal/types"
 )
@@ -10,7 +12,7 @@ import (
 // CodexAgent wraps Codex CLI with flexible configuration.
 type CodexAgent struct {
 	*CLIAgent
-	defaultConfig types.CodexConfig
+	defaultConfig atomic.Pointer[types.CodexConfig]
 }
 
 func NewCodexAgent(baseURL string) *CodexAgent {
@@ -32,35 +34,90 @@ func NewCodexAgent(baseURL string) *CodexAgent {
 		HealthArgs:     []string{"--version"},
 		Args:           []string{"exec", "{prompt}"},
 		Card:           card,
+		DataParts:      resolveDataParts("CODEX_DATA_PARTS"),
+		MaxTokensFlag:  true,
 		PromptPatterns: codexPromptPatterns(),
+		AuthPatterns:   resolveAuthPatterns(codexAuthPatterns(), "CODEX_AUTH_PATTERN"),
+		LoginHint:      "run `codex login`",
 	})
 
-	return &CodexAgent{
-		CLIAgent:      cliAgent,
-		defaultConfig: types.CodexConfig{},
-	}
+	agent := &CodexAgent{CLIAgent: cliAgent}
+	agent.defaultConfig.Store(&types.CodexConfig{})
+	return agent
 }
 
 func (a *CodexAgent) SetDefaultConfig(config types.CodexConfig) {
-	a.defaultConfig = config
+	a.defaultConfig.Store(&config)
 }
 
 func (a *CodexAgent) Execute(ctx types.ExecutionContext) (types.ExecutionResult, error) {
 	config := a.extractCodexConfig(ctx)
 	args := a.buildArgs(ctx, config)
 	ctx = a.withCodexPrompt(ctx, config)
-	return a.CLIAgent.ExecuteWithArgs(ctx, args)
+	if !config.LastMessageOnly {
+		return a.CLIAgent.ExecuteWithArgs(ctx, args)
+	}
+	return a.executeLastMessage(ctx, args)
+}
+
+// executeLastMessage asks Codex to write its final message to a file and uses that as
+// the result, so progress and tool chatter on stdout don't end up in the task. Codex
+// versions without --output-last-message fail the run; an empty file falls back to
+// the trimmed stdout.
+func (a *CodexAgent) executeLastMessage(ctx types.ExecutionContext, args []string) (types.ExecutionResult, error) {
+	file, err := os.CreateTemp("", "agents-hub-codex-*.txt")
+	if err != nil {
+		return types.ExecutionResult{}, err
+	}
+	path := file.Name()
+	file.Close()
+	defer os.Remove(path)
+
+	withOutput := make([]string, 0, len(args)+2)
+	for _, arg := range args {
+		if arg == "{prompt}" {
+			withOutput = append(withOutput, "--output-last-message", path)
+		}
+		withOutput = append(withOutput, arg)
+	}
+	result, err := a.CLIAgent.ExecuteWithArgs(ctx, withOutput)
+	if err != nil {
+		return result, err
+	}
+	data, err := os.ReadFile(path)
+	if text := strings.TrimSpace(string(data)); err == nil && text != "" && result.Task.Status.Message != nil {
+		result.Task.Status.Message.Parts = []types.Part{{Kind: "text", Text: text}}
+	}
+	return result, nil
 }
 
 func (a *CodexAgent) ExecuteStreaming(ctx types.ExecutionContext, output chan<- types.StreamEvent, input <-chan string) error {
 	config := a.extractCodexConfig(ctx)
 	args := a.buildArgs(ctx, config)
 	ctx = a.withCodexPrompt(ctx, config)
-	return a.CLIAgent.ExecuteStreamingWithArgs(ctx, args, output, input)
+	jsonEvents := a.JSONStream()
+	if jsonEvents {
+		// buildArgs ends with "exec", "{prompt}"
+		args = append(args[:len(args)-1], "--json", "{prompt}")
+	}
+	return a.CLIAgent.executeStreaming(ctx, args, jsonEvents, output, input)
+}
+
+// RequestedDirs returns the --cd directory and --add-dir entries a message's
+// codexConfig, or the default config, gives Codex
+func (a *CodexAgent) RequestedDirs(msg types.Message) (string, []string) {
+	config := a.extractCodexConfig(types.ExecutionContext{UserMessage: msg})
+	var extra []string
+	for _, dir := range config.AddDirs {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			extra = append(extra, dir)
+		}
+	}
+	return strings.TrimSpace(config.WorkingDir), extra
 }
 
 func (a *CodexAgent) extractCodexConfig(ctx types.ExecutionContext) types.CodexConfig {
-	config := a.defaultConfig
+	config := *a.defaultConfig.Load()
 	config.AddDirs = append([]string{}, config.AddDirs...)
 	config.ConfigOverrides = append([]string{}, config.ConfigOverrides...)
 	config.EnableFeatures = append([]string{}, config.EnableFeatures...)
@@ -110,6 +167,9 @@ func (a *CodexAgent) extractCodexConfig(ctx types.ExecutionContext) types.CodexC
 	if includeHistory, ok := cfgMap["includeHistory"].(bool); ok {
 		config.IncludeHistory = includeHistory
 	}
+	if lastMessage, ok := cfgMap["lastMessageOnly"].(bool); ok {
+		config.LastMessageOnly = lastMessage
+	}
 	if addDirs, ok := cfgMap["addDirs"].([]string); ok {
 		config.AddDirs = append([]string{}, addDirs...)
 	} else if addDirs, ok := cfgMap["addDirs"].([]any); ok {
@@ -133,6 +193,8 @@ func (a *CodexAgent) extractCodexConfig(ctx types.ExecutionContext) types.CodexC
 	return config
 }
 
+// buildArgs turns config into Codex CLI flags. BypassApprovals wins over FullAuto, which
+// wins over SandboxMode/ApprovalPolicy; see CodexConfig.Conflicts.
 func (a *CodexAgent) buildArgs(ctx types.ExecutionContext, config types.CodexConfig) []string {
 	args := []string{}
 
@@ -178,6 +240,9 @@ func (a *CodexAgent) buildArgs(ctx types.ExecutionContext, config types.CodexCon
 		}
 		args = append(args, "--config", override)
 	}
+	if limit := a.MaxOutputTokens(); limit > 0 {
+		args = append(args, "--config", fmt.Sprintf("model_max_output_tokens=%d", limit))
+	}
 	for _, feature := range config.EnableFeatures {
 		if strings.TrimSpace(feature) == "" {
 			continue
@@ -201,7 +266,7 @@ func (a *CodexAgent) withCodexPrompt(ctx types.ExecutionContext, config types.Co
 		Kind:      "message",
 		MessageID: ctx.UserMessage.MessageID,
 		Role:      ctx.UserMessage.Role,
-		Parts:     []types.Part{{Kind: "text", Text: prompt}},
+		Parts:     append([]types.Part{{Kind: "text", Text: prompt}}, dataParts(ctx.UserMessage)...),
 		TaskID:    ctx.TaskID,
 		ContextID: ctx.ContextID,
 		Metadata:  ctx.UserMessage.Metadata,
@@ -214,7 +279,7 @@ func (a *CodexAgent) withCodexPrompt(ctx types.ExecutionContext, config types.Co
 
 func (a *CodexAgent) buildPrompt(ctx types.ExecutionContext, config types.CodexConfig) string {
 	userPrompt := strings.TrimSpace(extractPrompt(ctx.UserMessage))
-	if userPrompt == "" {
+	if userPrompt == "" && len(dataParts(ctx.UserMessage)) == 0 {
 		return ""
 	}
 	sections := make([]string, 0, 3)
@@ -224,7 +289,9 @@ func (a *CodexAgent) buildPrompt(ctx types.ExecutionContext, config types.CodexC
 	if config.IncludeHistory && len(ctx.PreviousHistory) > 0 {
 		sections = append(sections, formatHistory(ctx.PreviousHistory))
 	}
-	sections = append(sections, userPrompt)
+	if userPrompt != "" {
+		sections = append(sections, userPrompt)
+	}
 	return strings.Join(sections, "\n\n")
 }
 
@@ -0,0 +1,45 @@
+package agents
+
+import (
+	"reflect"
+	"testing"
+
+	"agents-hub/internal/types"
+)
+
+func TestCodexBuildArgsPermissions(t *testing.T) {
+	tests := []struct {
+		name   string
+		config types.CodexConfig
+		want   []string
+	}{
+		{"no options", types.CodexConfig{}, nil},
+		{"defaults emit nothing", types.CodexConfig{SandboxMode: types.CodexSandboxDefault, ApprovalPolicy: types.CodexApprovalDefault}, nil},
+		{
+			"explicit sandbox and approval",
+			types.CodexConfig{SandboxMode: types.CodexSandboxReadOnly, ApprovalPolicy: types.CodexApprovalNever},
+			[]string{"--sandbox", "read-only", "--ask-for-approval", "never"},
+		},
+		{"full auto", types.CodexConfig{FullAuto: true}, []string{"--full-auto"}},
+		{
+			"full auto wins over sandbox and approval",
+			types.CodexConfig{FullAuto: true, SandboxMode: types.CodexSandboxReadOnly, ApprovalPolicy: types.CodexApprovalNever},
+			[]string{"--full-auto"},
+		},
+		{"bypass", types.CodexConfig{BypassApprovals: true}, []string{"--dangerously-bypass-approvals-and-sandbox"}},
+		{
+			"bypass wins over everything",
+			types.CodexConfig{BypassApprovals: true, FullAuto: true, SandboxMode: types.CodexSandboxWorkspaceWrite, ApprovalPolicy: types.CodexApprovalOnRequest},
+			[]string{"--dangerously-bypass-approvals-and-sandbox"},
+		},
+	}
+	agent := NewCodexAgent("")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := append(tt.want, "exec", "{prompt}")
+			if got := agent.buildArgs(types.ExecutionContext{}, tt.config); !reflect.DeepEqual(got, want) {
+				t.Fatalf("buildArgs = %q, want %q", got, want)
+			}
+		})
+	}
+}
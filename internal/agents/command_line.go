@@ -0,0 +1,38 @@
+package agents
+
+import "agents-hub/internal/types"
+
+// SetLaunchLogger has every run report its command line before the process starts,
+// with {prompt} standing for the prompt; nil turns it off
+func (a *CLIAgent) SetLaunchLogger(fn func(argv []string)) {
+	a.launchLog.Store(&fn)
+}
+
+func (a *CLIAgent) logLaunch(args []string) {
+	if fn := a.launchLog.Load(); fn != nil && *fn != nil {
+		(*fn)(append([]string{a.config.Exec}, args...))
+	}
+}
+
+// CommandLine is the command a send runs with the current defaults, with {prompt}
+// standing for the prompt
+func (a *CLIAgent) CommandLine() []string {
+	return append([]string{a.config.Exec}, a.config.Args...)
+}
+
+func (a *ClaudeAgent) CommandLine() []string {
+	return append([]string{a.ExecPath()}, a.buildArgs(a.extractClaudeConfig(types.ExecutionContext{}))...)
+}
+
+func (a *CodexAgent) CommandLine() []string {
+	var ctx types.ExecutionContext
+	return append([]string{a.ExecPath()}, a.buildArgs(ctx, a.extractCodexConfig(ctx))...)
+}
+
+func (a *GeminiAgent) CommandLine() []string {
+	return append([]string{a.ExecPath()}, a.buildArgs(a.extractGeminiConfig(types.ExecutionContext{}))...)
+}
+
+func (a *VibeAgent) CommandLine() []string {
+	return append([]string{a.ExecPath()}, a.buildArgs(a.extractVibeConfig(types.ExecutionContext{}))...)
+}
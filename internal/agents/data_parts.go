@@ -0,0 +1,94 @@
+package agents
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"agents-hub/internal/types"
+)
+
+// Data part handling modes for CLI agents
+const (
+	DataPartsInline = "inline" // append each data part to the prompt as a fenced JSON block
+	DataPartsFile   = "file"   // write each data part to a temp file and reference its path in the prompt
+	DataPartsIgnore = "ignore" // drop data parts
+)
+
+// resolveDataParts reads the data part mode from the first set env key, defaulting to inline
+func resolveDataParts(envKeys ...string) string {
+	for _, key := range envKeys {
+		switch strings.ToLower(strings.TrimSpace(os.Getenv(key))) {
+		case "":
+			continue
+		case DataPartsFile:
+			return DataPartsFile
+		case DataPartsIgnore:
+			return DataPartsIgnore
+		default:
+			return DataPartsInline
+		}
+	}
+	return DataPartsInline
+}
+
+// dataParts returns the data parts of a message that carry a payload
+func dataParts(msg types.Message) []types.Part {
+	var parts []types.Part
+	for _, part := range msg.Parts {
+		if part.Kind == "data" && part.Data != nil {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// buildPrompt renders the user message, history and data parts into a single prompt.
+// The returned cleanup func removes any temp files and must be called once the command exits.
+func (a *CLIAgent) buildPrompt(ctx types.ExecutionContext) (string, func(), error) {
+	prompt := extractPromptWithHistory(ctx.UserMessage, ctx.PreviousHistory)
+	cleanup := func() {}
+	data := dataParts(ctx.UserMessage)
+	if len(data) == 0 || a.config.DataParts == DataPartsIgnore {
+		return prompt, cleanup, nil
+	}
+
+	sections := make([]string, 0, len(data)+1)
+	if strings.TrimSpace(prompt) != "" {
+		sections = append(sections, prompt)
+	}
+	var files []string
+	cleanup = func() {
+		for _, path := range files {
+			_ = os.Remove(path)
+		}
+	}
+	for _, part := range data {
+		encoded, err := json.MarshalIndent(part.Data, "", "  ")
+		if err != nil {
+			cleanup()
+			return "", func() {}, fmt.Errorf("encode data part: %w", err)
+		}
+		if a.config.DataParts != DataPartsFile {
+			sections = append(sections, "```json\n"+string(encoded)+"\n```")
+			continue
+		}
+		file, err := os.CreateTemp("", "agents-hub-data-*.json")
+		if err != nil {
+			cleanup()
+			return "", func() {}, fmt.Errorf("write data part: %w", err)
+		}
+		files = append(files, file.Name())
+		_, err = file.Write(encoded)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			cleanup()
+			return "", func() {}, fmt.Errorf("write data part: %w", err)
+		}
+		sections = append(sections, "Structured input (JSON) is in "+file.Name())
+	}
+	return strings.Join(sections, "\n\n"), cleanup, nil
+}
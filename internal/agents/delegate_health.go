@@ -0,0 +1,125 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"agents-hub/internal/types"
+)
+
+// SetRouteUnhealthy makes the orchestrator route to delegates whose last health check
+// failed instead of skipping them
+func (o *Orchestrator) SetRouteUnhealthy(enabled bool) {
+	o.routeUnhealthy.Store(enabled)
+}
+
+// SetRouteUnhealthy is Orchestrator.SetRouteUnhealthy for the LLM orchestrator
+func (o *LLMOrchestrator) SetRouteUnhealthy(enabled bool) {
+	o.routeUnhealthy.Store(enabled)
+}
+
+// routableDelegates drops the delegates whose last health check failed unless
+// routeUnhealthy is set, returning a note for each one skipped. Without health (it
+// couldn't be read) every delegate is kept.
+func routableDelegates(delegates []string, health map[string]string, routeUnhealthy bool) ([]string, []string) {
+	if health == nil || routeUnhealthy {
+		return delegates, nil
+	}
+	return filterHealthy(delegates, health)
+}
+
+// healthCache keeps the agents' health statuses for descriptorCacheTTL, so an
+// orchestrator doesn't list every agent on each request
+type healthCache struct {
+	mu        sync.Mutex
+	health    map[string]string
+	fetchedAt time.Time
+}
+
+// get returns the cached health, reading it again once stale; nil when it can't be read
+func (c *healthCache) get(callCtx context.Context, caller RPCCaller) map[string]string {
+	c.mu.Lock()
+	cached, fetchedAt := c.health, c.fetchedAt
+	c.mu.Unlock()
+	if cached != nil && time.Since(fetchedAt) < descriptorCacheTTL {
+		return cached
+	}
+	health, err := delegateHealth(callCtx, caller)
+	if err != nil {
+		return nil
+	}
+	c.mu.Lock()
+	c.health, c.fetchedAt = health, time.Now()
+	c.mu.Unlock()
+	return health
+}
+
+// withRegisteredAgents adds to delegates every other registered agent that can be
+// routed to: not the orchestrator, and healthy unless routeUnhealthy is set
+func withRegisteredAgents(delegates []string, health map[string]string, routeUnhealthy bool) []string {
+	allowed := append([]string{}, delegates...)
+	for id, status := range health {
+		if id == "orchestrator" || slices.Contains(allowed, id) || (status == "unhealthy" && !routeUnhealthy) {
+			continue
+		}
+		allowed = append(allowed, id)
+	}
+	return allowed
+}
+
+// filterHealthy keeps the delegates whose status in health isn't "unhealthy". When
+// none is left they are all kept, since a stale check beats refusing the request.
+func filterHealthy(delegates []string, health map[string]string) ([]string, []string) {
+	healthy := make([]string, 0, len(delegates))
+	var notes []string
+	for _, id := range delegates {
+		if health[id] == "unhealthy" {
+			notes = append(notes, "note: skipped unhealthy agent "+id)
+			continue
+		}
+		healthy = append(healthy, id)
+	}
+	if len(healthy) == 0 && len(delegates) > 0 {
+		return delegates, []string{"note: every delegate is unhealthy; trying them anyway"}
+	}
+	return healthy, notes
+}
+
+// delegateHealth reads each agent's last health status from hub/agents/list
+func delegateHealth(callCtx context.Context, caller RPCCaller) (map[string]string, error) {
+	params, _ := json.Marshal(map[string]any{"includeHealth": true})
+	resp, err := caller.Call(callCtx, "hub/agents/list", params)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, errors.New(resp.Error.Message)
+	}
+	data, err := json.Marshal(resp.Result)
+	if err != nil {
+		return nil, err
+	}
+	var entries []struct {
+		ID     string            `json:"id"`
+		Health types.AgentHealth `json:"health"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	health := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		health[entry.ID] = entry.Health.Status
+	}
+	return health, nil
+}
+
+// healthyDescriptors keeps the descriptors of the delegates in ids
+func healthyDescriptors(descriptors []agentDescriptor, ids []string) []agentDescriptor {
+	return slices.DeleteFunc(slices.Clone(descriptors), func(descriptor agentDescriptor) bool {
+		return !slices.Contains(ids, descriptor.ID)
+	})
+}
@@ -0,0 +1,81 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"agents-hub/internal/jsonrpc"
+)
+
+func TestRoutableDelegates(t *testing.T) {
+	health := map[string]string{"a": "healthy", "b": "unhealthy", "c": "degraded", "d": "unhealthy"}
+	tests := []struct {
+		name           string
+		delegates      []string
+		health         map[string]string
+		routeUnhealthy bool
+		want           []string
+		wantNotes      []string
+	}{
+		{"mixed", []string{"a", "b", "c", "d"}, health, false, []string{"a", "c"}, []string{"note: skipped unhealthy agent b", "note: skipped unhealthy agent d"}},
+		{"unknown agents are kept", []string{"a", "e"}, health, false, []string{"a", "e"}, nil},
+		{"all unhealthy falls back to all", []string{"b", "d"}, health, false, []string{"b", "d"}, []string{"note: every delegate is unhealthy; trying them anyway"}},
+		{"route unhealthy keeps all", []string{"a", "b"}, health, true, []string{"a", "b"}, nil},
+		{"no health keeps all", []string{"a", "b"}, nil, false, []string{"a", "b"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, notes := routableDelegates(tt.delegates, tt.health, tt.routeUnhealthy)
+			if !reflect.DeepEqual(got, tt.want) || !reflect.DeepEqual(notes, tt.wantNotes) {
+				t.Fatalf("routableDelegates = %v, %q; want %v, %q", got, notes, tt.want, tt.wantNotes)
+			}
+		})
+	}
+}
+
+// listCaller answers hub/agents/list and counts the calls
+type listCaller struct {
+	agents []map[string]any
+	calls  atomic.Int32
+}
+
+func (c *listCaller) Call(ctx context.Context, method string, params []byte) (jsonrpc.Response, error) {
+	if method != "hub/agents/list" {
+		return jsonrpc.Response{}, fmt.Errorf("unexpected call to %s", method)
+	}
+	c.calls.Add(1)
+	return jsonrpc.Response{JSONRPC: "2.0", Result: c.agents}, nil
+}
+
+func TestDelegateHealthIsCached(t *testing.T) {
+	caller := &listCaller{agents: []map[string]any{
+		{"id": "a", "name": "A", "health": map[string]any{"status": "healthy"}},
+		{"id": "b", "name": "B", "health": map[string]any{"status": "unhealthy"}},
+	}}
+	want := map[string]string{"a": "healthy", "b": "unhealthy"}
+
+	var cache healthCache
+	for range 3 {
+		if got := cache.get(context.Background(), caller); !reflect.DeepEqual(got, want) {
+			t.Fatalf("health = %v, want %v", got, want)
+		}
+	}
+	if n := caller.calls.Load(); n != 1 {
+		t.Fatalf("round-robin orchestrator listed agents %d times, want 1", n)
+	}
+
+	caller.calls.Store(0)
+	llm := NewLLMOrchestrator(caller, "", []string{"a", "b"}, []string{"router"}, 3)
+	for range 3 {
+		descriptors, health := llm.describeAgents(context.Background(), llm.Delegates())
+		if len(descriptors) != 2 || !reflect.DeepEqual(health, want) {
+			t.Fatalf("describeAgents = %v, %v; want 2 descriptors and %v", descriptors, health, want)
+		}
+	}
+	if n := caller.calls.Load(); n != 1 {
+		t.Fatalf("LLM orchestrator listed agents %d times, want 1", n)
+	}
+}
@@ -0,0 +1,107 @@
+package agents
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"agents-hub/internal/types"
+)
+
+// buildFakeAgent builds testdata/fakeagent and points the agent's exec override at it
+func buildFakeAgent(t *testing.T, envVar string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fakeagent")
+	if out, err := exec.Command("go", "build", "-o", path, "./testdata/fakeagent").CombinedOutput(); err != nil {
+		t.Fatalf("building fakeagent: %v\n%s", err, out)
+	}
+	t.Setenv(envVar, path)
+}
+
+func fakeRun(prompt string, timeout time.Duration) types.ExecutionContext {
+	return types.ExecutionContext{
+		TaskID:      "task-" + strings.Fields(prompt)[0],
+		UserMessage: types.Message{Kind: "message", Role: "user", Parts: []types.Part{{Kind: "text", Text: prompt}}},
+		Timeout:     timeout,
+	}
+}
+
+func TestFakeAgentExecute(t *testing.T) {
+	buildFakeAgent(t, "CLAUDE_CMD")
+	agent := NewClaudeAgent("")
+	tests := []struct {
+		name    string
+		prompt  string
+		timeout time.Duration
+		want    string // the reply, or a substring of the error
+		wantErr error  // checked with errors.Is when set
+		failed  bool
+	}{
+		{name: "lines", prompt: "LINES 3", want: "line 1\nline 2\nline 3"},
+		{name: "echo", prompt: "hello there", want: "echo: hello there"},
+		{name: "stderr becomes the error", prompt: "FAIL out of credits", want: "out of credits", failed: true},
+		{name: "login prompt stops the run", prompt: "LOGIN", wantErr: ErrLoginRequired, failed: true},
+		{name: "timeout", prompt: "SLEEP 1m", timeout: 200 * time.Millisecond, failed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := time.Now()
+			result, err := agent.Execute(fakeRun(tt.prompt, tt.timeout))
+			if elapsed := time.Since(started); elapsed > 10*time.Second {
+				t.Fatalf("run took %s", elapsed)
+			}
+			if tt.failed {
+				if err == nil {
+					t.Fatal("run succeeded, want an error")
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				if !strings.Contains(err.Error(), tt.want) {
+					t.Fatalf("error = %v, want it to mention %q", err, tt.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(result.Task.Status.Message.Parts[0].Text); got != tt.want {
+				t.Fatalf("reply = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFakeAgentStreamingInput(t *testing.T) {
+	// Gemini watches its output for [y/N] prompts; Claude doesn't
+	buildFakeAgent(t, "GEMINI_CMD")
+	agent := NewGeminiAgent("")
+	output := make(chan types.StreamEvent, 16)
+	input := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- agent.ExecuteStreaming(fakeRun("ASK deploy", 30*time.Second), output, input)
+		close(output)
+	}()
+
+	var lines []string
+	for event := range output {
+		switch event.Kind {
+		case "prompt":
+			input <- "y"
+		case "output":
+			lines = append(lines, event.Text)
+		case "error":
+			t.Fatalf("stream error: %s", event.Text)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(lines, "\n"); !strings.Contains(got, "answer: y") {
+		t.Fatalf("output = %q, want the forwarded answer", got)
+	}
+}
@@ -2,6 +2,7 @@ package agents
 
 import (
 	"strings"
+	"sync/atomic"
 
 	"agents-hub/internal/types"
 )
@@ -9,7 +10,7 @@ import (
 // GeminiAgent wraps Gemini CLI with enhanced configuration
 type GeminiAgent struct {
 	*CLIAgent
-	defaultConfig types.GeminiConfig
+	defaultConfig atomic.Pointer[types.GeminiConfig]
 }
 
 // NewGeminiAgent creates a new Gemini agent
@@ -32,18 +33,20 @@ func NewGeminiAgent(baseURL string) *GeminiAgent {
 		HealthArgs:     []string{"--version"},
 		Args:           []string{"{prompt}", "-o", "text"},
 		Card:           card,
+		DataParts:      resolveDataParts("GEMINI_DATA_PARTS"),
 		PromptPatterns: codexPromptPatterns(),
+		AuthPatterns:   resolveAuthPatterns(geminiAuthPatterns(), "GEMINI_AUTH_PATTERN"),
+		LoginHint:      "run `gemini auth`",
 	})
 
-	return &GeminiAgent{
-		CLIAgent:      cliAgent,
-		defaultConfig: types.GeminiConfig{},
-	}
+	agent := &GeminiAgent{CLIAgent: cliAgent}
+	agent.defaultConfig.Store(&types.GeminiConfig{})
+	return agent
 }
 
 // SetDefaultConfig sets the default configuration for this agent
 func (a *GeminiAgent) SetDefaultConfig(config types.GeminiConfig) {
-	a.defaultConfig = config
+	a.defaultConfig.Store(&config)
 }
 
 // Execute runs Gemini with dynamic arguments based on config
@@ -62,7 +65,7 @@ func (a *GeminiAgent) ExecuteStreaming(ctx types.ExecutionContext, output chan<-
 
 // extractGeminiConfig gets GeminiConfig from execution context metadata or defaults
 func (a *GeminiAgent) extractGeminiConfig(ctx types.ExecutionContext) types.GeminiConfig {
-	config := a.defaultConfig
+	config := *a.defaultConfig.Load()
 
 	if ctx.UserMessage.Metadata != nil {
 		if cfgRaw, ok := ctx.UserMessage.Metadata["geminiConfig"]; ok {
@@ -0,0 +1,47 @@
+package agents
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"agents-hub/internal/types"
+)
+
+const (
+	// healthProbeTimeout bounds the deep health probe so a hung backend can't stall checks
+	healthProbeTimeout = 30 * time.Second
+	healthProbePrompt  = "Health check: reply with the single word OK."
+)
+
+// SetHealthProbe enables the deep health probe, which sends a tiny real prompt on
+// every health check. It costs a model call, so it is off by default.
+func (a *CLIAgent) SetHealthProbe(enabled bool) {
+	a.healthProbe.Store(enabled)
+}
+
+// HealthProbe reports whether the deep health probe is enabled
+func (a *CLIAgent) HealthProbe() bool {
+	return a.healthProbe.Load()
+}
+
+// probe runs the CLI's base command with a tiny prompt to check it can reach its backend
+func (a *CLIAgent) probe() error {
+	ctx := types.ExecutionContext{
+		TaskID:  "health-probe-" + a.ID(),
+		Timeout: healthProbeTimeout,
+		UserMessage: types.Message{
+			Kind:  "message",
+			Role:  "user",
+			Parts: []types.Part{{Kind: "text", Text: healthProbePrompt}},
+		},
+	}
+	result, err := a.ExecuteWithArgs(ctx, a.config.Args)
+	if err != nil {
+		return err
+	}
+	if msg := result.Task.Status.Message; msg == nil || strings.TrimSpace(extractPrompt(*msg)) == "" {
+		return errors.New("health probe returned no output")
+	}
+	return nil
+}
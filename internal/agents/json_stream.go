@@ -0,0 +1,191 @@
+package agents
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+
+	"agents-hub/internal/types"
+)
+
+// maxToolSummary bounds the text shown for one tool call
+const maxToolSummary = 200
+
+// SetJSONStream makes streaming runs ask Claude for its stream-json protocol, so tool
+// calls and thinking arrive as their own events. Non-streaming runs keep plain text.
+func (a *ClaudeAgent) SetJSONStream(enabled bool) {
+	a.jsonStream.Store(enabled)
+}
+
+// SetJSONStream makes streaming runs use `codex exec --json`, so commands, file edits
+// and reasoning arrive as their own events. Non-streaming runs keep plain text.
+func (a *CodexAgent) SetJSONStream(enabled bool) {
+	a.jsonStream.Store(enabled)
+}
+
+// JSONStream reports whether streaming runs use the CLI's JSON event protocol
+func (a *CLIAgent) JSONStream() bool {
+	return a.jsonStream.Load()
+}
+
+// jsonStreamLine is the union of the Claude stream-json and Codex exec --json fields
+// parseJSONStreamLine reads
+type jsonStreamLine struct {
+	Type    string `json:"type"`
+	IsError bool   `json:"is_error"`
+	Result  string `json:"result"`
+	Message any    `json:"message"` // Claude: {"content": [...]}; Codex error: a string
+	Item    struct {
+		Type    string `json:"type"`
+		Text    string `json:"text"`
+		Command string `json:"command"`
+		Server  string `json:"server"`
+		Tool    string `json:"tool"`
+		Query   string `json:"query"`
+		Message string `json:"message"`
+		Changes []struct {
+			Path string `json:"path"`
+			Kind string `json:"kind"`
+		} `json:"changes"`
+	} `json:"item"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type claudeContent struct {
+	Type     string         `json:"type"`
+	Text     string         `json:"text"`
+	Thinking string         `json:"thinking"`
+	Name     string         `json:"name"`
+	Input    map[string]any `json:"input"`
+}
+
+// parseJSONStreamLine turns one line of a JSON event stream into stream events, which
+// may be none for bookkeeping events. ok is false when the line isn't an event it
+// knows, and the caller shows the line as plain output instead.
+func parseJSONStreamLine(line string) ([]types.StreamEvent, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return nil, false
+	}
+	var event jsonStreamLine
+	if err := json.Unmarshal([]byte(trimmed), &event); err != nil {
+		return nil, false
+	}
+	switch event.Type {
+	// Claude stream-json
+	case "system", "user":
+		// Session setup and tool results; the results can be huge, so they are left out
+		return nil, true
+	case "assistant":
+		return claudeAssistantEvents(trimmed), true
+	case "result":
+		if event.IsError && event.Result != "" {
+			return []types.StreamEvent{{Kind: "output", Text: "error: " + event.Result}}, true
+		}
+		// The final text repeats the assistant messages already streamed
+		return nil, true
+	// Codex exec --json
+	case "thread.started", "turn.started", "turn.completed", "item.updated":
+		return nil, true
+	case "item.started":
+		return codexItemStarted(event), true
+	case "item.completed":
+		return codexItemCompleted(event), true
+	case "turn.failed":
+		return []types.StreamEvent{{Kind: "output", Text: "error: " + event.Error.Message}}, true
+	case "error":
+		if text, ok := event.Message.(string); ok {
+			return []types.StreamEvent{{Kind: "output", Text: "error: " + text}}, true
+		}
+	}
+	return nil, false
+}
+
+func claudeAssistantEvents(line string) []types.StreamEvent {
+	var event struct {
+		Message struct {
+			Content []claudeContent `json:"content"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		return nil
+	}
+	events := make([]types.StreamEvent, 0, len(event.Message.Content))
+	for _, content := range event.Message.Content {
+		switch content.Type {
+		case "text":
+			if strings.TrimSpace(content.Text) != "" {
+				events = append(events, types.StreamEvent{Kind: "output", Text: content.Text})
+			}
+		case "thinking":
+			if strings.TrimSpace(content.Thinking) != "" {
+				events = append(events, types.StreamEvent{Kind: "thinking", Text: content.Thinking})
+			}
+		case "tool_use":
+			events = append(events, types.StreamEvent{Kind: "tool", Tool: content.Name, Text: summarizeToolInput(content.Input)})
+		}
+	}
+	return events
+}
+
+func codexItemStarted(event jsonStreamLine) []types.StreamEvent {
+	item := event.Item
+	switch item.Type {
+	case "command_execution":
+		return []types.StreamEvent{{Kind: "tool", Tool: "shell", Text: clipSummary(item.Command)}}
+	case "mcp_tool_call":
+		return []types.StreamEvent{{Kind: "tool", Tool: item.Server + "." + item.Tool}}
+	case "web_search":
+		return []types.StreamEvent{{Kind: "tool", Tool: "web_search", Text: clipSummary(item.Query)}}
+	}
+	return nil
+}
+
+func codexItemCompleted(event jsonStreamLine) []types.StreamEvent {
+	item := event.Item
+	switch item.Type {
+	case "agent_message":
+		return []types.StreamEvent{{Kind: "output", Text: item.Text}}
+	case "reasoning":
+		return []types.StreamEvent{{Kind: "thinking", Text: item.Text}}
+	case "file_change":
+		changes := make([]string, 0, len(item.Changes))
+		for _, change := range item.Changes {
+			changes = append(changes, change.Kind+" "+change.Path)
+		}
+		return []types.StreamEvent{{Kind: "tool", Tool: "apply_patch", Text: clipSummary(strings.Join(changes, ", "))}}
+	case "error":
+		return []types.StreamEvent{{Kind: "output", Text: "error: " + item.Message}}
+	}
+	return nil
+}
+
+// summarizeToolInput picks the input field that says what a tool call does, such as
+// a Bash command or a file path, falling back to the compact JSON input
+func summarizeToolInput(input map[string]any) string {
+	for _, key := range []string{"command", "file_path", "path", "pattern", "url", "query", "description", "prompt"} {
+		if value, ok := input[key].(string); ok && strings.TrimSpace(value) != "" {
+			return clipSummary(value)
+		}
+	}
+	if len(input) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return ""
+	}
+	return clipSummary(string(data))
+}
+
+// clipSummary keeps a tool summary to one line of at most maxToolSummary characters
+func clipSummary(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= maxToolSummary {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:maxToolSummary-1]) + "…"
+}
@@ -5,22 +5,47 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
 
 	"agents-hub/internal/types"
 	"agents-hub/internal/utils"
 )
 
-const maxRoutingTargets = 3
+// DefaultMaxRoutingTargets is how many delegates one request is routed to unless
+// settings say otherwise; MaxRoutingTargetsLimit caps the setting
+const (
+	DefaultMaxRoutingTargets = 3
+	MaxRoutingTargetsLimit   = 10
+)
+
+// descriptorCacheTTL bounds how long hub/agents/list results are reused between requests
+const descriptorCacheTTL = 30 * time.Second
+
+// Caps on how much of each agent's skill list goes into the routing prompt
+const (
+	maxRoutingSkills    = 8
+	maxRoutingSkillTags = 4
+)
 
 type LLMOrchestrator struct {
-	mu          sync.RWMutex
-	caller      RPCCaller
-	agentIDs    []string
-	routerAgent string
-	card        types.AgentCard
+	mu                sync.RWMutex
+	caller            RPCCaller
+	agentIDs          []string
+	routerAgents      []string
+	maxTargets        atomic.Int32
+	routeUnhealthy    atomic.Bool
+	allowNonDelegates atomic.Bool
+	card              types.AgentCard
+
+	descriptors   []agentDescriptor // cached for descriptorCacheTTL; reset by SetDelegates
+	health        map[string]string // agent ID -> health status, cached with descriptors
+	descriptorsAt time.Time
 }
 
 type routingTarget struct {
@@ -45,9 +70,14 @@ type agentDescriptor struct {
 	ID          string
 	Name        string
 	Description string
+	Skills      []types.Skill
+	Priority    int // routing hint from settings, lower preferred; 0 when unset
 }
 
-func NewLLMOrchestrator(caller RPCCaller, baseURL string, agentIDs []string, routerAgent string) *LLMOrchestrator {
+// NewLLMOrchestrator routes with one or more router agents. Several routers are
+// consulted together and their plans merged by vote; see mergeRoutingVotes. A
+// request goes to at most maxTargets delegates.
+func NewLLMOrchestrator(caller RPCCaller, baseURL string, agentIDs []string, routerAgents []string, maxTargets int) *LLMOrchestrator {
 	card := types.AgentCard{
 		ProtocolVersion: "1.0",
 		Name:            "A2A Orchestrator (LLM)",
@@ -58,12 +88,39 @@ func NewLLMOrchestrator(caller RPCCaller, baseURL string, agentIDs []string, rou
 		Skills:          []types.Skill{},
 		Capabilities:    types.AgentCapabilities{Streaming: false, PushNotifications: false, StateTransitionHistory: false},
 	}
-	return &LLMOrchestrator{
-		caller:      caller,
-		agentIDs:    agentIDs,
-		routerAgent: strings.TrimSpace(routerAgent),
-		card:        card,
+	routers := make([]string, 0, len(routerAgents))
+	for _, id := range routerAgents {
+		if id = strings.TrimSpace(id); id != "" && !slices.Contains(routers, id) {
+			routers = append(routers, id)
+		}
+	}
+	orchestrator := &LLMOrchestrator{
+		caller:       caller,
+		agentIDs:     agentIDs,
+		routerAgents: routers,
+		card:         card,
+	}
+	orchestrator.SetMaxTargets(maxTargets)
+	return orchestrator
+}
+
+// ClampRoutingTargets turns a configured target limit into one the orchestrator uses:
+// 0 or less means the default, and anything above MaxRoutingTargetsLimit is cut to it
+func ClampRoutingTargets(n int) int {
+	if n <= 0 {
+		return DefaultMaxRoutingTargets
 	}
+	return min(n, MaxRoutingTargetsLimit)
+}
+
+// SetMaxTargets sets how many delegates a request may be routed to; see ClampRoutingTargets
+func (o *LLMOrchestrator) SetMaxTargets(n int) {
+	o.maxTargets.Store(int32(ClampRoutingTargets(n)))
+}
+
+// MaxTargets returns how many delegates a request may be routed to
+func (o *LLMOrchestrator) MaxTargets() int {
+	return int(o.maxTargets.Load())
 }
 
 func (o *LLMOrchestrator) ID() string                        { return "orchestrator" }
@@ -95,54 +152,56 @@ func (o *LLMOrchestrator) Execute(ctx types.ExecutionContext) (types.ExecutionRe
 	if len(delegates) == 0 {
 		return types.ExecutionResult{}, errors.New("no delegate agents configured")
 	}
-	router := strings.TrimSpace(o.routerAgent)
-	if router == "" {
+	if len(o.routerAgents) == 0 {
 		return types.ExecutionResult{}, errors.New("no router agent configured")
 	}
-	if router == o.ID() {
+	if slices.Contains(o.routerAgents, o.ID()) {
 		return types.ExecutionResult{}, errors.New("router agent cannot be orchestrator")
 	}
 
-	descriptors := o.describeAgents(ctx, delegates)
-	targets, notes, routeErr := o.routeTargets(ctx, prompt, router, descriptors)
-	routingNote := ""
-	if routeErr != nil {
-		routingNote = fmt.Sprintf("note: routing fallback used (%v)", routeErr)
+	// One deadline covers routing and every delegate call, as in Orchestrator.Execute
+	timeout := ctx.Timeout
+	if timeout <= 0 {
+		timeout = DefaultOrchestratorTimeout
 	}
+	callCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	targets = normalizeTargets(targets, delegates, prompt)
+	maxTargets := o.MaxTargets()
+	descriptors, health := o.describeAgents(callCtx, delegates)
+	delegates, healthNotes := routableDelegates(delegates, health, o.routeUnhealthy.Load())
+	descriptors = healthyDescriptors(descriptors, delegates)
+	scope := routingScope{allowed: delegates, health: health}
+	if o.allowNonDelegates.Load() {
+		scope.allowed = withRegisteredAgents(delegates, health, o.routeUnhealthy.Load())
+	}
+	targets, notes, routingNotes := o.consultRouters(callCtx, ctx, prompt, scope, descriptors, maxTargets)
 	if len(targets) == 0 {
-		targets = []routingTarget{{AgentID: delegates[0], Message: prompt}}
+		targets = localRoutingTargets(prompt, descriptors, maxTargets)
 	}
-	if len(targets) > maxRoutingTargets {
-		targets = targets[:maxRoutingTargets]
+	if len(targets) > maxTargets {
+		for _, target := range targets[maxTargets:] {
+			routingNotes = append(routingNotes, fmt.Sprintf("note: not sent to %s, over the limit of %d targets: %q", target.AgentID, maxTargets, target.Message))
+		}
+		targets = targets[:maxTargets]
 	}
 
-	results := make([]string, 0, len(targets)+1)
-	if routingNote != "" {
-		results = append(results, routingNote)
-	}
+	allNotes := append(healthNotes, routingNotes...)
 	if notes != "" {
-		results = append(results, "note: "+strings.TrimSpace(notes))
+		allNotes = append(allNotes, "note: "+strings.TrimSpace(notes))
 	}
 
+	results := make([]delegateResult, 0, len(targets))
 	for _, target := range targets {
-		task, err := o.sendToAgent(ctx, target.AgentID, target.Message)
+		task, err := o.sendToAgent(callCtx, ctx, target.AgentID, target.Message)
 		if err != nil {
-			results = append(results, fmt.Sprintf("%s: error: %v", target.AgentID, err))
+			results = append(results, delegateResult{AgentID: target.AgentID, Error: err.Error()})
 			continue
 		}
-		results = append(results, fmt.Sprintf("%s: %s", target.AgentID, extractTaskText(task)))
+		results = append(results, delegateResult{AgentID: target.AgentID, Text: extractTaskText(task)})
 	}
 
-	response := types.Message{
-		Kind:      "message",
-		MessageID: "resp-" + ctx.TaskID,
-		Role:      "agent",
-		Parts:     []types.Part{{Kind: "text", Text: strings.Join(results, "\n\n")}},
-		TaskID:    ctx.TaskID,
-		ContextID: ctx.ContextID,
-	}
+	response := orchestratedResponse(ctx, allNotes, results)
 	return types.ExecutionResult{
 		Task: types.Task{
 			Kind:      "task",
@@ -163,6 +222,9 @@ func (o *LLMOrchestrator) SetDelegates(ids []string) {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 	o.agentIDs = append([]string{}, ids...)
+	o.descriptors = nil
+	o.health = nil
+	o.descriptorsAt = time.Time{}
 }
 
 func (o *LLMOrchestrator) Delegates() []string {
@@ -175,9 +237,176 @@ func (o *LLMOrchestrator) ExecPath() string {
 	return "in-process"
 }
 
-func (o *LLMOrchestrator) routeTargets(ctx types.ExecutionContext, prompt, router string, agents []agentDescriptor) ([]routingTarget, string, error) {
-	text := buildRoutingPrompt(prompt, agents)
-	task, err := o.sendToAgent(ctx, router, text)
+// checkRouter fails when the router agent is missing or its last health check failed,
+// so Execute can skip the round-trip to an agent that cannot answer.
+func (o *LLMOrchestrator) checkRouter(callCtx context.Context, router string) error {
+	params, _ := json.Marshal(map[string]any{"agentId": router})
+	execCtx, cancel := context.WithTimeout(callCtx, 5*time.Second)
+	defer cancel()
+	resp, err := o.caller.Call(execCtx, "hub/agents/health", params)
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return errors.New(resp.Error.Message)
+	}
+	data, err := json.Marshal(resp.Result)
+	if err != nil {
+		return err
+	}
+	var health types.AgentHealth
+	if err := json.Unmarshal(data, &health); err != nil {
+		return err
+	}
+	if health.Status == "unhealthy" {
+		if health.ErrorMessage != "" {
+			return fmt.Errorf("unhealthy: %s", health.ErrorMessage)
+		}
+		return errors.New("unhealthy")
+	}
+	return nil
+}
+
+// routerResult is one router's answer in consultRouters
+type routerResult struct {
+	router  string
+	targets []routingTarget
+	dropped []string // picks outside the routing scope
+	notes   string
+	err     error
+}
+
+// routingScope is what a router's picks are checked against
+type routingScope struct {
+	allowed []string          // agents a request may be routed to
+	health  map[string]string // last health status of each registered agent; nil when unknown
+}
+
+// dropReason says why a router's pick was not in the scope
+func (scope routingScope) dropReason(agentID string) string {
+	status, registered := scope.health[agentID]
+	switch {
+	case agentID == "orchestrator":
+		return "the orchestrator itself"
+	case scope.health != nil && !registered:
+		return "not a registered agent"
+	case status == "unhealthy":
+		return "unhealthy"
+	}
+	return "not an orchestrator delegate"
+}
+
+// SetAllowNonDelegates lets routers pick any healthy registered agent, not only the
+// delegates; by default such picks are dropped with a note
+func (o *LLMOrchestrator) SetAllowNonDelegates(enabled bool) {
+	o.allowNonDelegates.Store(enabled)
+}
+
+// consultRouters asks every router agent for a routing plan at once and merges
+// the plans that came back. A router that is down or answers badly only adds a
+// note, so the others still decide; when none answers, targets is empty and the
+// caller routes locally.
+func (o *LLMOrchestrator) consultRouters(callCtx context.Context, ctx types.ExecutionContext, prompt string, scope routingScope, descriptors []agentDescriptor, maxTargets int) ([]routingTarget, string, []string) {
+	results := make([]routerResult, len(o.routerAgents))
+	var wg sync.WaitGroup
+	for i, router := range o.routerAgents {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := routerResult{router: router}
+			if err := o.checkRouter(callCtx, router); err != nil {
+				result.err = fmt.Errorf("unavailable (%v)", err)
+			} else if targets, notes, err := o.routeTargets(callCtx, ctx, prompt, router, descriptors, maxTargets); err != nil {
+				result.err = fmt.Errorf("failed (%v)", err)
+			} else if targets, result.dropped = normalizeTargets(targets, scope.allowed, prompt); len(targets) == 0 {
+				result.err = errors.New("failed (no usable targets)")
+			} else {
+				result.targets, result.notes = targets, strings.TrimSpace(notes)
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	var plans [][]routingTarget
+	var notes []string
+	for _, result := range results {
+		if result.err != nil {
+			continue
+		}
+		plans = append(plans, result.targets)
+		if result.notes != "" {
+			if len(results) > 1 {
+				result.notes = result.router + ": " + result.notes
+			}
+			notes = append(notes, result.notes)
+		}
+	}
+	fallback := "; routed locally by keyword/round-robin"
+	if len(plans) > 0 {
+		fallback = "; used the other routers"
+	}
+	var routingNotes []string
+	for _, result := range results {
+		for _, agentID := range result.dropped {
+			routingNotes = append(routingNotes, fmt.Sprintf("note: router %s picked %s, which is %s; dropped it", result.router, agentID, scope.dropReason(agentID)))
+		}
+		if result.err != nil {
+			routingNotes = append(routingNotes, fmt.Sprintf("note: router %s %v%s", result.router, result.err, fallback))
+		}
+	}
+	return mergeRoutingVotes(plans), strings.Join(notes, "; "), routingNotes
+}
+
+// mergeRoutingVotes combines the plans of several routers. Agents chosen by a
+// majority of routers win; when no agent has a majority the union is used, so a
+// single router picking a bad target can't add it on its own once others agree.
+// Each agent appears once, ordered by votes and then first mention, with the
+// message from the first router that chose it. A lone plan is returned as is.
+func mergeRoutingVotes(plans [][]routingTarget) []routingTarget {
+	if len(plans) <= 1 {
+		if len(plans) == 0 {
+			return nil
+		}
+		return plans[0]
+	}
+	votes := make(map[string]int)
+	first := make(map[string]routingTarget)
+	var order []string
+	for _, plan := range plans {
+		seen := make(map[string]bool)
+		for _, target := range plan {
+			if seen[target.AgentID] {
+				continue
+			}
+			seen[target.AgentID] = true
+			votes[target.AgentID]++
+			if _, ok := first[target.AgentID]; !ok {
+				first[target.AgentID] = target
+				order = append(order, target.AgentID)
+			}
+		}
+	}
+	chosen := make([]string, 0, len(order))
+	for _, id := range order {
+		if votes[id]*2 > len(plans) {
+			chosen = append(chosen, id)
+		}
+	}
+	if len(chosen) == 0 {
+		chosen = order
+	}
+	slices.SortStableFunc(chosen, func(a, b string) int { return votes[b] - votes[a] })
+	merged := make([]routingTarget, 0, len(chosen))
+	for _, id := range chosen {
+		merged = append(merged, first[id])
+	}
+	return merged
+}
+
+func (o *LLMOrchestrator) routeTargets(callCtx context.Context, ctx types.ExecutionContext, prompt, router string, agents []agentDescriptor, maxTargets int) ([]routingTarget, string, error) {
+	text := buildRoutingPrompt(prompt, agents, maxTargets)
+	task, err := o.sendToAgent(callCtx, ctx, router, text)
 	if err != nil {
 		return nil, "", err
 	}
@@ -189,22 +418,24 @@ func (o *LLMOrchestrator) routeTargets(ctx types.ExecutionContext, prompt, route
 	return targets, notes, nil
 }
 
-func (o *LLMOrchestrator) sendToAgent(ctx types.ExecutionContext, agentID, text string) (types.Task, error) {
+// sendToAgent runs one message/send under callCtx. The agent is given only the
+// time left before callCtx's deadline, so a slow call can't push the whole
+// orchestration past it.
+func (o *LLMOrchestrator) sendToAgent(callCtx context.Context, ctx types.ExecutionContext, agentID, text string) (types.Task, error) {
+	if err := callCtx.Err(); err != nil {
+		return types.Task{}, err
+	}
 	msg := types.Message{
 		Kind:      "message",
 		MessageID: utils.NewID("msg"),
 		Role:      "user",
 		Parts:     []types.Part{{Kind: "text", Text: text}},
 		ContextID: ctx.ContextID,
-		Metadata:  map[string]any{"targetAgent": agentID},
+		Metadata:  delegateMetadata(o.ID(), ctx, agentID),
 	}
-	if strings.TrimSpace(ctx.WorkingDir) != "" {
-		msg.Metadata["workingDirectory"] = ctx.WorkingDir
-	}
-	// Use default timeout if none specified
-	timeout := ctx.Timeout
-	if timeout <= 0 {
-		timeout = DefaultOrchestratorTimeout
+	timeout := DefaultOrchestratorTimeout
+	if deadline, ok := callCtx.Deadline(); ok {
+		timeout = max(time.Until(deadline), time.Millisecond)
 	}
 	configuration := map[string]any{
 		"historyLength": 10,
@@ -214,9 +445,7 @@ func (o *LLMOrchestrator) sendToAgent(ctx types.ExecutionContext, agentID, text
 		"message":       msg,
 		"configuration": configuration,
 	})
-	execCtx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-	resp, err := o.caller.Call(execCtx, "message/send", params)
+	resp, err := o.caller.Call(callCtx, "message/send", params)
 	if err != nil {
 		return types.Task{}, err
 	}
@@ -226,13 +455,25 @@ func (o *LLMOrchestrator) sendToAgent(ctx types.ExecutionContext, agentID, text
 	return decodeTask(resp.Result)
 }
 
-func (o *LLMOrchestrator) describeAgents(ctx types.ExecutionContext, delegates []string) []agentDescriptor {
-	info, err := o.fetchAgentInfo(ctx)
+// describeAgents returns the delegates' descriptors and every registered agent's
+// health status, both from one hub/agents/list call cached for descriptorCacheTTL.
+// The health is nil when the list can't be read.
+func (o *LLMOrchestrator) describeAgents(callCtx context.Context, delegates []string) ([]agentDescriptor, map[string]string) {
+	o.mu.RLock()
+	cached, health, fetchedAt := o.descriptors, o.health, o.descriptorsAt
+	o.mu.RUnlock()
+	if cached != nil && time.Since(fetchedAt) < descriptorCacheTTL {
+		return cached, health
+	}
+
+	info, err := o.fetchAgentInfo(callCtx)
 	if err != nil || len(info) == 0 {
-		return fallbackDescriptors(delegates)
+		return fallbackDescriptors(delegates), nil
 	}
 	byID := make(map[string]agentDescriptor, len(info))
+	health = make(map[string]string, len(info))
 	for _, entry := range info {
+		health[entry.ID] = entry.Health.Status
 		desc := strings.TrimSpace(entry.Card.Description)
 		if desc == "" {
 			desc = strings.TrimSpace(entry.Name)
@@ -241,6 +482,8 @@ func (o *LLMOrchestrator) describeAgents(ctx types.ExecutionContext, delegates [
 			ID:          entry.ID,
 			Name:        entry.Name,
 			Description: desc,
+			Skills:      entry.Card.Skills,
+			Priority:    entry.Priority,
 		}
 	}
 	descriptors := make([]agentDescriptor, 0, len(delegates))
@@ -251,23 +494,30 @@ func (o *LLMOrchestrator) describeAgents(ctx types.ExecutionContext, delegates [
 		}
 		descriptors = append(descriptors, agentDescriptor{ID: id, Name: id, Description: ""})
 	}
-	return descriptors
+
+	o.mu.Lock()
+	// Only cache when the delegates have not changed while the list was being fetched
+	if slices.Equal(o.agentIDs, delegates) {
+		o.descriptors = descriptors
+		o.health = health
+		o.descriptorsAt = time.Now()
+	}
+	o.mu.Unlock()
+	return descriptors, health
 }
 
-func (o *LLMOrchestrator) fetchAgentInfo(ctx types.ExecutionContext) ([]struct {
-	ID   string          `json:"id"`
-	Name string          `json:"name"`
-	Card types.AgentCard `json:"card"`
-}, error) {
-	params, _ := json.Marshal(map[string]any{"includeHealth": false})
-	// Use default timeout if none specified
-	timeout := ctx.Timeout
-	if timeout <= 0 {
-		timeout = DefaultOrchestratorTimeout
-	}
-	execCtx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-	resp, err := o.caller.Call(execCtx, "hub/agents/list", params)
+// agentListEntry is the part of a hub/agents/list entry the LLM orchestrator reads
+type agentListEntry struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Card     types.AgentCard   `json:"card"`
+	Priority int               `json:"priority"`
+	Health   types.AgentHealth `json:"health"`
+}
+
+func (o *LLMOrchestrator) fetchAgentInfo(callCtx context.Context) ([]agentListEntry, error) {
+	params, _ := json.Marshal(map[string]any{"includeHealth": true})
+	resp, err := o.caller.Call(callCtx, "hub/agents/list", params)
 	if err != nil {
 		return nil, err
 	}
@@ -278,18 +528,14 @@ func (o *LLMOrchestrator) fetchAgentInfo(ctx types.ExecutionContext) ([]struct {
 	if err != nil {
 		return nil, err
 	}
-	var entries []struct {
-		ID   string          `json:"id"`
-		Name string          `json:"name"`
-		Card types.AgentCard `json:"card"`
-	}
+	var entries []agentListEntry
 	if err := json.Unmarshal(data, &entries); err != nil {
 		return nil, err
 	}
 	return entries, nil
 }
 
-func buildRoutingPrompt(prompt string, agents []agentDescriptor) string {
+func buildRoutingPrompt(prompt string, agents []agentDescriptor, maxTargets int) string {
 	var builder strings.Builder
 	builder.WriteString("You are a routing agent for a local A2A hub.\n")
 	builder.WriteString("Choose the best agent(s) to handle the user request.\n")
@@ -297,8 +543,16 @@ func buildRoutingPrompt(prompt string, agents []agentDescriptor) string {
 	builder.WriteString("{\"targets\":[{\"agentId\":\"<id>\",\"message\":\"<message>\"}],\"notes\":\"optional\"}\n")
 	builder.WriteString("Rules:\n")
 	builder.WriteString("- Use only agentId values from the list below.\n")
-	builder.WriteString("- Use at most 3 targets.\n")
-	builder.WriteString("- If a single agent can handle the request, return one target.\n")
+	if maxTargets == 1 {
+		builder.WriteString("- Use exactly one target.\n")
+	} else {
+		fmt.Fprintf(&builder, "- Use at most %d targets.\n", maxTargets)
+		builder.WriteString("- If a single agent can handle the request, return one target.\n")
+	}
+	builder.WriteString("- Prefer agents whose skills or tags match the request.\n")
+	if hasRoutingPriorities(agents) {
+		builder.WriteString("- Prefer lower-cost agents when capable: among agents that fit, pick the lowest priority number.\n")
+	}
 	builder.WriteString("- Keep messages concise and grounded in the user request.\n\n")
 	builder.WriteString("Available agents:\n")
 	for _, agent := range agents {
@@ -307,17 +561,85 @@ func buildRoutingPrompt(prompt string, agents []agentDescriptor) string {
 			line = line + " - " + agent.Description
 		}
 		builder.WriteString(line + "\n")
+		if skills := formatRoutingSkills(agent.Skills); skills != "" {
+			builder.WriteString("  skills: " + skills + "\n")
+		}
+		if agent.Priority > 0 {
+			builder.WriteString(fmt.Sprintf("  priority: %d\n", agent.Priority))
+		}
 	}
 	builder.WriteString("\nUser request:\n")
 	builder.WriteString(prompt)
 	return builder.String()
 }
 
+func hasRoutingPriorities(agents []agentDescriptor) bool {
+	for _, agent := range agents {
+		if agent.Priority > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// formatRoutingSkills renders skills as "name [tag, tag]" entries, truncating long lists
+func formatRoutingSkills(skills []types.Skill) string {
+	entries := make([]string, 0, min(len(skills), maxRoutingSkills)+1)
+	for i, skill := range skills {
+		if i == maxRoutingSkills {
+			entries = append(entries, fmt.Sprintf("+%d more", len(skills)-maxRoutingSkills))
+			break
+		}
+		name := strings.TrimSpace(firstNonEmpty(skill.Name, skill.ID))
+		if name == "" {
+			continue
+		}
+		tags := skill.Tags
+		if len(tags) > maxRoutingSkillTags {
+			tags = tags[:maxRoutingSkillTags]
+		}
+		if len(tags) > 0 {
+			name += " [" + strings.Join(tags, ", ") + "]"
+		}
+		entries = append(entries, name)
+	}
+	return strings.Join(entries, "; ")
+}
+
+// jsonFence matches a fenced code block, optionally tagged json
+var jsonFence = regexp.MustCompile("(?s)```(?:json|JSON)?[ \t]*\n(.*?)```")
+
+// parseRoutingTargets reads a routing plan from the router's reply. Routers often wrap
+// the JSON in prose or code fences, so every JSON value in the reply is tried, and the
+// first one that names targets wins.
 func parseRoutingTargets(text string) ([]routingTarget, string, error) {
-	payload := extractJSON(text)
-	if payload == "" {
+	candidates := jsonCandidates(text)
+	if len(candidates) == 0 {
 		return nil, "", errors.New("router returned no JSON")
 	}
+	parsed := false
+	var notes string
+	for _, payload := range candidates {
+		targets, planNotes, ok := parseRoutingPayload(payload)
+		if !ok {
+			continue
+		}
+		if len(targets) > 0 {
+			return targets, planNotes, nil
+		}
+		if !parsed {
+			parsed, notes = true, planNotes
+		}
+	}
+	if parsed {
+		return nil, notes, nil
+	}
+	return nil, "", errors.New("unable to parse routing plan")
+}
+
+// parseRoutingPayload reads one JSON value as a plan object, a list of targets or a
+// single target; ok is false when it is none of these
+func parseRoutingPayload(payload string) ([]routingTarget, string, bool) {
 	var plan routingPlan
 	if err := json.Unmarshal([]byte(payload), &plan); err == nil {
 		targets := plan.Targets
@@ -334,19 +656,55 @@ func parseRoutingTargets(text string) ([]routingTarget, string, error) {
 				}}
 			}
 		}
-		return targets, plan.Notes, nil
+		return targets, plan.Notes, true
 	}
 	var targets []routingTarget
 	if err := json.Unmarshal([]byte(payload), &targets); err == nil && len(targets) > 0 {
-		return targets, "", nil
+		return targets, "", true
 	}
 	var target routingTarget
 	if err := json.Unmarshal([]byte(payload), &target); err == nil && (target.AgentID != "" || target.Agent != "") {
-		return []routingTarget{target}, "", nil
+		return []routingTarget{target}, "", true
 	}
-	return nil, "", errors.New("unable to parse routing plan")
+	return nil, "", false
 }
 
+// jsonCandidates lists the JSON values in text in the order to try them: those in
+// fenced code blocks first, then every top-level object or array, largest first
+func jsonCandidates(text string) []string {
+	var candidates []string
+	for _, match := range jsonFence.FindAllStringSubmatch(text, -1) {
+		if value := extractJSON(match[1]); value != "" {
+			candidates = append(candidates, value)
+		}
+	}
+	var values []string
+	for offset := 0; offset < len(text); {
+		start := strings.IndexAny(text[offset:], "{[")
+		if start == -1 {
+			break
+		}
+		start += offset
+		decoder := json.NewDecoder(strings.NewReader(text[start:]))
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			// Prose such as "[note]" isn't JSON; a value may still start further on
+			offset = start + 1
+			continue
+		}
+		values = append(values, string(raw))
+		offset = start + int(decoder.InputOffset())
+	}
+	slices.SortStableFunc(values, func(a, b string) int { return len(b) - len(a) })
+	for _, value := range values {
+		if !slices.Contains(candidates, value) {
+			candidates = append(candidates, value)
+		}
+	}
+	return candidates
+}
+
+// extractJSON returns the first JSON value in text, ignoring anything after it
 func extractJSON(text string) string {
 	start := strings.IndexAny(text, "{[")
 	if start == -1 {
@@ -360,21 +718,26 @@ func extractJSON(text string) string {
 	return string(raw)
 }
 
-func normalizeTargets(targets []routingTarget, delegates []string, fallbackMessage string) []routingTarget {
+// normalizeTargets keeps the targets naming an agent in delegates and fills in missing
+// messages; dropped lists the other agents named, once each
+func normalizeTargets(targets []routingTarget, delegates []string, fallbackMessage string) (normalized []routingTarget, dropped []string) {
 	if len(targets) == 0 {
-		return nil
+		return nil, nil
 	}
 	allowed := make(map[string]struct{}, len(delegates))
 	for _, id := range delegates {
 		allowed[id] = struct{}{}
 	}
-	normalized := make([]routingTarget, 0, len(targets))
+	normalized = make([]routingTarget, 0, len(targets))
 	for _, target := range targets {
 		agentID := strings.TrimSpace(firstNonEmpty(target.AgentID, target.Agent))
 		if agentID == "" {
 			continue
 		}
 		if _, ok := allowed[agentID]; !ok {
+			if !slices.Contains(dropped, agentID) {
+				dropped = append(dropped, agentID)
+			}
 			continue
 		}
 		message := strings.TrimSpace(firstNonEmpty(target.Message, target.Task))
@@ -383,7 +746,68 @@ func normalizeTargets(targets []routingTarget, delegates []string, fallbackMessa
 		}
 		normalized = append(normalized, routingTarget{AgentID: agentID, Message: message})
 	}
-	return normalized
+	return normalized, dropped
+}
+
+// localRoutingTargets mirrors the plain Orchestrator when no router is usable: the prompt
+// is split into parts and each part goes to the delegate whose skills best match its
+// keywords, or round-robin over the first maxTargets delegates when none match. Parts
+// for the same delegate are sent together, in order, so a long prompt isn't cut at
+// the target limit; only keyword matches spread over more delegates than that can be.
+func localRoutingTargets(prompt string, agents []agentDescriptor, maxTargets int) []routingTarget {
+	if len(agents) == 0 {
+		return nil
+	}
+	parts := splitPrompt(prompt)
+	if len(parts) == 0 {
+		parts = []string{prompt}
+	}
+	roundRobin := agents
+	if maxTargets > 0 && len(roundRobin) > maxTargets {
+		roundRobin = roundRobin[:maxTargets]
+	}
+	targets := make([]routingTarget, 0, len(parts))
+	index := make(map[string]int) // agent ID -> its target
+	for i, part := range parts {
+		agentID := matchSkillKeywords(part, agents)
+		if agentID == "" {
+			agentID = roundRobin[i%len(roundRobin)].ID
+		}
+		if at, ok := index[agentID]; ok {
+			targets[at].Message += "\n" + part
+			continue
+		}
+		index[agentID] = len(targets)
+		targets = append(targets, routingTarget{AgentID: agentID, Message: part})
+	}
+	return targets
+}
+
+// matchSkillKeywords returns the agent with the most skill IDs or tags appearing as
+// words in text, or "" when nothing matches. Ties go to the earlier agent; the hub
+// orders delegates by priority, so that is the preferred one.
+func matchSkillKeywords(text string, agents []agentDescriptor) string {
+	words := make(map[string]struct{})
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+	}) {
+		words[word] = struct{}{}
+	}
+	best, bestScore := "", 0
+	for _, agent := range agents {
+		score := 0
+		for _, skill := range agent.Skills {
+			for _, keyword := range append([]string{skill.ID}, skill.Tags...) {
+				if _, ok := words[strings.ToLower(keyword)]; ok {
+					score++
+				}
+			}
+		}
+		if score > bestScore {
+			best, bestScore = agent.ID, score
+		}
+	}
+	return best
 }
 
 func fallbackDescriptors(delegates []string) []agentDescriptor {
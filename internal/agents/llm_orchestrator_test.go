@@ -0,0 +1,248 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"agents-hub/internal/jsonrpc"
+	"agents-hub/internal/types"
+)
+
+func TestLocalRoutingTargets(t *testing.T) {
+	plain := []agentDescriptor{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"}}
+	skilled := []agentDescriptor{
+		{ID: "a", Skills: []types.Skill{{ID: "docs"}}},
+		{ID: "b", Skills: []types.Skill{{ID: "tests"}}},
+		{ID: "c", Skills: []types.Skill{{ID: "deploy"}}},
+		{ID: "d", Skills: []types.Skill{{ID: "review"}}},
+	}
+	tests := []struct {
+		name       string
+		prompt     string
+		agents     []agentDescriptor
+		maxTargets int
+		want       []routingTarget
+	}{
+		{
+			name:       "one part",
+			prompt:     "write docs",
+			agents:     plain,
+			maxTargets: 3,
+			want:       []routingTarget{{AgentID: "a", Message: "write docs"}},
+		},
+		{
+			name:       "round-robin stays within the limit and merges parts",
+			prompt:     "one\ntwo\nthree\nfour\nfive",
+			agents:     plain,
+			maxTargets: 3,
+			want: []routingTarget{
+				{AgentID: "a", Message: "one\nfour"},
+				{AgentID: "b", Message: "two\nfive"},
+				{AgentID: "c", Message: "three"},
+			},
+		},
+		{
+			name:       "keyword matches merge by agent",
+			prompt:     "fix docs; add tests; more docs",
+			agents:     skilled,
+			maxTargets: 3,
+			want: []routingTarget{
+				{AgentID: "a", Message: "fix docs\nmore docs"},
+				{AgentID: "b", Message: "add tests"},
+			},
+		},
+		{
+			name:       "keyword matches may exceed the limit",
+			prompt:     "docs; tests; deploy; review",
+			agents:     skilled,
+			maxTargets: 3,
+			want: []routingTarget{
+				{AgentID: "a", Message: "docs"},
+				{AgentID: "b", Message: "tests"},
+				{AgentID: "c", Message: "deploy"},
+				{AgentID: "d", Message: "review"},
+			},
+		},
+		{
+			name:       "limit of one sends everything to one agent",
+			prompt:     "one and two and three",
+			agents:     plain,
+			maxTargets: 1,
+			want:       []routingTarget{{AgentID: "a", Message: "one\ntwo\nthree"}},
+		},
+		{name: "no agents", prompt: "anything", maxTargets: 3, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := localRoutingTargets(tt.prompt, tt.agents, tt.maxTargets)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("localRoutingTargets = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescriptorCache(t *testing.T) {
+	caller := &listCaller{agents: []map[string]any{
+		{"id": "a", "name": "A", "card": map[string]any{"description": "writes docs"}},
+		{"id": "b", "name": "B"},
+	}}
+	llm := NewLLMOrchestrator(caller, "", []string{"a", "b"}, []string{"router"}, 3)
+	describe := func() []agentDescriptor {
+		descriptors, _ := llm.describeAgents(context.Background(), llm.Delegates())
+		return descriptors
+	}
+
+	first := describe()
+	if len(first) != 2 || first[0].Description != "writes docs" || first[1].Description != "B" {
+		t.Fatalf("descriptors = %+v", first)
+	}
+	describe()
+	if n := caller.calls.Load(); n != 1 {
+		t.Fatalf("listed agents %d times within the TTL, want 1", n)
+	}
+
+	llm.mu.Lock()
+	llm.descriptorsAt = time.Now().Add(-descriptorCacheTTL)
+	llm.mu.Unlock()
+	describe()
+	if n := caller.calls.Load(); n != 2 {
+		t.Fatalf("listed agents %d times after the TTL, want 2", n)
+	}
+
+	llm.SetDelegates([]string{"b"})
+	if got := describe(); len(got) != 1 || got[0].ID != "b" {
+		t.Fatalf("descriptors after SetDelegates = %+v, want b only", got)
+	}
+	if n := caller.calls.Load(); n != 3 {
+		t.Fatalf("listed agents %d times after SetDelegates, want 3", n)
+	}
+
+	// An empty list falls back to bare IDs and is not cached
+	caller.agents = nil
+	llm.SetDelegates([]string{"a"})
+	for range 2 {
+		if got := describe(); !reflect.DeepEqual(got, []agentDescriptor{{ID: "a", Name: "a"}}) {
+			t.Fatalf("fallback descriptors = %+v", got)
+		}
+	}
+	if n := caller.calls.Load(); n != 5 {
+		t.Fatalf("listed agents %d times with an empty list, want 5", n)
+	}
+}
+
+// sendCaller records the configuration of each message/send and answers it with
+// answer, which may block on ctx
+type sendCaller struct {
+	mu       sync.Mutex
+	timeouts []int
+	answer   func(ctx context.Context) (jsonrpc.Response, error)
+}
+
+func (c *sendCaller) Call(ctx context.Context, method string, params []byte) (jsonrpc.Response, error) {
+	if method != "message/send" {
+		return jsonrpc.Response{}, fmt.Errorf("unexpected call to %s", method)
+	}
+	var req struct {
+		Configuration struct {
+			Timeout int `json:"timeout"`
+		} `json:"configuration"`
+	}
+	_ = json.Unmarshal(params, &req)
+	c.mu.Lock()
+	c.timeouts = append(c.timeouts, req.Configuration.Timeout)
+	c.mu.Unlock()
+	return c.answer(ctx)
+}
+
+func TestSendToAgentStaysWithinDeadline(t *testing.T) {
+	caller := &sendCaller{answer: func(context.Context) (jsonrpc.Response, error) {
+		return jsonrpc.Response{JSONRPC: "2.0", Result: map[string]any{"kind": "task", "id": "t1"}}, nil
+	}}
+	llm := NewLLMOrchestrator(caller, "", []string{"a"}, []string{"router"}, 3)
+	run := types.ExecutionContext{TaskID: "task-1", ContextID: "ctx-1"}
+
+	withDeadline, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := llm.sendToAgent(withDeadline, run, "a", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := llm.sendToAgent(context.Background(), run, "a", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	expired, cancelExpired := context.WithCancel(context.Background())
+	cancelExpired()
+	if _, err := llm.sendToAgent(expired, run, "a", "hi"); err == nil {
+		t.Fatal("send after the deadline succeeded")
+	}
+
+	if len(caller.timeouts) != 2 {
+		t.Fatalf("made %d calls, want 2 (none once the deadline passed)", len(caller.timeouts))
+	}
+	if got := caller.timeouts[0]; got <= 1000 || got > 2000 {
+		t.Errorf("timeout under a 2s deadline = %dms", got)
+	}
+	if got, want := caller.timeouts[1], int(DefaultOrchestratorTimeout/time.Millisecond); got != want {
+		t.Errorf("timeout without a deadline = %dms, want %d", got, want)
+	}
+}
+
+func TestLLMOrchestratorHonorsTimeout(t *testing.T) {
+	// Every call hangs until its context ends
+	caller := &sendCaller{answer: func(ctx context.Context) (jsonrpc.Response, error) {
+		<-ctx.Done()
+		return jsonrpc.Response{}, ctx.Err()
+	}}
+	llm := NewLLMOrchestrator(caller, "", []string{"a", "b"}, []string{"router"}, 3)
+	llm.mu.Lock()
+	llm.descriptors = []agentDescriptor{{ID: "a"}, {ID: "b"}}
+	llm.descriptorsAt = time.Now()
+	llm.mu.Unlock()
+
+	started := time.Now()
+	_, _ = llm.Execute(types.ExecutionContext{
+		TaskID:      "task-1",
+		UserMessage: types.Message{Kind: "message", Role: "user", Parts: []types.Part{{Kind: "text", Text: "one\ntwo"}}},
+		Timeout:     300 * time.Millisecond,
+	})
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Fatalf("Execute took %s with a 300ms timeout", elapsed)
+	}
+}
+
+func TestParseRoutingTargets(t *testing.T) {
+	codex := []routingTarget{{AgentID: "codex", Message: "fix it"}}
+	tests := []struct {
+		name      string
+		reply     string
+		want      []routingTarget
+		wantNotes string
+		wantErr   bool
+	}{
+		{"bare plan", `{"targets":[{"agentId":"codex","message":"fix it"}],"notes":"n"}`, codex, "n", false},
+		{"fenced", "Here is the plan:\n```json\n{\"targets\":[{\"agentId\":\"codex\",\"message\":\"fix it\"}]}\n```\nGood luck.", codex, "", false},
+		{"untagged fence", "```\n[{\"agentId\":\"codex\",\"message\":\"fix it\"}]\n```", codex, "", false},
+		{"prose around a plan", `I think {"agentId":"codex","message":"fix it"} is best.`, codex, "", false},
+		{"fence wins over prose", "Try {\"agentId\":\"gemini\",\"message\":\"no\"} or\n```json\n{\"agentId\":\"codex\",\"message\":\"fix it\"}\n```", codex, "", false},
+		{"braces in prose are skipped", `Use {curly} style. {"routes":[{"agentId":"codex","message":"fix it"}]}`, codex, "", false},
+		{"empty plan keeps its notes", `{"targets":[],"notes":"nothing to do"}`, nil, "nothing to do", false},
+		{"no JSON", "I would ask codex.", nil, "", true},
+		{"JSON that isn't a plan", `{"answer": 42}`, nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, notes, err := parseRoutingTargets(tt.reply)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) || notes != tt.wantNotes {
+				t.Fatalf("parseRoutingTargets = %+v, %q; want %+v, %q", got, notes, tt.want, tt.wantNotes)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"agents-hub/internal/jsonrpc"
@@ -19,10 +20,12 @@ type RPCCaller interface {
 }
 
 type Orchestrator struct {
-	mu       sync.RWMutex
-	caller   RPCCaller
-	agentIDs []string
-	card     types.AgentCard
+	mu             sync.RWMutex
+	caller         RPCCaller
+	agentIDs       []string
+	card           types.AgentCard
+	routeUnhealthy atomic.Bool
+	health         healthCache
 }
 
 func NewOrchestrator(caller RPCCaller, baseURL string, agentIDs []string) *Orchestrator {
@@ -84,21 +87,17 @@ func (o *Orchestrator) Execute(ctx types.ExecutionContext) (types.ExecutionResul
 	callCtx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	results := make([]string, 0, len(parts))
+	delegates, notes := routableDelegates(o.Delegates(), o.health.get(callCtx, o.caller), o.routeUnhealthy.Load())
+	results := make([]delegateResult, 0, len(parts))
 	for i, part := range parts {
-		delegates := o.Delegates()
 		agentID := delegates[i%len(delegates)]
-		metadata := map[string]any{"targetAgent": agentID}
-		if strings.TrimSpace(ctx.WorkingDir) != "" {
-			metadata["workingDirectory"] = ctx.WorkingDir
-		}
 		msg := types.Message{
 			Kind:      "message",
 			MessageID: utils.NewID("msg"),
 			Role:      "user",
 			Parts:     []types.Part{{Kind: "text", Text: strings.TrimSpace(part)}},
 			ContextID: ctx.ContextID,
-			Metadata:  metadata,
+			Metadata:  delegateMetadata(o.ID(), ctx, agentID),
 		}
 		params, _ := json.Marshal(map[string]any{
 			"message": msg,
@@ -109,29 +108,22 @@ func (o *Orchestrator) Execute(ctx types.ExecutionContext) (types.ExecutionResul
 		})
 		resp, err := o.caller.Call(callCtx, "message/send", params)
 		if err != nil {
-			results = append(results, fmt.Sprintf("%s: error: %v", agentID, err))
+			results = append(results, delegateResult{AgentID: agentID, Error: err.Error()})
 			continue
 		}
 		if resp.Error != nil {
-			results = append(results, fmt.Sprintf("%s: error: %s", agentID, resp.Error.Message))
+			results = append(results, delegateResult{AgentID: agentID, Error: resp.Error.Message})
 			continue
 		}
 		task, err := decodeTask(resp.Result)
 		if err != nil {
-			results = append(results, fmt.Sprintf("%s: error: %v", agentID, err))
+			results = append(results, delegateResult{AgentID: agentID, Error: err.Error()})
 			continue
 		}
-		results = append(results, fmt.Sprintf("%s: %s", agentID, extractTaskText(task)))
+		results = append(results, delegateResult{AgentID: agentID, Text: extractTaskText(task)})
 	}
 
-	response := types.Message{
-		Kind:      "message",
-		MessageID: "resp-" + ctx.TaskID,
-		Role:      "agent",
-		Parts:     []types.Part{{Kind: "text", Text: strings.Join(results, "\n\n")}},
-		TaskID:    ctx.TaskID,
-		ContextID: ctx.ContextID,
-	}
+	response := orchestratedResponse(ctx, notes, results)
 	return types.ExecutionResult{
 		Task: types.Task{
 			Kind:      "task",
@@ -164,6 +156,61 @@ func (o *Orchestrator) ExecPath() string {
 	return "in-process"
 }
 
+// delegateMetadata is the metadata of a message an orchestrator sends to a delegate.
+// routedBy and parentTaskId link the delegate's task back to the orchestrator task.
+func delegateMetadata(orchestratorID string, ctx types.ExecutionContext, agentID string) map[string]any {
+	metadata := map[string]any{"targetAgent": agentID, "routedBy": orchestratorID}
+	if ctx.TaskID != "" {
+		metadata["parentTaskId"] = ctx.TaskID
+	}
+	if strings.TrimSpace(ctx.WorkingDir) != "" {
+		metadata["workingDirectory"] = ctx.WorkingDir
+	}
+	return metadata
+}
+
+// ResultFormatStructured, as message metadata "resultFormat" (or message/send's
+// configuration.resultFormat), makes an orchestrator answer with a data part listing
+// each delegate's result instead of one "<agentId>: <text>" string.
+const ResultFormatStructured = "structured"
+
+// delegateResult is one delegate's answer in an orchestrated response
+type delegateResult struct {
+	AgentID string `json:"agentId"`
+	Text    string `json:"text"`
+	Error   string `json:"error,omitempty"`
+}
+
+// orchestratedResponse builds the orchestrator's reply: routing notes and delegate
+// results joined into one text part, or a {"results", "notes"} data part when the
+// caller asked for structured results
+func orchestratedResponse(ctx types.ExecutionContext, notes []string, results []delegateResult) types.Message {
+	response := types.Message{
+		Kind:      "message",
+		MessageID: "resp-" + ctx.TaskID,
+		Role:      "agent",
+		TaskID:    ctx.TaskID,
+		ContextID: ctx.ContextID,
+	}
+	if format, _ := ctx.UserMessage.Metadata["resultFormat"].(string); format == ResultFormatStructured {
+		if notes == nil {
+			notes = []string{}
+		}
+		response.Parts = []types.Part{{Kind: "data", Data: map[string]any{"results": results, "notes": notes}}}
+		return response
+	}
+	lines := append([]string{}, notes...)
+	for _, result := range results {
+		if result.Error != "" {
+			lines = append(lines, fmt.Sprintf("%s: error: %s", result.AgentID, result.Error))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", result.AgentID, result.Text))
+	}
+	response.Parts = []types.Part{{Kind: "text", Text: strings.Join(lines, "\n\n")}}
+	return response
+}
+
 func extractMessageText(msg types.Message) string {
 	parts := make([]string, 0, len(msg.Parts))
 	for _, part := range msg.Parts {
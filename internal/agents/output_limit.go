@@ -0,0 +1,81 @@
+package agents
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+)
+
+// approxBytesPerToken turns a token limit into an output byte cap for CLIs without a native limit
+const approxBytesPerToken = 4
+
+// SetMaxOutputTokens limits how much output a run may produce; 0 removes the limit
+func (a *CLIAgent) SetMaxOutputTokens(n int) {
+	if n < 0 {
+		n = 0
+	}
+	a.maxOutputTokens.Store(int64(n))
+}
+
+// MaxOutputTokens returns the configured output token limit (0 = unlimited)
+func (a *CLIAgent) MaxOutputTokens() int {
+	return int(a.maxOutputTokens.Load())
+}
+
+// outputByteCap is the byte cap enforced by the hub when the CLI cannot enforce the limit itself
+func (a *CLIAgent) outputByteCap() int {
+	limit := a.MaxOutputTokens()
+	if limit == 0 || a.config.MaxTokensEnv != "" || a.config.MaxTokensFlag {
+		return 0
+	}
+	return limit * approxBytesPerToken
+}
+
+// applyOutputLimit passes the limit to CLIs that read it from the environment
+func (a *CLIAgent) applyOutputLimit(command *exec.Cmd) {
+	limit := a.MaxOutputTokens()
+	if limit == 0 || a.config.MaxTokensEnv == "" {
+		return
+	}
+	command.Env = append(command.Environ(), fmt.Sprintf("%s=%d", a.config.MaxTokensEnv, limit))
+}
+
+func truncationNotice(limit int) string {
+	return fmt.Sprintf("[output truncated at %d bytes by the max-tokens limit]", limit)
+}
+
+// cappedBuffer collects command output up to limit bytes, then calls onLimit
+// (typically the command's cancel func) and discards the rest.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	onLimit   func()
+	truncated atomic.Bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.limit <= 0 {
+		return b.buf.Write(p)
+	}
+	if b.truncated.Load() {
+		return len(p), nil
+	}
+	if remaining := b.limit - b.buf.Len(); len(p) > remaining {
+		b.buf.Write(p[:remaining])
+		b.truncated.Store(true)
+		b.onLimit()
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// Text returns the trimmed output, noting when it was cut short
+func (b *cappedBuffer) Text() string {
+	text := strings.TrimSpace(strings.ToValidUTF8(b.buf.String(), ""))
+	if b.truncated.Load() {
+		text += "\n\n" + truncationNotice(b.limit)
+	}
+	return text
+}
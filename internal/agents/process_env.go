@@ -0,0 +1,34 @@
+package agents
+
+import (
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// SetEnv sets extra environment variables for the agent's process launches; nil clears them
+func (a *CLIAgent) SetEnv(env map[string]string) {
+	entries := make([]string, 0, len(env))
+	for key, value := range env {
+		entries = append(entries, key+"="+value)
+	}
+	sort.Strings(entries)
+	a.env.Store(&entries)
+}
+
+// hubSecretEnv lists the hub's own secrets, which are not passed on to agent processes
+var hubSecretEnv = []string{"HUB_HTTP_TOKEN"}
+
+// applyEnv appends the agent's extra variables to the inherited environment, minus the
+// hub's secrets. An agent that needs one can still be given it with SetEnv.
+func (a *CLIAgent) applyEnv(command *exec.Cmd) {
+	inherited := slices.DeleteFunc(command.Environ(), func(entry string) bool {
+		key, _, _ := strings.Cut(entry, "=")
+		return slices.Contains(hubSecretEnv, key)
+	})
+	if env := a.env.Load(); env != nil {
+		inherited = append(inherited, *env...)
+	}
+	command.Env = inherited
+}
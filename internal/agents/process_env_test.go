@@ -0,0 +1,40 @@
+package agents
+
+import (
+	"os/exec"
+	"slices"
+	"testing"
+)
+
+func TestApplyEnvDropsHubSecrets(t *testing.T) {
+	t.Setenv("HUB_HTTP_TOKEN", "secret")
+	t.Setenv("AGENT_TEST_INHERITED", "kept")
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    []string
+		notWant []string
+	}{
+		{"inherited", nil, []string{"AGENT_TEST_INHERITED=kept"}, []string{"HUB_HTTP_TOKEN=secret"}},
+		{"agent variables", map[string]string{"API_KEY": "x"}, []string{"AGENT_TEST_INHERITED=kept", "API_KEY=x"}, []string{"HUB_HTTP_TOKEN=secret"}},
+		{"set for the agent on purpose", map[string]string{"HUB_HTTP_TOKEN": "given"}, []string{"HUB_HTTP_TOKEN=given"}, []string{"HUB_HTTP_TOKEN=secret"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := NewCLIAgent(CLIConfig{AgentID: "test", Exec: "true"})
+			agent.SetEnv(tt.env)
+			command := exec.Command("true")
+			agent.applyEnv(command)
+			for _, entry := range tt.want {
+				if !slices.Contains(command.Env, entry) {
+					t.Errorf("environment is missing %s", entry)
+				}
+			}
+			for _, entry := range tt.notWant {
+				if slices.Contains(command.Env, entry) {
+					t.Errorf("environment has %s", entry)
+				}
+			}
+		})
+	}
+}
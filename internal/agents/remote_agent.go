@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"reflect"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"agents-hub/internal/types"
@@ -17,12 +19,15 @@ import (
 
 // RemoteAgent wraps an external A2A agent
 type RemoteAgent struct {
-	id       string
-	name     string
-	cardURL  string
-	card     *sdka2a.AgentCard
-	client   *a2aclient.Client
-	alias    string
+	id      string
+	name    string
+	cardURL string
+	cardMu  sync.RWMutex
+	card    *sdka2a.AgentCard // replaced by RefreshCard
+	client  *a2aclient.Client
+	alias   string
+	credMu  sync.RWMutex
+	token   string
 }
 
 // NewRemoteAgent creates a remote agent from an A2A agent card URL
@@ -46,14 +51,77 @@ func NewRemoteAgent(ctx context.Context, cardURL string, alias string) (*RemoteA
 		id = "remote-" + sanitizeID(name)
 	}
 
-	return &RemoteAgent{
+	agent := &RemoteAgent{
 		id:      id,
 		name:    name,
 		cardURL: cardURL,
 		card:    card,
 		client:  client,
 		alias:   alias,
-	}, nil
+	}
+	client.AddCallInterceptor(remoteAuthInterceptor{agent: agent})
+	return agent, nil
+}
+
+// SetBearerToken sets the credential attached to outgoing requests (empty clears it)
+func (a *RemoteAgent) SetBearerToken(token string) {
+	a.credMu.Lock()
+	defer a.credMu.Unlock()
+	a.token = strings.TrimSpace(token)
+}
+
+// HasCredentials reports whether a credential is configured for this agent
+func (a *RemoteAgent) HasCredentials() bool {
+	a.credMu.RLock()
+	defer a.credMu.RUnlock()
+	return a.token != ""
+}
+
+// SecuritySchemes returns the names of the security schemes declared by the agent card
+func (a *RemoteAgent) SecuritySchemes() []string {
+	card := a.currentCard()
+	if card == nil {
+		return nil
+	}
+	names := make([]string, 0, len(card.SecuritySchemes))
+	for name := range card.SecuritySchemes {
+		names = append(names, string(name))
+	}
+	return names
+}
+
+func (a *RemoteAgent) bearerToken() string {
+	a.credMu.RLock()
+	defer a.credMu.RUnlock()
+	return a.token
+}
+
+// remoteAuthInterceptor attaches the configured credential to every SDK request,
+// placing it where the card's security scheme expects it.
+type remoteAuthInterceptor struct {
+	a2aclient.PassthroughInterceptor
+	agent *RemoteAgent
+}
+
+func (i remoteAuthInterceptor) Before(ctx context.Context, req *a2aclient.Request) (context.Context, error) {
+	token := i.agent.bearerToken()
+	if token == "" {
+		return ctx, nil
+	}
+	card := req.Card
+	if card == nil {
+		card = i.agent.currentCard()
+	}
+	if card != nil {
+		for _, scheme := range card.SecuritySchemes {
+			if apiKey, ok := scheme.(sdka2a.APIKeySecurityScheme); ok && apiKey.In == sdka2a.APIKeySecuritySchemeInHeader && apiKey.Name != "" {
+				req.Meta[apiKey.Name] = []string{token}
+				return ctx, nil
+			}
+		}
+	}
+	req.Meta["Authorization"] = []string{"Bearer " + token}
+	return ctx, nil
 }
 
 // ID returns the agent's unique identifier
@@ -81,10 +149,32 @@ func (a *RemoteAgent) Shutdown() error {
 
 // GetCard returns the agent's card
 func (a *RemoteAgent) GetCard() (types.AgentCard, error) {
-	if a.card == nil {
+	card := a.currentCard()
+	if card == nil {
 		return types.AgentCard{}, fmt.Errorf("agent card not available")
 	}
-	return fromSDKAgentCard(a.card), nil
+	return fromSDKAgentCard(card), nil
+}
+
+// RefreshCard re-fetches the card from the agent's card URL and replaces the cached
+// one, reporting whether it changed. Calls keep going to the endpoint the agent was
+// registered with.
+func (a *RemoteAgent) RefreshCard(ctx context.Context) (bool, error) {
+	card, err := fetchAgentCard(ctx, a.cardURL)
+	if err != nil {
+		return false, err
+	}
+	a.cardMu.Lock()
+	defer a.cardMu.Unlock()
+	changed := !reflect.DeepEqual(a.card, card)
+	a.card = card
+	return changed, nil
+}
+
+func (a *RemoteAgent) currentCard() *sdka2a.AgentCard {
+	a.cardMu.RLock()
+	defer a.cardMu.RUnlock()
+	return a.card
 }
 
 // GetCapabilities returns the agent's runtime capabilities
@@ -97,13 +187,13 @@ func (a *RemoteAgent) GetCapabilities() types.RuntimeCapabilities {
 		SupportedOutputModes: []string{"text/plain"},
 	}
 
-	if a.card != nil {
-		caps.SupportsStreaming = a.card.Capabilities.Streaming
-		if len(a.card.DefaultInputModes) > 0 {
-			caps.SupportedInputModes = a.card.DefaultInputModes
+	if card := a.currentCard(); card != nil {
+		caps.SupportsStreaming = card.Capabilities.Streaming
+		if len(card.DefaultInputModes) > 0 {
+			caps.SupportedInputModes = card.DefaultInputModes
 		}
-		if len(a.card.DefaultOutputModes) > 0 {
-			caps.SupportedOutputModes = a.card.DefaultOutputModes
+		if len(card.DefaultOutputModes) > 0 {
+			caps.SupportedOutputModes = card.DefaultOutputModes
 		}
 	}
 
@@ -399,6 +489,11 @@ func fromSDKAgentCard(card *sdka2a.AgentCard) types.AgentCard {
 			URL:  card.Provider.URL,
 		}
 	}
+	if len(card.SecuritySchemes) > 0 {
+		if data, err := json.Marshal(card.SecuritySchemes); err == nil {
+			_ = json.Unmarshal(data, &result.SecuritySchemes)
+		}
+	}
 	if len(card.Skills) > 0 {
 		result.Skills = make([]types.Skill, len(card.Skills))
 		for i, skill := range card.Skills {
@@ -0,0 +1,83 @@
+// Command fakeagent is a deterministic stand-in for an agent CLI. Point an agent's
+// exec override at it (CLAUDE_CMD, GEMINI_CMD, ...) to exercise the hub end to end
+// without a real backend:
+//
+//	go build -o /tmp/fakeagent ./internal/agents/testdata/fakeagent
+//	CLAUDE_CMD=/tmp/fakeagent ./agents-hub tui --once claude "ASK deploy"
+//
+// The prompt is the argument after -p, --prompt or exec (otherwise the first
+// argument). Its first word picks the behaviour:
+//
+//	ASK <text>      print a [y/N] prompt, read one line of input and echo it
+//	SLEEP <dur>     sleep for the duration (e.g. 2s) before answering, for timeouts
+//	FAIL <text>     write text to stderr and exit 2
+//	LINES <n>       print n numbered lines
+//	LONG <n>        print one line of n bytes with no newline until the end
+//	BINARY          print a line of non-UTF-8 bytes
+//	LOGIN           print a "Please run /login" prompt and block for a minute
+//
+// Anything else is echoed back as "echo: <prompt>".
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func main() {
+	args := os.Args[1:]
+	if len(args) == 1 && (args[0] == "--version" || args[0] == "--help") {
+		fmt.Println("fakeagent 1.0.0")
+		return
+	}
+	prompt := promptArg(args)
+	command, rest, _ := strings.Cut(strings.TrimSpace(prompt), " ")
+	switch strings.ToUpper(command) {
+	case "ASK":
+		fmt.Printf("%s - proceed? [y/N] \n", rest)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		fmt.Println("answer: " + strings.TrimSpace(answer))
+	case "SLEEP":
+		d, err := time.ParseDuration(rest)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(2)
+		}
+		time.Sleep(d)
+		fmt.Println("slept " + d.String())
+	case "FAIL":
+		fmt.Fprintln(os.Stderr, rest)
+		os.Exit(2)
+	case "LINES":
+		n, _ := strconv.Atoi(rest)
+		for i := 1; i <= n; i++ {
+			fmt.Printf("line %d\n", i)
+		}
+	case "LONG":
+		n, _ := strconv.Atoi(rest)
+		fmt.Println(strings.Repeat("x", n))
+	case "BINARY":
+		os.Stdout.Write([]byte{0x00, 0x01, 0xfe, 0xff, '\n'})
+	case "LOGIN":
+		fmt.Print("Invalid API key · Please run /login ")
+		time.Sleep(time.Minute)
+	default:
+		fmt.Println("echo: " + prompt)
+	}
+}
+
+func promptArg(args []string) string {
+	for i, arg := range args {
+		if (arg == "-p" || arg == "--prompt" || arg == "exec") && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	if len(args) > 0 {
+		return args[0]
+	}
+	return ""
+}
@@ -2,6 +2,7 @@ package agents
 
 import (
 	"strings"
+	"sync/atomic"
 
 	"agents-hub/internal/types"
 )
@@ -11,7 +12,7 @@ import (
 // See: https://github.com/mistralai/mistral-vibe
 type VibeAgent struct {
 	*CLIAgent
-	defaultConfig types.VibeConfig
+	defaultConfig atomic.Pointer[types.VibeConfig]
 }
 
 // NewVibeAgent creates a new Vibe agent with skills and capabilities
@@ -35,18 +36,19 @@ func NewVibeAgent(baseURL string) *VibeAgent {
 		HealthArgs:     []string{"--help"},
 		Args:           []string{"--prompt", "{prompt}"},
 		Card:           card,
+		DataParts:      resolveDataParts("VIBE_DATA_PARTS"),
 		PromptPatterns: vibePromptPatterns(),
+		AuthPatterns:   resolveAuthPatterns(nil, "VIBE_AUTH_PATTERN"),
 	})
 
-	return &VibeAgent{
-		CLIAgent:      cliAgent,
-		defaultConfig: types.VibeConfig{NonInteractive: true},
-	}
+	agent := &VibeAgent{CLIAgent: cliAgent}
+	agent.defaultConfig.Store(&types.VibeConfig{NonInteractive: true})
+	return agent
 }
 
 // SetDefaultConfig sets the default configuration for this agent
 func (a *VibeAgent) SetDefaultConfig(config types.VibeConfig) {
-	a.defaultConfig = config
+	a.defaultConfig.Store(&config)
 }
 
 // Execute runs Vibe with dynamic arguments based on config
@@ -67,7 +69,7 @@ func (a *VibeAgent) Execute(ctx types.ExecutionContext) (types.ExecutionResult,
 // extractVibeConfig gets VibeConfig from execution context metadata or defaults
 func (a *VibeAgent) extractVibeConfig(ctx types.ExecutionContext) types.VibeConfig {
 	// Start with default config
-	config := a.defaultConfig
+	config := *a.defaultConfig.Load()
 
 	// Check if config is passed in message metadata
 	if ctx.UserMessage.Metadata != nil {
@@ -105,7 +107,7 @@ func (a *VibeAgent) withVibePrompt(ctx types.ExecutionContext, config types.Vibe
 		Kind:      "message",
 		MessageID: ctx.UserMessage.MessageID,
 		Role:      ctx.UserMessage.Role,
-		Parts:     []types.Part{{Kind: "text", Text: prompt}},
+		Parts:     append([]types.Part{{Kind: "text", Text: prompt}}, dataParts(ctx.UserMessage)...),
 		TaskID:    ctx.TaskID,
 		ContextID: ctx.ContextID,
 		Metadata:  ctx.UserMessage.Metadata,
@@ -116,7 +118,7 @@ func (a *VibeAgent) withVibePrompt(ctx types.ExecutionContext, config types.Vibe
 // buildPrompt constructs the full prompt including system prompt and history
 func (a *VibeAgent) buildPrompt(ctx types.ExecutionContext, config types.VibeConfig) string {
 	userPrompt := strings.TrimSpace(extractPrompt(ctx.UserMessage))
-	if userPrompt == "" {
+	if userPrompt == "" && len(dataParts(ctx.UserMessage)) == 0 {
 		return ""
 	}
 
@@ -133,7 +135,9 @@ func (a *VibeAgent) buildPrompt(ctx types.ExecutionContext, config types.VibeCon
 		sections = append(sections, a.formatHistory(ctx.PreviousHistory))
 	}
 
-	sections = append(sections, userPrompt)
+	if userPrompt != "" {
+		sections = append(sections, userPrompt)
+	}
 	return strings.Join(sections, "\n\n")
 }
 
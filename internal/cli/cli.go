@@ -20,9 +20,11 @@ import (
 	internala2a "agents-hub/internal/a2a"
 	"agents-hub/internal/hub"
 	"agents-hub/internal/jsonrpc"
+	"agents-hub/internal/taskfmt"
 	"agents-hub/internal/transport"
 	"agents-hub/internal/types"
 	"agents-hub/internal/utils"
+	"agents-hub/internal/version"
 
 	sdka2a "github.com/a2aproject/a2a-go/a2a"
 	"github.com/a2aproject/a2a-go/a2aclient"
@@ -34,6 +36,10 @@ func Run() int {
 	}
 
 	cmd := os.Args[1]
+	if cmd == "--version" || cmd == "-version" {
+		fmt.Println("agents-hub " + version.Get().String())
+		return 0
+	}
 	if strings.HasPrefix(cmd, "-") {
 		return runTUI(os.Args[1:])
 	}
@@ -50,6 +56,8 @@ func Run() int {
 		return runSend(os.Args[2:])
 	case "tasks":
 		return runTasks(os.Args[2:])
+	case "contexts":
+		return runContexts(os.Args[2:])
 	case "tui":
 		return runTUI(os.Args[2:])
 	default:
@@ -60,34 +68,37 @@ func Run() int {
 
 func usage() {
 	fmt.Println("agents-hub <command> [options]")
-	fmt.Println("Commands: start, stop, status, agents, send, tasks, tui")
+	fmt.Println("Commands: start, stop, status, agents, send, tasks [get|cancel <id>|deadletters], contexts [list|get <id>], tui")
 }
 
 func runStart(args []string) int {
 	fs := flag.NewFlagSet("start", flag.ContinueOnError)
 	foreground := fs.Bool("foreground", false, "run in foreground")
-	httpPort := fs.Int("http-port", 8080, "http port")
-	noHTTP := fs.Bool("no-http", false, "disable http")
-	socketPath := fs.String("socket", "/tmp/a2a-hub.sock", "unix socket path")
-	verbose := fs.Bool("verbose", false, "debug logging")
-	orchestratorAgents := fs.String("orchestrator-agents", "", "comma-separated agent IDs for orchestrator")
-	orchestratorRouter := fs.String("orchestrator-router", "", "agent ID for LLM orchestrator routing")
+	printConfig := fs.Bool("print-config", false, "print the resolved configuration as JSON and exit")
+	flags := registerHubFlags(fs)
 	if err := fs.Parse(args); err != nil {
 		return 1
 	}
 	_ = foreground
 
-	cfg := hub.DefaultConfig()
-	cfg.Socket.Path = *socketPath
-	cfg.HTTP.Port = *httpPort
-	cfg.HTTP.Enabled = !*noHTTP
-	cfg.Orchestrator.Agents = resolveOrchestratorAgents(*orchestratorAgents)
-	cfg.Orchestrator.RouterAgent = resolveOrchestratorRouter(*orchestratorRouter)
-	if *verbose {
-		cfg.Logging.Level = "debug"
+	cfg, err := flags.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		return 1
+	}
+	if *printConfig {
+		printEffectiveConfig(cfg, flags.configPath())
+	}
+	if err := cfg.Validate(); err != nil {
+		printConfigErrors(err)
+		return 1
+	}
+	if *printConfig {
+		return 0
 	}
 
 	logger := utils.NewLogger(cfg.Logging.Level)
+	logger.SetFormat(cfg.Logging.Format)
 	setHubEnv(cfg)
 	server := hub.NewServer(cfg, logger)
 	server.RegisterHandlers()
@@ -103,6 +114,7 @@ func runStart(args []string) int {
 	ctx, cancel := contextWithSignals()
 	defer cancel()
 	server.Registry().StartHealthChecks(30 * time.Second)
+	server.StartTaskPruning(ctx, 30*time.Second)
 
 	if cfg.Socket.Enabled {
 		unixTransport := transport.NewUnixTransport(cfg, server, logger)
@@ -122,7 +134,9 @@ func runStart(args []string) int {
 	}
 
 	<-ctx.Done()
+	server.StopTaskQueue()
 	server.Registry().Stop()
+	server.FlushState()
 	server.RemovePid()
 	return 0
 }
@@ -153,12 +167,17 @@ func runStop(args []string) int {
 
 func runStatus(args []string) int {
 	fs := flag.NewFlagSet("status", flag.ContinueOnError)
-	format := fs.String("format", "pretty", "output format: json|pretty")
+	format := formatFlag(fs)
 	socketPath := fs.String("socket", "/tmp/a2a-hub.sock", "unix socket path")
+	verbose := fs.Bool("verbose", false, "include per-agent health, latency and registration details")
 	if err := fs.Parse(args); err != nil {
 		return 1
 	}
-	resp, err := sendRPCUnix(*socketPath, jsonrpc.Request{JSONRPC: "2.0", Method: "hub/status", Params: nil, ID: "1"})
+	var params json.RawMessage
+	if *verbose {
+		params, _ = json.Marshal(map[string]any{"includeAgents": true})
+	}
+	resp, err := sendRPCUnix(*socketPath, jsonrpc.Request{JSONRPC: "2.0", Method: "hub/status", Params: params})
 	if err != nil {
 		fmt.Println("hub not responding")
 		return 1
@@ -169,14 +188,17 @@ func runStatus(args []string) int {
 
 func runAgents(args []string) int {
 	fs := flag.NewFlagSet("agents", flag.ContinueOnError)
-	format := fs.String("format", "pretty", "output format: json|pretty")
+	format := formatFlag(fs)
 	socketPath := fs.String("socket", "/tmp/a2a-hub.sock", "unix socket path")
 	withHealth := fs.Bool("health", false, "include health")
+	sortBy := fs.String("sort", "", "sort by id|name|health|latency (default registration order)")
+	status := fs.String("status", "", "only agents with this health: healthy|degraded|unhealthy|unknown")
+	agentType := fs.String("type", "", "only agents of this type: local|remote|orchestrator")
 	if err := fs.Parse(args); err != nil {
 		return 1
 	}
-	params, _ := json.Marshal(map[string]any{"includeHealth": *withHealth})
-	resp, err := sendRPCUnix(*socketPath, jsonrpc.Request{JSONRPC: "2.0", Method: "hub/agents/list", Params: params, ID: "1"})
+	params, _ := json.Marshal(map[string]any{"includeHealth": *withHealth, "sortBy": *sortBy, "status": *status, "type": *agentType})
+	resp, err := sendRPCUnix(*socketPath, jsonrpc.Request{JSONRPC: "2.0", Method: "hub/agents/list", Params: params})
 	if err != nil {
 		fmt.Println("hub not responding")
 		return 1
@@ -187,37 +209,73 @@ func runAgents(args []string) int {
 
 func runSend(args []string) int {
 	fs := flag.NewFlagSet("send", flag.ContinueOnError)
-	format := fs.String("format", "pretty", "output format: json|pretty")
+	format := formatFlag(fs)
 	socketPath := fs.String("socket", "/tmp/a2a-hub.sock", "unix socket path")
-	contextID := fs.String("context", "", "context id")
+	contextID := fs.String("context", "", "continue this context's conversation; a new id starts one")
 	timeoutMs := fs.Int("timeout", 0, "timeout ms")
+	retries := fs.Int("retries", 0, "retry a failed send up to n times (sends over the socket)")
+	async := fs.Bool("async", false, "queue the task and print it without waiting; check it with tasks get (sends over the socket)")
+	verbose := fs.Bool("verbose", false, "debug logging")
+	fromStdin := fs.Bool("stdin", false, "read the prompt from stdin, after the message if one is given")
+	var files fileList
+	fs.Var(&files, "file", "attach a file (repeatable)")
 	if err := fs.Parse(args); err != nil {
 		return 1
 	}
-	if fs.NArg() < 2 {
-		fmt.Println("usage: agents-hub send <agent-id> \"message\"")
+	logLevel := "info"
+	if *verbose {
+		logLevel = "debug"
+	}
+	logger := utils.NewLogger(logLevel)
+	if fs.NArg() < 2 && (fs.NArg() < 1 || (len(files) == 0 && !*fromStdin)) {
+		fmt.Println("usage: agents-hub send [--file path]... [--stdin] <agent-id> \"message\"|-")
+		return 1
+	}
+	text, err := readPrompt(fs.Arg(1), *fromStdin, os.Stdin)
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+	var parts []types.Part
+	if text != "" {
+		parts = append(parts, types.Part{Kind: "text", Text: text})
+	}
+	for _, path := range files {
+		part, err := filePart(path)
+		if err != nil {
+			fmt.Println(err.Error())
+			return 1
+		}
+		parts = append(parts, part)
+	}
+	agentID, err := resolveSendAgent(*socketPath, fs.Arg(0))
+	if err != nil {
+		fmt.Println(err.Error())
 		return 1
 	}
-	agentID := fs.Arg(0)
-	messageText := fs.Arg(1)
+	if agentID != fs.Arg(0) {
+		fmt.Fprintf(os.Stderr, "using agent %s\n", agentID)
+	}
 
-	if baseURL := resolveA2ABaseURL(); baseURL != "" {
-		resp, err := sendA2A(context.Background(), baseURL, agentID, messageText, *contextID, *timeoutMs)
+	// Retries and async are message/send options, so they skip the A2A endpoint
+	if baseURL := resolveA2ABaseURL(); baseURL != "" && *retries == 0 && !*async {
+		resp, err := sendA2A(context.Background(), baseURL, agentID, parts, *contextID, *timeoutMs)
 		if err == nil {
-			printResponse(resp, *format)
+			printTask(resp, *format)
 			return 0
 		}
-		if !isA2ATransportError(err) {
+		if !errors.Is(err, errA2AUnavailable) && !isA2ATransportError(err) {
 			fmt.Println(err.Error())
 			return 1
 		}
+		logger.Debugf("A2A send failed, falling back to unix socket: %v", err)
 	}
 
 	msg := types.Message{
 		Kind:      "message",
 		MessageID: "msg-" + fmt.Sprint(time.Now().UnixNano()),
 		Role:      "user",
-		Parts:     []types.Part{{Kind: "text", Text: messageText}},
+		Parts:     parts,
 		ContextID: *contextID,
 		Metadata:  map[string]any{"targetAgent": agentID},
 	}
@@ -226,29 +284,103 @@ func runSend(args []string) int {
 	}
 	params, _ := json.Marshal(map[string]any{
 		"message":       msg,
-		"configuration": map[string]any{"historyLength": 10, "timeout": *timeoutMs},
+		"configuration": map[string]any{"historyLength": 10, "timeout": *timeoutMs, "retries": *retries, "blocking": !*async},
 	})
-	resp, err := sendRPCUnix(*socketPath, jsonrpc.Request{JSONRPC: "2.0", Method: "message/send", Params: params, ID: "1"})
+	done := make(chan struct{})
+	defer close(done)
+	go cancelSendOnInterrupt(*socketPath, msg.MessageID, done)
+	resp, err := sendRPCUnix(*socketPath, jsonrpc.Request{JSONRPC: "2.0", Method: "message/send", Params: params})
 	if err != nil {
 		fmt.Println("hub not responding")
 		return 1
 	}
-	printResponse(resp, *format)
+	printTask(resp, *format)
 	return 0
 }
 
+// cancelSendOnInterrupt turns the first Ctrl-C during a send into a tasks/cancel
+// on a second connection, so the hub stops the agent and the pending send returns
+// the canceled task. A second Ctrl-C exits immediately.
+func cancelSendOnInterrupt(socketPath, messageID string, done <-chan struct{}) {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	select {
+	case <-done:
+		return
+	case <-interrupt:
+	}
+	signal.Stop(interrupt)
+	fmt.Fprintln(os.Stderr, "canceling...")
+	params, _ := json.Marshal(map[string]string{"messageId": messageID})
+	resp, err := sendRPCUnix(socketPath, jsonrpc.Request{JSONRPC: "2.0", Method: "tasks/cancel", Params: params})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cancel failed: %v\n", err)
+		return
+	}
+	if resp.Error != nil {
+		fmt.Fprintf(os.Stderr, "cancel failed: %s\n", resp.Error.Message)
+	}
+}
+
+// resolveSendAgent expands an abbreviated agent name ("claude" -> "claude-code") against
+// the hub's agent list. When the list can't be fetched the name is used as given.
+func resolveSendAgent(socketPath, name string) (string, error) {
+	resp, err := sendRPCUnix(socketPath, jsonrpc.Request{JSONRPC: "2.0", Method: "hub/agents/list", Params: json.RawMessage(`{}`)})
+	if err != nil || resp.Error != nil {
+		return name, nil
+	}
+	raw, err := json.Marshal(resp.Result)
+	if err != nil {
+		return name, nil
+	}
+	var agents []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(raw, &agents); err != nil {
+		return name, nil
+	}
+	ids := make([]string, 0, len(agents))
+	for _, agent := range agents {
+		if agent.ID == name {
+			return name, nil
+		}
+		ids = append(ids, agent.ID)
+	}
+	return hub.MatchAgentID(name, ids)
+}
+
+// Exit codes for task subcommands, distinct so scripts can react to them
+const (
+	exitTaskNotFound      = 3
+	exitTaskNotCancelable = 4
+)
+
 func runTasks(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "get":
+			return runTaskCommand("get", "tasks/get", args[1:])
+		case "cancel":
+			return runTaskCommand("cancel", "tasks/cancel", args[1:])
+		case "deadletters":
+			return runDeadLetters(args[1:])
+		}
+	}
 	fs := flag.NewFlagSet("tasks", flag.ContinueOnError)
-	format := fs.String("format", "pretty", "output format: json|pretty")
+	format := formatFlag(fs)
 	socketPath := fs.String("socket", "/tmp/a2a-hub.sock", "unix socket path")
 	contextID := fs.String("context", "", "context id")
+	parentTaskID := fs.String("parent", "", "only sub-tasks an orchestrator task delegated")
 	state := fs.String("state", "", "task state")
+	since := fs.String("since", "", "only tasks updated since this RFC3339 time or duration ago (e.g. 1h)")
+	until := fs.String("until", "", "only tasks updated before this RFC3339 time or duration ago")
 	limit := fs.Int("limit", 20, "limit")
 	if err := fs.Parse(args); err != nil {
 		return 1
 	}
-	params, _ := json.Marshal(map[string]any{"contextId": *contextID, "state": *state, "limit": *limit, "offset": 0})
-	resp, err := sendRPCUnix(*socketPath, jsonrpc.Request{JSONRPC: "2.0", Method: "hub/tasks/list", Params: params, ID: "1"})
+	params, _ := json.Marshal(map[string]any{"contextId": *contextID, "parentTaskId": *parentTaskID, "state": *state, "since": *since, "until": *until, "limit": *limit, "offset": 0})
+	resp, err := sendRPCUnix(*socketPath, jsonrpc.Request{JSONRPC: "2.0", Method: "hub/tasks/list", Params: params})
 	if err != nil {
 		fmt.Println("hub not responding")
 		return 1
@@ -257,6 +389,127 @@ func runTasks(args []string) int {
 	return 0
 }
 
+// Exit code for contexts get on an unknown id
+const exitContextNotFound = 3
+
+func runContexts(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			args = args[1:]
+		case "get":
+			return runContextGet(args[1:])
+		}
+	}
+	fs := flag.NewFlagSet("contexts", flag.ContinueOnError)
+	format := formatFlag(fs)
+	socketPath := fs.String("socket", "/tmp/a2a-hub.sock", "unix socket path")
+	limit := fs.Int("limit", 20, "limit (0 for all)")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	params, _ := json.Marshal(map[string]any{"limit": *limit})
+	resp, err := sendRPCUnix(*socketPath, jsonrpc.Request{JSONRPC: "2.0", Method: "hub/contexts/list", Params: params})
+	if err != nil {
+		fmt.Println("hub not responding")
+		return 1
+	}
+	printResponse(resp, *format)
+	return 0
+}
+
+func runContextGet(args []string) int {
+	fs := flag.NewFlagSet("contexts get", flag.ContinueOnError)
+	format := formatFlag(fs)
+	socketPath := fs.String("socket", "/tmp/a2a-hub.sock", "unix socket path")
+	historyLength := fs.Int("history", 0, "only the last n messages (0 for all)")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	contextID := fs.Arg(0)
+	// Allow flags after the context id as well
+	if fs.NArg() > 1 {
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return 1
+		}
+	}
+	if strings.TrimSpace(contextID) == "" {
+		fmt.Println("usage: agents-hub contexts get [--history n] <context-id>")
+		return 1
+	}
+	params, _ := json.Marshal(map[string]any{"id": contextID, "historyLength": *historyLength})
+	resp, err := sendRPCUnix(*socketPath, jsonrpc.Request{JSONRPC: "2.0", Method: "hub/contexts/get", Params: params})
+	if err != nil {
+		fmt.Println("hub not responding")
+		return 1
+	}
+	printResponse(resp, *format)
+	if resp.Error != nil {
+		if resp.Error.Code == jsonrpc.ErrContextNotFound {
+			return exitContextNotFound
+		}
+		return 1
+	}
+	return 0
+}
+
+func runDeadLetters(args []string) int {
+	fs := flag.NewFlagSet("tasks deadletters", flag.ContinueOnError)
+	format := formatFlag(fs)
+	socketPath := fs.String("socket", "/tmp/a2a-hub.sock", "unix socket path")
+	agentID := fs.String("agent", "", "only this agent's dead letters")
+	limit := fs.Int("limit", 20, "limit (0 for all)")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	params, _ := json.Marshal(map[string]any{"agentId": *agentID, "limit": *limit})
+	resp, err := sendRPCUnix(*socketPath, jsonrpc.Request{JSONRPC: "2.0", Method: "hub/tasks/deadletters", Params: params})
+	if err != nil {
+		fmt.Println("hub not responding")
+		return 1
+	}
+	printResponse(resp, *format)
+	return 0
+}
+
+func runTaskCommand(name, method string, args []string) int {
+	fs := flag.NewFlagSet("tasks "+name, flag.ContinueOnError)
+	format := formatFlag(fs)
+	socketPath := fs.String("socket", "/tmp/a2a-hub.sock", "unix socket path")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	taskID := fs.Arg(0)
+	// Allow flags after the task id as well
+	if fs.NArg() > 1 {
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return 1
+		}
+	}
+	if strings.TrimSpace(taskID) == "" {
+		fmt.Printf("usage: agents-hub tasks %s <task-id>\n", name)
+		return 1
+	}
+	params, _ := json.Marshal(map[string]any{"id": taskID})
+	resp, err := sendRPCUnix(*socketPath, jsonrpc.Request{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		fmt.Println("hub not responding")
+		return 1
+	}
+	printTask(resp, *format)
+	if resp.Error != nil {
+		switch resp.Error.Code {
+		case jsonrpc.ErrTaskNotFound:
+			return exitTaskNotFound
+		case jsonrpc.ErrTaskNotCancelable:
+			return exitTaskNotCancelable
+		default:
+			return 1
+		}
+	}
+	return 0
+}
+
 func contextWithSignals() (context.Context, context.CancelFunc) {
 	ctx, cancel := context.WithCancel(context.Background())
 	sigCh := make(chan os.Signal, 1)
@@ -274,6 +527,9 @@ func sendRPCUnix(socketPath string, req jsonrpc.Request) (jsonrpc.Response, erro
 		return jsonrpc.Response{}, err
 	}
 	defer conn.Close()
+	if req.ID == nil {
+		req.ID = jsonrpc.NextID("cli")
+	}
 	data, _ := json.Marshal(req)
 	_, err = conn.Write(append(data, '\n'))
 	if err != nil {
@@ -288,9 +544,39 @@ func sendRPCUnix(socketPath string, req jsonrpc.Request) (jsonrpc.Response, erro
 	if err := json.Unmarshal(bytes.TrimSpace(line), &resp); err != nil {
 		return jsonrpc.Response{}, err
 	}
+	if !jsonrpc.SameID(resp.ID, req.ID) {
+		return jsonrpc.Response{}, fmt.Errorf("response id %v does not match request id %v", resp.ID, req.ID)
+	}
 	return resp, nil
 }
 
+// formatFlag registers --format, defaulting to A2A_HUB_FORMAT, then the outputFormat
+// in settings.json, then pretty
+func formatFlag(fs *flag.FlagSet) *string {
+	return fs.String("format", defaultOutputFormat(), "output format: json|pretty")
+}
+
+func defaultOutputFormat() string {
+	if val := strings.ToLower(strings.TrimSpace(os.Getenv("A2A_HUB_FORMAT"))); hub.ValidOutputFormat(val) {
+		return val
+	}
+	if settings, err := hub.ReadSettings(hubDataDir()); err == nil && hub.ValidOutputFormat(settings.OutputFormat) {
+		return settings.OutputFormat
+	}
+	return "pretty"
+}
+
+// hubDataDir is the data dir a hub started without --config uses: the default
+// config file's data_dir, or the default data dir
+func hubDataDir() string {
+	if path := hub.FindConfigFile(""); path != "" {
+		if cfg, err := hub.LoadConfigFile(path); err == nil && cfg.DataDir != "" {
+			return cfg.DataDir
+		}
+	}
+	return hub.DefaultDataDir()
+}
+
 func printResponse(resp jsonrpc.Response, format string) {
 	if format == "json" {
 		data, _ := json.Marshal(resp)
@@ -301,6 +587,26 @@ func printResponse(resp jsonrpc.Response, format string) {
 	fmt.Println(string(data))
 }
 
+// printTask prints a response whose result is a task. The pretty format renders the
+// task the way the TUI's detail pane does; json, errors and results that aren't a
+// task print as printResponse does.
+func printTask(resp jsonrpc.Response, format string) {
+	if format == "json" || resp.Error != nil {
+		printResponse(resp, format)
+		return
+	}
+	var task types.Task
+	raw, err := json.Marshal(resp.Result)
+	if err == nil {
+		err = json.Unmarshal(raw, &task)
+	}
+	if err != nil || task.ID == "" || task.Status.State == "" {
+		printResponse(resp, format)
+		return
+	}
+	fmt.Println(taskfmt.Detail(task, nil, taskfmt.Hints{DeadLetters: "see agents-hub tasks deadletters"}))
+}
+
 func resolveA2ABaseURL() string {
 	if val := strings.TrimSpace(os.Getenv("A2A_HUB_URL")); val != "" {
 		return val
@@ -326,19 +632,21 @@ func isA2ATransportError(err error) bool {
 		strings.Contains(msg, "failed to decode response")
 }
 
-func sendA2A(ctx context.Context, baseURL, agentID, messageText, contextID string, timeoutMs int) (jsonrpc.Response, error) {
+var errA2AUnavailable = errors.New("A2A client unavailable")
+
+func sendA2A(ctx context.Context, baseURL, agentID string, parts []types.Part, contextID string, timeoutMs int) (jsonrpc.Response, error) {
 	if strings.TrimSpace(baseURL) == "" {
 		return jsonrpc.Response{}, errors.New("missing A2A base URL")
 	}
 	a2aURL := strings.TrimRight(baseURL, "/") + "/a2a"
 	client, err := a2aclient.NewFromEndpoints(ctx, []sdka2a.AgentInterface{
 		{URL: a2aURL, Transport: sdka2a.TransportProtocolJSONRPC},
-	})
+	}, hub.BearerClientOptions(resolveHTTPToken(""))...)
 	if err != nil {
-		return jsonrpc.Response{}, err
+		return jsonrpc.Response{}, fmt.Errorf("%w: %v", errA2AUnavailable, err)
 	}
 
-	message := sdka2a.NewMessage(sdka2a.MessageRoleUser, &sdka2a.TextPart{Text: messageText})
+	message := sdka2a.NewMessage(sdka2a.MessageRoleUser, internala2a.ToSDKParts(parts)...)
 	if strings.TrimSpace(contextID) != "" {
 		message.ContextID = contextID
 	}
@@ -373,7 +681,7 @@ func sendA2A(ctx context.Context, baseURL, agentID, messageText, contextID strin
 		payload = result
 	}
 
-	return jsonrpc.Response{JSONRPC: "2.0", Result: payload, ID: "1"}, nil
+	return jsonrpc.Response{JSONRPC: "2.0", Result: payload, ID: jsonrpc.NextID("cli")}, nil
 }
 
 func parsePID(val string) int {
@@ -382,12 +690,12 @@ func parsePID(val string) int {
 	return pid
 }
 
-func resolveOrchestratorAgents(flagValue string) []string {
+func resolveOrchestratorAgents(flagValue string, fallback []string) []string {
 	if flagValue == "" {
 		flagValue = os.Getenv("ORCHESTRATOR_AGENTS")
 	}
 	if flagValue == "" {
-		return hub.DefaultConfig().Orchestrator.Agents
+		return fallback
 	}
 	if strings.EqualFold(flagValue, "none") {
 		return nil
@@ -404,12 +712,70 @@ func resolveOrchestratorAgents(flagValue string) []string {
 	return out
 }
 
-func resolveOrchestratorRouter(flagValue string) string {
+// resolveEnabledAgents returns the built-in agents to register; nil means all of them
+func resolveEnabledAgents(flagValue string, fallback []string) []string {
 	if flagValue == "" {
-		flagValue = os.Getenv("ORCHESTRATOR_ROUTER")
+		flagValue = os.Getenv("HUB_AGENTS")
+	}
+	if strings.TrimSpace(flagValue) == "" {
+		return fallback
+	}
+	if strings.EqualFold(flagValue, "all") {
+		return nil
+	}
+	out := []string{}
+	for _, item := range strings.Split(flagValue, ",") {
+		if val := strings.TrimSpace(item); val != "" {
+			out = append(out, val)
+		}
+	}
+	return out
+}
+
+func resolveCORSOrigins(flagValue string, fallback []string) []string {
+	if flagValue == "" {
+		flagValue = os.Getenv("CORS_ORIGINS")
+	}
+	if flagValue == "" {
+		return fallback
 	}
 	if strings.EqualFold(flagValue, "none") {
-		return ""
+		return nil
+	}
+	items := strings.Split(flagValue, ",")
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		val := strings.TrimSpace(item)
+		if val == "" {
+			continue
+		}
+		out = append(out, val)
+	}
+	return out
+}
+
+func resolveHTTPToken(flagValue string) string {
+	if flagValue == "" {
+		flagValue = os.Getenv("HUB_HTTP_TOKEN")
 	}
 	return strings.TrimSpace(flagValue)
 }
+
+func resolveOrchestratorRouters(flagValue string, fallback []string) []string {
+	if flagValue == "" {
+		flagValue = os.Getenv("ORCHESTRATOR_ROUTER")
+	}
+	if flagValue == "" {
+		return fallback
+	}
+	if strings.EqualFold(flagValue, "none") {
+		return nil
+	}
+	out := []string{}
+	for _, item := range strings.Split(flagValue, ",") {
+		if val := strings.TrimSpace(item); val != "" {
+			out = append(out, val)
+		}
+	}
+	return out
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultOutputFormat(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dataDir := filepath.Join(home, "state")
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(home, ".a2a-hub", "config.toml"), "data_dir = \""+dataDir+"\"\n")
+	write(filepath.Join(dataDir, "settings.json"), `{"outputFormat":"json"}`)
+
+	tests := []struct {
+		name string
+		env  string
+		want string
+	}{
+		{"settings in the configured data dir", "", "json"},
+		{"env wins", "pretty", "pretty"},
+		{"env is case-insensitive", " PRETTY ", "pretty"},
+		{"invalid env falls back to settings", "yaml", "json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("A2A_HUB_FORMAT", tt.env)
+			if got := defaultOutputFormat(); got != tt.want {
+				t.Fatalf("defaultOutputFormat = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"agents-hub/internal/hub"
+)
+
+// hubFlags are the hub settings shared by start and tui
+type hubFlags struct {
+	fs                 *flag.FlagSet
+	config             *string
+	httpPort           *int
+	noHTTP             *bool
+	socketPath         *string
+	verbose            *bool
+	logFormat          *string
+	orchestratorAgents *string
+	orchestratorRouter *string
+	noOrchestrator     *bool
+	enabledAgents      *string
+	metrics            *bool
+	corsOrigins        *string
+	httpToken          *string
+	authCards          *bool
+	taskTTL            *time.Duration
+	taskWorkers        *int
+}
+
+func registerHubFlags(fs *flag.FlagSet) *hubFlags {
+	return &hubFlags{
+		fs:                 fs,
+		config:             fs.String("config", "", "config file (.toml or .yaml); default config.toml or config.yaml in ~/.a2a-hub"),
+		httpPort:           fs.Int("http-port", 8080, "http port"),
+		noHTTP:             fs.Bool("no-http", false, "disable http"),
+		socketPath:         fs.String("socket", "/tmp/a2a-hub.sock", "unix socket path"),
+		verbose:            fs.Bool("verbose", false, "debug logging"),
+		logFormat:          fs.String("log-format", "text", "log output format: text|json"),
+		orchestratorAgents: fs.String("orchestrator-agents", "", "comma-separated agent IDs for orchestrator"),
+		orchestratorRouter: fs.String("orchestrator-router", "", "comma-separated agent IDs for LLM orchestrator routing; several vote on the route"),
+		noOrchestrator:     fs.Bool("no-orchestrator", false, "don't register the orchestrator agent"),
+		enabledAgents:      fs.String("agents", "", "comma-separated built-in agents to register (default all)"),
+		metrics:            fs.Bool("metrics", false, "expose prometheus metrics at /metrics"),
+		corsOrigins:        fs.String("cors-origin", "", "comma-separated origins allowed for CORS (* for any)"),
+		httpToken:          fs.String("http-token", "", "require this bearer token on http endpoints"),
+		authCards:          fs.Bool("http-auth-cards", false, "require the http token for well-known agent cards"),
+		taskTTL:            fs.Duration("task-ttl", 0, "prune finished tasks older than this (e.g. 168h); 0 disables"),
+		taskWorkers:        fs.Int("task-workers", 4, "non-blocking sends the hub runs at once; the rest are queued"),
+	}
+}
+
+// isSet reports whether the flag was given on the command line
+func (f *hubFlags) isSet(name string) bool {
+	set := false
+	f.fs.Visit(func(fl *flag.Flag) {
+		if fl.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// load builds the hub config. Precedence is flags > env > config file > defaults:
+// a flag only counts when it was given, and env vars only fill in unset flags.
+func (f *hubFlags) load() (hub.Config, error) {
+	path := f.configPath()
+	cfg := hub.DefaultConfig()
+	if path != "" {
+		var err error
+		if cfg, err = hub.LoadConfigFile(path); err != nil {
+			return cfg, err
+		}
+	}
+
+	if f.isSet("socket") {
+		cfg.Socket.Path = *f.socketPath
+	}
+	if f.isSet("http-port") {
+		cfg.HTTP.Port = *f.httpPort
+	}
+	if f.isSet("no-http") {
+		cfg.HTTP.Enabled = !*f.noHTTP
+	}
+	cfg.Orchestrator.Agents = resolveOrchestratorAgents(*f.orchestratorAgents, cfg.Orchestrator.Agents)
+	cfg.Orchestrator.RouterAgents = resolveOrchestratorRouters(*f.orchestratorRouter, cfg.Orchestrator.RouterAgents)
+	// "none" opts out too, so saved delegates can't bring the orchestrator back
+	cfg.Orchestrator.Disabled = cfg.Orchestrator.Disabled || *f.noOrchestrator || cfg.Orchestrator.Agents == nil
+	cfg.EnabledAgents = resolveEnabledAgents(*f.enabledAgents, cfg.EnabledAgents)
+	if f.isSet("metrics") {
+		cfg.Metrics.Enabled = *f.metrics
+	}
+	cfg.HTTP.CORSOrigins = resolveCORSOrigins(*f.corsOrigins, cfg.HTTP.CORSOrigins)
+	if token := resolveHTTPToken(*f.httpToken); token != "" {
+		cfg.HTTP.Token = token
+	}
+	if f.isSet("http-auth-cards") {
+		cfg.HTTP.PublicCards = !*f.authCards
+	}
+	if f.isSet("task-ttl") {
+		cfg.Tasks.TTL = *f.taskTTL
+	}
+	if f.isSet("task-workers") {
+		cfg.Tasks.Workers = *f.taskWorkers
+	}
+	if *f.verbose {
+		cfg.Logging.Level = "debug"
+	}
+	if f.isSet("log-format") {
+		cfg.Logging.Format = *f.logFormat
+	}
+	return cfg, nil
+}
+
+// configPath is the config file load reads, or "" when there is none
+func (f *hubFlags) configPath() string {
+	if *f.config != "" {
+		return *f.config
+	}
+	return hub.FindConfigFile("")
+}
+
+// printEffectiveConfig prints cfg as JSON under the config file's key names, so
+// `start --print-config` shows what flags, env and the file resolved to. Secrets are
+// redacted.
+func printEffectiveConfig(cfg hub.Config, path string) {
+	data, _ := json.MarshalIndent(effectiveConfig(cfg, path), "", "  ")
+	fmt.Println(string(data))
+}
+
+// effectiveConfig is what printEffectiveConfig prints: every field of cfg, with the
+// token redacted, defaults filled in and durations written as the config file takes them
+func effectiveConfig(cfg hub.Config, path string) map[string]any {
+	if cfg.HTTP.Token != "" {
+		cfg.HTTP.Token = "[redacted]"
+	}
+	if cfg.DataDir == "" {
+		cfg.DataDir = hub.DefaultDataDir()
+	}
+	if cfg.EnabledAgents == nil {
+		cfg.EnabledAgents = hub.BuiltinAgentIDs
+	}
+	effective := configFields(reflect.ValueOf(cfg))
+	effective["config_file"] = path
+	return effective
+}
+
+// configFields maps a config struct's fields by their json names, recursing into
+// nested structs
+func configFields(v reflect.Value) map[string]any {
+	fields := make(map[string]any, v.NumField())
+	for i := range v.NumField() {
+		name := v.Type().Field(i).Tag.Get("json")
+		switch field := v.Field(i); {
+		case field.Type() == reflect.TypeOf(time.Duration(0)):
+			fields[name] = time.Duration(field.Int()).String()
+		case field.Kind() == reflect.Struct:
+			fields[name] = configFields(field)
+		default:
+			fields[name] = field.Interface()
+		}
+	}
+	return fields
+}
+
+// printConfigErrors lists each problem found by Config.Validate on its own line
+func printConfigErrors(err error) {
+	fmt.Fprintln(os.Stderr, "invalid configuration:")
+	for _, line := range strings.Split(err.Error(), "\n") {
+		fmt.Fprintf(os.Stderr, "  - %s\n", line)
+	}
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"agents-hub/internal/hub"
+)
+
+func TestEffectiveConfigCoversEveryField(t *testing.T) {
+	cfg := hub.DefaultConfig()
+	cfg.HTTP.Token = "secret"
+	cfg.Logging.Pretty = true
+	cfg.Tasks.TTL = 168 * time.Hour
+	effective := effectiveConfig(cfg, "/etc/hub.toml")
+
+	// Every field, including ones added later, needs a json name and a printed value
+	var walk func(typ reflect.Type, printed map[string]any, path string)
+	walk = func(typ reflect.Type, printed map[string]any, path string) {
+		for i := range typ.NumField() {
+			field := typ.Field(i)
+			name := field.Tag.Get("json")
+			if name == "" {
+				t.Errorf("%s%s has no json name", path, field.Name)
+				continue
+			}
+			value, ok := printed[name]
+			if !ok {
+				t.Errorf("%s%s is not printed", path, name)
+				continue
+			}
+			if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Duration(0)) {
+				walk(field.Type, value.(map[string]any), path+name+".")
+			}
+		}
+	}
+	walk(reflect.TypeOf(cfg), effective, "")
+
+	http := effective["http"].(map[string]any)
+	logging := effective["logging"].(map[string]any)
+	tasks := effective["tasks"].(map[string]any)
+	checks := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"config_file", effective["config_file"], "/etc/hub.toml"},
+		{"http.token", http["token"], "[redacted]"},
+		{"logging.pretty", logging["pretty"], true},
+		{"tasks.ttl", tasks["ttl"], "168h0m0s"},
+		{"data_dir", effective["data_dir"], hub.DefaultDataDir()},
+		{"agents", effective["agents"], hub.BuiltinAgentIDs},
+	}
+	for _, check := range checks {
+		if !reflect.DeepEqual(check.got, check.want) {
+			t.Errorf("%s = %v, want %v", check.name, check.got, check.want)
+		}
+	}
+	if cfg.HTTP.Token != "secret" {
+		t.Fatal("effectiveConfig changed the caller's config")
+	}
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agents-hub/internal/types"
+)
+
+// maxInlineFileBytes is the largest --file sent inline; bigger files are sent as a
+// file:// URI, which only works when the agent runs on the same machine
+const maxInlineFileBytes = 1 << 20
+
+// fileList collects repeated --file flags
+type fileList []string
+
+func (f *fileList) String() string { return strings.Join(*f, ",") }
+
+func (f *fileList) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// filePart reads path into a file part: inline base64 bytes for small files, a
+// file:// URI for large ones
+func filePart(path string) (types.Part, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return types.Part{}, fileError(path, err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return types.Part{}, fileError(path, err)
+	}
+	if info.IsDir() {
+		return types.Part{}, fmt.Errorf("cannot read --file %s: is a directory", path)
+	}
+	file := &types.File{Name: filepath.Base(abs), MimeType: mime.TypeByExtension(filepath.Ext(abs))}
+	if info.Size() > maxInlineFileBytes {
+		// The agent reads it later, but fail now if it can't be read at all
+		f, err := os.Open(abs)
+		if err != nil {
+			return types.Part{}, fileError(path, err)
+		}
+		f.Close()
+		if file.MimeType == "" {
+			file.MimeType = "application/octet-stream"
+		}
+		file.URI = (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+		return types.Part{Kind: "file", File: file}, nil
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return types.Part{}, fileError(path, err)
+	}
+	if file.MimeType == "" {
+		file.MimeType = http.DetectContentType(data)
+	}
+	file.Bytes = base64.StdEncoding.EncodeToString(data)
+	return types.Part{Kind: "file", File: file}, nil
+}
+
+// fileError names the --file argument without repeating the path from os errors
+func fileError(path string, err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return fmt.Errorf("cannot read --file %s: %v", path, err)
+}
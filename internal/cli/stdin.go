@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// readPrompt resolves the message for send and tui --once. A "-" message, or
+// --stdin, reads the prompt from stdin; with --stdin and a message, the message
+// comes first and stdin follows after a blank line, as in
+// `git diff | agents-hub send --stdin claude "Review this diff"`.
+func readPrompt(message string, fromStdin bool, stdin *os.File) (string, error) {
+	if message == "-" {
+		message, fromStdin = "", true
+	}
+	if !fromStdin {
+		return message, nil
+	}
+	if info, err := stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		return "", errors.New("stdin is a terminal: pipe the prompt in, e.g. git diff | agents-hub send claude -")
+	}
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", fmt.Errorf("reading stdin: %w", err)
+	}
+	piped := strings.TrimRight(string(data), "\n")
+	if strings.TrimSpace(piped) == "" {
+		return "", errors.New("stdin was empty")
+	}
+	if strings.TrimSpace(message) == "" {
+		return piped, nil
+	}
+	return message + "\n\n" + piped, nil
+}
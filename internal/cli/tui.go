@@ -1,40 +1,86 @@
 package cli
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
+	"net"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
-	"agents-hub/internal/hub"
 	"agents-hub/internal/tui"
 	"agents-hub/internal/utils"
 )
 
 func runTUI(args []string) int {
 	fs := flag.NewFlagSet("tui", flag.ContinueOnError)
-	httpPort := fs.Int("http-port", 8080, "http port")
-	noHTTP := fs.Bool("no-http", false, "disable http")
-	socketPath := fs.String("socket", "/tmp/a2a-hub.sock", "unix socket path")
+	flags := registerHubFlags(fs)
 	noSocket := fs.Bool("no-socket", false, "disable unix socket")
-	verbose := fs.Bool("verbose", false, "debug logging")
-	orchestratorAgents := fs.String("orchestrator-agents", "", "comma-separated agent IDs for orchestrator")
-	orchestratorRouter := fs.String("orchestrator-router", "", "agent ID for LLM orchestrator routing")
+	attach := fs.Bool("attach", false, "attach to an already running hub over the unix socket")
+	refreshInterval := fs.Duration("refresh-interval", 5*time.Second, "how often the TUI refreshes hub data")
+	maxConcurrent := fs.Int("max-concurrent-agents", 4, "how many agents a multi-agent send runs at once; the rest are queued")
+	refreshWhileTyping := fs.Bool("refresh-while-typing", false, "keep auto-refreshing while a message is being typed")
+	once := fs.Bool("once", false, "send one message without the UI: tui --once <agent> <message>")
+	fromStdin := fs.Bool("stdin", false, "with --once, read the prompt from stdin, after the message if one is given")
 	if err := fs.Parse(args); err != nil {
 		return 1
 	}
 
-	cfg := hub.DefaultConfig()
-	cfg.Socket.Path = *socketPath
-	cfg.Socket.Enabled = !*noSocket
-	cfg.HTTP.Port = *httpPort
-	cfg.HTTP.Enabled = !*noHTTP
-	cfg.Orchestrator.Agents = resolveOrchestratorAgents(*orchestratorAgents)
-	cfg.Orchestrator.RouterAgent = resolveOrchestratorRouter(*orchestratorRouter)
-	if *verbose {
-		cfg.Logging.Level = "debug"
+	cfg, err := flags.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		return 1
+	}
+	if flags.isSet("no-socket") {
+		cfg.Socket.Enabled = !*noSocket
+	}
+	if flags.isSet("refresh-interval") && *refreshInterval > 0 {
+		cfg.TUI.RefreshInterval = *refreshInterval
+	}
+	if flags.isSet("max-concurrent-agents") {
+		cfg.TUI.MaxConcurrentAgents = *maxConcurrent
+	}
+	if flags.isSet("refresh-while-typing") {
+		cfg.TUI.RefreshWhileTyping = *refreshWhileTyping
+	}
+	if err := cfg.Validate(); err != nil {
+		printConfigErrors(err)
+		return 1
 	}
 
 	logger := utils.NewLogger(cfg.Logging.Level)
+	logger.SetFormat(cfg.Logging.Format)
+	if *once {
+		if fs.NArg() < 2 && (fs.NArg() < 1 || !*fromStdin) {
+			fmt.Fprintln(os.Stderr, "usage: agents-hub tui --once [--stdin] <agent> <message>|-")
+			return 1
+		}
+		message, err := readPrompt(strings.Join(fs.Args()[1:], " "), *fromStdin, os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+		// Keep stdout for the agent's response; no transports are served in this mode
+		logger.SetOutput(os.Stderr)
+		cfg.Socket.Enabled = false
+		cfg.HTTP.Enabled = false
+		setHubEnv(cfg)
+		return tui.RunOnce(cfg, logger, fs.Arg(0), message, os.Stdin, os.Stdout, os.Stderr)
+	}
+	if !*attach {
+		if pid, running := detectRunningHub(cfg.Socket.Path); running {
+			*attach = confirmAttach(pid, cfg.Socket.Path)
+		}
+	}
+	if *attach {
+		if err := tui.RunAttached(cfg, logger); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+		return 0
+	}
 	setHubEnv(cfg)
 	if err := tui.Run(cfg, logger); err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
@@ -42,3 +88,37 @@ func runTUI(args []string) int {
 	}
 	return 0
 }
+
+// detectRunningHub reports whether a hub already answers on the socket, along with
+// its pid from the pidfile when known
+func detectRunningHub(socketPath string) (int, bool) {
+	conn, err := net.DialTimeout("unix", socketPath, 500*time.Millisecond)
+	if err != nil {
+		return 0, false
+	}
+	_ = conn.Close()
+	data, err := os.ReadFile(filepath.Join(os.Getenv("HOME"), ".a2a-hub", "hub.pid"))
+	if err != nil {
+		return 0, true
+	}
+	return parsePID(strings.TrimSpace(string(data))), true
+}
+
+// confirmAttach asks whether to attach to a running hub. Non-interactive sessions
+// keep the old behaviour of starting an embedded hub.
+func confirmAttach(pid int, socketPath string) bool {
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	running := "A hub is already running on " + socketPath
+	if pid > 0 {
+		running = fmt.Sprintf("A hub is already running (pid %d) on %s", pid, socketPath)
+	}
+	fmt.Fprintf(os.Stderr, "%s. Attach to it? [Y/n] ", running)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "", "y", "yes":
+		return true
+	}
+	return false
+}
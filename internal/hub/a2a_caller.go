@@ -3,11 +3,14 @@ package hub
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"strings"
+	"sync"
 	"time"
 
 	"agents-hub/internal/jsonrpc"
 	"agents-hub/internal/types"
+	"agents-hub/internal/utils"
 
 	sdka2a "github.com/a2aproject/a2a-go/a2a"
 	"github.com/a2aproject/a2a-go/a2aclient"
@@ -24,26 +27,76 @@ type a2aMessageSendRequest struct {
 
 // A2ARoutingCaller prefers A2A for message/send and falls back to local JSON-RPC when unavailable.
 type A2ARoutingCaller struct {
-	local  *LocalCaller
-	client *a2aclient.Client
+	local   *LocalCaller
+	client  *a2aclient.Client
+	logger  *utils.Logger
+	mu      sync.RWMutex
+	lastErr error
 }
 
-func NewA2ARoutingCaller(local *LocalCaller, baseURL string, httpEnabled bool) *A2ARoutingCaller {
-	caller := &A2ARoutingCaller{local: local}
+// bearerInterceptor attaches a static bearer token to outgoing A2A requests.
+type bearerInterceptor struct {
+	a2aclient.PassthroughInterceptor
+	token string
+}
+
+func (i bearerInterceptor) Before(ctx context.Context, req *a2aclient.Request) (context.Context, error) {
+	req.Meta["Authorization"] = []string{"Bearer " + i.token}
+	return ctx, nil
+}
+
+// BearerClientOptions returns A2A client options that authenticate against a hub
+// started with an HTTP token. It returns no options when token is empty.
+func BearerClientOptions(token string) []a2aclient.FactoryOption {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil
+	}
+	return []a2aclient.FactoryOption{a2aclient.WithInterceptors(bearerInterceptor{token: token})}
+}
+
+func NewA2ARoutingCaller(local *LocalCaller, baseURL string, httpEnabled bool, token string, logger *utils.Logger) *A2ARoutingCaller {
+	caller := &A2ARoutingCaller{local: local, logger: logger}
 	if !httpEnabled || strings.TrimSpace(baseURL) == "" {
+		caller.lastErr = errors.New("http transport disabled")
 		return caller
 	}
 	a2aURL := strings.TrimRight(baseURL, "/") + "/a2a"
 	client, err := a2aclient.NewFromEndpoints(context.Background(), []sdka2a.AgentInterface{
 		{URL: a2aURL, Transport: sdka2a.TransportProtocolJSONRPC},
-	})
+	}, BearerClientOptions(token)...)
 	if err != nil {
+		caller.lastErr = err
+		if logger != nil {
+			logger.Debugf("A2A client unavailable, routing via local JSON-RPC: %v", err)
+		}
 		return caller
 	}
 	caller.client = client
 	return caller
 }
 
+// RemoteLive reports whether message/send is routed over A2A. It is false when the
+// client could not be created or the most recent A2A call failed.
+func (c *A2ARoutingCaller) RemoteLive() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.client != nil && c.lastErr == nil
+}
+
+// LastError returns the reason A2A routing is not live, if any.
+func (c *A2ARoutingCaller) LastError() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastErr
+}
+
+func (c *A2ARoutingCaller) setLastError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastErr = err
+}
+
 func (c *A2ARoutingCaller) Call(ctx context.Context, method string, params []byte) (jsonrpc.Response, error) {
 	if method != "message/send" || c.client == nil {
 		return c.local.Call(ctx, method, params)
@@ -85,7 +138,11 @@ func (c *A2ARoutingCaller) Call(ctx context.Context, method string, params []byt
 	}
 
 	result, err := c.client.SendMessage(callCtx, paramsMsg)
+	c.setLastError(err)
 	if err != nil {
+		if c.logger != nil {
+			c.logger.Debugf("A2A message/send failed: %v", err)
+		}
 		return jsonrpc.Response{JSONRPC: "2.0", Error: &jsonrpc.RPCError{Code: jsonrpc.ErrInternalError, Message: err.Error()}}, nil
 	}
 
@@ -0,0 +1,48 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MatchAgentID resolves a loosely typed agent name: an exact ID wins, then a unique
+// prefix, substring or in-order character match (so "cc" finds "claude-code").
+func MatchAgentID(query string, ids []string) (string, error) {
+	query = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(query), "@"))
+	if query == "" {
+		return "", errors.New("agent name required")
+	}
+	matchers := []func(id string) bool{
+		func(id string) bool { return id == query },
+		func(id string) bool { return strings.HasPrefix(id, query) },
+		func(id string) bool { return strings.Contains(id, query) },
+		func(id string) bool { return isSubsequence(query, id) },
+	}
+	for _, match := range matchers {
+		var found []string
+		for _, id := range ids {
+			if match(strings.ToLower(id)) {
+				found = append(found, id)
+			}
+		}
+		switch {
+		case len(found) == 1:
+			return found[0], nil
+		case len(found) > 1:
+			return "", fmt.Errorf("agent %q is ambiguous: %s", query, strings.Join(found, ", "))
+		}
+	}
+	return "", fmt.Errorf("no agent matches %q", query)
+}
+
+func isSubsequence(needle, haystack string) bool {
+	runes := []rune(needle)
+	i := 0
+	for _, r := range haystack {
+		if i < len(runes) && runes[i] == r {
+			i++
+		}
+	}
+	return i == len(runes)
+}
@@ -0,0 +1,96 @@
+package hub
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"agents-hub/internal/agents"
+)
+
+// Values accepted by hub/agents/list for sortBy and type
+var (
+	agentSortKeys  = []string{"id", "name", "health", "latency"}
+	agentTypes     = []string{"local", "remote", "orchestrator"}
+	agentStatuses  = []string{"healthy", "degraded", "unhealthy", "unknown"}
+	healthSortRank = map[string]int{"healthy": 0, "degraded": 1, "unknown": 2, "unhealthy": 3}
+)
+
+// agentQuery holds the optional hub/agents/list params; zero values keep registry order and every agent
+type agentQuery struct {
+	SortBy string `json:"sortBy"`
+	Status string `json:"status"`
+	Type   string `json:"type"`
+}
+
+func (q agentQuery) validate() error {
+	if q.SortBy != "" && !slices.Contains(agentSortKeys, q.SortBy) {
+		return fmt.Errorf("sortBy must be one of %s", strings.Join(agentSortKeys, ", "))
+	}
+	if q.Status != "" && !slices.Contains(agentStatuses, q.Status) {
+		return fmt.Errorf("status must be one of %s", strings.Join(agentStatuses, ", "))
+	}
+	if q.Type != "" && !slices.Contains(agentTypes, q.Type) {
+		return fmt.Errorf("type must be one of %s", strings.Join(agentTypes, ", "))
+	}
+	return nil
+}
+
+// apply filters infos and sorts what is left. Sorting is stable, so ties keep registry order.
+func (q agentQuery) apply(infos []AgentInfo) []AgentInfo {
+	out := make([]AgentInfo, 0, len(infos))
+	for _, info := range infos {
+		if q.Status != "" && agentStatus(info) != q.Status {
+			continue
+		}
+		if q.Type != "" && agentType(info.Agent) != q.Type {
+			continue
+		}
+		out = append(out, info)
+	}
+	switch q.SortBy {
+	case "id":
+		slices.SortStableFunc(out, func(a, b AgentInfo) int { return cmp.Compare(a.Agent.ID(), b.Agent.ID()) })
+	case "name":
+		slices.SortStableFunc(out, func(a, b AgentInfo) int {
+			return cmp.Compare(strings.ToLower(a.Agent.Name()), strings.ToLower(b.Agent.Name()))
+		})
+	case "health":
+		slices.SortStableFunc(out, func(a, b AgentInfo) int {
+			return cmp.Compare(healthSortRank[agentStatus(a)], healthSortRank[agentStatus(b)])
+		})
+	case "latency":
+		// Fastest first; agents without a measurement go last
+		slices.SortStableFunc(out, func(a, b AgentInfo) int {
+			la, lb := a.Health.LatencyMs, b.Health.LatencyMs
+			if (la == 0) != (lb == 0) {
+				if la == 0 {
+					return 1
+				}
+				return -1
+			}
+			return cmp.Compare(la, lb)
+		})
+	}
+	return out
+}
+
+// agentStatus is the agent's health status, "unknown" before the first check
+func agentStatus(info AgentInfo) string {
+	if _, ok := healthSortRank[info.Health.Status]; ok {
+		return info.Health.Status
+	}
+	return "unknown"
+}
+
+// agentType classifies an agent as local, remote or orchestrator
+func agentType(agent agents.Agent) string {
+	switch agent.(type) {
+	case *agents.RemoteAgent:
+		return "remote"
+	case *agents.Orchestrator, *agents.LLMOrchestrator:
+		return "orchestrator"
+	}
+	return "local"
+}
@@ -0,0 +1,52 @@
+package hub
+
+import (
+	"reflect"
+	"testing"
+
+	"agents-hub/internal/agents"
+	"agents-hub/internal/types"
+)
+
+func TestAgentQuery(t *testing.T) {
+	infos := []AgentInfo{
+		{Agent: agents.NewGeminiAgent(""), Health: types.AgentHealth{Status: "unhealthy", LatencyMs: 40}},
+		{Agent: agents.NewCodexAgent(""), Health: types.AgentHealth{Status: "healthy", LatencyMs: 90}},
+		{Agent: agents.NewOrchestrator(nil, "", nil)},
+		{Agent: agents.NewClaudeAgent(""), Health: types.AgentHealth{Status: "degraded", LatencyMs: 20}},
+	}
+	tests := []struct {
+		name  string
+		query agentQuery
+		want  []string
+	}{
+		{"registry order", agentQuery{}, []string{"gemini", "codex", "orchestrator", "claude-code"}},
+		{"by id", agentQuery{SortBy: "id"}, []string{"claude-code", "codex", "gemini", "orchestrator"}},
+		{"by name", agentQuery{SortBy: "name"}, []string{"orchestrator", "claude-code", "codex", "gemini"}},
+		{"by health, unknown before unhealthy", agentQuery{SortBy: "health"}, []string{"codex", "claude-code", "orchestrator", "gemini"}},
+		{"by latency, unmeasured last", agentQuery{SortBy: "latency"}, []string{"claude-code", "gemini", "codex", "orchestrator"}},
+		{"status filter", agentQuery{Status: "unknown"}, []string{"orchestrator"}},
+		{"type filter", agentQuery{Type: "local", SortBy: "id"}, []string{"claude-code", "codex", "gemini"}},
+		{"no match", agentQuery{Type: "remote"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.query.validate(); err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, info := range tt.query.apply(infos) {
+				got = append(got, info.Agent.ID())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("apply = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []agentQuery{{SortBy: "size"}, {Status: "ok"}, {Type: "cloud"}} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("validate(%+v) accepted it", bad)
+		}
+	}
+}
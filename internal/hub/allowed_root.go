@@ -0,0 +1,163 @@
+package hub
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agents-hub/internal/agents"
+	"agents-hub/internal/types"
+)
+
+// AllowedRoots returns the directories local agents' working directories must stay within
+func (s *Server) AllowedRoots() map[string]string {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	roots := make(map[string]string, len(s.settings.AllowedRoots))
+	for id, root := range s.settings.AllowedRoots {
+		roots[id] = root
+	}
+	return roots
+}
+
+// UpdateAllowedRoot pins a local agent's working directories inside root and persists
+// it; an empty root removes the pin
+func (s *Server) UpdateAllowedRoot(agentID, root string) error {
+	agentID = strings.TrimSpace(agentID)
+	info, ok := s.registry.Get(agentID)
+	if !ok {
+		return fmt.Errorf("agent not found: %s", agentID)
+	}
+	if agentType(info.Agent) != "local" {
+		return fmt.Errorf("agent %s does not run in a local working directory", agentID)
+	}
+	root = strings.TrimSpace(root)
+	if root == "" {
+		return s.updateSettings(func(settings *Settings) error {
+			delete(settings.AllowedRoots, agentID)
+			return nil
+		})
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	stat, err := os.Stat(abs)
+	if err != nil {
+		return fmt.Errorf("allowed root %s: %w", root, err)
+	}
+	if !stat.IsDir() {
+		return fmt.Errorf("allowed root %s is not a directory", root)
+	}
+	return s.updateSettings(func(settings *Settings) error {
+		if settings.AllowedRoots == nil {
+			settings.AllowedRoots = make(map[string]string)
+		}
+		settings.AllowedRoots[agentID] = abs
+		return nil
+	})
+}
+
+// allowedRoot returns agentID's allowed root, or "" when it has none
+func (s *Server) allowedRoot(agentID string) string {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.settings.AllowedRoots[agentID]
+}
+
+// CheckWorkingDir fails when dir is outside agentID's allowed root, if it has one. A
+// relative dir is refused under a root, since the agent would resolve it against a
+// directory of its own.
+func (s *Server) CheckWorkingDir(agentID, dir string) error {
+	root := s.allowedRoot(agentID)
+	if root == "" {
+		return nil
+	}
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("working directory %s must be an absolute path inside the allowed root of %s (%s)", dir, agentID, root)
+	}
+	if withinRoot(root, dir) {
+		return nil
+	}
+	return fmt.Errorf("working directory %s is outside the allowed root of %s (%s)", dir, agentID, root)
+}
+
+// CheckAgentDirs checks the directories msg makes agentID pick on its own, such as
+// Codex's codexConfig.workingDirectory and addDirs, against its allowed root. It
+// returns the working directory the agent will use instead of the hub's, if any.
+func (s *Server) CheckAgentDirs(agentID string, msg types.Message) (string, error) {
+	info, ok := s.registry.Get(agentID)
+	if !ok {
+		return "", nil
+	}
+	requester, ok := info.Agent.(agents.DirectoryRequester)
+	if !ok {
+		return "", nil
+	}
+	workingDir, extra := requester.RequestedDirs(msg)
+	for _, dir := range extra {
+		if err := s.CheckWorkingDir(agentID, dir); err != nil {
+			return "", fmt.Errorf("added directory: %w", err)
+		}
+	}
+	if workingDir != "" {
+		if err := s.CheckWorkingDir(agentID, workingDir); err != nil {
+			return "", err
+		}
+	}
+	return workingDir, nil
+}
+
+// ResolveWorkingDir picks the directory agentID runs msg in. The agent's own choice
+// (see CheckAgentDirs) wins over requested, and a directory outside the agent's
+// allowed root is an error; without either the agent's last directory is used, or
+// its root when that is unset or outside it.
+func (s *Server) ResolveWorkingDir(agentID string, msg types.Message, requested string) (string, error) {
+	override, err := s.CheckAgentDirs(agentID, msg)
+	if err != nil {
+		return "", err
+	}
+	if override != "" {
+		requested = override
+	}
+	if requested != "" {
+		if err := s.CheckWorkingDir(agentID, requested); err != nil {
+			return "", err
+		}
+		s.rememberWorkingDir(agentID, requested)
+		return requested, nil
+	}
+	dir := s.LastWorkingDir(agentID)
+	if root := s.allowedRoot(agentID); root != "" && (dir == "" || !withinRoot(root, dir)) {
+		return root, nil
+	}
+	return dir, nil
+}
+
+// withinRoot reports whether dir is root or below it, after resolving ".." and
+// symlinks, so neither leads out of the root
+func withinRoot(root, dir string) bool {
+	rel, err := filepath.Rel(realPath(root), realPath(dir))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// realPath makes path absolute and resolves its symlinks; a path that doesn't exist
+// yet keeps its longest existing parent resolved
+func realPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+	parent := filepath.Dir(abs)
+	if parent == abs {
+		return abs
+	}
+	return filepath.Join(realPath(parent), filepath.Base(abs))
+}
@@ -0,0 +1,140 @@
+package hub
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agents-hub/internal/agents"
+	"agents-hub/internal/types"
+	"agents-hub/internal/utils"
+)
+
+// newTestServer returns a server keeping its state in a temp dir, with no agents
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.DataDir = t.TempDir()
+	return NewServer(cfg, utils.NewLogger("error"))
+}
+
+// pinnedCodex registers Codex on a test server and pins it inside a new root
+func pinnedCodex(t *testing.T) (*Server, string) {
+	t.Helper()
+	t.Setenv("CODEX_CMD", "true")
+	s := newTestServer(t)
+	if err := s.registry.Register(agents.NewCodexAgent("")); err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateAllowedRoot("codex", root); err != nil {
+		t.Fatal(err)
+	}
+	return s, root
+}
+
+func TestCheckWorkingDir(t *testing.T) {
+	s, root := pinnedCodex(t)
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "sub"), filepath.Join(root, "inner")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		dir  string
+		ok   bool
+	}{
+		{"root", root, true},
+		{"subdirectory", filepath.Join(root, "sub"), true},
+		{"missing subdirectory", filepath.Join(root, "sub", "new"), true},
+		{"dot dot inside", filepath.Join(root, "sub") + "/..", true},
+		{"dot dot out", root + "/..", false},
+		{"dot dot through sub", root + "/sub/../../x", false},
+		{"sibling with root as prefix", root + "-other", false},
+		{"symlink out", filepath.Join(root, "escape"), false},
+		{"below symlink out", filepath.Join(root, "escape", "deeper"), false},
+		{"symlink inside", filepath.Join(root, "inner"), true},
+		{"relative", "sub", false},
+		{"relative dot dot", "../" + filepath.Base(root), false},
+		{"filesystem root", "/", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.CheckWorkingDir("codex", tt.dir)
+			if (err == nil) != tt.ok {
+				t.Fatalf("CheckWorkingDir(%q) = %v, want ok %v", tt.dir, err, tt.ok)
+			}
+		})
+	}
+	if err := s.CheckWorkingDir("claude-code", "relative"); err != nil {
+		t.Fatalf("an agent without a root accepts any directory, got %v", err)
+	}
+}
+
+func TestResolveWorkingDirCodexConfig(t *testing.T) {
+	s, root := pinnedCodex(t)
+	codexMessage := func(config map[string]any) types.Message {
+		return types.Message{Kind: "message", Metadata: map[string]any{"targetAgent": "codex", "codexConfig": config}}
+	}
+	tests := []struct {
+		name      string
+		msg       types.Message
+		requested string
+		want      string // "" when it must fail
+	}{
+		{"no config", codexMessage(nil), root, root},
+		{"no request falls back to root", codexMessage(nil), "", root},
+		{"requested outside", codexMessage(nil), "/", ""},
+		{"workingDirectory inside", codexMessage(map[string]any{"workingDirectory": filepath.Join(root, "sub")}), root, filepath.Join(root, "sub")},
+		{"workingDir wins over request", codexMessage(map[string]any{"workingDir": filepath.Join(root, "sub")}), "/", filepath.Join(root, "sub")},
+		{"workingDirectory outside", codexMessage(map[string]any{"workingDirectory": "/"}), root, ""},
+		{"workingDirectory relative", codexMessage(map[string]any{"workingDirectory": "sub"}), root, ""},
+		{"workingDirectory dot dot", codexMessage(map[string]any{"workingDirectory": root + "/.."}), root, ""},
+		{"addDirs inside", codexMessage(map[string]any{"addDirs": []any{filepath.Join(root, "sub")}}), root, root},
+		{"addDirs outside", codexMessage(map[string]any{"addDirs": []any{filepath.Join(root, "sub"), "/etc"}}), root, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.ResolveWorkingDir("codex", tt.msg, tt.requested)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("ResolveWorkingDir = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("ResolveWorkingDir = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveWorkingDirDefaultCodexDirs(t *testing.T) {
+	s, root := pinnedCodex(t)
+	tests := []struct {
+		name     string
+		settings types.CodexSettings
+		ok       bool
+	}{
+		{"default dir inside", types.CodexSettings{DefaultWorkingDir: filepath.Join(root, "sub")}, true},
+		{"default dir outside", types.CodexSettings{DefaultWorkingDir: "/"}, false},
+		{"default added dir outside", types.CodexSettings{DefaultAddDirs: []string{"/tmp"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.UpdateCodexSettings(tt.settings); err != nil {
+				t.Fatal(err)
+			}
+			_, err := s.ResolveWorkingDir("codex", types.Message{Kind: "message"}, root)
+			if (err == nil) != tt.ok {
+				t.Fatalf("ResolveWorkingDir error = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
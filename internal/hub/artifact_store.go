@@ -0,0 +1,130 @@
+package hub
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"agents-hub/internal/types"
+	"agents-hub/internal/utils"
+)
+
+// ArtifactURIPrefix starts the URI of a file part whose bytes live in the artifact store.
+// The rest of the URI is the artifact file ID, also served over HTTP at the same path.
+const ArtifactURIPrefix = "/artifacts/"
+
+var errArtifactNotFound = errors.New("artifact not found")
+
+// ArtifactStore keeps artifact file bytes under <data dir>/artifacts/<task ID>/ so
+// tasks.json only holds a reference to them
+type ArtifactStore struct {
+	dir string
+}
+
+func NewArtifactStore() *ArtifactStore {
+	return &ArtifactStore{}
+}
+
+func (as *ArtifactStore) SetDir(dir string) {
+	as.dir = dir
+}
+
+// Externalize writes the inline bytes of each file part to disk and returns the
+// artifacts with those parts pointing at the store instead. A part that can't be
+// stored stays inline.
+func (as *ArtifactStore) Externalize(taskID string, artifacts []types.Artifact) ([]types.Artifact, error) {
+	if as.dir == "" || len(artifacts) == 0 {
+		return artifacts, nil
+	}
+	out := make([]types.Artifact, len(artifacts))
+	var errs []error
+	n := 0
+	for i, artifact := range artifacts {
+		parts := make([]types.Part, len(artifact.Parts))
+		for j, part := range artifact.Parts {
+			parts[j] = part
+			if part.File == nil || part.File.Bytes == "" {
+				continue
+			}
+			data, err := base64.StdEncoding.DecodeString(part.File.Bytes)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("artifact %s: %w", artifact.ArtifactID, err))
+				continue
+			}
+			n++
+			id := taskID + "." + strconv.Itoa(n)
+			if err := as.write(id, data); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			parts[j].File = &types.File{Name: part.File.Name, MimeType: part.File.MimeType, URI: ArtifactURIPrefix + id}
+		}
+		out[i] = artifact
+		out[i].Parts = parts
+	}
+	return out, errors.Join(errs...)
+}
+
+// Read returns the stored bytes of an artifact file
+func (as *ArtifactStore) Read(id string) ([]byte, error) {
+	path, ok := as.path(id)
+	if !ok {
+		return nil, errArtifactNotFound
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, errArtifactNotFound
+	}
+	return data, err
+}
+
+// RemoveTask deletes every artifact file stored for a task
+func (as *ArtifactStore) RemoveTask(taskID string) error {
+	if as.dir == "" || !validArtifactSegment(taskID) {
+		return nil
+	}
+	return os.RemoveAll(filepath.Join(as.dir, taskID))
+}
+
+func (as *ArtifactStore) write(id string, data []byte) error {
+	path, ok := as.path(id)
+	if !ok {
+		return fmt.Errorf("invalid artifact id %q", id)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(path, data, 0o644)
+}
+
+// path maps "<task ID>.<n>" to <dir>/<task ID>/<n>, rejecting anything that could escape the store
+func (as *ArtifactStore) path(id string) (string, bool) {
+	taskID, n, ok := artifactIDParts(id)
+	if !ok || as.dir == "" {
+		return "", false
+	}
+	return filepath.Join(as.dir, taskID, n), true
+}
+
+func artifactIDParts(id string) (taskID, n string, ok bool) {
+	i := strings.LastIndexByte(id, '.')
+	if i <= 0 {
+		return "", "", false
+	}
+	taskID, n = id[:i], id[i+1:]
+	if !validArtifactSegment(taskID) {
+		return "", "", false
+	}
+	if _, err := strconv.Atoi(n); err != nil {
+		return "", "", false
+	}
+	return taskID, n, true
+}
+
+func validArtifactSegment(s string) bool {
+	return s != "" && s != "." && s != ".." && !strings.ContainsAny(s, `/\`)
+}
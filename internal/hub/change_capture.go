@@ -0,0 +1,267 @@
+package hub
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"agents-hub/internal/types"
+)
+
+// maxSnapshotFiles bounds a snapshot of a directory that isn't a git repository; a
+// larger tree is not captured
+const maxSnapshotFiles = 20000
+
+// ChangesArtifactID names the artifact listing the files a run changed
+const ChangesArtifactID = "changed-files"
+
+var errTooManyFiles = fmt.Errorf("more than %d files and not a git repository", maxSnapshotFiles)
+
+// fileStamp is what a snapshot keeps per file; a missing file has exists false
+type fileStamp struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// dirSnapshot is the state of a working directory before a run. In a git repository
+// only the files git reports as modified, deleted or untracked are stamped, which
+// keeps big repositories cheap; elsewhere every file is.
+type dirSnapshot struct {
+	dir   string
+	git   bool
+	files map[string]fileStamp // path relative to dir
+}
+
+// FileChange is one file a run added, modified or deleted
+type FileChange struct {
+	Path   string `json:"path"`
+	Change string `json:"change"` // added, modified or deleted
+}
+
+// CaptureChanges returns the IDs of agents whose message/send runs record changed files
+func (s *Server) CaptureChanges() []string {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return append([]string{}, s.settings.CaptureChanges...)
+}
+
+// UpdateCaptureChanges turns changed-file capture on or off for a local agent and persists it
+func (s *Server) UpdateCaptureChanges(agentID string, enabled bool) error {
+	agentID = strings.TrimSpace(agentID)
+	info, ok := s.registry.Get(agentID)
+	if !ok {
+		return fmt.Errorf("agent not found: %s", agentID)
+	}
+	if agentType(info.Agent) != "local" {
+		return fmt.Errorf("agent %s does not run in a local working directory", agentID)
+	}
+	return s.updateSettings(func(settings *Settings) error {
+		settings.CaptureChanges = toggleID(settings.CaptureChanges, agentID, enabled)
+		return nil
+	})
+}
+
+// snapshotForRun snapshots dir before agentID runs, or returns nil when capture is
+// off for the agent or the directory can't be snapshotted
+func (s *Server) snapshotForRun(agentID, dir string) *dirSnapshot {
+	if dir == "" || !slices.Contains(s.CaptureChanges(), agentID) {
+		return nil
+	}
+	snapshot, err := snapshotDir(dir)
+	if err != nil {
+		s.logger.Warnf("not capturing changed files for %s in %s: %v", agentID, dir, err)
+		return nil
+	}
+	return snapshot
+}
+
+// CaptureRun snapshots dir before agentID runs outside message/send, as TUI streams
+// do, and returns a function that lists the files the run changed. It returns nil when
+// capture is off for the agent or the directory can't be snapshotted.
+func (s *Server) CaptureRun(agentID, dir string) func() []FileChange {
+	snapshot := s.snapshotForRun(agentID, dir)
+	if snapshot == nil {
+		return nil
+	}
+	return func() []FileChange { return s.runChanges(snapshot) }
+}
+
+// runChanges compares dir with the snapshot, logging a failure as no changes
+func (s *Server) runChanges(snapshot *dirSnapshot) []FileChange {
+	changes, err := snapshot.changes()
+	if err != nil {
+		s.logger.Warnf("failed to capture changed files in %s: %v", snapshot.dir, err)
+		return nil
+	}
+	return changes
+}
+
+// changesArtifact compares dir with the snapshot and lists the changed files as an
+// artifact; ok is false when nothing changed
+func (s *Server) changesArtifact(snapshot *dirSnapshot) (types.Artifact, bool) {
+	if snapshot == nil {
+		return types.Artifact{}, false
+	}
+	changes := s.runChanges(snapshot)
+	if len(changes) == 0 {
+		return types.Artifact{}, false
+	}
+	return types.Artifact{
+		ArtifactID:  ChangesArtifactID,
+		Name:        "Changed files",
+		Description: fmt.Sprintf("%d file(s) changed in %s", len(changes), snapshot.dir),
+		Parts: []types.Part{{Kind: "data", Data: map[string]any{
+			"workingDirectory": snapshot.dir,
+			"files":            changes,
+		}}},
+	}, true
+}
+
+func snapshotDir(dir string) (*dirSnapshot, error) {
+	snapshot := &dirSnapshot{dir: dir, files: make(map[string]fileStamp)}
+	paths, err := gitDirtyFiles(dir)
+	if err == nil {
+		snapshot.git = true
+		for _, path := range paths {
+			snapshot.files[path] = stampFile(filepath.Join(dir, path))
+		}
+		return snapshot, nil
+	}
+	snapshot.files, err = walkDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// changes lists the files whose state differs from the snapshot, sorted by path
+func (ds *dirSnapshot) changes() ([]FileChange, error) {
+	after := make(map[string]fileStamp)
+	if ds.git {
+		paths, err := gitDirtyFiles(ds.dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			after[path] = stampFile(filepath.Join(ds.dir, path))
+		}
+		// A file dirty before the run but clean now was changed back
+		for path := range ds.files {
+			if _, ok := after[path]; !ok {
+				after[path] = stampFile(filepath.Join(ds.dir, path))
+			}
+		}
+	} else {
+		var err error
+		if after, err = walkDir(ds.dir); err != nil {
+			return nil, err
+		}
+	}
+	changes := make([]FileChange, 0)
+	for path, stamp := range after {
+		before, seen := ds.files[path]
+		if !seen && ds.git {
+			// Clean before the run, so it existed unless git now reports it as untracked or deleted
+			before = fileStamp{exists: true}
+			if stamp.exists && !gitTracked(ds.dir, path) {
+				before = fileStamp{}
+			}
+		}
+		switch {
+		case !before.exists && stamp.exists:
+			changes = append(changes, FileChange{Path: path, Change: "added"})
+		case before.exists && !stamp.exists:
+			changes = append(changes, FileChange{Path: path, Change: "deleted"})
+		case stamp.exists && (!seen || stamp.size != before.size || !stamp.modTime.Equal(before.modTime)):
+			changes = append(changes, FileChange{Path: path, Change: "modified"})
+		}
+	}
+	if !ds.git {
+		for path, before := range ds.files {
+			if _, ok := after[path]; !ok && before.exists {
+				changes = append(changes, FileChange{Path: path, Change: "deleted"})
+			}
+		}
+	}
+	slices.SortFunc(changes, func(a, b FileChange) int { return strings.Compare(a.Path, b.Path) })
+	return changes, nil
+}
+
+// gitDirtyFiles lists the modified, deleted and untracked (not ignored) files under
+// dir, relative to it; it fails when dir is not inside a git work tree
+func gitDirtyFiles(dir string) ([]string, error) {
+	out, err := runGit(dir, "ls-files", "--modified", "--deleted", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+	// A deleted file is also listed as modified
+	seen := make(map[string]bool)
+	var paths []string
+	for _, path := range bytes.Split(out, []byte{0}) {
+		if len(path) > 0 && !seen[string(path)] {
+			seen[string(path)] = true
+			paths = append(paths, string(path))
+		}
+	}
+	return paths, nil
+}
+
+func gitTracked(dir, path string) bool {
+	_, err := runGit(dir, "ls-files", "--error-unmatch", "--", path)
+	return err == nil
+}
+
+func runGit(dir string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	return cmd.Output()
+}
+
+// walkDir stamps every file under dir, skipping .git directories
+func walkDir(dir string) (map[string]fileStamp, error) {
+	files := make(map[string]fileStamp)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if len(files) == maxSnapshotFiles {
+			return errTooManyFiles
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		files[filepath.ToSlash(rel)] = stampFile(path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func stampFile(path string) fileStamp {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
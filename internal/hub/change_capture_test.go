@@ -0,0 +1,29 @@
+package hub
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCaptureRun(t *testing.T) {
+	s, root := pinnedCodex(t)
+	if changes := s.CaptureRun("codex", root); changes != nil {
+		t.Fatal("CaptureRun returned a capture while capture is off")
+	}
+	if err := s.UpdateCaptureChanges("codex", true); err != nil {
+		t.Fatal(err)
+	}
+	changes := s.CaptureRun("codex", root)
+	if changes == nil {
+		t.Fatal("CaptureRun returned nil while capture is on")
+	}
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := []FileChange{{Path: "main.go", Change: "added"}}
+	if got := changes(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("changes = %v, want %v", got, want)
+	}
+}
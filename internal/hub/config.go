@@ -1,24 +1,64 @@
 package hub
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"agents-hub/internal/utils"
+)
+
+// BuiltinAgentIDs are the CLI agents the hub can register on its own
+var BuiltinAgentIDs = []string{"claude-code", "gemini", "codex", "vibe"}
+
+// Config is the hub's configuration. Its json names are the config file's keys,
+// which start --print-config prints.
 type Config struct {
 	Socket struct {
-		Path    string
-		Enabled bool
-	}
+		Path    string `json:"path"`
+		Enabled bool   `json:"enabled"`
+	} `json:"socket"`
 	HTTP struct {
-		Enabled bool
-		Host    string
-		Port    int
-	}
+		Enabled     bool     `json:"enabled"`
+		Host        string   `json:"host"`
+		Port        int      `json:"port"`
+		CORSOrigins []string `json:"cors_origins"`
+		Token       string   `json:"token"`
+		PublicCards bool     `json:"public_cards"`
+	} `json:"http"`
 	Orchestrator struct {
-		Agents      []string
-		RouterAgent string
-	}
+		Agents       []string `json:"agents"`
+		RouterAgents []string `json:"routers"`  // routing LLMs; with several, their plans are merged by vote
+		Disabled     bool     `json:"disabled"` // never register the orchestrator, whatever the saved delegates
+	} `json:"orchestrator"`
 	Logging struct {
-		Level  string
-		Pretty bool
-	}
-	DataDir string
+		Level          string   `json:"level"`
+		Format         string   `json:"format"`
+		Pretty         bool     `json:"pretty"`
+		RedactPatterns []string `json:"redact_patterns"` // argument names whose values are masked in logged and displayed command lines
+	} `json:"logging"`
+	Metrics struct {
+		Enabled bool `json:"enabled"`
+	} `json:"metrics"`
+	Contexts struct {
+		MaxMessages   int `json:"max_messages"`
+		RetentionDays int `json:"retention_days"` // 0 keeps contexts until they are pruned on demand
+	} `json:"contexts"`
+	Tasks struct {
+		TTL          time.Duration `json:"ttl"`
+		KeepRecent   int           `json:"keep_recent"`
+		Workers      int           `json:"workers"`       // non-blocking message/sends run at once; the rest wait in the queue
+		QueueSize    int           `json:"queue_size"`    // non-blocking message/sends that may wait for a worker
+		DrainTimeout time.Duration `json:"drain_timeout"` // how long shutdown waits for queued tasks before canceling them
+	} `json:"tasks"`
+	TUI struct {
+		RefreshInterval     time.Duration `json:"refresh_interval"`
+		MaxConcurrentAgents int           `json:"max_concurrent_agents"` // streams a multi-agent send runs at once; the rest wait
+		RefreshWhileTyping  bool          `json:"refresh_while_typing"`  // keep auto-refreshing while a message is being typed
+	} `json:"tui"`
+	DataDir       string   `json:"data_dir"`
+	EnabledAgents []string `json:"agents"` // built-in agents to register; nil registers all of them
 }
 
 func DefaultConfig() Config {
@@ -28,10 +68,95 @@ func DefaultConfig() Config {
 	cfg.HTTP.Enabled = true
 	cfg.HTTP.Host = "127.0.0.1"
 	cfg.HTTP.Port = 8080
-	cfg.Orchestrator.Agents = []string{"claude-code", "gemini", "codex", "vibe"}
-	cfg.Orchestrator.RouterAgent = ""
+	cfg.HTTP.CORSOrigins = nil
+	cfg.HTTP.Token = ""
+	cfg.HTTP.PublicCards = true
+	cfg.Orchestrator.Agents = append([]string{}, BuiltinAgentIDs...)
+	cfg.Orchestrator.RouterAgents = nil
 	cfg.Logging.Level = "info"
+	cfg.Logging.Format = "text"
 	cfg.Logging.Pretty = false
+	cfg.Logging.RedactPatterns = append([]string{}, utils.DefaultRedactPatterns...)
+	cfg.Metrics.Enabled = false
+	cfg.Contexts.MaxMessages = DefaultMaxContextMessages
+	cfg.Contexts.RetentionDays = 0
+	cfg.Tasks.TTL = 0
+	cfg.Tasks.KeepRecent = 100
+	cfg.Tasks.Workers = 4
+	cfg.Tasks.QueueSize = 100
+	cfg.Tasks.DrainTimeout = 30 * time.Second
+	cfg.TUI.RefreshInterval = 5 * time.Second
+	cfg.TUI.MaxConcurrentAgents = 4
 	cfg.DataDir = ""
 	return cfg
 }
+
+// Validate reports every invalid setting at once, each with the flag or config
+// key to fix, so a bad setup fails before the hub starts.
+func (c Config) Validate() error {
+	var errs []error
+	if c.Socket.Enabled && strings.TrimSpace(c.Socket.Path) == "" {
+		errs = append(errs, errors.New("socket path is empty: pass --socket or set socket.path (or disable the socket)"))
+	}
+	if c.HTTP.Enabled {
+		if c.HTTP.Port < 1 || c.HTTP.Port > 65535 {
+			errs = append(errs, fmt.Errorf("http port %d is out of range 1-65535: fix --http-port or http.port", c.HTTP.Port))
+		}
+		if strings.TrimSpace(c.HTTP.Host) == "" {
+			errs = append(errs, errors.New("http host is empty: set http.host or pass --no-http"))
+		}
+		if !c.HTTP.PublicCards && c.HTTP.Token == "" {
+			errs = append(errs, errors.New("--http-auth-cards needs an http token: pass --http-token or set HUB_HTTP_TOKEN"))
+		}
+	}
+	for _, id := range c.Orchestrator.RouterAgents {
+		if strings.EqualFold(strings.TrimSpace(id), "orchestrator") {
+			errs = append(errs, errors.New(`orchestrator router can't be "orchestrator" itself: pick the agent that routes, e.g. --orchestrator-router claude-code`))
+			break
+		}
+	}
+	for _, id := range c.Orchestrator.Agents {
+		if strings.EqualFold(id, "orchestrator") {
+			errs = append(errs, errors.New(`orchestrator agents can't include "orchestrator" itself: fix --orchestrator-agents or orchestrator.agents`))
+			break
+		}
+	}
+	switch strings.ToLower(c.Logging.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("unknown log level %q: use debug, info, warn or error", c.Logging.Level))
+	}
+	switch strings.ToLower(c.Logging.Format) {
+	case "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("unknown log format %q: use --log-format text or json", c.Logging.Format))
+	}
+	if c.Contexts.MaxMessages < 0 {
+		errs = append(errs, fmt.Errorf("contexts.max_messages is %d: use 0 for no limit or a positive count", c.Contexts.MaxMessages))
+	}
+	if c.Contexts.RetentionDays < 0 {
+		errs = append(errs, fmt.Errorf("contexts.retention_days is %d: it must not be negative", c.Contexts.RetentionDays))
+	}
+	if c.Tasks.TTL < 0 {
+		errs = append(errs, fmt.Errorf("task ttl %s is negative: use 0 to disable pruning", c.Tasks.TTL))
+	}
+	if c.Tasks.KeepRecent < 0 {
+		errs = append(errs, fmt.Errorf("tasks.keep_recent is %d: it must not be negative", c.Tasks.KeepRecent))
+	}
+	if c.Tasks.Workers < 1 {
+		errs = append(errs, fmt.Errorf("tasks.workers is %d: it must be at least 1", c.Tasks.Workers))
+	}
+	if c.Tasks.QueueSize < 1 {
+		errs = append(errs, fmt.Errorf("tasks.queue_size is %d: it must be at least 1", c.Tasks.QueueSize))
+	}
+	if c.Tasks.DrainTimeout < 0 {
+		errs = append(errs, fmt.Errorf("tasks.drain_timeout %s is negative: use 0 to cancel queued tasks at once", c.Tasks.DrainTimeout))
+	}
+	if c.TUI.RefreshInterval <= 0 {
+		errs = append(errs, fmt.Errorf("tui refresh interval %s must be positive", c.TUI.RefreshInterval))
+	}
+	if c.TUI.MaxConcurrentAgents < 1 {
+		errs = append(errs, fmt.Errorf("tui.max_concurrent_agents is %d: it must be at least 1", c.TUI.MaxConcurrentAgents))
+	}
+	return errors.Join(errs...)
+}
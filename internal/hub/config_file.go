@@ -0,0 +1,214 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileNames are looked up in the data dir when no --config path is given
+var configFileNames = []string{"config.toml", "config.yaml", "config.yml"}
+
+// fileConfig is the on-disk form of Config. Pointers tell a missing key from a zero value.
+type fileConfig struct {
+	Socket       fileSocket       `toml:"socket" yaml:"socket"`
+	HTTP         fileHTTP         `toml:"http" yaml:"http"`
+	Orchestrator fileOrchestrator `toml:"orchestrator" yaml:"orchestrator"`
+	Logging      fileLogging      `toml:"logging" yaml:"logging"`
+	Metrics      fileMetrics      `toml:"metrics" yaml:"metrics"`
+	Contexts     fileContexts     `toml:"contexts" yaml:"contexts"`
+	Tasks        fileTasks        `toml:"tasks" yaml:"tasks"`
+	TUI          fileTUI          `toml:"tui" yaml:"tui"`
+	DataDir      *string          `toml:"data_dir" yaml:"data_dir"`
+	Agents       []string         `toml:"agents" yaml:"agents"`
+}
+
+type fileSocket struct {
+	Path    *string `toml:"path" yaml:"path"`
+	Enabled *bool   `toml:"enabled" yaml:"enabled"`
+}
+
+type fileHTTP struct {
+	Enabled     *bool    `toml:"enabled" yaml:"enabled"`
+	Host        *string  `toml:"host" yaml:"host"`
+	Port        *int     `toml:"port" yaml:"port"`
+	CORSOrigins []string `toml:"cors_origins" yaml:"cors_origins"`
+	Token       *string  `toml:"token" yaml:"token"`
+	PublicCards *bool    `toml:"public_cards" yaml:"public_cards"`
+}
+
+type fileOrchestrator struct {
+	Agents   []string `toml:"agents" yaml:"agents"`
+	Router   *string  `toml:"router" yaml:"router"`
+	Routers  []string `toml:"routers" yaml:"routers"`
+	Disabled *bool    `toml:"disabled" yaml:"disabled"`
+}
+
+type fileLogging struct {
+	Level          *string  `toml:"level" yaml:"level"`
+	Format         *string  `toml:"format" yaml:"format"`
+	RedactPatterns []string `toml:"redact_patterns" yaml:"redact_patterns"`
+}
+
+type fileMetrics struct {
+	Enabled *bool `toml:"enabled" yaml:"enabled"`
+}
+
+type fileContexts struct {
+	MaxMessages   *int `toml:"max_messages" yaml:"max_messages"`
+	RetentionDays *int `toml:"retention_days" yaml:"retention_days"`
+}
+
+type fileTasks struct {
+	TTL          *string `toml:"ttl" yaml:"ttl"`
+	KeepRecent   *int    `toml:"keep_recent" yaml:"keep_recent"`
+	Workers      *int    `toml:"workers" yaml:"workers"`
+	QueueSize    *int    `toml:"queue_size" yaml:"queue_size"`
+	DrainTimeout *string `toml:"drain_timeout" yaml:"drain_timeout"`
+}
+
+type fileTUI struct {
+	RefreshInterval     *string `toml:"refresh_interval" yaml:"refresh_interval"`
+	MaxConcurrentAgents *int    `toml:"max_concurrent_agents" yaml:"max_concurrent_agents"`
+	RefreshWhileTyping  *bool   `toml:"refresh_while_typing" yaml:"refresh_while_typing"`
+}
+
+// DefaultDataDir is where the hub keeps its state unless Config.DataDir says otherwise
+func DefaultDataDir() string {
+	return filepath.Join(os.Getenv("HOME"), ".a2a-hub")
+}
+
+// FindConfigFile returns the first config file present in dataDir, or "" when there is none
+func FindConfigFile(dataDir string) string {
+	if dataDir == "" {
+		dataDir = DefaultDataDir()
+	}
+	for _, name := range configFileNames {
+		path := filepath.Join(dataDir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// LoadConfigFile reads a TOML or YAML config file (chosen by extension) and
+// returns DefaultConfig with the file's values laid over it.
+func LoadConfigFile(path string) (Config, error) {
+	cfg := DefaultConfig()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	var file fileConfig
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		meta, err := toml.Decode(string(data), &file)
+		if err != nil {
+			return cfg, fmt.Errorf("%s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return cfg, fmt.Errorf("%s: unknown key %q", path, undecoded[0].String())
+		}
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(strings.NewReader(string(data)))
+		dec.KnownFields(true)
+		if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+			return cfg, fmt.Errorf("%s: %w", path, err)
+		}
+	default:
+		return cfg, fmt.Errorf("%s: config file must end in .toml, .yaml or .yml", path)
+	}
+	if err := file.apply(&cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (f fileConfig) apply(cfg *Config) error {
+	setString(&cfg.Socket.Path, f.Socket.Path)
+	setBool(&cfg.Socket.Enabled, f.Socket.Enabled)
+	setBool(&cfg.HTTP.Enabled, f.HTTP.Enabled)
+	setString(&cfg.HTTP.Host, f.HTTP.Host)
+	setInt(&cfg.HTTP.Port, f.HTTP.Port)
+	if f.HTTP.CORSOrigins != nil {
+		cfg.HTTP.CORSOrigins = f.HTTP.CORSOrigins
+	}
+	setString(&cfg.HTTP.Token, f.HTTP.Token)
+	setBool(&cfg.HTTP.PublicCards, f.HTTP.PublicCards)
+	if f.Orchestrator.Agents != nil {
+		cfg.Orchestrator.Agents = f.Orchestrator.Agents
+	}
+	if f.Orchestrator.Router != nil || f.Orchestrator.Routers != nil {
+		routers := append([]string{}, f.Orchestrator.Routers...)
+		if f.Orchestrator.Router != nil && *f.Orchestrator.Router != "" {
+			routers = append([]string{*f.Orchestrator.Router}, routers...)
+		}
+		cfg.Orchestrator.RouterAgents = routers
+	}
+	setBool(&cfg.Orchestrator.Disabled, f.Orchestrator.Disabled)
+	setString(&cfg.Logging.Level, f.Logging.Level)
+	setString(&cfg.Logging.Format, f.Logging.Format)
+	if f.Logging.RedactPatterns != nil {
+		cfg.Logging.RedactPatterns = f.Logging.RedactPatterns
+	}
+	setBool(&cfg.Metrics.Enabled, f.Metrics.Enabled)
+	setInt(&cfg.Contexts.MaxMessages, f.Contexts.MaxMessages)
+	setInt(&cfg.Contexts.RetentionDays, f.Contexts.RetentionDays)
+	if err := setDuration(&cfg.Tasks.TTL, f.Tasks.TTL, "tasks.ttl"); err != nil {
+		return err
+	}
+	setInt(&cfg.Tasks.KeepRecent, f.Tasks.KeepRecent)
+	setInt(&cfg.Tasks.Workers, f.Tasks.Workers)
+	setInt(&cfg.Tasks.QueueSize, f.Tasks.QueueSize)
+	if err := setDuration(&cfg.Tasks.DrainTimeout, f.Tasks.DrainTimeout, "tasks.drain_timeout"); err != nil {
+		return err
+	}
+	if err := setDuration(&cfg.TUI.RefreshInterval, f.TUI.RefreshInterval, "tui.refresh_interval"); err != nil {
+		return err
+	}
+	setInt(&cfg.TUI.MaxConcurrentAgents, f.TUI.MaxConcurrentAgents)
+	setBool(&cfg.TUI.RefreshWhileTyping, f.TUI.RefreshWhileTyping)
+	setString(&cfg.DataDir, f.DataDir)
+	if len(f.Agents) > 0 {
+		cfg.EnabledAgents = f.Agents
+	}
+	return nil
+}
+
+func setString(dst *string, val *string) {
+	if val != nil {
+		*dst = *val
+	}
+}
+
+func setBool(dst *bool, val *bool) {
+	if val != nil {
+		*dst = *val
+	}
+}
+
+func setInt(dst *int, val *int) {
+	if val != nil {
+		*dst = *val
+	}
+}
+
+func setDuration(dst *time.Duration, val *string, key string) error {
+	if val == nil {
+		return nil
+	}
+	d, err := time.ParseDuration(*val)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = d
+	return nil
+}
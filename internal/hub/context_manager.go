@@ -3,6 +3,7 @@ package hub
 import (
 	"encoding/json"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -11,26 +12,38 @@ import (
 )
 
 type Context struct {
-	ID        string           `json:"id"`
-	CreatedAt time.Time        `json:"createdAt"`
-	History   []types.Message  `json:"history,omitempty"`
+	ID        string          `json:"id"`
+	CreatedAt time.Time       `json:"createdAt"`
+	UpdatedAt time.Time       `json:"updatedAt,omitempty"`
+	History   []types.Message `json:"history,omitempty"`
 }
 
+// DefaultMaxContextMessages bounds the history kept per context
+const DefaultMaxContextMessages = 200
+
 type ContextManager struct {
 	mu          sync.RWMutex
 	contexts    map[string]Context
+	maxMessages int
 	persistPath string
 	persistMu   sync.Mutex
 }
 
 func NewContextManager() *ContextManager {
-	return &ContextManager{contexts: make(map[string]Context)}
+	return &ContextManager{contexts: make(map[string]Context), maxMessages: DefaultMaxContextMessages}
 }
 
 func (cm *ContextManager) SetPersistence(path string) {
 	cm.persistPath = path
 }
 
+// SetMaxMessages sets how many messages each context retains (0 disables trimming)
+func (cm *ContextManager) SetMaxMessages(limit int) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.maxMessages = limit
+}
+
 func (cm *ContextManager) Get(id string) (Context, bool) {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
@@ -38,15 +51,22 @@ func (cm *ContextManager) Get(id string) (Context, bool) {
 	return ctx, ok
 }
 
+// Create adds an empty context, or returns the existing one untouched when the ID is
+// already known, so its history is never reset
 func (cm *ContextManager) Create(id string) Context {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
-	ctx := Context{ID: id, CreatedAt: time.Now().UTC()}
+	if existing, ok := cm.contexts[id]; ok {
+		return existing
+	}
+	now := time.Now().UTC()
+	ctx := Context{ID: id, CreatedAt: now, UpdatedAt: now}
 	cm.contexts[id] = ctx
 	cm.persistLocked()
 	return ctx
 }
 
+// List returns contexts most recently active first; limit <= 0 returns all
 func (cm *ContextManager) List(limit int) []Context {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
@@ -54,6 +74,9 @@ func (cm *ContextManager) List(limit int) []Context {
 	for _, ctx := range cm.contexts {
 		result = append(result, ctx)
 	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].lastActive().After(result[j].lastActive())
+	})
 	if limit > 0 && limit < len(result) {
 		return result[:limit]
 	}
@@ -76,6 +99,11 @@ func (cm *ContextManager) AddMessage(contextID string, msg types.Message) error
 	}
 
 	ctx.History = append(ctx.History, msg)
+	if cm.maxMessages > 0 && len(ctx.History) > cm.maxMessages {
+		// Drop the oldest messages; copy so the trimmed prefix can be collected
+		ctx.History = append([]types.Message(nil), ctx.History[len(ctx.History)-cm.maxMessages:]...)
+	}
+	ctx.UpdatedAt = time.Now().UTC()
 	cm.contexts[contextID] = ctx
 	cm.persistLocked()
 	return nil
@@ -111,6 +139,31 @@ func (cm *ContextManager) GetHistoryWithLimit(contextID string, limit int) []typ
 	return ctx.History[len(ctx.History)-limit:]
 }
 
+// Prune removes contexts with no activity since the cutoff and returns how many were dropped
+func (cm *ContextManager) Prune(cutoff time.Time) int {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	pruned := 0
+	for id, ctx := range cm.contexts {
+		if ctx.lastActive().Before(cutoff) {
+			delete(cm.contexts, id)
+			pruned++
+		}
+	}
+	if pruned > 0 {
+		cm.persistLocked()
+	}
+	return pruned
+}
+
+// lastActive is when the context last gained a message, or its creation time
+func (ctx Context) lastActive() time.Time {
+	if ctx.UpdatedAt.IsZero() {
+		return ctx.CreatedAt
+	}
+	return ctx.UpdatedAt
+}
+
 func (cm *ContextManager) Load() error {
 	if cm.persistPath == "" {
 		return nil
@@ -0,0 +1,152 @@
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"agents-hub/internal/agents"
+	"agents-hub/internal/types"
+	"agents-hub/internal/utils"
+)
+
+// maxDeadLetters caps dead_letters.json; the oldest records are dropped first
+const maxDeadLetters = 500
+
+// DeadLetter records a message/send that still failed after its last attempt. It
+// outlives the task itself, which task pruning eventually removes.
+type DeadLetter struct {
+	TaskID    string `json:"taskId"`
+	ContextID string `json:"contextId"`
+	AgentID   string `json:"agentId"`
+	Attempts  int    `json:"attempts"`
+	Error     string `json:"error"`
+	Prompt    string `json:"prompt,omitempty"`
+	FailedAt  string `json:"failedAt"`
+}
+
+type DeadLetterStore struct {
+	mu          sync.Mutex
+	letters     []DeadLetter // oldest first
+	persistPath string
+}
+
+func NewDeadLetterStore() *DeadLetterStore {
+	return &DeadLetterStore{}
+}
+
+func (ds *DeadLetterStore) SetPersistence(path string) {
+	ds.persistPath = path
+}
+
+// Add records a dead letter and rewrites the file
+func (ds *DeadLetterStore) Add(letter DeadLetter) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.letters = append(ds.letters, letter)
+	if over := len(ds.letters) - maxDeadLetters; over > 0 {
+		ds.letters = append([]DeadLetter{}, ds.letters[over:]...)
+	}
+	return ds.persistLocked()
+}
+
+// List returns dead letters newest first, optionally for one agent; limit <= 0 returns all
+func (ds *DeadLetterStore) List(agentID string, limit int) []DeadLetter {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	result := make([]DeadLetter, 0)
+	for i := len(ds.letters) - 1; i >= 0; i-- {
+		if agentID != "" && ds.letters[i].AgentID != agentID {
+			continue
+		}
+		result = append(result, ds.letters[i])
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result
+}
+
+func (ds *DeadLetterStore) Load() error {
+	if ds.persistPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(ds.persistPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var stored []DeadLetter
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.letters = stored
+	return nil
+}
+
+func (ds *DeadLetterStore) persistLocked() error {
+	if ds.persistPath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(ds.letters, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(ds.persistPath, data, 0o644)
+}
+
+// maxSendRetries bounds message/send's configuration.retries
+const maxSendRetries = 5
+
+// retryableFailure reports whether another attempt could succeed. A login prompt
+// needs the user, so it is not retried.
+func retryableFailure(result types.ExecutionResult, err error) bool {
+	if err != nil {
+		return !errors.Is(err, agents.ErrLoginRequired)
+	}
+	return result.Task.Status.State == types.TaskStateFailed
+}
+
+// retriesUsedUp reports whether a failed send was retried as many times as its
+// configuration allowed; sends without retries are not dead-lettered
+func retriesUsedUp(cfg sendConfiguration, attempts int) bool {
+	return cfg.Retries > 0 && attempts > cfg.Retries
+}
+
+// deadLetter tags a task that failed its last attempt and records it in dead_letters.json
+func (s *Server) deadLetter(task *types.Task, agentID string, attempts int, reason string, msg types.Message) {
+	_ = s.tasks.SetMetadata(task.ID, map[string]any{"deadLettered": true, "attempts": attempts})
+	letter := DeadLetter{
+		TaskID:    task.ID,
+		ContextID: task.ContextID,
+		AgentID:   agentID,
+		Attempts:  attempts,
+		Error:     reason,
+		Prompt:    messageText(&msg),
+		FailedAt:  time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if err := s.deadLetters.Add(letter); err != nil {
+		s.logger.Warnf("failed to save dead letter for task %s: %v", task.ID, err)
+	}
+	s.logger.Warnf("task %s dead-lettered: %s failed after %d attempt(s)", task.ID, agentID, attempts)
+}
+
+func messageText(msg *types.Message) string {
+	if msg == nil {
+		return ""
+	}
+	texts := make([]string, 0, len(msg.Parts))
+	for _, part := range msg.Parts {
+		if part.Kind == "text" && part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
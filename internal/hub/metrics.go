@@ -0,0 +1,200 @@
+package hub
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"agents-hub/internal/types"
+)
+
+// latencyBuckets are the upper bounds (in seconds) of the request latency histogram.
+// Agents wrap CLI processes, so the buckets skew towards long-running requests.
+var latencyBuckets = []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+
+type agentRequestMetrics struct {
+	outcomes map[string]int64
+	buckets  []int64
+	sum      float64
+	count    int64
+}
+
+// Metrics is a lightweight in-process metrics registry rendered in Prometheus text format.
+type Metrics struct {
+	mu           sync.Mutex
+	activeTasks  int64
+	requests     map[string]*agentRequestMetrics
+	healthChecks map[string]map[string]int64
+}
+
+// NewMetrics creates an empty metrics registry
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests:     make(map[string]*agentRequestMetrics),
+		healthChecks: make(map[string]map[string]int64),
+	}
+}
+
+// TaskStarted records a task dispatched to an agent
+func (m *Metrics) TaskStarted() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.activeTasks++
+}
+
+// TaskFinished records the outcome and latency of a task dispatched to an agent
+func (m *Metrics) TaskFinished(agentID string, state types.TaskState, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.activeTasks > 0 {
+		m.activeTasks--
+	}
+	req, ok := m.requests[agentID]
+	if !ok {
+		req = &agentRequestMetrics{outcomes: make(map[string]int64), buckets: make([]int64, len(latencyBuckets))}
+		m.requests[agentID] = req
+	}
+	req.outcomes[string(state)]++
+	seconds := elapsed.Seconds()
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			req.buckets[i]++
+		}
+	}
+	req.sum += seconds
+	req.count++
+}
+
+// HealthChecked records the result of a periodic health check
+func (m *Metrics) HealthChecked(agentID, status string) {
+	if m == nil {
+		return
+	}
+	if status == "" {
+		status = "unknown"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	byStatus, ok := m.healthChecks[agentID]
+	if !ok {
+		byStatus = make(map[string]int64)
+		m.healthChecks[agentID] = byStatus
+	}
+	byStatus[status]++
+}
+
+// ActiveTasks returns the number of tasks currently executing
+func (m *Metrics) ActiveTasks() int64 {
+	if m == nil {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.activeTasks
+}
+
+// WriteText writes all metrics in the Prometheus text exposition format.
+// Task counts and agent health are snapshots taken by the caller at scrape time.
+func (m *Metrics) WriteText(w io.Writer, uptime time.Duration, tasksByState map[types.TaskState]int, agents []AgentInfo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+
+	writeHeader(&b, "agents_hub_uptime_seconds", "gauge", "Seconds since the hub started.")
+	fmt.Fprintf(&b, "agents_hub_uptime_seconds %d\n", int64(uptime.Seconds()))
+
+	writeHeader(&b, "agents_hub_tasks", "gauge", "Tasks tracked by the hub, by state.")
+	states := make([]string, 0, len(tasksByState))
+	for state := range tasksByState {
+		states = append(states, string(state))
+	}
+	sort.Strings(states)
+	for _, state := range states {
+		fmt.Fprintf(&b, "agents_hub_tasks{state=%q} %d\n", state, tasksByState[types.TaskState(state)])
+	}
+
+	writeHeader(&b, "agents_hub_active_tasks", "gauge", "Tasks currently executing.")
+	fmt.Fprintf(&b, "agents_hub_active_tasks %d\n", m.activeTasks)
+
+	agentIDs := make([]string, 0, len(m.requests))
+	for id := range m.requests {
+		agentIDs = append(agentIDs, id)
+	}
+	sort.Strings(agentIDs)
+
+	writeHeader(&b, "agents_hub_agent_requests_total", "counter", "Requests dispatched to each agent, by outcome.")
+	for _, id := range agentIDs {
+		req := m.requests[id]
+		outcomes := make([]string, 0, len(req.outcomes))
+		for outcome := range req.outcomes {
+			outcomes = append(outcomes, outcome)
+		}
+		sort.Strings(outcomes)
+		for _, outcome := range outcomes {
+			fmt.Fprintf(&b, "agents_hub_agent_requests_total{agent=%q,outcome=%q} %d\n", id, outcome, req.outcomes[outcome])
+		}
+	}
+
+	writeHeader(&b, "agents_hub_agent_request_duration_seconds", "histogram", "Latency of requests dispatched to each agent.")
+	for _, id := range agentIDs {
+		req := m.requests[id]
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(&b, "agents_hub_agent_request_duration_seconds_bucket{agent=%q,le=%q} %d\n", id, formatFloat(bound), req.buckets[i])
+		}
+		fmt.Fprintf(&b, "agents_hub_agent_request_duration_seconds_bucket{agent=%q,le=\"+Inf\"} %d\n", id, req.count)
+		fmt.Fprintf(&b, "agents_hub_agent_request_duration_seconds_sum{agent=%q} %s\n", id, formatFloat(req.sum))
+		fmt.Fprintf(&b, "agents_hub_agent_request_duration_seconds_count{agent=%q} %d\n", id, req.count)
+	}
+
+	sortedAgents := append([]AgentInfo{}, agents...)
+	sort.Slice(sortedAgents, func(i, j int) bool {
+		return sortedAgents[i].Agent.ID() < sortedAgents[j].Agent.ID()
+	})
+	writeHeader(&b, "agents_hub_agent_up", "gauge", "Whether the agent's last health check was healthy (1) or not (0).")
+	for _, info := range sortedAgents {
+		up := 0
+		if info.Health.Status == "healthy" {
+			up = 1
+		}
+		fmt.Fprintf(&b, "agents_hub_agent_up{agent=%q} %d\n", info.Agent.ID(), up)
+	}
+
+	checkIDs := make([]string, 0, len(m.healthChecks))
+	for id := range m.healthChecks {
+		checkIDs = append(checkIDs, id)
+	}
+	sort.Strings(checkIDs)
+	writeHeader(&b, "agents_hub_health_checks_total", "counter", "Periodic health checks performed, by resulting status.")
+	for _, id := range checkIDs {
+		statuses := make([]string, 0, len(m.healthChecks[id]))
+		for status := range m.healthChecks[id] {
+			statuses = append(statuses, status)
+		}
+		sort.Strings(statuses)
+		for _, status := range statuses {
+			fmt.Fprintf(&b, "agents_hub_health_checks_total{agent=%q,status=%q} %d\n", id, status, m.healthChecks[id][status])
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeHeader(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, kind)
+}
+
+func formatFloat(val float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.3f", val), "0"), ".")
+}
@@ -1,6 +1,11 @@
 package hub
 
 import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
 	"sync"
 	"time"
 
@@ -16,10 +21,24 @@ type AgentInfo struct {
 	RegisteredAt time.Time
 }
 
+// cardRefresher is implemented by agents whose card can change after registration
+type cardRefresher interface {
+	RefreshCard(ctx context.Context) (bool, error)
+}
+
+// ErrDuplicateID is returned by Register when an agent with the same ID is already registered
+var ErrDuplicateID = errors.New("agent ID already registered")
+
+var (
+	errAgentNotRegistered = errors.New("agent not found")
+	errCardNotRefreshable = errors.New("agent card is fixed; only remote agents can be refreshed")
+)
+
 type AgentRegistry struct {
 	mu      sync.RWMutex
 	agents  map[string]*AgentInfo
 	logger  *utils.Logger
+	metrics *Metrics
 	stopCh  chan struct{}
 }
 
@@ -27,20 +46,47 @@ func NewAgentRegistry(logger *utils.Logger) *AgentRegistry {
 	return &AgentRegistry{agents: make(map[string]*AgentInfo), logger: logger, stopCh: make(chan struct{})}
 }
 
+// SetMetrics records health check results into the given registry
+func (ar *AgentRegistry) SetMetrics(metrics *Metrics) {
+	ar.metrics = metrics
+}
+
+// Register adds an agent, failing with ErrDuplicateID when its ID is taken; use
+// Unregister first to replace one
 func (ar *AgentRegistry) Register(agent agents.Agent) error {
+	if _, ok := ar.Get(agent.ID()); ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateID, agent.ID())
+	}
 	card, err := agent.GetCard()
 	if err != nil {
 		return err
 	}
 	info := &AgentInfo{Agent: agent, Card: card, RegisteredAt: time.Now().UTC()}
-	health, _ := agent.CheckHealth()
+	health, err := agent.CheckHealth()
+	if err != nil {
+		health.Status = "unhealthy"
+		health.ErrorMessage = err.Error()
+	}
 	info.Health = health
 	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	// Checked again: the health check above ran without the lock
+	if _, ok := ar.agents[agent.ID()]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateID, agent.ID())
+	}
 	ar.agents[agent.ID()] = info
-	ar.mu.Unlock()
 	return nil
 }
 
+// Unregister removes an agent and reports whether it was registered
+func (ar *AgentRegistry) Unregister(id string) bool {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	_, ok := ar.agents[id]
+	delete(ar.agents, id)
+	return ok
+}
+
 func (ar *AgentRegistry) Get(id string) (*AgentInfo, bool) {
 	ar.mu.RLock()
 	defer ar.mu.RUnlock()
@@ -55,9 +101,41 @@ func (ar *AgentRegistry) List() []AgentInfo {
 	for _, info := range ar.agents {
 		result = append(result, *info)
 	}
+	// Registration order, so listings don't shuffle between calls
+	slices.SortStableFunc(result, func(a, b AgentInfo) int {
+		if c := a.RegisteredAt.Compare(b.RegisteredAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Agent.ID(), b.Agent.ID())
+	})
 	return result
 }
 
+// RefreshCard re-fetches an agent's card and stores it, so listings and routing
+// see the agent's current skills. It reports whether the card changed.
+func (ar *AgentRegistry) RefreshCard(ctx context.Context, id string) (bool, error) {
+	info, ok := ar.Get(id)
+	if !ok {
+		return false, errAgentNotRegistered
+	}
+	refresher, ok := info.Agent.(cardRefresher)
+	if !ok {
+		return false, errCardNotRefreshable
+	}
+	changed, err := refresher.RefreshCard(ctx)
+	if err != nil {
+		return false, err
+	}
+	card, err := info.Agent.GetCard()
+	if err != nil {
+		return changed, err
+	}
+	ar.mu.Lock()
+	info.Card = card
+	ar.mu.Unlock()
+	return changed, nil
+}
+
 func (ar *AgentRegistry) StartHealthChecks(interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	go func() {
@@ -78,14 +156,44 @@ func (ar *AgentRegistry) Stop() {
 }
 
 func (ar *AgentRegistry) checkAll() {
-	ar.mu.Lock()
-	defer ar.mu.Unlock()
+	// Checks run without the lock: a deep health probe can take seconds
+	ar.mu.RLock()
+	infos := make([]*AgentInfo, 0, len(ar.agents))
 	for _, info := range ar.agents {
+		infos = append(infos, info)
+	}
+	ar.mu.RUnlock()
+	for _, info := range infos {
+		start := time.Now()
 		health, err := info.Agent.CheckHealth()
 		if err != nil {
 			health.Status = "unhealthy"
 			health.ErrorMessage = err.Error()
 		}
+		if health.LatencyMs == 0 {
+			health.LatencyMs = time.Since(start).Milliseconds()
+		}
+		ar.mu.Lock()
 		info.Health = health
+		ar.mu.Unlock()
+		ar.metrics.HealthChecked(info.Agent.ID(), health.Status)
+		if _, ok := info.Agent.(cardRefresher); ok && health.Status == "healthy" {
+			ar.refreshCardQuietly(info.Agent.ID())
+		}
+	}
+}
+
+// refreshCardQuietly is the periodic refresh: failures only reach the debug log,
+// since the health check already reports an unreachable agent.
+func (ar *AgentRegistry) refreshCardQuietly(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	changed, err := ar.RefreshCard(ctx, id)
+	if err != nil {
+		ar.logger.Debugf("card refresh for %s failed: %v", id, err)
+		return
+	}
+	if changed {
+		ar.logger.Infof("agent %s updated its card", id)
 	}
 }
@@ -0,0 +1,59 @@
+package hub
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"agents-hub/internal/types"
+)
+
+func TestRegistryListOrder(t *testing.T) {
+	s := newTestServer(t)
+	idle := func(types.ExecutionContext) (types.ExecutionResult, error) { return types.ExecutionResult{}, nil }
+	ids := func() []string {
+		var out []string
+		for _, info := range s.registry.List() {
+			out = append(out, info.Agent.ID())
+		}
+		return out
+	}
+	for _, id := range []string{"b-agent", "c-agent", "a-agent"} {
+		registerStub(t, s, id, idle)
+		// Registration times must differ for the order to be by registration
+		time.Sleep(time.Millisecond)
+	}
+
+	want := []string{"b-agent", "c-agent", "a-agent"}
+	for range 20 {
+		if got := ids(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("List = %v, want %v", got, want)
+		}
+	}
+
+	if err := s.registry.Register(&stubAgent{id: "c-agent", execute: idle}); !errors.Is(err, ErrDuplicateID) {
+		t.Fatalf("duplicate register error = %v, want ErrDuplicateID", err)
+	}
+	if got := ids(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("List after a rejected duplicate = %v, want %v", got, want)
+	}
+
+	// Re-registering moves an agent to the end and leaves the rest in place
+	s.registry.Unregister("b-agent")
+	registerStub(t, s, "b-agent", idle)
+	if got, want := ids(), []string{"c-agent", "a-agent", "b-agent"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("List after re-registering = %v, want %v", got, want)
+	}
+
+	// Agents registered at the same moment are ordered by ID
+	s.registry.mu.Lock()
+	at := time.Now().UTC()
+	for _, info := range s.registry.agents {
+		info.RegisteredAt = at
+	}
+	s.registry.mu.Unlock()
+	if got, want := ids(), []string{"a-agent", "b-agent", "c-agent"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("List with equal registration times = %v, want %v", got, want)
+	}
+}
@@ -31,9 +31,10 @@ func (r *RemoteAgentRegistry) DiscoverAndRegister(ctx context.Context, cardURL s
 	}
 
 	r.mu.Lock()
-	// Check if already registered
+	// Rediscovering a remote agent replaces it; any other agent keeps its ID
 	if existing, ok := r.remoteAgents[agent.ID()]; ok {
 		existing.Shutdown()
+		r.mainRegistry.Unregister(agent.ID())
 	}
 	r.remoteAgents[agent.ID()] = agent
 	r.mu.Unlock()
@@ -61,8 +62,7 @@ func (r *RemoteAgentRegistry) RemoveRemoteAgent(id string) error {
 
 	agent.Shutdown()
 	delete(r.remoteAgents, id)
-
-	// Note: we don't remove from main registry as it doesn't support removal
+	r.mainRegistry.Unregister(id)
 	return nil
 }
 
@@ -88,10 +88,12 @@ func (r *RemoteAgentRegistry) List() []*agents.RemoteAgent {
 
 // RemoteAgentInfo contains information about a remote agent
 type RemoteAgentInfo struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	CardURL string `json:"cardUrl"`
-	Alias   string `json:"alias"`
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	CardURL         string   `json:"cardUrl"`
+	Alias           string   `json:"alias"`
+	SecuritySchemes []string `json:"securitySchemes,omitempty"`
+	HasCredentials  bool     `json:"hasCredentials"`
 }
 
 // ListInfo returns info about all remote agents
@@ -102,10 +104,12 @@ func (r *RemoteAgentRegistry) ListInfo() []RemoteAgentInfo {
 	result := make([]RemoteAgentInfo, 0, len(r.remoteAgents))
 	for _, agent := range r.remoteAgents {
 		result = append(result, RemoteAgentInfo{
-			ID:      agent.ID(),
-			Name:    agent.Name(),
-			CardURL: agent.CardURL(),
-			Alias:   agent.Alias(),
+			ID:              agent.ID(),
+			Name:            agent.Name(),
+			CardURL:         agent.CardURL(),
+			Alias:           agent.Alias(),
+			SecuritySchemes: agent.SecuritySchemes(),
+			HasCredentials:  agent.HasCredentials(),
 		})
 	}
 	return result
@@ -1,11 +1,24 @@
 package hub
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
 
 	"agents-hub/internal/jsonrpc"
 )
 
+// Caller issues JSON-RPC calls against a hub, either in-process or over a transport
+type Caller interface {
+	Call(ctx context.Context, method string, params []byte) (jsonrpc.Response, error)
+}
+
 type LocalCaller struct {
 	handler *jsonrpc.Handler
 }
@@ -19,3 +32,138 @@ func (c *LocalCaller) Call(ctx context.Context, method string, params []byte) (j
 	resp := c.handler.Handle(ctx, req)
 	return resp, nil
 }
+
+// maxIdleSocketConns bounds how many idle connections a SocketCaller keeps open
+const maxIdleSocketConns = 4
+
+// SocketCaller calls a hub running in another process over its unix socket.
+// Connections are reused across calls; concurrent calls each get their own
+// connection so a long message/send does not hold up status refreshes.
+type SocketCaller struct {
+	socketPath string
+	mu         sync.Mutex
+	idle       []*socketConn
+	closed     bool
+}
+
+type socketConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func NewSocketCaller(socketPath string) *SocketCaller {
+	return &SocketCaller{socketPath: socketPath}
+}
+
+// Call sends one request. A pooled connection that turns out to be stale (for
+// example after the hub restarted) is dropped and the call retried on a fresh one,
+// but only when the request never went out: once it is written the hub may be
+// running it, and sending it again could run a message/send twice.
+func (c *SocketCaller) Call(ctx context.Context, method string, params []byte) (jsonrpc.Response, error) {
+	id := jsonrpc.NextID("socket")
+	data, err := json.Marshal(jsonrpc.Request{JSONRPC: "2.0", Method: method, Params: params, ID: id})
+	if err != nil {
+		return jsonrpc.Response{}, err
+	}
+	for {
+		sc, reused, err := c.get(ctx)
+		if err != nil {
+			return jsonrpc.Response{}, err
+		}
+		resp, sent, err := sc.roundTrip(ctx, data, id)
+		if err == nil {
+			c.put(sc)
+			return resp, nil
+		}
+		_ = sc.conn.Close()
+		if ctx.Err() != nil {
+			return jsonrpc.Response{}, ctx.Err()
+		}
+		if !reused || sent {
+			return jsonrpc.Response{}, err
+		}
+	}
+}
+
+// Close drops all idle connections; later calls fail
+func (c *SocketCaller) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for _, sc := range c.idle {
+		_ = sc.conn.Close()
+	}
+	c.idle = nil
+	return nil
+}
+
+func (c *SocketCaller) get(ctx context.Context) (*socketConn, bool, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, false, net.ErrClosed
+	}
+	for n := len(c.idle); n > 0; n = len(c.idle) {
+		sc := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		if sc.alive() {
+			c.mu.Unlock()
+			return sc, true, nil
+		}
+		_ = sc.conn.Close()
+	}
+	c.mu.Unlock()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", c.socketPath)
+	if err != nil {
+		return nil, false, err
+	}
+	return &socketConn{conn: conn, reader: bufio.NewReader(conn)}, false, nil
+}
+
+func (c *SocketCaller) put(sc *socketConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || len(c.idle) >= maxIdleSocketConns {
+		_ = sc.conn.Close()
+		return
+	}
+	c.idle = append(c.idle, sc)
+}
+
+// alive reports whether an idle connection is still open. The hub closing it shows up
+// as EOF on a short read, which otherwise times out; unexpected bytes, such as a late
+// answer, make it unusable too.
+func (sc *socketConn) alive() bool {
+	_ = sc.conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+	_, err := sc.reader.Peek(1)
+	_ = sc.conn.SetReadDeadline(time.Time{})
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// roundTrip writes one request and reads its response; sent reports whether the
+// request was written. A reply carrying a different ID (say, the late answer to an
+// earlier call that timed out) is an error.
+func (sc *socketConn) roundTrip(ctx context.Context, data []byte, id string) (resp jsonrpc.Response, sent bool, err error) {
+	deadline, _ := ctx.Deadline()
+	_ = sc.conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() {
+		_ = sc.conn.SetDeadline(time.Now())
+	})
+	defer stop()
+	if n, err := sc.conn.Write(append(data, '\n')); err != nil {
+		return jsonrpc.Response{}, n > 0, err
+	}
+	line, err := sc.reader.ReadBytes('\n')
+	if err != nil {
+		return jsonrpc.Response{}, true, err
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(line), &resp); err != nil {
+		return jsonrpc.Response{}, true, err
+	}
+	if !jsonrpc.SameID(resp.ID, id) {
+		return jsonrpc.Response{}, true, fmt.Errorf("response id %v does not match request id %s", resp.ID, id)
+	}
+	return resp, true, nil
+}
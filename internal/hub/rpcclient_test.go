@@ -0,0 +1,143 @@
+package hub
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"agents-hub/internal/jsonrpc"
+)
+
+// fakeSocketHub answers JSON-RPC requests on a unix socket. reply decides, per
+// request in arrival order, whether to answer it (true) or drop the connection
+// after reading it (false); after answering, a connection is closed when
+// closeAfter is set, which leaves the caller's pooled connection stale.
+type fakeSocketHub struct {
+	path       string
+	reply      func(n int) bool
+	closeAfter bool
+	mu         sync.Mutex
+	methods    []string
+}
+
+func startFakeSocketHub(t *testing.T, reply func(n int) bool, closeAfter bool) *fakeSocketHub {
+	t.Helper()
+	// Unix socket paths are short, so stay out of the long test temp dir
+	dir, err := os.MkdirTemp("", "rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	hub := &fakeSocketHub{path: filepath.Join(dir, "hub.sock"), reply: reply, closeAfter: closeAfter}
+	listener, err := net.Listen("unix", hub.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go hub.serve(conn)
+		}
+	}()
+	return hub
+}
+
+func (h *fakeSocketHub) serve(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var req jsonrpc.Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			return
+		}
+		h.mu.Lock()
+		h.methods = append(h.methods, req.Method)
+		n := len(h.methods)
+		h.mu.Unlock()
+		if !h.reply(n) {
+			return
+		}
+		data, _ := json.Marshal(jsonrpc.Response{JSONRPC: "2.0", Result: "ok", ID: req.ID})
+		if _, err := conn.Write(append(data, '\n')); err != nil || h.closeAfter {
+			return
+		}
+	}
+}
+
+func (h *fakeSocketHub) received() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.methods)
+}
+
+func TestSocketCallerRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		reply      func(n int) bool
+		closeAfter bool
+		wantErr    bool
+		wantSent   int // requests the hub read over both calls
+	}{
+		{"reused connection", func(int) bool { return true }, false, false, 2},
+		{"stale pooled connection is replaced", func(int) bool { return true }, true, false, 2},
+		{"dropped mid-response is not resent", func(n int) bool { return n == 1 }, false, true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := startFakeSocketHub(t, tt.reply, tt.closeAfter)
+			caller := NewSocketCaller(hub.path)
+			defer caller.Close()
+			if _, err := caller.Call(context.Background(), "hub/status", []byte(`{}`)); err != nil {
+				t.Fatalf("first call: %v", err)
+			}
+			_, err := caller.Call(context.Background(), "message/send", []byte(`{}`))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("second call error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := hub.received(); got != tt.wantSent {
+				t.Fatalf("hub read %d requests, want %d", got, tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestSocketCallerRejectsMismatchedID(t *testing.T) {
+	dir, err := os.MkdirTemp("", "rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, "hub.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Answer with the ID of some earlier call, as a late reply would
+		if _, err := bufio.NewReader(conn).ReadBytes('\n'); err == nil {
+			_, _ = conn.Write([]byte(`{"jsonrpc":"2.0","result":"ok","id":"socket-0"}` + "\n"))
+		}
+	}()
+
+	caller := NewSocketCaller(path)
+	defer caller.Close()
+	if _, err := caller.Call(context.Background(), "hub/status", []byte(`{}`)); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("error = %v, want an ID mismatch", err)
+	}
+}
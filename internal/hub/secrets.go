@@ -0,0 +1,130 @@
+package hub
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"agents-hub/internal/utils"
+)
+
+// SecretStore persists remote-agent credentials and per-agent environment variables
+// separately from settings.json so they can be written with owner-only permissions.
+type SecretStore struct {
+	mu          sync.RWMutex
+	tokens      map[string]string
+	agentEnv    map[string]map[string]string // agent ID -> env var -> value
+	persistPath string
+}
+
+// NewSecretStore creates an empty secret store
+func NewSecretStore() *SecretStore {
+	return &SecretStore{tokens: make(map[string]string), agentEnv: make(map[string]map[string]string)}
+}
+
+func (ss *SecretStore) SetPersistence(path string) {
+	ss.persistPath = path
+}
+
+// RemoteToken returns the bearer token stored for a remote agent
+func (ss *SecretStore) RemoteToken(agentID string) string {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	return ss.tokens[agentID]
+}
+
+// SetRemoteToken stores (or clears, when token is empty) the bearer token for a remote agent
+func (ss *SecretStore) SetRemoteToken(agentID, token string) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	token = strings.TrimSpace(token)
+	if token == "" {
+		delete(ss.tokens, agentID)
+	} else {
+		ss.tokens[agentID] = token
+	}
+	return ss.persistLocked()
+}
+
+// AgentEnv returns a copy of the environment variables stored for an agent
+func (ss *SecretStore) AgentEnv(agentID string) map[string]string {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	env := make(map[string]string, len(ss.agentEnv[agentID]))
+	for key, value := range ss.agentEnv[agentID] {
+		env[key] = value
+	}
+	return env
+}
+
+// EnvAgents returns the IDs of agents with stored environment variables
+func (ss *SecretStore) EnvAgents() []string {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	ids := make([]string, 0, len(ss.agentEnv))
+	for id := range ss.agentEnv {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// SetAgentEnv stores (or clears, when value is empty) an environment variable for an agent
+func (ss *SecretStore) SetAgentEnv(agentID, key, value string) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if value == "" {
+		delete(ss.agentEnv[agentID], key)
+		if len(ss.agentEnv[agentID]) == 0 {
+			delete(ss.agentEnv, agentID)
+		}
+	} else {
+		if ss.agentEnv[agentID] == nil {
+			ss.agentEnv[agentID] = make(map[string]string)
+		}
+		ss.agentEnv[agentID][key] = value
+	}
+	return ss.persistLocked()
+}
+
+func (ss *SecretStore) Load() error {
+	if ss.persistPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(ss.persistPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var stored struct {
+		RemoteTokens map[string]string            `json:"remoteTokens"`
+		AgentEnv     map[string]map[string]string `json:"agentEnv"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for id, token := range stored.RemoteTokens {
+		ss.tokens[id] = token
+	}
+	for id, env := range stored.AgentEnv {
+		ss.agentEnv[id] = env
+	}
+	return nil
+}
+
+func (ss *SecretStore) persistLocked() error {
+	if ss.persistPath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(map[string]any{"remoteTokens": ss.tokens, "agentEnv": ss.agentEnv}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(ss.persistPath, data, 0o600)
+}
@@ -2,18 +2,23 @@ package hub
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"agents-hub/internal/agents"
 	"agents-hub/internal/jsonrpc"
 	"agents-hub/internal/types"
 	"agents-hub/internal/utils"
+	"agents-hub/internal/version"
 )
 
 type Server struct {
@@ -24,14 +29,29 @@ type Server struct {
 	tasks          *TaskManager
 	contexts       *ContextManager
 	sessions       *SessionManager
+	secrets        *SecretStore
+	artifacts      *ArtifactStore
+	deadLetters    *DeadLetterStore
 	handler        *jsonrpc.Handler
+	metrics        *Metrics
+	a2aCaller      *A2ARoutingCaller
 	startTime      time.Time
 	settings       Settings
+	settingsMu     sync.RWMutex // guards settings and cfg.Orchestrator.Agents
+	runningMu      sync.Mutex
+	running        map[string]runningTask // task ID -> in-flight message/send
+	queue          *TaskQueue             // runs non-blocking message/sends
+}
+
+// runningTask is a message/send whose agent is still executing
+type runningTask struct {
+	agent     agents.Agent
+	messageID string
 }
 
 func NewServer(cfg Config, logger *utils.Logger) *Server {
 	if cfg.DataDir == "" {
-		cfg.DataDir = filepath.Join(os.Getenv("HOME"), ".a2a-hub")
+		cfg.DataDir = DefaultDataDir()
 	}
 	registry := NewAgentRegistry(logger)
 	server := &Server{
@@ -42,38 +62,75 @@ func NewServer(cfg Config, logger *utils.Logger) *Server {
 		tasks:          NewTaskManager(),
 		contexts:       NewContextManager(),
 		sessions:       NewSessionManager(),
+		secrets:        NewSecretStore(),
+		artifacts:      NewArtifactStore(),
+		deadLetters:    NewDeadLetterStore(),
 		handler:        jsonrpc.NewHandler(),
+		metrics:        NewMetrics(),
 		startTime:      time.Now().UTC(),
 		settings:       Settings{OrchestratorAgents: append([]string{}, cfg.Orchestrator.Agents...)},
+		running:        make(map[string]runningTask),
+		queue:          NewTaskQueue(cfg.Tasks.Workers, cfg.Tasks.QueueSize),
 	}
 	server.tasks.SetPersistence(filepath.Join(cfg.DataDir, "tasks.json"))
 	server.contexts.SetPersistence(filepath.Join(cfg.DataDir, "contexts.json"))
+	server.contexts.SetMaxMessages(cfg.Contexts.MaxMessages)
 	server.sessions.SetDataDir(cfg.DataDir)
+	server.secrets.SetPersistence(filepath.Join(cfg.DataDir, "secrets.json"))
+	server.artifacts.SetDir(filepath.Join(cfg.DataDir, "artifacts"))
+	server.deadLetters.SetPersistence(filepath.Join(cfg.DataDir, "dead_letters.json"))
+	registry.SetMetrics(server.metrics)
 	return server
 }
 
 func (s *Server) InitAgents(baseURL string) error {
 	caller := NewLocalCaller(s.handler)
-	a2aCaller := NewA2ARoutingCaller(caller, baseURL, s.cfg.HTTP.Enabled)
-	agentsList := []agents.Agent{
+	a2aCaller := NewA2ARoutingCaller(caller, baseURL, s.cfg.HTTP.Enabled, s.cfg.HTTP.Token, s.logger)
+	s.a2aCaller = a2aCaller
+	for _, id := range s.cfg.EnabledAgents {
+		if !slices.Contains(BuiltinAgentIDs, id) {
+			s.logger.Warnf("unknown built-in agent %q in enabled agents (known: %s)", id, strings.Join(BuiltinAgentIDs, ", "))
+		}
+	}
+	var agentsList []agents.Agent
+	for _, agent := range []agents.Agent{
 		agents.NewClaudeAgent(baseURL),
 		agents.NewGeminiAgent(baseURL),
 		agents.NewCodexAgent(baseURL),
 		agents.NewVibeAgent(baseURL),
+	} {
+		if s.builtinEnabled(agent.ID()) {
+			agentsList = append(agentsList, agent)
+		}
 	}
-	if len(s.cfg.Orchestrator.Agents) > 0 {
-		orchestratorAgent := agents.Agent(agents.NewOrchestrator(a2aCaller, baseURL, s.cfg.Orchestrator.Agents))
-		if strings.TrimSpace(s.cfg.Orchestrator.RouterAgent) != "" {
-			orchestratorAgent = agents.NewLLMOrchestrator(a2aCaller, baseURL, s.cfg.Orchestrator.Agents, s.cfg.Orchestrator.RouterAgent)
+	if configured := s.configuredDelegates(); !s.cfg.Orchestrator.Disabled && len(configured) > 0 {
+		delegates := s.enabledDelegates(configured)
+		// Only one agent may be "orchestrator": the LLM one when a router is configured
+		var orchestratorAgent agents.Agent
+		if len(s.cfg.Orchestrator.RouterAgents) > 0 {
+			orchestratorAgent = agents.NewLLMOrchestrator(a2aCaller, baseURL, delegates, s.cfg.Orchestrator.RouterAgents, s.MaxRoutingTargets())
+			s.logger.Infof("orchestrator: LLM routing via %s", strings.Join(s.cfg.Orchestrator.RouterAgents, ", "))
+		} else {
+			orchestratorAgent = agents.NewOrchestrator(a2aCaller, baseURL, delegates)
+			s.logger.Infof("orchestrator: round-robin routing (no router agent configured)")
 		}
 		agentsList = append([]agents.Agent{orchestratorAgent}, agentsList...)
 	}
 	for _, agent := range agentsList {
 		if err := s.registry.Register(agent); err != nil {
+			if errors.Is(err, ErrDuplicateID) {
+				s.logger.Warnf("skipping %s: an agent with that ID is already registered", agent.ID())
+				continue
+			}
 			s.logger.Warnf("failed to register %s: %v", agent.ID(), err)
+			continue
+		}
+		if info, ok := s.registry.Get(agent.ID()); ok && info.Health.Status == "unhealthy" {
+			s.logger.Warnf("agent %s is unhealthy: %s", agent.ID(), info.Health.ErrorMessage)
 		}
 	}
 	s.applySettingsToAgents()
+	s.dropStaleLastAgent()
 	return nil
 }
 
@@ -83,11 +140,19 @@ func (s *Server) RegisterHandlers() {
 	s.handler.Register("hub/agents/get", s.handleAgentsGet)
 	s.handler.Register("hub/agents/health", s.handleAgentsHealth)
 	s.handler.Register("hub/agents/discover", s.handleAgentsDiscover)
+	s.handler.Register("hub/agents/refresh", s.handleAgentsRefresh)
 	s.handler.Register("hub/agents/remove-remote", s.handleAgentsRemoveRemote)
 	s.handler.Register("hub/agents/list-remote", s.handleAgentsListRemote)
 	s.handler.Register("hub/tasks/list", s.handleTasksList)
+	s.handler.Register("hub/tasks/prune", s.handleTasksPrune)
+	s.handler.Register("hub/tasks/deadletters", s.handleTasksDeadLetters)
+	s.handler.Register("hub/artifacts/get", s.handleArtifactGet)
 	s.handler.Register("hub/contexts/list", s.handleContextsList)
+	s.handler.Register("hub/contexts/get", s.handleContextsGet)
+	s.handler.Register("hub/contexts/prune", s.handleContextsPrune)
+	s.handler.Register("hub/settings/reload", s.handleSettingsReload)
 	s.handler.Register("message/send", s.handleMessageSend)
+	s.handler.Register("message/replay", s.handleMessageReplay)
 	s.handler.Register("tasks/get", s.handleTaskGet)
 	s.handler.Register("tasks/cancel", s.handleTaskCancel)
 }
@@ -124,14 +189,27 @@ func (s *Server) RemoteRegistry() *RemoteAgentRegistry {
 	return s.remoteRegistry
 }
 
+func (s *Server) Metrics() *Metrics {
+	return s.metrics
+}
+
+// WriteMetrics renders hub metrics in Prometheus text format
+func (s *Server) WriteMetrics(w io.Writer) error {
+	return s.metrics.WriteText(w, time.Since(s.startTime), s.tasks.CountByState(), s.registry.List())
+}
+
 func (s *Server) LoadState() error {
 	if err := s.EnsureDataDir(); err != nil {
 		return err
 	}
+	if err := s.secrets.Load(); err != nil {
+		return err
+	}
 	if err := s.LoadSettings(); err != nil {
 		return err
 	}
 	s.applySettingsToAgents()
+	s.dropStaleLastAgent()
 	if err := s.contexts.Load(); err != nil {
 		return err
 	}
@@ -141,33 +219,137 @@ func (s *Server) LoadState() error {
 	if err := s.sessions.Load(); err != nil {
 		return err
 	}
+	if err := s.deadLetters.Load(); err != nil {
+		return err
+	}
 	return nil
 }
 
+// PruneTasks drops terminal tasks older than olderThan, keeping the configured number of recent tasks
+func (s *Server) PruneTasks(olderThan time.Duration) int {
+	pruned := s.tasks.Prune(time.Now().UTC().Add(-olderThan), s.cfg.Tasks.KeepRecent)
+	for _, id := range pruned {
+		if err := s.artifacts.RemoveTask(id); err != nil {
+			s.logger.Warnf("failed to remove artifacts for task %s: %v", id, err)
+		}
+	}
+	if len(pruned) > 0 {
+		s.logger.Debugf("pruned %d tasks older than %s", len(pruned), olderThan)
+	}
+	return len(pruned)
+}
+
+// PruneContexts drops contexts inactive for longer than the configured retention.
+// It is a no-op when retention is off.
+func (s *Server) PruneContexts() int {
+	days := s.cfg.Contexts.RetentionDays
+	if days <= 0 {
+		return 0
+	}
+	pruned := s.contexts.Prune(time.Now().UTC().Add(-time.Duration(days) * 24 * time.Hour))
+	if pruned > 0 {
+		s.logger.Debugf("pruned %d contexts inactive for %d days", pruned, days)
+	}
+	return pruned
+}
+
+// StartTaskPruning periodically prunes tasks past the configured TTL and contexts past
+// the configured retention until ctx is done. It is a no-op when neither is configured.
+func (s *Server) StartTaskPruning(ctx context.Context, interval time.Duration) {
+	if s.cfg.Tasks.TTL <= 0 && s.cfg.Contexts.RetentionDays <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if s.cfg.Tasks.TTL > 0 {
+					s.PruneTasks(s.cfg.Tasks.TTL)
+				}
+				s.PruneContexts()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// StopTaskQueue stops taking non-blocking sends and waits up to the configured
+// drain timeout for the queued ones to run; the rest are canceled
+func (s *Server) StopTaskQueue() {
+	_, queued, running := s.queue.Stats()
+	if queued+running > 0 {
+		s.logger.Infof("waiting up to %s for %d queued and %d running task(s)", s.cfg.Tasks.DrainTimeout, queued, running)
+	}
+	if !s.queue.Stop(s.cfg.Tasks.DrainTimeout) {
+		s.logger.Warnf("task queue did not drain in %s; canceled the remaining tasks", s.cfg.Tasks.DrainTimeout)
+	}
+}
+
+// FlushState writes any batched state to disk
+func (s *Server) FlushState() {
+	if err := s.tasks.Flush(); err != nil {
+		s.logger.Warnf("failed to flush tasks: %v", err)
+	}
+}
+
 func (s *Server) applySettingsToAgents() {
+	settings := s.settingsSnapshot()
 	if info, ok := s.registry.Get("claude-code"); ok {
 		if setter, ok := info.Agent.(interface{ SetDefaultConfig(types.ClaudeConfig) }); ok {
-			setter.SetDefaultConfig(s.GetClaudeConfig())
+			setter.SetDefaultConfig(claudeConfigFromSettings(settings.Claude))
 		}
 	}
 	if info, ok := s.registry.Get("codex"); ok {
 		if setter, ok := info.Agent.(interface{ SetDefaultConfig(types.CodexConfig) }); ok {
-			setter.SetDefaultConfig(s.GetCodexConfig())
+			setter.SetDefaultConfig(codexConfigFromSettings(settings.Codex))
 		}
 	}
 	if info, ok := s.registry.Get("gemini"); ok {
 		if setter, ok := info.Agent.(interface{ SetDefaultConfig(types.GeminiConfig) }); ok {
-			setter.SetDefaultConfig(s.GetGeminiConfig())
+			setter.SetDefaultConfig(geminiConfigFromSettings(settings.Gemini))
 		}
 	}
 	if info, ok := s.registry.Get("vibe"); ok {
 		if setter, ok := info.Agent.(interface{ SetDefaultConfig(types.VibeConfig) }); ok {
-			setter.SetDefaultConfig(s.GetVibeConfig())
+			setter.SetDefaultConfig(vibeConfigFromSettings(settings.Vibe))
+		}
+	}
+	for _, info := range s.registry.List() {
+		if setter, ok := info.Agent.(interface{ SetMaxOutputTokens(int) }); ok {
+			setter.SetMaxOutputTokens(settings.MaxOutputTokens[info.Agent.ID()])
+		}
+		if setter, ok := info.Agent.(interface{ SetHealthProbe(bool) }); ok {
+			setter.SetHealthProbe(slices.Contains(settings.HealthProbes, info.Agent.ID()))
+		}
+		if setter, ok := info.Agent.(interface{ SetJSONStream(bool) }); ok {
+			setter.SetJSONStream(slices.Contains(settings.JSONStreams, info.Agent.ID()))
+		}
+		if setter, ok := info.Agent.(interface{ SetEnv(map[string]string) }); ok {
+			setter.SetEnv(s.secrets.AgentEnv(info.Agent.ID()))
+		}
+		if setter, ok := info.Agent.(interface{ SetMaxTargets(int) }); ok {
+			setter.SetMaxTargets(settings.MaxRoutingTargets)
+		}
+		if setter, ok := info.Agent.(interface{ SetRouteUnhealthy(bool) }); ok {
+			setter.SetRouteUnhealthy(settings.RouteUnhealthy)
+		}
+		if setter, ok := info.Agent.(interface{ SetAllowNonDelegates(bool) }); ok {
+			setter.SetAllowNonDelegates(settings.AllowNonDelegates)
+		}
+		if setter, ok := info.Agent.(interface{ SetLaunchLogger(func([]string)) }); ok {
+			id := info.Agent.ID()
+			setter.SetLaunchLogger(func(argv []string) {
+				s.logger.Debugf("launching %s: %s", id, utils.JoinArgs(s.RedactArgs(argv)))
+			})
 		}
 	}
 }
 
-func extractWorkingDir(metadata map[string]any) string {
+// ExtractWorkingDir reads the working directory a message asks for from its metadata
+func ExtractWorkingDir(metadata map[string]any) string {
 	if metadata == nil {
 		return ""
 	}
@@ -184,7 +366,17 @@ func extractWorkingDir(metadata map[string]any) string {
 }
 
 func (s *Server) Config() Config {
-	return s.cfg
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	cfg := s.cfg
+	cfg.Orchestrator.Agents = append([]string{}, s.cfg.Orchestrator.Agents...)
+	return cfg
+}
+
+// RedactArgs masks the values of a command line's sensitive arguments, as named by
+// the logging redact patterns
+func (s *Server) RedactArgs(args []string) []string {
+	return utils.RedactArgs(args, s.cfg.Logging.RedactPatterns)
 }
 
 func (s *Server) OrchestratorAgents() []string {
@@ -194,27 +386,69 @@ func (s *Server) OrchestratorAgents() []string {
 			return getter.Delegates()
 		}
 	}
+	return s.configuredDelegates()
+}
+
+// configuredDelegates returns the orchestrator delegates as configured, before
+// disabled agents are dropped
+func (s *Server) configuredDelegates() []string {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
 	return append([]string{}, s.cfg.Orchestrator.Agents...)
 }
 
+// builtinEnabled reports whether a built-in agent should be registered
+func (s *Server) builtinEnabled(id string) bool {
+	return s.cfg.EnabledAgents == nil || slices.Contains(s.cfg.EnabledAgents, id)
+}
+
+// enabledDelegates drops built-in agents that aren't registered from an orchestrator delegate list
+func (s *Server) enabledDelegates(ids []string) []string {
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if slices.Contains(BuiltinAgentIDs, id) && !s.builtinEnabled(id) {
+			continue
+		}
+		out = append(out, id)
+	}
+	// Preferred agents go first: the orchestrators break ties by delegate order
+	priorities := s.AgentPriorities()
+	slices.SortStableFunc(out, func(a, b string) int {
+		return comparePriority(priorities[a], priorities[b])
+	})
+	return out
+}
+
 func (s *Server) UpdateOrchestratorAgents(ids []string) bool {
-	s.cfg.Orchestrator.Agents = append([]string{}, ids...)
-	s.updateSettingsAgents(ids)
-	if err := s.SaveSettings(); err != nil {
+	err := s.updateSettings(func(settings *Settings) error {
+		s.cfg.Orchestrator.Agents = append([]string{}, ids...)
+		settings.OrchestratorAgents = append([]string{}, ids...)
+		return nil
+	})
+	if err != nil {
 		s.logger.Warnf("failed to save settings: %v", err)
 	}
+	return s.applyOrchestratorDelegates()
+}
+
+// applyOrchestratorDelegates hands the orchestrator its enabled delegates in priority order
+func (s *Server) applyOrchestratorDelegates() bool {
 	info, ok := s.registry.Get("orchestrator")
 	if !ok {
 		return false
 	}
 	if setter, ok := info.Agent.(interface{ SetDelegates([]string) }); ok {
-		setter.SetDelegates(ids)
+		setter.SetDelegates(s.enabledDelegates(s.configuredDelegates()))
 		return true
 	}
 	return false
 }
 
 func (s *Server) handleHubStatus(ctx context.Context, params json.RawMessage) (any, *jsonrpc.RPCError) {
+	var req struct {
+		IncludeAgents bool `json:"includeAgents"`
+	}
+	_ = json.Unmarshal(params, &req)
 	agentsInfo := s.registry.List()
 	resultAgents := make([]map[string]any, 0, len(agentsInfo))
 	healthy := 0
@@ -233,40 +467,81 @@ func (s *Server) handleHubStatus(ctx context.Context, params json.RawMessage) (a
 		default:
 			unknown++
 		}
-		resultAgents = append(resultAgents, map[string]any{
+		entry := map[string]any{
 			"id":     info.Agent.ID(),
 			"name":   info.Agent.Name(),
 			"status": status,
-		})
+		}
+		if req.IncludeAgents {
+			entry["lastCheck"] = info.Health.LastCheck.Format(time.RFC3339Nano)
+			entry["latencyMs"] = info.Health.LatencyMs
+			entry["registeredAt"] = info.RegisteredAt.Format(time.RFC3339Nano)
+			if info.Health.ErrorMessage != "" {
+				entry["errorMessage"] = info.Health.ErrorMessage
+			}
+		}
+		resultAgents = append(resultAgents, entry)
 	}
+	build := version.Get()
 	return map[string]any{
-		"version":     "1.0.0",
+		"version":     build.Version,
+		"commit":      build.Commit,
+		"buildDate":   build.Date,
 		"uptime":      int(time.Since(s.startTime).Seconds()),
 		"agents":      resultAgents,
-		"activeTasks": 0,
-		"totalTasks":  len(s.tasks.List("", "", 0, 0)),
+		"activeTasks": s.metrics.ActiveTasks(),
+		"totalTasks":  len(s.tasks.List(TaskFilter{}, 0, 0)),
 		"total":       len(agentsInfo),
 		"healthy":     healthy,
 		"degraded":    degraded,
 		"unhealthy":   unhealthy,
 		"unknown":     unknown,
+		"a2aRouting":  s.a2aRoutingStatus(),
+		"queue":       s.queueStatus(),
 	}, nil
 }
 
+func (s *Server) a2aRoutingStatus() map[string]any {
+	if s.a2aCaller == nil {
+		return map[string]any{"live": false, "error": "not initialized"}
+	}
+	status := map[string]any{"live": s.a2aCaller.RemoteLive()}
+	if err := s.a2aCaller.LastError(); err != nil {
+		status["error"] = err.Error()
+	}
+	return status
+}
+
+func (s *Server) queueStatus() map[string]any {
+	workers, queued, running := s.queue.Stats()
+	return map[string]any{"workers": workers, "queued": queued, "running": running}
+}
+
 func (s *Server) handleAgentsList(ctx context.Context, params json.RawMessage) (any, *jsonrpc.RPCError) {
 	var req struct {
 		IncludeHealth bool `json:"includeHealth"`
+		agentQuery
 	}
 	_ = json.Unmarshal(params, &req)
-	infos := s.registry.List()
+	if err := req.agentQuery.validate(); err != nil {
+		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: err.Error()}
+	}
+	infos := req.agentQuery.apply(s.registry.List())
 	result := make([]map[string]any, 0, len(infos))
 	for _, info := range infos {
 		entry := map[string]any{
 			"id":           info.Agent.ID(),
 			"name":         info.Agent.Name(),
+			"type":         agentType(info.Agent),
 			"card":         info.Card,
 			"registeredAt": info.RegisteredAt.Format(time.RFC3339Nano),
 		}
+		if dir := s.LastWorkingDir(info.Agent.ID()); dir != "" {
+			entry["lastWorkingDir"] = dir
+		}
+		if priority := s.AgentPriority(info.Agent.ID()); priority > 0 {
+			entry["priority"] = priority
+		}
 		if req.IncludeHealth {
 			entry["health"] = info.Health
 		}
@@ -287,11 +562,13 @@ func (s *Server) handleAgentsGet(ctx context.Context, params json.RawMessage) (a
 		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrAgentNotFound, Message: "agent not found"}
 	}
 	return map[string]any{
-		"id":           info.Agent.ID(),
-		"name":         info.Agent.Name(),
-		"card":         info.Card,
-		"health":       info.Health,
-		"registeredAt": info.RegisteredAt.Format(time.RFC3339Nano),
+		"id":             info.Agent.ID(),
+		"name":           info.Agent.Name(),
+		"card":           info.Card,
+		"health":         info.Health,
+		"capabilities":   info.Agent.GetCapabilities(),
+		"registeredAt":   info.RegisteredAt.Format(time.RFC3339Nano),
+		"lastWorkingDir": s.LastWorkingDir(info.Agent.ID()),
 	}, nil
 }
 
@@ -326,6 +603,8 @@ func (s *Server) handleAgentsDiscover(ctx context.Context, params json.RawMessag
 		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInternalError, Message: fmt.Sprintf("failed to discover agent: %v", err)}
 	}
 
+	s.applyRemoteCredentials()
+
 	// Persist the configuration
 	if err := s.AddRemoteAgent(req.CardURL, req.Alias); err != nil {
 		s.logger.Warnf("failed to persist remote agent config: %v", err)
@@ -345,6 +624,49 @@ func (s *Server) handleAgentsDiscover(ctx context.Context, params json.RawMessag
 	return map[string]any{"registered": true}, nil
 }
 
+func (s *Server) handleAgentsRefresh(ctx context.Context, params json.RawMessage) (any, *jsonrpc.RPCError) {
+	var req struct {
+		AgentID string `json:"agentId"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: "invalid params"}
+		}
+	}
+
+	refreshCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if req.AgentID != "" {
+		changed, err := s.registry.RefreshCard(refreshCtx, req.AgentID)
+		switch {
+		case errors.Is(err, errAgentNotRegistered):
+			return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrAgentNotFound, Message: "agent not found"}
+		case errors.Is(err, errCardNotRefreshable):
+			return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrUnsupported, Message: err.Error()}
+		case err != nil:
+			return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrAgentUnavailable, Message: fmt.Sprintf("failed to refresh card: %v", err)}
+		}
+		return map[string]any{"refreshed": []map[string]any{{"agentId": req.AgentID, "changed": changed}}}, nil
+	}
+
+	refreshed := []map[string]any{}
+	for _, info := range s.registry.List() {
+		if _, ok := info.Agent.(cardRefresher); !ok {
+			continue
+		}
+		entry := map[string]any{"agentId": info.Agent.ID(), "changed": false}
+		changed, err := s.registry.RefreshCard(refreshCtx, info.Agent.ID())
+		if err != nil {
+			entry["error"] = err.Error()
+		} else {
+			entry["changed"] = changed
+		}
+		refreshed = append(refreshed, entry)
+	}
+	return map[string]any{"refreshed": refreshed}, nil
+}
+
 func (s *Server) handleAgentsRemoveRemote(ctx context.Context, params json.RawMessage) (any, *jsonrpc.RPCError) {
 	var req struct {
 		AgentID string `json:"agentId"`
@@ -394,13 +716,76 @@ func (s *Server) handleAgentsListRemote(ctx context.Context, params json.RawMess
 
 func (s *Server) handleTasksList(ctx context.Context, params json.RawMessage) (any, *jsonrpc.RPCError) {
 	var req struct {
-		ContextID string          `json:"contextId"`
-		State     types.TaskState `json:"state"`
-		Limit     int             `json:"limit"`
-		Offset    int             `json:"offset"`
+		ContextID    string          `json:"contextId"`
+		ParentTaskID string          `json:"parentTaskId"`
+		State        types.TaskState `json:"state"`
+		Since        string          `json:"since"`
+		Until        string          `json:"until"`
+		Limit        int             `json:"limit"`
+		Offset       int             `json:"offset"`
 	}
 	_ = json.Unmarshal(params, &req)
-	return s.tasks.List(req.ContextID, req.State, req.Limit, req.Offset), nil
+	filter := TaskFilter{ContextID: req.ContextID, ParentTaskID: req.ParentTaskID, State: req.State}
+	now := time.Now()
+	var err error
+	if filter.Since, err = parseTimeBound(req.Since, now); err != nil {
+		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: "since " + err.Error()}
+	}
+	if filter.Until, err = parseTimeBound(req.Until, now); err != nil {
+		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: "until " + err.Error()}
+	}
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && filter.Since.After(filter.Until) {
+		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: "since must not be after until"}
+	}
+	return s.tasks.List(filter, req.Limit, req.Offset), nil
+}
+
+// parseTimeBound reads a since/until value: an RFC3339 time, or a duration such as 1h
+// meaning that long before now. An empty value gives the zero time, which doesn't bound.
+func parseTimeBound(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if at, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return at, nil
+	}
+	ago, err := time.ParseDuration(value)
+	if err != nil || ago < 0 {
+		return time.Time{}, errors.New("must be an RFC3339 time or a duration like 1h")
+	}
+	return now.Add(-ago), nil
+}
+
+func (s *Server) handleTasksPrune(ctx context.Context, params json.RawMessage) (any, *jsonrpc.RPCError) {
+	var req struct {
+		OlderThan string `json:"olderThan"`
+	}
+	_ = json.Unmarshal(params, &req)
+	ttl := s.cfg.Tasks.TTL
+	if strings.TrimSpace(req.OlderThan) != "" {
+		parsed, err := time.ParseDuration(req.OlderThan)
+		if err != nil {
+			return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: "olderThan must be a duration like 24h"}
+		}
+		ttl = parsed
+	}
+	if ttl <= 0 {
+		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: "olderThan required when no task TTL is configured"}
+	}
+	return map[string]any{
+		"pruned":    s.PruneTasks(ttl),
+		"olderThan": ttl.String(),
+	}, nil
+}
+
+func (s *Server) handleTasksDeadLetters(ctx context.Context, params json.RawMessage) (any, *jsonrpc.RPCError) {
+	var req struct {
+		AgentID string `json:"agentId"`
+		Limit   int    `json:"limit"`
+	}
+	_ = json.Unmarshal(params, &req)
+	return s.deadLetters.List(req.AgentID, req.Limit), nil
 }
 
 func (s *Server) handleContextsList(ctx context.Context, params json.RawMessage) (any, *jsonrpc.RPCError) {
@@ -412,21 +797,84 @@ func (s *Server) handleContextsList(ctx context.Context, params json.RawMessage)
 	result := make([]map[string]any, 0, len(contexts))
 	for _, ctx := range contexts {
 		result = append(result, map[string]any{
-			"id":        ctx.ID,
-			"createdAt": ctx.CreatedAt.Format(time.RFC3339Nano),
+			"id":           ctx.ID,
+			"createdAt":    ctx.CreatedAt.Format(time.RFC3339Nano),
+			"updatedAt":    ctx.lastActive().Format(time.RFC3339Nano),
+			"messageCount": len(ctx.History),
 		})
 	}
 	return result, nil
 }
 
+// handleContextsGet returns a context with its history, optionally only the last historyLength messages
+func (s *Server) handleContextsGet(ctx context.Context, params json.RawMessage) (any, *jsonrpc.RPCError) {
+	var req struct {
+		ID            string `json:"id"`
+		HistoryLength int    `json:"historyLength"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil || strings.TrimSpace(req.ID) == "" {
+		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: "id required"}
+	}
+	found, ok := s.contexts.Get(req.ID)
+	if !ok {
+		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrContextNotFound, Message: "context not found"}
+	}
+	history := s.contexts.GetHistoryWithLimit(req.ID, req.HistoryLength)
+	if history == nil {
+		history = []types.Message{}
+	}
+	return map[string]any{
+		"id":           found.ID,
+		"createdAt":    found.CreatedAt.Format(time.RFC3339Nano),
+		"updatedAt":    found.lastActive().Format(time.RFC3339Nano),
+		"messageCount": len(found.History),
+		"history":      history,
+	}, nil
+}
+
+func (s *Server) handleContextsPrune(ctx context.Context, params json.RawMessage) (any, *jsonrpc.RPCError) {
+	var req struct {
+		OlderThanDays int `json:"olderThanDays"`
+	}
+	_ = json.Unmarshal(params, &req)
+	days := req.OlderThanDays
+	if days == 0 {
+		days = s.cfg.Contexts.RetentionDays
+	}
+	if days <= 0 {
+		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: "olderThanDays must be positive"}
+	}
+	cutoff := time.Now().UTC().Add(-time.Duration(days) * 24 * time.Hour)
+	return map[string]any{
+		"pruned":        s.contexts.Prune(cutoff),
+		"olderThanDays": days,
+	}, nil
+}
+
+func (s *Server) handleSettingsReload(ctx context.Context, params json.RawMessage) (any, *jsonrpc.RPCError) {
+	if err := s.ReloadSettings(); err != nil {
+		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInternalError, Message: err.Error()}
+	}
+	return map[string]any{"reloaded": true, "path": s.SettingsPath()}, nil
+}
+
+// sendConfiguration is the configuration of a message/send
+type sendConfiguration struct {
+	HistoryLength int    `json:"historyLength"`
+	TimeoutMs     int    `json:"timeout"`
+	WorkingDir    string `json:"workingDirectory"`
+	Retries       int    `json:"retries"`
+	ResultFormat  string `json:"resultFormat"`
+	Blocking      *bool  `json:"blocking"` // false queues the task and returns it at once
+}
+
+// handleMessageSend runs a message on its target agent and returns the finished
+// task. With configuration.blocking false the task is queued for a worker and
+// returned as submitted; tasks/get reports how it ends.
 func (s *Server) handleMessageSend(ctx context.Context, params json.RawMessage) (any, *jsonrpc.RPCError) {
 	var req struct {
-		Message       types.Message `json:"message"`
-		Configuration struct {
-			HistoryLength int    `json:"historyLength"`
-			TimeoutMs     int    `json:"timeout"`
-			WorkingDir    string `json:"workingDirectory"`
-		} `json:"configuration"`
+		Message       types.Message     `json:"message"`
+		Configuration sendConfiguration `json:"configuration"`
 	}
 	if err := json.Unmarshal(params, &req); err != nil {
 		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: "invalid params"}
@@ -434,6 +882,12 @@ func (s *Server) handleMessageSend(ctx context.Context, params json.RawMessage)
 	if req.Message.Kind != "message" {
 		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: "message required"}
 	}
+	if !req.Message.HasContent() {
+		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: types.ErrEmptyMessage.Error()}
+	}
+	if req.Configuration.Retries < 0 || req.Configuration.Retries > maxSendRetries {
+		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: fmt.Sprintf("configuration.retries must be between 0 and %d", maxSendRetries)}
+	}
 	if req.Message.Metadata == nil {
 		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: "metadata.targetAgent required"}
 	}
@@ -441,41 +895,101 @@ func (s *Server) handleMessageSend(ctx context.Context, params json.RawMessage)
 	if !ok || agentID == "" {
 		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: "metadata.targetAgent required"}
 	}
+	switch req.Configuration.ResultFormat {
+	case "", "text":
+	case agents.ResultFormatStructured:
+		// The orchestrators read it from the message, which is how A2A clients set it too
+		req.Message.Metadata["resultFormat"] = agents.ResultFormatStructured
+	default:
+		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: "configuration.resultFormat must be text or structured"}
+	}
 	info, ok := s.registry.Get(agentID)
 	if !ok {
 		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrAgentNotFound, Message: "agent not found"}
 	}
+	requestedDir := strings.TrimSpace(req.Configuration.WorkingDir)
+	if requestedDir == "" {
+		requestedDir = ExtractWorkingDir(req.Message.Metadata)
+	}
+	workingDir, dirErr := s.ResolveWorkingDir(agentID, req.Message, requestedDir)
+	if dirErr != nil {
+		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: dirErr.Error()}
+	}
 
 	contextID := req.Message.ContextID
 	if contextID == "" {
 		contextID = utils.NewID("ctx")
-		s.contexts.Create(contextID)
-	}
-	if _, exists := s.contexts.Get(contextID); !exists {
-		s.contexts.Create(contextID)
 	}
+	s.contexts.Create(contextID)
 
 	taskID := utils.NewID("task")
 	req.Message.TaskID = taskID
 	req.Message.ContextID = contextID
 
-	// Add agent ID to user message metadata for history attribution
-	if req.Message.Metadata == nil {
-		req.Message.Metadata = make(map[string]any)
-	}
-
 	status := types.TaskStatus{State: types.TaskStateSubmitted, Timestamp: time.Now().UTC().Format(time.RFC3339Nano)}
 	task := &types.Task{Kind: "task", ID: taskID, ContextID: contextID, Status: status}
 	s.tasks.Create(task)
-	_ = s.tasks.UpdateStatus(taskID, types.TaskStateWorking, nil)
 
-	workingDir := strings.TrimSpace(req.Configuration.WorkingDir)
-	if workingDir == "" {
-		workingDir = extractWorkingDir(req.Message.Metadata)
+	taskMetadata := make(map[string]any)
+	if workingDir != "" {
+		taskMetadata["workingDirectory"] = workingDir
+	}
+	// Orchestrator delegations name their parent task; replays name the source context
+	for _, key := range []string{"routedBy", "parentTaskId", "replayOf"} {
+		if value, ok := req.Message.Metadata[key].(string); ok && value != "" {
+			taskMetadata[key] = value
+		}
+	}
+	if len(taskMetadata) > 0 {
+		_ = s.tasks.SetMetadata(taskID, taskMetadata)
 	}
 
+	if req.Configuration.Blocking != nil && !*req.Configuration.Blocking {
+		queued, _ := s.tasks.Get(taskID)
+		err := s.queue.Submit(queuedTask{
+			taskID: taskID,
+			run: func() {
+				if _, rpcErr := s.executeSend(task, info, req.Message, req.Configuration, workingDir); rpcErr != nil {
+					s.logger.Warnf("queued task %s failed: %s", taskID, rpcErr.Message)
+				}
+			},
+			cancel: func() { s.cancelQueued(taskID) },
+		})
+		if err != nil {
+			_ = s.tasks.UpdateStatus(taskID, types.TaskStateFailed, &types.Message{Kind: "message", MessageID: "error-" + taskID, Role: "agent", Parts: []types.Part{{Kind: "text", Text: err.Error()}}, TaskID: taskID, ContextID: contextID})
+			return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrAgentUnavailable, Message: err.Error()}
+		}
+		return queued, nil
+	}
+	return s.executeSend(task, info, req.Message, req.Configuration, workingDir)
+}
+
+// executeSend runs a created message/send task on its agent, retrying as configured,
+// and records the outcome in the task store and the context history
+func (s *Server) executeSend(task *types.Task, info *AgentInfo, msg types.Message, cfg sendConfiguration, workingDir string) (*types.Task, *jsonrpc.RPCError) {
+	taskID, contextID, agentID := task.ID, task.ContextID, info.Agent.ID()
+	// latest is the stored task, for runs that end without a result
+	latest := func() (*types.Task, *jsonrpc.RPCError) {
+		if current, ok := s.tasks.Get(taskID); ok {
+			return current, nil
+		}
+		return task, nil
+	}
+	// Canceled while it waited in the queue
+	if !s.tasks.UpdateStatusIf(taskID, types.TaskStateSubmitted, types.TaskStateWorking, nil) {
+		return latest()
+	}
+	canceled := func() bool {
+		state, _ := s.tasks.State(taskID)
+		return state == types.TaskStateCanceled
+	}
+	started := time.Now()
+	s.metrics.TaskStarted()
+	s.trackRunning(taskID, runningTask{agent: info.Agent, messageID: msg.MessageID})
+	defer s.untrackRunning(taskID)
+
 	// Get full conversation history from context for multi-agent awareness
-	historyLimit := req.Configuration.HistoryLength
+	historyLimit := cfg.HistoryLength
 	var previousHistory []types.Message
 	if historyLimit > 0 {
 		previousHistory = s.contexts.GetHistoryWithLimit(contextID, historyLimit)
@@ -484,19 +998,44 @@ func (s *Server) handleMessageSend(ctx context.Context, params json.RawMessage)
 	}
 
 	// Store the user message in context history before execution
-	_ = s.contexts.AddMessage(contextID, req.Message)
-
-	result, err := info.Agent.Execute(types.ExecutionContext{
-		TaskID:          taskID,
-		ContextID:       contextID,
-		UserMessage:     req.Message,
-		PreviousHistory: previousHistory,
-		Timeout:         time.Duration(req.Configuration.TimeoutMs) * time.Millisecond,
-		WorkingDir:      workingDir,
-	})
+	_ = s.contexts.AddMessage(contextID, msg)
+	snapshot := s.snapshotForRun(agentID, workingDir)
+
+	var result types.ExecutionResult
+	var err error
+	attempts := 0
+	for {
+		attempts++
+		result, err = info.Agent.Execute(types.ExecutionContext{
+			TaskID:          taskID,
+			ContextID:       contextID,
+			UserMessage:     msg,
+			PreviousHistory: previousHistory,
+			Timeout:         time.Duration(cfg.TimeoutMs) * time.Millisecond,
+			WorkingDir:      workingDir,
+		})
+		if canceled() || attempts > cfg.Retries || !retryableFailure(result, err) {
+			break
+		}
+		s.logger.Infof("task %s: %s failed (attempt %d of %d), retrying", taskID, agentID, attempts, cfg.Retries+1)
+	}
+	if canceled() {
+		s.metrics.TaskFinished(agentID, types.TaskStateCanceled, time.Since(started))
+		return latest()
+	}
 	if err != nil {
-		_ = s.tasks.UpdateStatus(taskID, types.TaskStateFailed, &types.Message{Kind: "message", MessageID: "error-" + taskID, Role: "agent", Parts: []types.Part{{Kind: "text", Text: err.Error()}}, TaskID: taskID, ContextID: contextID})
-		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInternalError, Message: err.Error()}
+		failure := &types.Message{Kind: "message", MessageID: "error-" + taskID, Role: "agent", Parts: []types.Part{{Kind: "text", Text: err.Error()}}, TaskID: taskID, ContextID: contextID}
+		code := jsonrpc.ErrInternalError
+		if errors.Is(err, agents.ErrLoginRequired) {
+			failure.Metadata = map[string]any{"failureReason": "login_required"}
+			code = jsonrpc.ErrAuthError
+		}
+		_ = s.tasks.UpdateStatus(taskID, types.TaskStateFailed, failure)
+		s.metrics.TaskFinished(agentID, types.TaskStateFailed, time.Since(started))
+		if retriesUsedUp(cfg, attempts) {
+			s.deadLetter(task, agentID, attempts, err.Error(), msg)
+		}
+		return nil, &jsonrpc.RPCError{Code: code, Message: err.Error()}
 	}
 	if result.Task.Status.Message != nil {
 		result.Task.Status.Message.ContextID = contextID
@@ -511,13 +1050,70 @@ func (s *Server) handleMessageSend(ctx context.Context, params json.RawMessage)
 		// Store the agent response in context history
 		_ = s.contexts.AddMessage(contextID, *result.Task.Status.Message)
 	}
-	task.Status = result.Task.Status
-	task.History = append([]types.Message{req.Message}, result.Task.History...)
-	task.Artifacts = result.Task.Artifacts
-	task.ContextID = contextID
-	_ = s.tasks.UpdateStatus(taskID, task.Status.State, task.Status.Message)
+	status := result.Task.Status
+	history := append([]types.Message{msg}, result.Task.History...)
+	artifacts := result.Task.Artifacts
+	if len(artifacts) == 0 {
+		artifacts = result.Artifacts
+	}
+	if changes, ok := s.changesArtifact(snapshot); ok {
+		artifacts = append(artifacts, changes)
+	}
+	artifacts, err = s.artifacts.Externalize(taskID, artifacts)
+	if err != nil {
+		s.logger.Warnf("failed to store artifacts for task %s: %v", taskID, err)
+	}
+	// Canceled while the agent ran
+	finished, ok := s.tasks.FinishIf(taskID, types.TaskStateWorking, status, history, artifacts)
+	if !ok {
+		s.metrics.TaskFinished(agentID, types.TaskStateCanceled, time.Since(started))
+		return latest()
+	}
+	s.metrics.TaskFinished(agentID, status.State, time.Since(started))
+	if status.State == types.TaskStateFailed && retriesUsedUp(cfg, attempts) {
+		s.deadLetter(task, agentID, attempts, messageText(status.Message), msg)
+		finished, _ = s.tasks.Get(taskID)
+	}
 
-	return task, nil
+	return finished, nil
+}
+
+// handleMessageReplay sends a context's user turns, joined in order, to another agent
+// under a new context whose task records the source as metadata.replayOf. The
+// configuration is passed through to message/send.
+func (s *Server) handleMessageReplay(ctx context.Context, params json.RawMessage) (any, *jsonrpc.RPCError) {
+	var req struct {
+		ContextID     string          `json:"contextId"`
+		TargetAgent   string          `json:"targetAgent"`
+		Configuration json.RawMessage `json:"configuration"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil || req.ContextID == "" || req.TargetAgent == "" {
+		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: "contextId and targetAgent required"}
+	}
+	if _, ok := s.contexts.Get(req.ContextID); !ok {
+		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrContextNotFound, Message: "context not found"}
+	}
+	turns := make([]string, 0)
+	for _, msg := range s.contexts.GetHistory(req.ContextID) {
+		if msg.Role != "user" {
+			continue
+		}
+		if text := strings.TrimSpace(messageText(&msg)); text != "" {
+			turns = append(turns, text)
+		}
+	}
+	if len(turns) == 0 {
+		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: "context has no user messages to replay"}
+	}
+	msg := types.Message{
+		Kind:      "message",
+		MessageID: utils.NewID("msg"),
+		Role:      "user",
+		Parts:     []types.Part{{Kind: "text", Text: strings.Join(turns, "\n\n")}},
+		Metadata:  map[string]any{"targetAgent": req.TargetAgent, "replayOf": req.ContextID},
+	}
+	sendParams, _ := json.Marshal(map[string]any{"message": msg, "configuration": req.Configuration})
+	return s.handleMessageSend(ctx, sendParams)
 }
 
 func (s *Server) handleTaskGet(ctx context.Context, params json.RawMessage) (any, *jsonrpc.RPCError) {
@@ -534,37 +1130,154 @@ func (s *Server) handleTaskGet(ctx context.Context, params json.RawMessage) (any
 	return task, nil
 }
 
-func (s *Server) handleTaskCancel(ctx context.Context, params json.RawMessage) (any, *jsonrpc.RPCError) {
+// Artifact returns a stored artifact file with the name and MIME type recorded in its task
+func (s *Server) Artifact(id string) (types.File, []byte, error) {
+	taskID, _, ok := artifactIDParts(id)
+	if !ok {
+		return types.File{}, nil, errArtifactNotFound
+	}
+	task, ok := s.tasks.Get(taskID)
+	if !ok {
+		return types.File{}, nil, errArtifactNotFound
+	}
+	var file *types.File
+	for _, artifact := range task.Artifacts {
+		for _, part := range artifact.Parts {
+			if part.File != nil && part.File.URI == ArtifactURIPrefix+id {
+				file = part.File
+			}
+		}
+	}
+	if file == nil {
+		return types.File{}, nil, errArtifactNotFound
+	}
+	data, err := s.artifacts.Read(id)
+	if err != nil {
+		return types.File{}, nil, err
+	}
+	return *file, data, nil
+}
+
+func (s *Server) handleArtifactGet(ctx context.Context, params json.RawMessage) (any, *jsonrpc.RPCError) {
 	var req struct {
 		ID string `json:"id"`
 	}
 	if err := json.Unmarshal(params, &req); err != nil || req.ID == "" {
 		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: "id required"}
 	}
-	task, ok := s.tasks.Get(req.ID)
-	if !ok {
-		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrTaskNotFound, Message: "task not found"}
+	id := strings.TrimPrefix(req.ID, ArtifactURIPrefix)
+	file, data, err := s.Artifact(id)
+	if errors.Is(err, errArtifactNotFound) {
+		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrArtifactNotFound, Message: "artifact not found"}
+	}
+	if err != nil {
+		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInternalError, Message: err.Error()}
+	}
+	return map[string]any{
+		"id":       id,
+		"name":     file.Name,
+		"mimeType": file.MimeType,
+		"size":     len(data),
+		"bytes":    base64.StdEncoding.EncodeToString(data),
+	}, nil
+}
+
+// handleTaskCancel cancels a task and stops its agent if it is still running. A
+// client still waiting on message/send does not know the task ID yet, so the task
+// can also be named by the messageId it was started with.
+func (s *Server) handleTaskCancel(ctx context.Context, params json.RawMessage) (any, *jsonrpc.RPCError) {
+	var req struct {
+		ID        string `json:"id"`
+		MessageID string `json:"messageId"`
+	}
+	if err := json.Unmarshal(params, &req); err != nil || (req.ID == "" && req.MessageID == "") {
+		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidParams, Message: "id required"}
+	}
+	id := req.ID
+	if id == "" {
+		var ok bool
+		if id, ok = s.runningTaskForMessage(req.MessageID); !ok {
+			return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrTaskNotFound, Message: "task not found"}
+		}
 	}
-	if task.Status.State == types.TaskStateCompleted || task.Status.State == types.TaskStateFailed || task.Status.State == types.TaskStateCanceled {
+	if err := s.tasks.Cancel(id, nil); errors.Is(err, errTaskNotFound) {
+		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrTaskNotFound, Message: "task not found"}
+	} else if err != nil {
 		return nil, &jsonrpc.RPCError{Code: jsonrpc.ErrTaskNotCancelable, Message: "task not cancelable"}
 	}
-	task.Status.State = types.TaskStateCanceled
-	task.Status.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
-	return map[string]any{"canceled": true}, nil
+	return map[string]any{"canceled": true, "id": id, "stopped": s.stopRunning(id), "canceledSubtasks": s.cancelSubtasks(id)}, nil
+}
+
+// cancelSubtasks cancels and stops the unfinished tasks an orchestrator task
+// delegated, and theirs in turn, and returns their IDs
+func (s *Server) cancelSubtasks(parentID string) []string {
+	canceled := make([]string, 0)
+	for _, sub := range s.tasks.List(TaskFilter{ParentTaskID: parentID}, 0, 0) {
+		if s.tasks.Cancel(sub.ID, nil) == nil {
+			s.stopRunning(sub.ID)
+			canceled = append(canceled, sub.ID)
+		}
+		canceled = append(canceled, s.cancelSubtasks(sub.ID)...)
+	}
+	return canceled
+}
+
+func (s *Server) trackRunning(taskID string, run runningTask) {
+	s.runningMu.Lock()
+	defer s.runningMu.Unlock()
+	s.running[taskID] = run
+}
+
+func (s *Server) untrackRunning(taskID string) {
+	s.runningMu.Lock()
+	defer s.runningMu.Unlock()
+	delete(s.running, taskID)
+}
+
+func (s *Server) runningTaskForMessage(messageID string) (string, bool) {
+	s.runningMu.Lock()
+	defer s.runningMu.Unlock()
+	for taskID, run := range s.running {
+		if run.messageID == messageID {
+			return taskID, true
+		}
+	}
+	return "", false
+}
+
+// stopRunning asks the agent executing taskID to stop it
+func (s *Server) stopRunning(taskID string) bool {
+	s.runningMu.Lock()
+	run, ok := s.running[taskID]
+	s.runningMu.Unlock()
+	if !ok {
+		return false
+	}
+	stopped, err := run.agent.Cancel(taskID)
+	if err != nil {
+		s.logger.Warnf("failed to stop task %s: %v", taskID, err)
+	}
+	return stopped
 }
 
 func (s *Server) HubCard(baseURL string) types.AgentCard {
 	a2aURL := strings.TrimRight(baseURL, "/") + "/a2a"
-	return types.AgentCard{
+	card := types.AgentCard{
 		ProtocolVersion: "1.0",
 		Name:            "A2A Local Hub",
 		Description:     "Local multi-agent hub",
 		URL:             a2aURL,
-		Version:         "1.0.0",
+		Version:         version.Get().Version,
 		Provider:        types.Provider{Name: "Local"},
 		Skills:          []types.Skill{},
 		Capabilities:    types.AgentCapabilities{Streaming: true, PushNotifications: false, StateTransitionHistory: false},
 	}
+	if s.cfg.HTTP.Token != "" {
+		card.SecuritySchemes = map[string]any{
+			"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+		}
+	}
+	return card
 }
 
 func (s *Server) EnsureDataDir() error {
@@ -0,0 +1,257 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"agents-hub/internal/agents"
+	"agents-hub/internal/jsonrpc"
+	"agents-hub/internal/types"
+)
+
+// stubAgent answers every message with execute
+type stubAgent struct {
+	id      string
+	execute func(ctx types.ExecutionContext) (types.ExecutionResult, error)
+}
+
+func (a *stubAgent) ID() string        { return a.id }
+func (a *stubAgent) Name() string      { return a.id }
+func (a *stubAgent) Initialize() error { return nil }
+func (a *stubAgent) Shutdown() error   { return nil }
+func (a *stubAgent) GetCard() (types.AgentCard, error) {
+	return types.AgentCard{Name: a.id}, nil
+}
+func (a *stubAgent) GetCapabilities() types.RuntimeCapabilities { return types.RuntimeCapabilities{} }
+func (a *stubAgent) CheckHealth() (types.AgentHealth, error) {
+	return types.AgentHealth{Status: "healthy"}, nil
+}
+func (a *stubAgent) Execute(ctx types.ExecutionContext) (types.ExecutionResult, error) {
+	return a.execute(ctx)
+}
+func (a *stubAgent) Cancel(taskID string) (bool, error) { return false, nil }
+
+// registerStub registers a stub agent on s
+func registerStub(t *testing.T, s *Server, id string, execute func(types.ExecutionContext) (types.ExecutionResult, error)) {
+	t.Helper()
+	if err := s.registry.Register(&stubAgent{id: id, execute: execute}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// send calls message/send with text for agentID and the given configuration
+func send(t *testing.T, s *Server, agentID, contextID, text, configuration string) (*types.Task, *jsonrpc.RPCError) {
+	t.Helper()
+	msg := types.Message{
+		Kind:      "message",
+		MessageID: "msg-" + text,
+		Role:      "user",
+		ContextID: contextID,
+		Parts:     []types.Part{{Kind: "text", Text: text}},
+		Metadata:  map[string]any{"targetAgent": agentID},
+	}
+	params, _ := json.Marshal(map[string]any{"message": msg, "configuration": json.RawMessage(configuration)})
+	result, rpcErr := s.handleMessageSend(context.Background(), params)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	return result.(*types.Task), nil
+}
+
+// reply is a completed run answering with text
+func reply(ctx types.ExecutionContext, text string) types.ExecutionResult {
+	answer := &types.Message{Kind: "message", MessageID: "reply-" + ctx.TaskID, Role: "agent", Parts: []types.Part{{Kind: "text", Text: text}}}
+	return types.ExecutionResult{Task: types.Task{
+		Status:    types.TaskStatus{State: types.TaskStateCompleted, Message: answer},
+		History:   []types.Message{*answer},
+		Artifacts: []types.Artifact{{ArtifactID: "out", Parts: []types.Part{{Kind: "text", Text: text}}}},
+	}}
+}
+
+func TestMessageSendRejectsEmptyMessages(t *testing.T) {
+	s := newTestServer(t)
+	for _, parts := range []string{`[]`, `[{"kind":"text","text":"  "}]`, `[{"kind":"file","file":{}}]`} {
+		params := `{"message":{"kind":"message","role":"user","messageId":"m1","parts":` + parts + `,"metadata":{"targetAgent":"codex"}}}`
+		_, rpcErr := s.handleMessageSend(context.Background(), json.RawMessage(params))
+		if rpcErr == nil || rpcErr.Code != jsonrpc.ErrInvalidParams || rpcErr.Message != types.ErrEmptyMessage.Error() {
+			t.Errorf("parts %s: error = %v, want %q", parts, rpcErr, types.ErrEmptyMessage)
+		}
+	}
+}
+
+func TestPruningDropsInactiveContexts(t *testing.T) {
+	s := newTestServer(t)
+	s.cfg.Tasks.TTL = 0
+	s.cfg.Contexts.RetentionDays = 30
+	s.contexts.Create("fresh")
+	stale := s.contexts.Create("stale")
+	stale.CreatedAt = time.Now().UTC().AddDate(0, 0, -s.cfg.Contexts.RetentionDays-1)
+	stale.UpdatedAt = stale.CreatedAt
+	s.contexts.mu.Lock()
+	s.contexts.contexts["stale"] = stale
+	s.contexts.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.StartTaskPruning(ctx, 10*time.Millisecond)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := s.contexts.Get("stale"); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the pruning ticker kept a context past the retention")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := s.contexts.Get("fresh"); !ok {
+		t.Fatal("the pruning ticker dropped an active context")
+	}
+
+	// Retention is off by default
+	off := newTestServer(t)
+	off.contexts.mu.Lock()
+	off.contexts.contexts["stale"] = stale
+	off.contexts.mu.Unlock()
+	if n := off.PruneContexts(); n != 0 {
+		t.Fatalf("pruned %d contexts with retention off, want 0", n)
+	}
+}
+
+// TestAsyncSendIsSafeToPoll reads a queued task while a worker finishes it; run it
+// with -race to catch a write to the stored task outside the TaskManager lock
+func TestAsyncSendIsSafeToPoll(t *testing.T) {
+	s := newTestServer(t)
+	registerStub(t, s, "stub", func(ctx types.ExecutionContext) (types.ExecutionResult, error) {
+		time.Sleep(20 * time.Millisecond)
+		if messageText(&ctx.UserMessage) == "fail" {
+			return types.ExecutionResult{Task: types.Task{Status: types.TaskStatus{State: types.TaskStateFailed}}}, nil
+		}
+		return reply(ctx, "done"), nil
+	})
+	var ids []string
+	for _, text := range []string{"ok", "fail"} {
+		task, rpcErr := send(t, s, "stub", "ctx-poll", text, `{"blocking":false,"retries":1}`)
+		if rpcErr != nil {
+			t.Fatal(rpcErr)
+		}
+		ids = append(ids, task.ID)
+	}
+
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			deadline := time.Now().Add(5 * time.Second)
+			for time.Now().Before(deadline) {
+				task, rpcErr := s.handleTaskGet(context.Background(), json.RawMessage(`{"id":"`+id+`"}`))
+				if rpcErr != nil {
+					t.Error(rpcErr)
+					return
+				}
+				list, _ := s.handleTasksList(context.Background(), json.RawMessage(`{"contextId":"ctx-poll"}`))
+				if _, err := json.Marshal([]any{task, list}); err != nil {
+					t.Error(err)
+					return
+				}
+				if task.(*types.Task).Status.State.IsTerminal() {
+					return
+				}
+			}
+			t.Errorf("task %s did not finish", id)
+		}()
+	}
+	wg.Wait()
+
+	done, _ := s.tasks.Get(ids[0])
+	if done.Status.State != types.TaskStateCompleted || len(done.History) != 2 || len(done.Artifacts) != 1 {
+		t.Fatalf("finished task = %+v, want completed with its history and artifacts", done)
+	}
+	failed, _ := s.tasks.Get(ids[1])
+	if failed.Status.State != types.TaskStateFailed || failed.Metadata["deadLettered"] != true {
+		t.Fatalf("failed task = %+v, want failed and dead-lettered", failed)
+	}
+}
+
+func TestSendKeepsContextHistory(t *testing.T) {
+	s := newTestServer(t)
+	var seen []types.Message
+	registerStub(t, s, "stub", func(ctx types.ExecutionContext) (types.ExecutionResult, error) {
+		seen = ctx.PreviousHistory
+		return reply(ctx, "answer to "+messageText(&ctx.UserMessage)), nil
+	})
+	s.contexts.Create("ctx-1")
+	for _, text := range []string{"earlier question", "earlier answer"} {
+		_ = s.contexts.AddMessage("ctx-1", types.Message{Kind: "message", MessageID: text, Role: "user", Parts: []types.Part{{Kind: "text", Text: text}}})
+	}
+
+	if _, rpcErr := send(t, s, "stub", "ctx-1", "follow-up", `{}`); rpcErr != nil {
+		t.Fatal(rpcErr)
+	}
+	texts := func(history []types.Message) []string {
+		var out []string
+		for _, msg := range history {
+			out = append(out, messageText(&msg))
+		}
+		return out
+	}
+	if got, want := texts(seen), []string{"earlier question", "earlier answer"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("agent saw history %q, want %q", got, want)
+	}
+	want := []string{"earlier question", "earlier answer", "follow-up", "answer to follow-up"}
+	if got := texts(s.contexts.GetHistory("ctx-1")); !reflect.DeepEqual(got, want) {
+		t.Fatalf("context history = %q, want %q", got, want)
+	}
+}
+
+func TestDeadLetterNeedsRetries(t *testing.T) {
+	s := newTestServer(t)
+	registerStub(t, s, "flaky", func(types.ExecutionContext) (types.ExecutionResult, error) {
+		return types.ExecutionResult{}, errors.New("boom")
+	})
+	registerStub(t, s, "locked", func(types.ExecutionContext) (types.ExecutionResult, error) {
+		return types.ExecutionResult{}, agents.ErrLoginRequired
+	})
+	tests := []struct {
+		name          string
+		agent         string
+		configuration string
+		wantLetter    bool
+		wantAttempts  int
+	}{
+		{"no retries", "flaky", `{}`, false, 0},
+		{"retries used up", "flaky", `{"retries":2}`, true, 3},
+		{"login is not retried", "locked", `{"retries":2}`, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contextID := "ctx-" + strings.ReplaceAll(tt.name, " ", "-")
+			if _, rpcErr := send(t, s, tt.agent, contextID, "try", tt.configuration); rpcErr == nil {
+				t.Fatal("failing send succeeded")
+			}
+			tasks := s.tasks.List(TaskFilter{ContextID: contextID}, 0, 0)
+			if len(tasks) != 1 || tasks[0].Status.State != types.TaskStateFailed {
+				t.Fatalf("tasks = %+v, want one failed task", tasks)
+			}
+			var letter *DeadLetter
+			for _, l := range s.deadLetters.List("", 0) {
+				if l.TaskID == tasks[0].ID {
+					letter = &l
+				}
+			}
+			if (letter != nil) != tt.wantLetter || (tasks[0].Metadata["deadLettered"] == true) != tt.wantLetter {
+				t.Fatalf("dead letter = %+v, metadata = %v; want dead-lettered %v", letter, tasks[0].Metadata, tt.wantLetter)
+			}
+			if letter != nil && letter.Attempts != tt.wantAttempts {
+				t.Fatalf("attempts = %d, want %d", letter.Attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
@@ -1,13 +1,20 @@
 package hub
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
+	"agents-hub/internal/agents"
 	"agents-hub/internal/types"
 	"agents-hub/internal/utils"
 )
@@ -26,59 +33,216 @@ type Settings struct {
 	Gemini             types.GeminiSettings `json:"gemini,omitempty"`
 	Vibe               types.VibeSettings   `json:"vibe,omitempty"`
 	RemoteAgents       []RemoteAgentConfig  `json:"remoteAgents,omitempty"`
+	MaxOutputTokens    map[string]int       `json:"maxOutputTokens,omitempty"` // agent ID -> output token limit
+	PinnedAgents       []string             `json:"pinnedAgents,omitempty"`
+	HealthProbes       []string             `json:"healthProbes,omitempty"`      // agents checked with a real prompt
+	JSONStreams        []string             `json:"jsonStreams,omitempty"`       // agents streamed through their JSON event protocol
+	CaptureChanges     []string             `json:"captureChanges,omitempty"`    // agents whose runs record the files they changed
+	LastWorkingDir     map[string]string    `json:"lastWorkingDir,omitempty"`    // agent ID -> last working directory
+	AllowedRoots       map[string]string    `json:"allowedRoots,omitempty"`      // agent ID -> directory its working directories must stay within
+	ProgressPatterns   map[string]string    `json:"progressPatterns,omitempty"`  // agent ID -> progress regex
+	AgentPriorities    map[string]int       `json:"agentPriorities,omitempty"`   // agent ID -> routing priority, lower preferred
+	MaxRoutingTargets  int                  `json:"maxRoutingTargets,omitempty"` // delegates the LLM orchestrator may route one request to; 0 = default
+	RouteUnhealthy     bool                 `json:"routeUnhealthy,omitempty"`    // orchestrators also route to agents whose health check failed
+	AllowNonDelegates  bool                 `json:"allowNonDelegates,omitempty"` // LLM routers may pick registered agents that aren't delegates
+	OutputFormat       string               `json:"outputFormat,omitempty"`      // CLI --format default: json or pretty
+	LastTab            string               `json:"lastTab,omitempty"`           // TUI tab open at exit, e.g. "tasks"
+	SendModalOpen      bool                 `json:"sendModalOpen,omitempty"`     // whether the TUI exited with the send modal open
+}
+
+// clone returns a copy of the settings that shares no maps or slices with them
+func (st Settings) clone() Settings {
+	st.OrchestratorAgents = slices.Clone(st.OrchestratorAgents)
+	st.RemoteAgents = slices.Clone(st.RemoteAgents)
+	st.MaxOutputTokens = maps.Clone(st.MaxOutputTokens)
+	st.PinnedAgents = slices.Clone(st.PinnedAgents)
+	st.HealthProbes = slices.Clone(st.HealthProbes)
+	st.JSONStreams = slices.Clone(st.JSONStreams)
+	st.CaptureChanges = slices.Clone(st.CaptureChanges)
+	st.LastWorkingDir = maps.Clone(st.LastWorkingDir)
+	st.AllowedRoots = maps.Clone(st.AllowedRoots)
+	st.ProgressPatterns = maps.Clone(st.ProgressPatterns)
+	st.AgentPriorities = maps.Clone(st.AgentPriorities)
+	return st
+}
+
+// MaxPinnedAgents matches the TUI's 1-9 quick-pick keys
+const MaxPinnedAgents = 9
+
+// errSettingsUnchanged is returned by an updateSettings change that has nothing to save
+var errSettingsUnchanged = errors.New("settings unchanged")
+
+// settingsSnapshot returns a copy of the current settings, safe to read without the lock
+func (s *Server) settingsSnapshot() Settings {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.settings.clone()
+}
+
+// updateSettings runs change on the settings and saves them, both under the settings
+// lock. Nothing is saved when change fails or returns errSettingsUnchanged.
+func (s *Server) updateSettings(change func(*Settings) error) error {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	if err := change(&s.settings); err != nil {
+		if errors.Is(err, errSettingsUnchanged) {
+			return nil
+		}
+		return err
+	}
+	return s.saveSettingsLocked()
+}
+
+// updateAgentSettings is updateSettings for a change the agents pick up
+func (s *Server) updateAgentSettings(change func(*Settings)) error {
+	err := s.updateSettings(func(settings *Settings) error {
+		change(settings)
+		return nil
+	})
+	s.applySettingsToAgents()
+	return err
 }
 
 func (s *Server) SettingsPath() string {
 	return filepath.Join(s.cfg.DataDir, "settings.json")
 }
 
+// ReadSettings reads settings.json from dataDir without a server, for CLI commands
+// that only talk to a running hub. A missing file gives empty settings.
+func ReadSettings(dataDir string) (Settings, error) {
+	var settings Settings
+	data, err := os.ReadFile(filepath.Join(dataDir, "settings.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return settings, nil
+		}
+		return settings, err
+	}
+	err = json.Unmarshal(data, &settings)
+	return settings, err
+}
+
 func (s *Server) LoadSettings() error {
 	if err := s.EnsureDataDir(); err != nil {
 		return err
 	}
-	data, err := os.ReadFile(s.SettingsPath())
+	if _, err := os.Stat(s.SettingsPath()); os.IsNotExist(err) {
+		return nil
+	}
+	settings, err := ReadSettings(s.cfg.DataDir)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
-		}
 		return err
 	}
+	s.useSettings(settings)
+	return nil
+}
+
+// settingsReadAttempts bounds how often ReloadSettings reads a settings.json that
+// doesn't parse, since another process may be halfway through writing it
+const settingsReadAttempts = 3
+
+// ReloadSettings re-reads settings.json and applies it to the agents, so edits made
+// outside the hub take effect without a restart. When the file still doesn't parse
+// after a few attempts, the current settings are kept and the error is returned.
+func (s *Server) ReloadSettings() error {
 	var settings Settings
-	if err := json.Unmarshal(data, &settings); err != nil {
-		return err
+	var err error
+	for attempt := 1; ; attempt++ {
+		settings, err = ReadSettings(s.cfg.DataDir)
+		var syntaxErr *json.SyntaxError
+		if err == nil || attempt == settingsReadAttempts || !errors.As(err, &syntaxErr) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
 	}
-	s.settings = settings
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", s.SettingsPath(), err)
+	}
+	s.useSettings(settings)
+	s.removeUnlistedRemoteAgents()
+	s.applySettingsToAgents()
+	s.dropStaleLastAgent()
+	return nil
+}
+
+// useSettings makes settings current and registers the orchestrator delegates and
+// remote agents they list
+func (s *Server) useSettings(settings Settings) {
+	s.settingsMu.Lock()
 	if settings.OrchestratorAgents != nil {
 		s.cfg.Orchestrator.Agents = append([]string{}, settings.OrchestratorAgents...)
 	} else {
-		s.settings.OrchestratorAgents = append([]string{}, s.cfg.Orchestrator.Agents...)
+		settings.OrchestratorAgents = append([]string{}, s.cfg.Orchestrator.Agents...)
+	}
+	s.settings = settings
+	s.settingsMu.Unlock()
+	for _, conflict := range s.GetCodexConfig().Conflicts() {
+		s.logger.Warnf("codex settings: %s", conflict)
 	}
-	_ = s.UpdateOrchestratorAgents(s.cfg.Orchestrator.Agents)
+	_ = s.UpdateOrchestratorAgents(settings.OrchestratorAgents)
 
 	// Initialize remote agents from saved configuration
 	s.initRemoteAgents()
-
-	return nil
 }
 
-// initRemoteAgents registers all configured remote agents
+// initRemoteAgents registers the configured remote agents that aren't registered yet
 func (s *Server) initRemoteAgents() {
-	if len(s.settings.RemoteAgents) == 0 {
+	remotes := s.RemoteAgentSettings()
+	if len(remotes) == 0 {
 		return
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	for _, cfg := range s.settings.RemoteAgents {
+	registered := make(map[string]bool)
+	for _, agent := range s.remoteRegistry.List() {
+		registered[agent.CardURL()] = true
+	}
+	for _, cfg := range remotes {
+		if registered[cfg.CardURL] {
+			continue
+		}
 		if err := s.remoteRegistry.DiscoverAndRegister(ctx, cfg.CardURL, cfg.Alias); err != nil {
 			s.logger.Warnf("failed to register remote agent %s: %v", cfg.CardURL, err)
 		} else {
 			s.logger.Debugf("registered remote agent from %s", cfg.CardURL)
 		}
 	}
+	s.applyRemoteCredentials()
+}
+
+// removeUnlistedRemoteAgents unregisters remote agents whose card URL was removed from the settings
+func (s *Server) removeUnlistedRemoteAgents() {
+	remotes := s.RemoteAgentSettings()
+	listed := make(map[string]bool, len(remotes))
+	for _, cfg := range remotes {
+		listed[cfg.CardURL] = true
+	}
+	for _, agent := range s.remoteRegistry.List() {
+		if listed[agent.CardURL()] {
+			continue
+		}
+		if err := s.remoteRegistry.RemoveRemoteAgent(agent.ID()); err != nil {
+			s.logger.Warnf("failed to remove remote agent %s: %v", agent.ID(), err)
+		}
+	}
+}
+
+// applyRemoteCredentials hands stored tokens to the registered remote agents
+func (s *Server) applyRemoteCredentials() {
+	for _, agent := range s.remoteRegistry.List() {
+		agent.SetBearerToken(s.secrets.RemoteToken(agent.ID()))
+	}
 }
 
 func (s *Server) SaveSettings() error {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	return s.saveSettingsLocked()
+}
+
+// saveSettingsLocked writes settings.json; the caller holds settingsMu
+func (s *Server) saveSettingsLocked() error {
 	if err := s.EnsureDataDir(); err != nil {
 		return err
 	}
@@ -89,275 +253,771 @@ func (s *Server) SaveSettings() error {
 	return utils.WriteFileAtomic(s.SettingsPath(), data, 0o644)
 }
 
-func (s *Server) updateSettingsAgents(ids []string) {
-	s.settings.OrchestratorAgents = append([]string{}, ids...)
-}
-
 func (s *Server) UpdateLastAgent(id string) {
 	id = strings.TrimSpace(id)
 	if id == "" {
 		return
 	}
-	if s.settings.LastAgent == id {
-		return
-	}
-	s.settings.LastAgent = id
-	if err := s.SaveSettings(); err != nil {
+	err := s.updateSettings(func(settings *Settings) error {
+		if settings.LastAgent == id {
+			return errSettingsUnchanged
+		}
+		settings.LastAgent = id
+		return nil
+	})
+	if err != nil {
 		s.logger.Warnf("failed to save settings: %v", err)
 	}
 }
 
 func (s *Server) LastAgent() string {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
 	return s.settings.LastAgent
 }
 
+// UpdateLastView remembers the TUI's active tab and whether the send modal was open
+func (s *Server) UpdateLastView(tab string, sendModalOpen bool) {
+	err := s.updateSettings(func(settings *Settings) error {
+		if settings.LastTab == tab && settings.SendModalOpen == sendModalOpen {
+			return errSettingsUnchanged
+		}
+		settings.LastTab = tab
+		settings.SendModalOpen = sendModalOpen
+		return nil
+	})
+	if err != nil {
+		s.logger.Warnf("failed to save settings: %v", err)
+	}
+}
+
+// LastView returns the tab and send modal state saved by UpdateLastView
+func (s *Server) LastView() (string, bool) {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.settings.LastTab, s.settings.SendModalOpen
+}
+
+// dropStaleLastAgent forgets a last agent that is no longer registered (e.g. a removed
+// remote agent) so clients fall back to a real one. Settings and agents load in either
+// order, so it is a no-op until the built-in agents are initialized.
+func (s *Server) dropStaleLastAgent() {
+	last := s.LastAgent()
+	if s.a2aCaller == nil || last == "" {
+		return
+	}
+	if _, ok := s.registry.Get(last); ok {
+		return
+	}
+	s.logger.Warnf("last agent %s is no longer registered", last)
+	err := s.updateSettings(func(settings *Settings) error {
+		if settings.LastAgent != last {
+			return errSettingsUnchanged
+		}
+		settings.LastAgent = ""
+		return nil
+	})
+	if err != nil {
+		s.logger.Warnf("failed to save settings: %v", err)
+	}
+}
+
+// LastWorkingDir returns the working directory of the agent's last send
+func (s *Server) LastWorkingDir(agentID string) string {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.settings.LastWorkingDir[agentID]
+}
+
+// rememberWorkingDir records an agent's working directory, saving only on change
+func (s *Server) rememberWorkingDir(agentID, dir string) {
+	if dir == "" {
+		return
+	}
+	err := s.updateSettings(func(settings *Settings) error {
+		if settings.LastWorkingDir[agentID] == dir {
+			return errSettingsUnchanged
+		}
+		if settings.LastWorkingDir == nil {
+			settings.LastWorkingDir = make(map[string]string)
+		}
+		settings.LastWorkingDir[agentID] = dir
+		return nil
+	})
+	if err != nil {
+		s.logger.Warnf("failed to save settings: %v", err)
+	}
+}
+
+// PinnedAgents returns the pinned agent IDs in pin order
+func (s *Server) PinnedAgents() []string {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return append([]string{}, s.settings.PinnedAgents...)
+}
+
+// PinAgent appends an agent to the pinned list and persists it
+func (s *Server) PinAgent(agentID string) error {
+	agentID = strings.TrimSpace(agentID)
+	if _, ok := s.registry.Get(agentID); !ok {
+		return fmt.Errorf("agent not found: %s", agentID)
+	}
+	return s.updateSettings(func(settings *Settings) error {
+		if slices.Contains(settings.PinnedAgents, agentID) {
+			return errSettingsUnchanged
+		}
+		if len(settings.PinnedAgents) >= MaxPinnedAgents {
+			return fmt.Errorf("at most %d agents can be pinned", MaxPinnedAgents)
+		}
+		settings.PinnedAgents = append(settings.PinnedAgents, agentID)
+		return nil
+	})
+}
+
+// UnpinAgent removes an agent from the pinned list and persists it
+func (s *Server) UnpinAgent(agentID string) error {
+	return s.updateSettings(func(settings *Settings) error {
+		index := slices.Index(settings.PinnedAgents, strings.TrimSpace(agentID))
+		if index < 0 {
+			return fmt.Errorf("agent not pinned: %s", agentID)
+		}
+		settings.PinnedAgents = slices.Delete(settings.PinnedAgents, index, index+1)
+		return nil
+	})
+}
+
+// MaxOutputTokens returns the per-agent output token limits
+func (s *Server) MaxOutputTokens() map[string]int {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	limits := make(map[string]int, len(s.settings.MaxOutputTokens))
+	for id, limit := range s.settings.MaxOutputTokens {
+		limits[id] = limit
+	}
+	return limits
+}
+
+// UpdateMaxOutputTokens sets an agent's output token limit and persists it; 0 clears it
+func (s *Server) UpdateMaxOutputTokens(agentID string, limit int) error {
+	agentID = strings.TrimSpace(agentID)
+	if agentID == "" {
+		return fmt.Errorf("agent ID required")
+	}
+	if limit < 0 {
+		return fmt.Errorf("max tokens must be a positive integer")
+	}
+	info, ok := s.registry.Get(agentID)
+	if !ok {
+		return fmt.Errorf("agent not found: %s", agentID)
+	}
+	if _, ok := info.Agent.(interface{ SetMaxOutputTokens(int) }); !ok {
+		return fmt.Errorf("agent %s does not support an output limit", agentID)
+	}
+	return s.updateAgentSettings(func(settings *Settings) {
+		if limit == 0 {
+			delete(settings.MaxOutputTokens, agentID)
+			return
+		}
+		if settings.MaxOutputTokens == nil {
+			settings.MaxOutputTokens = make(map[string]int)
+		}
+		settings.MaxOutputTokens[agentID] = limit
+	})
+}
+
+// MaxRoutingTargetsLimit is the largest accepted max routing targets setting
+const MaxRoutingTargetsLimit = agents.MaxRoutingTargetsLimit
+
+// MaxRoutingTargets returns how many delegates the LLM orchestrator may route one request to
+func (s *Server) MaxRoutingTargets() int {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return agents.ClampRoutingTargets(s.settings.MaxRoutingTargets)
+}
+
+// UpdateMaxRoutingTargets sets how many delegates the LLM orchestrator may route one
+// request to and persists it; 0 restores the default
+func (s *Server) UpdateMaxRoutingTargets(n int) error {
+	if n < 0 || n > MaxRoutingTargetsLimit {
+		return fmt.Errorf("max routing targets must be between 1 and %d", MaxRoutingTargetsLimit)
+	}
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.MaxRoutingTargets = n
+	})
+}
+
+// RouteUnhealthy reports whether the orchestrators route to delegates whose last health
+// check failed; by default they are skipped
+func (s *Server) RouteUnhealthy() bool {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.settings.RouteUnhealthy
+}
+
+// UpdateRouteUnhealthy sets whether the orchestrators route to unhealthy delegates and persists it
+func (s *Server) UpdateRouteUnhealthy(enabled bool) error {
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.RouteUnhealthy = enabled
+	})
+}
+
+// StrictDelegates reports whether the LLM orchestrator only routes to its delegates,
+// dropping a router's other picks with a note; this is the default
+func (s *Server) StrictDelegates() bool {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return !s.settings.AllowNonDelegates
+}
+
+// UpdateStrictDelegates sets whether the LLM orchestrator only routes to its delegates and persists it
+func (s *Server) UpdateStrictDelegates(strict bool) error {
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.AllowNonDelegates = !strict
+	})
+}
+
+// AgentPriorities returns the per-agent routing priorities
+func (s *Server) AgentPriorities() map[string]int {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	priorities := make(map[string]int, len(s.settings.AgentPriorities))
+	for id, priority := range s.settings.AgentPriorities {
+		priorities[id] = priority
+	}
+	return priorities
+}
+
+// AgentPriority returns an agent's routing priority, or 0 when none is set
+func (s *Server) AgentPriority(agentID string) int {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.settings.AgentPriorities[agentID]
+}
+
+// UpdateAgentPriority sets an agent's routing priority and persists it; 0 clears it.
+// Lower numbers are preferred, so give cheap or fast agents 1. The orchestrators use
+// it as a hint only: a better-matching agent still wins.
+func (s *Server) UpdateAgentPriority(agentID string, priority int) error {
+	agentID = strings.TrimSpace(agentID)
+	if agentID == "" {
+		return fmt.Errorf("agent ID required")
+	}
+	if priority < 0 {
+		return fmt.Errorf("priority must be a positive integer")
+	}
+	if agentID == "orchestrator" {
+		return fmt.Errorf("the orchestrator is not routed to")
+	}
+	if _, ok := s.registry.Get(agentID); !ok {
+		return fmt.Errorf("agent not found: %s", agentID)
+	}
+	err := s.updateSettings(func(settings *Settings) error {
+		if priority == 0 {
+			delete(settings.AgentPriorities, agentID)
+			return nil
+		}
+		if settings.AgentPriorities == nil {
+			settings.AgentPriorities = make(map[string]int)
+		}
+		settings.AgentPriorities[agentID] = priority
+		return nil
+	})
+	s.applyOrchestratorDelegates()
+	return err
+}
+
+// comparePriority orders routing priorities with unset (0) after every set one
+func comparePriority(a, b int) int {
+	switch {
+	case a == b:
+		return 0
+	case a == 0:
+		return 1
+	case b == 0:
+		return -1
+	}
+	return cmp.Compare(a, b)
+}
+
+// ValidOutputFormat reports whether format is a CLI --format value
+func ValidOutputFormat(format string) bool {
+	return format == "json" || format == "pretty"
+}
+
+// OutputFormat returns the default CLI output format, or "" when unset
+func (s *Server) OutputFormat() string {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.settings.OutputFormat
+}
+
+// UpdateOutputFormat sets the default CLI output format and persists it; "" clears it
+func (s *Server) UpdateOutputFormat(format string) error {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format != "" && !ValidOutputFormat(format) {
+		return fmt.Errorf("output format must be json or pretty")
+	}
+	return s.updateSettings(func(settings *Settings) error {
+		settings.OutputFormat = format
+		return nil
+	})
+}
+
+// HealthProbes returns the IDs of agents with the deep health probe enabled
+func (s *Server) HealthProbes() []string {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return append([]string{}, s.settings.HealthProbes...)
+}
+
+// UpdateHealthProbe enables or disables an agent's deep health probe and persists it
+func (s *Server) UpdateHealthProbe(agentID string, enabled bool) error {
+	agentID = strings.TrimSpace(agentID)
+	info, ok := s.registry.Get(agentID)
+	if !ok {
+		return fmt.Errorf("agent not found: %s", agentID)
+	}
+	if _, ok := info.Agent.(interface{ SetHealthProbe(bool) }); !ok {
+		return fmt.Errorf("agent %s does not support a health probe", agentID)
+	}
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.HealthProbes = toggleID(settings.HealthProbes, agentID, enabled)
+	})
+}
+
+// JSONStreams returns the IDs of agents that stream structured tool and thinking events
+func (s *Server) JSONStreams() []string {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return append([]string{}, s.settings.JSONStreams...)
+}
+
+// UpdateJSONStream switches an agent's streaming runs to or from its CLI's JSON event
+// protocol and persists it
+func (s *Server) UpdateJSONStream(agentID string, enabled bool) error {
+	agentID = strings.TrimSpace(agentID)
+	info, ok := s.registry.Get(agentID)
+	if !ok {
+		return fmt.Errorf("agent not found: %s", agentID)
+	}
+	if _, ok := info.Agent.(interface{ SetJSONStream(bool) }); !ok {
+		return fmt.Errorf("agent %s has no JSON stream mode", agentID)
+	}
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.JSONStreams = toggleID(settings.JSONStreams, agentID, enabled)
+	})
+}
+
+// toggleID adds id to ids when enabled and removes it otherwise
+func toggleID(ids []string, id string, enabled bool) []string {
+	index := slices.Index(ids, id)
+	switch {
+	case enabled && index < 0:
+		return append(ids, id)
+	case !enabled && index >= 0:
+		return slices.Delete(ids, index, index+1)
+	}
+	return ids
+}
+
 // ClaudeSettings returns the current Claude configuration
 func (s *Server) ClaudeSettings() types.ClaudeSettings {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
 	return s.settings.Claude
 }
 
 // UpdateClaudeSettings updates Claude configuration and persists it
 func (s *Server) UpdateClaudeSettings(settings types.ClaudeSettings) error {
-	s.settings.Claude = settings
-	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return s.updateAgentSettings(func(current *Settings) {
+		current.Claude = settings
+	})
 }
 
 // UpdateClaudeModel updates the default Claude model
 func (s *Server) UpdateClaudeModel(model string) error {
-	s.settings.Claude.DefaultModel = model
-	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.Claude.DefaultModel = model
+	})
 }
 
 // UpdateClaudeToolProfile updates the default tool profile
 func (s *Server) UpdateClaudeToolProfile(profile string) error {
-	s.settings.Claude.DefaultToolProfile = profile
-	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.Claude.DefaultToolProfile = profile
+	})
 }
 
 // UpdateClaudeContinue updates the continue mode setting
 func (s *Server) UpdateClaudeContinue(enabled bool) error {
-	s.settings.Claude.EnableContinue = enabled
-	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.Claude.EnableContinue = enabled
+	})
 }
 
 // GetClaudeConfig builds a ClaudeConfig from current settings
 func (s *Server) GetClaudeConfig() types.ClaudeConfig {
+	return claudeConfigFromSettings(s.ClaudeSettings())
+}
+
+func claudeConfigFromSettings(settings types.ClaudeSettings) types.ClaudeConfig {
 	return types.ClaudeConfig{
-		Continue:     s.settings.Claude.EnableContinue,
-		Model:        types.ClaudeModel(s.settings.Claude.DefaultModel),
-		ToolProfile:  types.ClaudeToolProfile(s.settings.Claude.DefaultToolProfile),
-		AllowedTools: s.settings.Claude.CustomAllowedTools,
+		Continue:     settings.EnableContinue,
+		Model:        types.ClaudeModel(settings.DefaultModel),
+		ToolProfile:  types.ClaudeToolProfile(settings.DefaultToolProfile),
+		AllowedTools: settings.CustomAllowedTools,
 	}
 }
 
 // CodexSettings returns the current Codex configuration.
 func (s *Server) CodexSettings() types.CodexSettings {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
 	return s.settings.Codex
 }
 
 // UpdateCodexSettings updates Codex configuration and persists it.
 func (s *Server) UpdateCodexSettings(settings types.CodexSettings) error {
-	s.settings.Codex = settings
+	return s.changeCodexSettings(func(codex *types.CodexSettings) {
+		*codex = settings
+	})
+}
+
+// changeCodexSettings applies change to the Codex settings unless the result conflicts
+func (s *Server) changeCodexSettings(change func(*types.CodexSettings)) error {
+	err := s.updateSettings(func(settings *Settings) error {
+		codex := settings.Codex
+		change(&codex)
+		if conflicts := codexConfigFromSettings(codex).Conflicts(); len(conflicts) > 0 {
+			return fmt.Errorf("conflicting Codex settings: %s", strings.Join(conflicts, "; "))
+		}
+		settings.Codex = codex
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return nil
 }
 
 // UpdateCodexModel updates the default Codex model.
 func (s *Server) UpdateCodexModel(model string) error {
-	s.settings.Codex.DefaultModel = model
-	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.Codex.DefaultModel = model
+	})
 }
 
 // UpdateCodexProfile updates the default Codex profile.
 func (s *Server) UpdateCodexProfile(profile string) error {
-	s.settings.Codex.DefaultProfile = profile
-	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.Codex.DefaultProfile = profile
+	})
 }
 
 // UpdateCodexSandbox updates the default Codex sandbox mode.
 func (s *Server) UpdateCodexSandbox(mode string) error {
-	s.settings.Codex.DefaultSandbox = mode
-	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return s.changeCodexSettings(func(codex *types.CodexSettings) {
+		codex.DefaultSandbox = mode
+	})
 }
 
 // UpdateCodexApprovalPolicy updates the default Codex approval policy.
 func (s *Server) UpdateCodexApprovalPolicy(policy string) error {
-	s.settings.Codex.DefaultApprovalPolicy = policy
-	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return s.changeCodexSettings(func(codex *types.CodexSettings) {
+		codex.DefaultApprovalPolicy = policy
+	})
 }
 
 // UpdateCodexSearch updates Codex search toggle.
 func (s *Server) UpdateCodexSearch(enabled bool) error {
-	s.settings.Codex.EnableSearch = enabled
-	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.Codex.EnableSearch = enabled
+	})
+}
+
+// UpdateCodexLastMessage toggles keeping only Codex's final message as the task result.
+func (s *Server) UpdateCodexLastMessage(enabled bool) error {
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.Codex.LastMessageOnly = enabled
+	})
 }
 
 // GetCodexConfig builds a CodexConfig from current settings.
 func (s *Server) GetCodexConfig() types.CodexConfig {
+	return codexConfigFromSettings(s.CodexSettings())
+}
+
+func codexConfigFromSettings(settings types.CodexSettings) types.CodexConfig {
 	return types.CodexConfig{
-		Model:           s.settings.Codex.DefaultModel,
-		Profile:         s.settings.Codex.DefaultProfile,
-		SandboxMode:     types.CodexSandboxMode(s.settings.Codex.DefaultSandbox),
-		ApprovalPolicy:  types.CodexApprovalPolicy(s.settings.Codex.DefaultApprovalPolicy),
-		Search:          s.settings.Codex.EnableSearch,
-		FullAuto:        s.settings.Codex.FullAuto,
-		BypassApprovals: s.settings.Codex.BypassApprovals,
-		WorkingDir:      s.settings.Codex.DefaultWorkingDir,
-		SystemPrompt:    s.settings.Codex.DefaultSystemPrompt,
-		AddDirs:         append([]string{}, s.settings.Codex.DefaultAddDirs...),
-		ConfigOverrides: append([]string{}, s.settings.Codex.ConfigOverrides...),
-		EnableFeatures:  append([]string{}, s.settings.Codex.EnableFeatures...),
-		DisableFeatures: append([]string{}, s.settings.Codex.DisableFeatures...),
-		IncludeHistory:  s.settings.Codex.IncludeHistory,
+		Model:           settings.DefaultModel,
+		Profile:         settings.DefaultProfile,
+		SandboxMode:     types.CodexSandboxMode(settings.DefaultSandbox),
+		ApprovalPolicy:  types.CodexApprovalPolicy(settings.DefaultApprovalPolicy),
+		Search:          settings.EnableSearch,
+		FullAuto:        settings.FullAuto,
+		BypassApprovals: settings.BypassApprovals,
+		WorkingDir:      settings.DefaultWorkingDir,
+		SystemPrompt:    settings.DefaultSystemPrompt,
+		AddDirs:         append([]string{}, settings.DefaultAddDirs...),
+		ConfigOverrides: append([]string{}, settings.ConfigOverrides...),
+		EnableFeatures:  append([]string{}, settings.EnableFeatures...),
+		DisableFeatures: append([]string{}, settings.DisableFeatures...),
+		IncludeHistory:  settings.IncludeHistory,
+		LastMessageOnly: settings.LastMessageOnly,
 	}
 }
 
 // GeminiSettings returns the current Gemini configuration.
 func (s *Server) GeminiSettings() types.GeminiSettings {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
 	return s.settings.Gemini
 }
 
 // UpdateGeminiSettings updates Gemini configuration and persists it.
 func (s *Server) UpdateGeminiSettings(settings types.GeminiSettings) error {
-	s.settings.Gemini = settings
-	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return s.updateAgentSettings(func(current *Settings) {
+		current.Gemini = settings
+	})
 }
 
 // UpdateGeminiModel updates the default Gemini model.
 func (s *Server) UpdateGeminiModel(model string) error {
-	s.settings.Gemini.DefaultModel = model
-	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.Gemini.DefaultModel = model
+	})
 }
 
 // UpdateGeminiSandbox updates the default Gemini sandbox mode.
 func (s *Server) UpdateGeminiSandbox(enabled bool) error {
-	s.settings.Gemini.DefaultSandbox = enabled
-	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.Gemini.DefaultSandbox = enabled
+	})
 }
 
 // UpdateGeminiApprovalMode updates the default Gemini approval mode.
 func (s *Server) UpdateGeminiApprovalMode(mode string) error {
-	s.settings.Gemini.DefaultApprovalMode = mode
-	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.Gemini.DefaultApprovalMode = mode
+	})
 }
 
 // UpdateGeminiResume updates the Gemini session to resume.
 func (s *Server) UpdateGeminiResume(sessionID string) error {
-	s.settings.Gemini.ResumeSession = sessionID
-	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.Gemini.ResumeSession = sessionID
+	})
 }
 
 // GetGeminiConfig builds a GeminiConfig from current settings.
 func (s *Server) GetGeminiConfig() types.GeminiConfig {
+	return geminiConfigFromSettings(s.GeminiSettings())
+}
+
+func geminiConfigFromSettings(settings types.GeminiSettings) types.GeminiConfig {
 	return types.GeminiConfig{
-		Model:        types.GeminiModel(s.settings.Gemini.DefaultModel),
-		Sandbox:      s.settings.Gemini.DefaultSandbox,
-		ApprovalMode: s.settings.Gemini.DefaultApprovalMode,
-		AllowedTools: s.settings.Gemini.CustomAllowedTools,
-		Resume:       s.settings.Gemini.ResumeSession,
+		Model:        types.GeminiModel(settings.DefaultModel),
+		Sandbox:      settings.DefaultSandbox,
+		ApprovalMode: settings.DefaultApprovalMode,
+		AllowedTools: settings.CustomAllowedTools,
+		Resume:       settings.ResumeSession,
 	}
 }
 
 // VibeSettings returns the current Vibe configuration
 func (s *Server) VibeSettings() types.VibeSettings {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
 	return s.settings.Vibe
 }
 
 // UpdateVibeSettings updates Vibe configuration and persists it
 func (s *Server) UpdateVibeSettings(settings types.VibeSettings) error {
-	s.settings.Vibe = settings
-	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return s.updateAgentSettings(func(current *Settings) {
+		current.Vibe = settings
+	})
 }
 
 // UpdateVibeAgent updates the default Vibe agent configuration
 func (s *Server) UpdateVibeAgent(agent string) error {
-	s.settings.Vibe.DefaultAgent = agent
-	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.Vibe.DefaultAgent = agent
+	})
 }
 
 // UpdateVibeNonInteractive updates the non-interactive mode toggle
 func (s *Server) UpdateVibeNonInteractive(enabled bool) error {
-	s.settings.Vibe.NonInteractive = enabled
-	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.Vibe.NonInteractive = enabled
+	})
 }
 
 // UpdateVibeAutoApprove updates the auto-approve toggle
 func (s *Server) UpdateVibeAutoApprove(enabled bool) error {
-	s.settings.Vibe.AutoApprove = enabled
-	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.Vibe.AutoApprove = enabled
+	})
 }
 
 // UpdateVibeIncludeHistory updates the include history toggle
 func (s *Server) UpdateVibeIncludeHistory(enabled bool) error {
-	s.settings.Vibe.IncludeHistory = enabled
-	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.Vibe.IncludeHistory = enabled
+	})
 }
 
 // UpdateVibeSystemPrompt updates the default system prompt
 func (s *Server) UpdateVibeSystemPrompt(prompt string) error {
-	s.settings.Vibe.DefaultSystemPrompt = prompt
-	s.applySettingsToAgents()
-	return s.SaveSettings()
+	return s.updateAgentSettings(func(settings *Settings) {
+		settings.Vibe.DefaultSystemPrompt = prompt
+	})
 }
 
 // GetVibeConfig builds a VibeConfig from current settings
 func (s *Server) GetVibeConfig() types.VibeConfig {
+	return vibeConfigFromSettings(s.VibeSettings())
+}
+
+func vibeConfigFromSettings(settings types.VibeSettings) types.VibeConfig {
 	return types.VibeConfig{
-		Agent:          s.settings.Vibe.DefaultAgent,
-		NonInteractive: s.settings.Vibe.NonInteractive,
-		AutoApprove:    s.settings.Vibe.AutoApprove,
-		IncludeHistory: s.settings.Vibe.IncludeHistory,
-		SystemPrompt:   s.settings.Vibe.DefaultSystemPrompt,
+		Agent:          settings.DefaultAgent,
+		NonInteractive: settings.NonInteractive,
+		AutoApprove:    settings.AutoApprove,
+		IncludeHistory: settings.IncludeHistory,
+		SystemPrompt:   settings.DefaultSystemPrompt,
 	}
 }
 
 // RemoteAgentSettings returns the current remote agent configurations
 func (s *Server) RemoteAgentSettings() []RemoteAgentConfig {
-	return s.settings.RemoteAgents
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return slices.Clone(s.settings.RemoteAgents)
 }
 
 // AddRemoteAgent adds a remote agent configuration and persists it
 func (s *Server) AddRemoteAgent(cardURL, alias string) error {
-	// Check if already exists
-	for _, existing := range s.settings.RemoteAgents {
-		if existing.CardURL == cardURL {
-			return nil // Already exists
+	return s.updateSettings(func(settings *Settings) error {
+		// Check if already exists
+		for _, existing := range settings.RemoteAgents {
+			if existing.CardURL == cardURL {
+				return errSettingsUnchanged
+			}
 		}
-	}
-	s.settings.RemoteAgents = append(s.settings.RemoteAgents, RemoteAgentConfig{
-		CardURL: cardURL,
-		Alias:   alias,
+		settings.RemoteAgents = append(settings.RemoteAgents, RemoteAgentConfig{
+			CardURL: cardURL,
+			Alias:   alias,
+		})
+		return nil
 	})
-	return s.SaveSettings()
 }
 
 // RemoveRemoteAgent removes a remote agent configuration by card URL
 func (s *Server) RemoveRemoteAgent(cardURL string) error {
-	newList := make([]RemoteAgentConfig, 0, len(s.settings.RemoteAgents))
-	for _, cfg := range s.settings.RemoteAgents {
-		if cfg.CardURL != cardURL {
-			newList = append(newList, cfg)
+	return s.updateSettings(func(settings *Settings) error {
+		newList := make([]RemoteAgentConfig, 0, len(settings.RemoteAgents))
+		for _, cfg := range settings.RemoteAgents {
+			if cfg.CardURL != cardURL {
+				newList = append(newList, cfg)
+			}
 		}
+		settings.RemoteAgents = newList
+		return nil
+	})
+}
+
+// ProgressPatterns returns the per-agent progress regexes
+func (s *Server) ProgressPatterns() map[string]string {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	patterns := make(map[string]string, len(s.settings.ProgressPatterns))
+	for id, pattern := range s.settings.ProgressPatterns {
+		patterns[id] = pattern
+	}
+	return patterns
+}
+
+// UpdateProgressPattern sets the regex that reads progress from an agent's stream output
+// and persists it; an empty pattern clears it. The regex needs either two capture groups
+// (current and total) or one (a percentage).
+func (s *Server) UpdateProgressPattern(agentID, pattern string) error {
+	if _, ok := s.registry.Get(agentID); !ok {
+		return fmt.Errorf("agent not found: %s", agentID)
 	}
-	s.settings.RemoteAgents = newList
-	return s.SaveSettings()
-}
\ No newline at end of file
+	if pattern == "" {
+		return s.updateSettings(func(settings *Settings) error {
+			delete(settings.ProgressPatterns, agentID)
+			return nil
+		})
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid progress pattern: %w", err)
+	}
+	if n := re.NumSubexp(); n != 1 && n != 2 {
+		return fmt.Errorf("progress pattern needs 1 (percent) or 2 (current, total) capture groups, has %d", n)
+	}
+	return s.updateSettings(func(settings *Settings) error {
+		if settings.ProgressPatterns == nil {
+			settings.ProgressPatterns = make(map[string]string)
+		}
+		settings.ProgressPatterns[agentID] = pattern
+		return nil
+	})
+}
+
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// AgentEnv returns the environment variables applied to an agent's process launches
+func (s *Server) AgentEnv(agentID string) map[string]string {
+	return s.secrets.AgentEnv(agentID)
+}
+
+// EnvAgents returns the IDs of agents with environment variables set
+func (s *Server) EnvAgents() []string {
+	return s.secrets.EnvAgents()
+}
+
+// UpdateAgentEnv sets one environment variable for an agent's process launches from a
+// KEY=VALUE assignment; an empty value (KEY=) removes it. Values are kept in secrets.json.
+func (s *Server) UpdateAgentEnv(agentID, assignment string) error {
+	info, ok := s.registry.Get(agentID)
+	if !ok {
+		return fmt.Errorf("agent not found: %s", agentID)
+	}
+	setter, ok := info.Agent.(interface{ SetEnv(map[string]string) })
+	if !ok {
+		return fmt.Errorf("agent %s does not launch a local process", agentID)
+	}
+	key, value, found := strings.Cut(assignment, "=")
+	key = strings.TrimSpace(key)
+	if !found || !envKeyPattern.MatchString(key) {
+		return fmt.Errorf("expected KEY=VALUE, got %q", assignment)
+	}
+	if err := s.EnsureDataDir(); err != nil {
+		return err
+	}
+	if err := s.secrets.SetAgentEnv(agentID, key, value); err != nil {
+		return err
+	}
+	setter.SetEnv(s.secrets.AgentEnv(agentID))
+	return nil
+}
+
+// UpdateRemoteAuth stores the bearer token for a remote agent and applies it immediately.
+// The token is kept in secrets.json, never in settings.json.
+func (s *Server) UpdateRemoteAuth(agentID, token string) error {
+	agent, ok := s.remoteRegistry.Get(agentID)
+	if !ok {
+		return fmt.Errorf("remote agent not found: %s", agentID)
+	}
+	if err := s.EnsureDataDir(); err != nil {
+		return err
+	}
+	if err := s.secrets.SetRemoteToken(agentID, token); err != nil {
+		return err
+	}
+	agent.SetBearerToken(token)
+	return nil
+}
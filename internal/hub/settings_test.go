@@ -0,0 +1,76 @@
+package hub
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"agents-hub/internal/types"
+)
+
+// TestSettingsConcurrentAccess runs the settings readers and writers at once; run it
+// with -race to catch an access that skips the settings lock
+func TestSettingsConcurrentAccess(t *testing.T) {
+	s, root := pinnedCodex(t)
+	var wg sync.WaitGroup
+	run := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 50 {
+				f(i)
+			}
+		}()
+	}
+	run(func(i int) { s.rememberWorkingDir("codex", filepath.Join(root, fmt.Sprint(i))) })
+	run(func(int) { _ = s.LastWorkingDir("codex") })
+	run(func(i int) { _ = s.UpdateAgentPriority("codex", i%3) })
+	run(func(int) { _ = s.AgentPriorities() })
+	run(func(int) { _ = s.UpdateAllowedRoot("codex", root) })
+	run(func(int) { _ = s.CheckWorkingDir("codex", root) })
+	run(func(i int) { _ = s.UpdateCodexModel(fmt.Sprint("model-", i)) })
+	run(func(int) { _ = s.UpdateOrchestratorAgents([]string{"codex"}) })
+	run(func(int) { _ = s.Config() })
+	run(func(int) { _ = s.SaveSettings() })
+	run(func(int) { _ = s.ReloadSettings() })
+	wg.Wait()
+
+	if err := s.SaveSettings(); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := ReadSettings(s.Config().DataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := saved.LastWorkingDir["codex"], s.LastWorkingDir("codex"); got != want {
+		t.Fatalf("saved last working dir %q, want %q", got, want)
+	}
+}
+
+func TestCodexSettingsRejectConflicts(t *testing.T) {
+	s := newTestServer(t)
+	if err := s.UpdateCodexSandbox("read-only"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateCodexSettings(types.CodexSettings{DefaultSandbox: "read-only", FullAuto: true}); err == nil {
+		t.Fatal("full auto with a sandbox mode was accepted")
+	}
+	if got := s.CodexSettings(); got.FullAuto || got.DefaultSandbox != "read-only" {
+		t.Fatalf("a rejected change was kept: %+v", got)
+	}
+
+	if err := s.UpdateCodexSettings(types.CodexSettings{BypassApprovals: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateCodexApprovalPolicy("never"); err == nil {
+		t.Fatal("an approval policy under bypassApprovals was accepted")
+	}
+	saved, err := ReadSettings(s.Config().DataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Codex.DefaultApprovalPolicy != "" || !saved.Codex.BypassApprovals {
+		t.Fatalf("saved Codex settings = %+v, want bypass only", saved.Codex)
+	}
+}
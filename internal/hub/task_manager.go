@@ -3,7 +3,10 @@ package hub
 import (
 	"encoding/json"
 	"errors"
+	"maps"
 	"os"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 
@@ -11,33 +14,91 @@ import (
 	"agents-hub/internal/utils"
 )
 
+// DefaultTaskPersistDelay is how long task changes are batched before tasks.json is rewritten
+const DefaultTaskPersistDelay = 250 * time.Millisecond
+
+var (
+	errTaskNotFound      = errors.New("task not found")
+	errTaskNotCancelable = errors.New("task not cancelable")
+)
+
 type TaskManager struct {
-	mu          sync.RWMutex
-	tasks       map[string]*types.Task
-	persistPath string
-	persistMu   sync.Mutex
+	mu           sync.RWMutex
+	tasks        map[string]*types.Task
+	byContext    map[string]map[string]struct{} // context ID -> IDs of its tasks
+	taskContext  map[string]string              // task ID -> context it is indexed under
+	persistPath  string
+	persistMu    sync.Mutex
+	persistDelay time.Duration
+	flushTimer   *time.Timer
+	dirty        bool
 }
 
 func NewTaskManager() *TaskManager {
-	return &TaskManager{tasks: make(map[string]*types.Task)}
+	return &TaskManager{
+		tasks:        make(map[string]*types.Task),
+		byContext:    make(map[string]map[string]struct{}),
+		taskContext:  make(map[string]string),
+		persistDelay: DefaultTaskPersistDelay,
+	}
 }
 
 func (tm *TaskManager) SetPersistence(path string) {
 	tm.persistPath = path
 }
 
+// SetPersistDelay sets the write batching window (0 writes on every change)
+func (tm *TaskManager) SetPersistDelay(delay time.Duration) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.persistDelay = delay
+}
+
+// Create stores a copy of task, so later changes go through the TaskManager
 func (tm *TaskManager) Create(task *types.Task) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
-	tm.tasks[task.ID] = task
+	stored := cloneTask(task)
+	tm.tasks[task.ID] = stored
+	tm.indexLocked(stored)
 	tm.persistLocked()
 }
 
+// Get returns a copy of a task that is safe to read while it runs
 func (tm *TaskManager) Get(id string) (*types.Task, bool) {
 	tm.mu.RLock()
 	defer tm.mu.RUnlock()
 	task, ok := tm.tasks[id]
-	return task, ok
+	if !ok {
+		return nil, false
+	}
+	return cloneTask(task), true
+}
+
+// cloneTask copies a task's metadata, history, artifacts and status message. Stored
+// messages and artifacts are replaced rather than changed in place, so their own
+// parts are shared.
+func cloneTask(task *types.Task) *types.Task {
+	clone := *task
+	clone.Metadata = maps.Clone(task.Metadata)
+	clone.History = slices.Clone(task.History)
+	clone.Artifacts = slices.Clone(task.Artifacts)
+	if task.Status.Message != nil {
+		msg := *task.Status.Message
+		clone.Status.Message = &msg
+	}
+	return &clone
+}
+
+// State returns a task's current state
+func (tm *TaskManager) State(id string) (types.TaskState, bool) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	task, ok := tm.tasks[id]
+	if !ok {
+		return "", false
+	}
+	return task.Status.State, true
 }
 
 func (tm *TaskManager) UpdateStatus(id string, state types.TaskState, msg *types.Message) error {
@@ -45,27 +106,124 @@ func (tm *TaskManager) UpdateStatus(id string, state types.TaskState, msg *types
 	defer tm.mu.Unlock()
 	task, ok := tm.tasks[id]
 	if !ok {
-		return errors.New("task not found")
+		return errTaskNotFound
+	}
+	tm.setStatusLocked(task, state, msg)
+	return nil
+}
+
+// UpdateStatusIf sets a task's status only while it is still in state from, and
+// reports whether it did, so a cancel that lands first is not overwritten
+func (tm *TaskManager) UpdateStatusIf(id string, from, state types.TaskState, msg *types.Message) bool {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	task, ok := tm.tasks[id]
+	if !ok || task.Status.State != from {
+		return false
+	}
+	tm.setStatusLocked(task, state, msg)
+	return true
+}
+
+// FinishIf records a run's history, artifacts and final status while the task is
+// still in state from, and returns the finished task; it reports false, changing
+// nothing, if a cancel landed first
+func (tm *TaskManager) FinishIf(id string, from types.TaskState, status types.TaskStatus, history []types.Message, artifacts []types.Artifact) (*types.Task, bool) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	task, ok := tm.tasks[id]
+	if !ok || task.Status.State != from {
+		return nil, false
+	}
+	task.History = history
+	task.Artifacts = artifacts
+	tm.setStatusLocked(task, status.State, status.Message)
+	return cloneTask(task), true
+}
+
+// Cancel marks a task canceled unless it already finished
+func (tm *TaskManager) Cancel(id string, msg *types.Message) error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	task, ok := tm.tasks[id]
+	if !ok {
+		return errTaskNotFound
 	}
+	if task.Status.State.IsTerminal() {
+		return errTaskNotCancelable
+	}
+	tm.setStatusLocked(task, types.TaskStateCanceled, msg)
+	return nil
+}
+
+func (tm *TaskManager) setStatusLocked(task *types.Task, state types.TaskState, msg *types.Message) {
 	task.Status.State = state
 	task.Status.Message = msg
 	task.Status.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+	// Callers may have set the context on the task itself
+	tm.indexLocked(task)
+	tm.persistLocked()
+}
+
+// SetMetadata merges entries into a task's metadata
+func (tm *TaskManager) SetMetadata(id string, entries map[string]any) error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	task, ok := tm.tasks[id]
+	if !ok {
+		return errTaskNotFound
+	}
+	if task.Metadata == nil {
+		task.Metadata = make(map[string]any, len(entries))
+	}
+	for key, value := range entries {
+		task.Metadata[key] = value
+	}
 	tm.persistLocked()
 	return nil
 }
 
-func (tm *TaskManager) List(contextID string, state types.TaskState, limit, offset int) []types.Task {
+// TaskFilter selects tasks in List; zero fields match every task
+type TaskFilter struct {
+	ContextID    string
+	ParentTaskID string // the sub-tasks an orchestrator task delegated
+	State        types.TaskState
+	Since        time.Time // status updated at or after Since
+	Until        time.Time // status updated before Until
+}
+
+// matches reports whether task passes the filter's parent, state and time bounds. A
+// task whose status timestamp can't be read fails any time bound.
+func (f TaskFilter) matches(task *types.Task) bool {
+	if parent, _ := task.Metadata["parentTaskId"].(string); f.ParentTaskID != "" && parent != f.ParentTaskID {
+		return false
+	}
+	if f.State != "" && task.Status.State != f.State {
+		return false
+	}
+	if f.Since.IsZero() && f.Until.IsZero() {
+		return true
+	}
+	updated, err := time.Parse(time.RFC3339Nano, task.Status.Timestamp)
+	if err != nil {
+		return false
+	}
+	return !updated.Before(f.Since) && (f.Until.IsZero() || updated.Before(f.Until))
+}
+
+// List returns the tasks matching filter
+func (tm *TaskManager) List(filter TaskFilter, limit, offset int) []types.Task {
 	tm.mu.RLock()
 	defer tm.mu.RUnlock()
 	result := make([]types.Task, 0)
-	for _, task := range tm.tasks {
-		if contextID != "" && task.ContextID != contextID {
+	for _, task := range tm.candidatesLocked(filter.ContextID) {
+		if filter.ContextID != "" && task.ContextID != filter.ContextID {
 			continue
 		}
-		if state != "" && task.Status.State != state {
+		if !filter.matches(task) {
 			continue
 		}
-		result = append(result, *task)
+		result = append(result, *cloneTask(task))
 	}
 	if offset >= len(result) {
 		return []types.Task{}
@@ -77,6 +235,106 @@ func (tm *TaskManager) List(contextID string, state types.TaskState, limit, offs
 	return result[offset:end]
 }
 
+// candidatesLocked returns the tasks List has to check: those indexed under contextID,
+// or every task when there is no context filter
+func (tm *TaskManager) candidatesLocked(contextID string) map[string]*types.Task {
+	if contextID == "" {
+		return tm.tasks
+	}
+	ids := tm.byContext[contextID]
+	tasks := make(map[string]*types.Task, len(ids))
+	for id := range ids {
+		if task, ok := tm.tasks[id]; ok {
+			tasks[id] = task
+		}
+	}
+	return tasks
+}
+
+// indexLocked files the task under its current context ID
+func (tm *TaskManager) indexLocked(task *types.Task) {
+	indexed, ok := tm.taskContext[task.ID]
+	if ok && indexed == task.ContextID {
+		return
+	}
+	if ok {
+		tm.unindexLocked(task.ID)
+	}
+	if task.ContextID == "" {
+		return
+	}
+	ids := tm.byContext[task.ContextID]
+	if ids == nil {
+		ids = make(map[string]struct{})
+		tm.byContext[task.ContextID] = ids
+	}
+	ids[task.ID] = struct{}{}
+	tm.taskContext[task.ID] = task.ContextID
+}
+
+func (tm *TaskManager) unindexLocked(taskID string) {
+	contextID, ok := tm.taskContext[taskID]
+	if !ok {
+		return
+	}
+	delete(tm.taskContext, taskID)
+	delete(tm.byContext[contextID], taskID)
+	if len(tm.byContext[contextID]) == 0 {
+		delete(tm.byContext, contextID)
+	}
+}
+
+// Prune removes terminal tasks last updated before the cutoff and returns their IDs. The
+// keepRecent most recently updated tasks are always retained, and non-terminal tasks are
+// never pruned.
+func (tm *TaskManager) Prune(cutoff time.Time, keepRecent int) []string {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	type entry struct {
+		id      string
+		updated time.Time
+	}
+	entries := make([]entry, 0, len(tm.tasks))
+	for id, task := range tm.tasks {
+		updated, err := time.Parse(time.RFC3339Nano, task.Status.Timestamp)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry{id: id, updated: updated})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].updated.After(entries[j].updated)
+	})
+	var pruned []string
+	for i, e := range entries {
+		if i < keepRecent {
+			continue
+		}
+		task := tm.tasks[e.id]
+		if !task.Status.State.IsTerminal() || !e.updated.Before(cutoff) {
+			continue
+		}
+		delete(tm.tasks, e.id)
+		tm.unindexLocked(e.id)
+		pruned = append(pruned, e.id)
+	}
+	if len(pruned) > 0 {
+		tm.persistLocked()
+	}
+	return pruned
+}
+
+// CountByState returns the number of tracked tasks in each state
+func (tm *TaskManager) CountByState() map[types.TaskState]int {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	counts := make(map[types.TaskState]int)
+	for _, task := range tm.tasks {
+		counts[task.Status.State]++
+	}
+	return counts
+}
+
 func (tm *TaskManager) Load() error {
 	if tm.persistPath == "" {
 		return nil
@@ -96,14 +354,43 @@ func (tm *TaskManager) Load() error {
 	defer tm.mu.Unlock()
 	for _, task := range stored {
 		tm.tasks[task.ID] = task
+		tm.indexLocked(task)
 	}
 	return nil
 }
 
+// Flush writes any batched changes to disk immediately. Call it on shutdown.
+func (tm *TaskManager) Flush() error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.flushTimer != nil {
+		tm.flushTimer.Stop()
+		tm.flushTimer = nil
+	}
+	if !tm.dirty {
+		return nil
+	}
+	return tm.writeLocked()
+}
+
+// persistLocked marks the tasks dirty and schedules a batched write
 func (tm *TaskManager) persistLocked() {
 	if tm.persistPath == "" {
 		return
 	}
+	tm.dirty = true
+	if tm.persistDelay <= 0 {
+		_ = tm.writeLocked()
+		return
+	}
+	if tm.flushTimer == nil {
+		tm.flushTimer = time.AfterFunc(tm.persistDelay, func() {
+			_ = tm.Flush()
+		})
+	}
+}
+
+func (tm *TaskManager) writeLocked() error {
 	tm.persistMu.Lock()
 	defer tm.persistMu.Unlock()
 	snapshot := make([]*types.Task, 0, len(tm.tasks))
@@ -112,7 +399,11 @@ func (tm *TaskManager) persistLocked() {
 	}
 	data, err := json.MarshalIndent(snapshot, "", "  ")
 	if err != nil {
-		return
+		return err
 	}
-	_ = utils.WriteFileAtomic(tm.persistPath, data, 0o644)
+	if err := utils.WriteFileAtomic(tm.persistPath, data, 0o644); err != nil {
+		return err
+	}
+	tm.dirty = false
+	return nil
 }
@@ -0,0 +1,206 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"agents-hub/internal/jsonrpc"
+	"agents-hub/internal/types"
+)
+
+func TestUpdateStatusIf(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     types.TaskState // the task's state before the update; "" for no task
+		wantOK    bool
+		wantState types.TaskState
+	}{
+		{"submitted starts working", types.TaskStateSubmitted, true, types.TaskStateWorking},
+		{"canceled stays canceled", types.TaskStateCanceled, false, types.TaskStateCanceled},
+		{"working is not started twice", types.TaskStateWorking, false, types.TaskStateWorking},
+		{"missing task", "", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tm := NewTaskManager()
+			if tt.state != "" {
+				tm.Create(&types.Task{Kind: "task", ID: "task-1", Status: types.TaskStatus{State: tt.state}})
+			}
+			if ok := tm.UpdateStatusIf("task-1", types.TaskStateSubmitted, types.TaskStateWorking, nil); ok != tt.wantOK {
+				t.Fatalf("UpdateStatusIf = %v, want %v", ok, tt.wantOK)
+			}
+			if state, _ := tm.State("task-1"); state != tt.wantState {
+				t.Fatalf("state = %q, want %q", state, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestTaskCancelCascadesToSubtasks(t *testing.T) {
+	s := newTestServer(t)
+	add := func(id, parent string, state types.TaskState) {
+		s.tasks.Create(&types.Task{Kind: "task", ID: id, ContextID: "ctx-1", Status: types.TaskStatus{State: state}})
+		if parent != "" {
+			_ = s.tasks.SetMetadata(id, map[string]any{"parentTaskId": parent})
+		}
+	}
+	add("parent", "", types.TaskStateWorking)
+	add("child", "parent", types.TaskStateWorking)
+	add("grandchild", "child", types.TaskStateSubmitted)
+	add("done", "parent", types.TaskStateCompleted)
+	add("other", "", types.TaskStateWorking)
+
+	result, rpcErr := s.handleTaskCancel(context.Background(), json.RawMessage(`{"id":"parent"}`))
+	if rpcErr != nil {
+		t.Fatal(rpcErr)
+	}
+	if got := result.(map[string]any)["canceledSubtasks"]; !reflect.DeepEqual(got, []string{"child", "grandchild"}) {
+		t.Fatalf("canceledSubtasks = %v, want [child grandchild]", got)
+	}
+	want := map[string]types.TaskState{
+		"parent":     types.TaskStateCanceled,
+		"child":      types.TaskStateCanceled,
+		"grandchild": types.TaskStateCanceled,
+		"done":       types.TaskStateCompleted,
+		"other":      types.TaskStateWorking,
+	}
+	for id, wantState := range want {
+		if state, _ := s.tasks.State(id); state != wantState {
+			t.Errorf("%s: state = %q, want %q", id, state, wantState)
+		}
+	}
+
+	if _, rpcErr := s.handleTaskCancel(context.Background(), json.RawMessage(`{"id":"parent"}`)); rpcErr == nil || rpcErr.Code != jsonrpc.ErrTaskNotCancelable {
+		t.Fatalf("second cancel error = %v, want not cancelable", rpcErr)
+	}
+}
+
+func TestTaskFilterTimeBounds(t *testing.T) {
+	at := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	stamp := at.Format(time.RFC3339Nano)
+	tests := []struct {
+		name      string
+		timestamp string
+		filter    TaskFilter
+		want      bool
+	}{
+		{"no bounds", stamp, TaskFilter{}, true},
+		{"no bounds keeps an unreadable timestamp", "yesterday", TaskFilter{}, true},
+		{"since is inclusive", stamp, TaskFilter{Since: at}, true},
+		{"before since", stamp, TaskFilter{Since: at.Add(time.Nanosecond)}, false},
+		{"until is exclusive", stamp, TaskFilter{Until: at}, false},
+		{"before until", stamp, TaskFilter{Until: at.Add(time.Nanosecond)}, true},
+		{"inside both", stamp, TaskFilter{Since: at, Until: at.Add(time.Hour)}, true},
+		{"unreadable timestamp fails since", "yesterday", TaskFilter{Since: at}, false},
+		{"unreadable timestamp fails until", "", TaskFilter{Until: at}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &types.Task{Status: types.TaskStatus{State: types.TaskStateCompleted, Timestamp: tt.timestamp}}
+			if got := tt.filter.matches(task); got != tt.want {
+				t.Fatalf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTasksListRejectsSinceAfterUntil(t *testing.T) {
+	s := newTestServer(t)
+	_, rpcErr := s.handleTasksList(context.Background(), json.RawMessage(`{"since":"2026-05-02T00:00:00Z","until":"2026-05-01T00:00:00Z"}`))
+	if rpcErr == nil || rpcErr.Code != jsonrpc.ErrInvalidParams {
+		t.Fatalf("error = %v, want invalid params", rpcErr)
+	}
+	if _, rpcErr := s.handleTasksList(context.Background(), json.RawMessage(`{"since":"2h","until":"1h"}`)); rpcErr != nil {
+		t.Fatalf("since before until: %v", rpcErr)
+	}
+}
+
+func TestTaskPersistenceBatchesWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	tm := NewTaskManager()
+	tm.SetPersistence(path)
+	tm.SetPersistDelay(time.Hour)
+	for _, id := range []string{"task-1", "task-2"} {
+		tm.Create(&types.Task{Kind: "task", ID: id, ContextID: "ctx-1", Status: types.TaskStatus{State: types.TaskStateSubmitted}})
+	}
+	_ = tm.UpdateStatus("task-1", types.TaskStateCompleted, nil)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("tasks were written before the batch window closed: %v", err)
+	}
+	if err := tm.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewTaskManager()
+	loaded.SetPersistence(path)
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := loaded.State("task-1"); state != types.TaskStateCompleted {
+		t.Fatalf("loaded task-1 state = %q, want completed", state)
+	}
+	if got := loaded.List(TaskFilter{ContextID: "ctx-1"}, 0, 0); len(got) != 2 {
+		t.Fatalf("loaded %d tasks in ctx-1, want 2", len(got))
+	}
+
+	// With no delay every change is written at once
+	immediate := NewTaskManager()
+	immediate.SetPersistence(filepath.Join(t.TempDir(), "tasks.json"))
+	immediate.SetPersistDelay(0)
+	immediate.Create(&types.Task{Kind: "task", ID: "task-3"})
+	if _, err := os.Stat(immediate.persistPath); err != nil {
+		t.Fatalf("task was not written right away: %v", err)
+	}
+}
+
+func TestContextIndex(t *testing.T) {
+	tm := NewTaskManager()
+	old := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339Nano)
+	tm.Create(&types.Task{Kind: "task", ID: "task-1", ContextID: "ctx-a", Status: types.TaskStatus{State: types.TaskStateCompleted, Timestamp: old}})
+	tm.Create(&types.Task{Kind: "task", ID: "task-2", ContextID: "ctx-a", Status: types.TaskStatus{State: types.TaskStateWorking}})
+	count := func(contextID string) int { return len(tm.List(TaskFilter{ContextID: contextID}, 0, 0)) }
+	if count("ctx-a") != 2 || count("ctx-b") != 0 {
+		t.Fatalf("ctx-a has %d tasks, ctx-b %d; want 2 and 0", count("ctx-a"), count("ctx-b"))
+	}
+
+	// A task moved to another context is refiled on its next status update
+	tm.mu.Lock()
+	tm.tasks["task-2"].ContextID = "ctx-b"
+	tm.mu.Unlock()
+	_ = tm.UpdateStatus("task-2", types.TaskStateCompleted, nil)
+	if count("ctx-a") != 1 || count("ctx-b") != 1 {
+		t.Fatalf("after the move ctx-a has %d tasks, ctx-b %d; want 1 and 1", count("ctx-a"), count("ctx-b"))
+	}
+
+	if pruned := tm.Prune(time.Now().Add(-time.Minute), 0); !reflect.DeepEqual(pruned, []string{"task-1"}) {
+		t.Fatalf("pruned %v, want task-1", pruned)
+	}
+	if count("ctx-a") != 0 || len(tm.byContext) != 1 {
+		t.Fatalf("ctx-a still lists %d tasks and %d contexts are indexed after the prune", count("ctx-a"), len(tm.byContext))
+	}
+}
+
+// BenchmarkListByContext compares a context-scoped list, served from the index,
+// with a state-filtered one that has to scan every task
+func BenchmarkListByContext(b *testing.B) {
+	tm := NewTaskManager()
+	for i := range 10000 {
+		tm.Create(&types.Task{Kind: "task", ID: fmt.Sprint("task-", i), ContextID: fmt.Sprint("ctx-", i%500), Status: types.TaskStatus{State: types.TaskStateCompleted}})
+	}
+	b.Run("indexed context", func(b *testing.B) {
+		for b.Loop() {
+			tm.List(TaskFilter{ContextID: "ctx-7"}, 0, 0)
+		}
+	})
+	b.Run("full scan", func(b *testing.B) {
+		for b.Loop() {
+			tm.List(TaskFilter{State: types.TaskStateWorking}, 0, 0)
+		}
+	})
+}
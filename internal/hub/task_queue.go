@@ -0,0 +1,154 @@
+package hub
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"agents-hub/internal/types"
+)
+
+var (
+	errQueueFull   = errors.New("task queue is full, try again later")
+	errQueueClosed = errors.New("hub is shutting down")
+)
+
+// queuedTask is a message/send waiting for a worker. cancel is called instead of run
+// when the queue is stopped before the task starts, and while it runs when the
+// drain times out.
+type queuedTask struct {
+	taskID string
+	run    func()
+	cancel func()
+}
+
+// TaskQueue runs non-blocking message/send requests on a fixed pool of workers, in
+// the order they were submitted, so the number of agents running at once stays
+// bounded whichever transport the requests came from
+type TaskQueue struct {
+	tasks   chan queuedTask
+	workers int
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	closed  bool
+	aborted bool
+	running map[string]queuedTask // task ID -> task a worker is running
+}
+
+// NewTaskQueue starts workers that take tasks from a queue holding up to size of them
+func NewTaskQueue(workers, size int) *TaskQueue {
+	if workers < 1 {
+		workers = 1
+	}
+	if size < 0 {
+		size = 0
+	}
+	q := &TaskQueue{
+		tasks:   make(chan queuedTask, size),
+		workers: workers,
+		running: make(map[string]queuedTask),
+	}
+	q.wg.Add(workers)
+	for range workers {
+		go q.work()
+	}
+	return q
+}
+
+// Submit queues a task without waiting; it fails when the queue is full or stopped
+func (q *TaskQueue) Submit(task queuedTask) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return errQueueClosed
+	}
+	select {
+	case q.tasks <- task:
+		return nil
+	default:
+		return errQueueFull
+	}
+}
+
+// Stats reports the worker count and how many tasks are waiting and running
+func (q *TaskQueue) Stats() (workers, queued, running int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.workers, len(q.tasks), len(q.running)
+}
+
+// Stop refuses new tasks and waits up to drain for the queued and running ones to
+// finish. Past that, the tasks still queued are canceled instead of run and the
+// running ones are canceled too, and Stop waits for them to wind down. It reports
+// whether the queue drained in time.
+func (q *TaskQueue) Stop(drain time.Duration) bool {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return true
+	}
+	q.closed = true
+	close(q.tasks)
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	if drain > 0 {
+		select {
+		case <-done:
+			return true
+		case <-time.After(drain):
+		}
+	}
+
+	q.mu.Lock()
+	q.aborted = true
+	running := make([]queuedTask, 0, len(q.running))
+	for _, task := range q.running {
+		running = append(running, task)
+	}
+	q.mu.Unlock()
+	for _, task := range running {
+		task.cancel()
+	}
+	<-done
+	return false
+}
+
+func (q *TaskQueue) work() {
+	defer q.wg.Done()
+	for task := range q.tasks {
+		q.mu.Lock()
+		if q.aborted {
+			q.mu.Unlock()
+			task.cancel()
+			continue
+		}
+		q.running[task.taskID] = task
+		q.mu.Unlock()
+
+		task.run()
+
+		q.mu.Lock()
+		delete(q.running, task.taskID)
+		q.mu.Unlock()
+	}
+}
+
+// cancelQueued cancels a queued task the hub is shutting down without running, and
+// stops its agent if it already started
+func (s *Server) cancelQueued(taskID string) {
+	task, ok := s.tasks.Get(taskID)
+	if !ok {
+		return
+	}
+	reason := &types.Message{Kind: "message", MessageID: "canceled-" + taskID, Role: "agent", Parts: []types.Part{{Kind: "text", Text: "canceled: the hub shut down"}}, TaskID: taskID, ContextID: task.ContextID}
+	if !s.tasks.UpdateStatusIf(taskID, types.TaskStateSubmitted, types.TaskStateCanceled, reason) &&
+		!s.tasks.UpdateStatusIf(taskID, types.TaskStateWorking, types.TaskStateCanceled, reason) {
+		return
+	}
+	s.stopRunning(taskID)
+}
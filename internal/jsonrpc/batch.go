@@ -0,0 +1,63 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// maxBatchConcurrency bounds how many requests of one batch run at once.
+const maxBatchConcurrency = 8
+
+// IsBatch reports whether a raw payload is a JSON-RPC batch (a JSON array).
+func IsBatch(data []byte) bool {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// HandleBatch dispatches every request of a batch and returns the payload to
+// send back: a []Response in batch order, a single Response when the batch is
+// malformed or empty, or nil when the batch only held notifications.
+func (h *Handler) HandleBatch(ctx context.Context, data []byte) any {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return Response{JSONRPC: "2.0", Error: &RPCError{Code: ErrParseError, Message: "Parse error"}}
+	}
+	if len(items) == 0 {
+		return Response{JSONRPC: "2.0", Error: &RPCError{Code: ErrInvalidRequest, Message: "Invalid Request"}}
+	}
+
+	responses := make([]*Response, len(items))
+	sem := make(chan struct{}, maxBatchConcurrency)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		var req Request
+		if err := DecodeRequest(item, &req); err != nil {
+			responses[i] = &Response{JSONRPC: "2.0", Error: &RPCError{Code: ErrInvalidRequest, Message: "Invalid Request"}}
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, req Request) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			resp := h.Handle(ctx, req)
+			if req.ID != nil {
+				responses[i] = &resp
+			}
+		}(i, req)
+	}
+	wg.Wait()
+
+	out := make([]Response, 0, len(responses))
+	for _, resp := range responses {
+		if resp != nil {
+			out = append(out, *resp)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
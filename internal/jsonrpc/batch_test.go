@@ -0,0 +1,74 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+)
+
+func echoHandler() *Handler {
+	h := NewHandler()
+	h.Register("echo", func(ctx context.Context, params json.RawMessage) (any, *RPCError) {
+		return params, nil
+	})
+	return h
+}
+
+func TestHandleBatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		batch string
+		want  string // the JSON sent back, or "null" for nothing
+	}{
+		{
+			"answers in batch order",
+			`[{"jsonrpc":"2.0","method":"echo","params":1,"id":1},{"jsonrpc":"2.0","method":"echo","params":"x","id":"b"}]`,
+			`[{"jsonrpc":"2.0","result":1,"id":1},{"jsonrpc":"2.0","result":"x","id":"b"}]`,
+		},
+		{
+			"notifications get no answer",
+			`[{"jsonrpc":"2.0","method":"echo","params":1},{"jsonrpc":"2.0","method":"echo","params":2,"id":2}]`,
+			`[{"jsonrpc":"2.0","result":2,"id":2}]`,
+		},
+		{
+			"invalid entries get their own error",
+			`[1,{"jsonrpc":"2.0","method":"missing","id":3}]`,
+			`[{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null},{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":3}]`,
+		},
+		{"only notifications", `[{"jsonrpc":"2.0","method":"echo","params":1}]`, `null`},
+		{"empty batch", `[]`, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`},
+		{"malformed batch", `[{"jsonrpc":`, `{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !IsBatch([]byte(" \n" + tt.batch)) {
+				t.Fatal("IsBatch = false")
+			}
+			got, err := json.Marshal(echoHandler().HandleBatch(context.Background(), []byte(tt.batch)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("HandleBatch = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleBatchKeepsOrderAcrossWorkers(t *testing.T) {
+	batch := make([]Request, 3*maxBatchConcurrency)
+	for i := range batch {
+		batch[i] = Request{JSONRPC: "2.0", Method: "echo", Params: json.RawMessage(strconv.Itoa(i)), ID: i}
+	}
+	data, _ := json.Marshal(batch)
+	responses, ok := echoHandler().HandleBatch(context.Background(), data).([]Response)
+	if !ok || len(responses) != len(batch) {
+		t.Fatalf("got %d responses, want %d", len(responses), len(batch))
+	}
+	for i, resp := range responses {
+		if !SameID(resp.ID, i) {
+			t.Fatalf("response %d has id %v", i, resp.ID)
+		}
+	}
+}
@@ -49,4 +49,5 @@ const (
 	ErrAuthError       = -32006
 	ErrTimeout         = -32007
 	ErrContextNotFound = -32008
+	ErrArtifactNotFound = -32009
 )
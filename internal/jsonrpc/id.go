@@ -0,0 +1,31 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+)
+
+var idCounter atomic.Uint64
+
+// NextID returns a process-unique request ID such as "cli-3"
+func NextID(prefix string) string {
+	return fmt.Sprintf("%s-%d", prefix, idCounter.Add(1))
+}
+
+// SameID reports whether a response ID echoes a request ID. IDs are compared
+// by their JSON form, so a number decoded as float64 matches the json.Number it
+// was sent as.
+func SameID(a, b any) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
+
+// DecodeRequest unmarshals one request, keeping numeric IDs exactly as sent
+func DecodeRequest(data []byte, req *Request) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(req)
+}
@@ -0,0 +1,40 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestRequestIDsEchoExactly(t *testing.T) {
+	for _, id := range []string{`12345678901234567890`, `1.50`, `-0`, `"cli-3"`, `null`} {
+		var req Request
+		if err := DecodeRequest([]byte(`{"jsonrpc":"2.0","method":"echo","params":1,"id":`+id+`}`), &req); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := json.Marshal(echoHandler().Handle(context.Background(), req))
+		if want := `{"jsonrpc":"2.0","result":1,"id":` + id + `}`; string(data) != want {
+			t.Errorf("id %s: response %s, want %s", id, data, want)
+		}
+	}
+}
+
+func TestSameID(t *testing.T) {
+	tests := []struct {
+		a, b any
+		want bool
+	}{
+		{"socket-1", "socket-1", true},
+		{"socket-1", "socket-2", false},
+		{float64(3), json.Number("3"), true},
+		{float64(3), 3, true},
+		{"3", 3, false},
+		{nil, nil, true},
+		{nil, "socket-1", false},
+	}
+	for _, tt := range tests {
+		if got := SameID(tt.a, tt.b); got != tt.want {
+			t.Errorf("SameID(%#v, %#v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
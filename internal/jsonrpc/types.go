@@ -13,7 +13,7 @@ type Response struct {
 	JSONRPC string    `json:"jsonrpc"`
 	Result  any       `json:"result,omitempty"`
 	Error   *RPCError `json:"error,omitempty"`
-	ID      any       `json:"id,omitempty"`
+	ID      any       `json:"id"`
 }
 
 type RPCError struct {
@@ -0,0 +1,204 @@
+package taskfmt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"agents-hub/internal/hub"
+	"agents-hub/internal/types"
+)
+
+// Hints are notes a front end adds to some lines of Detail, such as the key or
+// command that acts on them; empty hints are left out
+type Hints struct {
+	WorkingDir  string // after the working directory, e.g. "e to open"
+	DeadLetters string // after the dead-letter note, e.g. "see /deadletters"
+}
+
+// Detail renders a task as a text block: its state, agent, routing, changed files,
+// artifacts, sub-tasks and response. The CLI's pretty format and the TUI's detail
+// pane both use it.
+func Detail(task types.Task, children []types.Task, hints Hints) string {
+	lines := []string{
+		fmt.Sprintf("ID: %s", task.ID),
+		fmt.Sprintf("State: %s", task.Status.State),
+	}
+	if agent := Agent(task); agent != "" {
+		lines = append(lines, fmt.Sprintf("Agent: %s", agent))
+	}
+	lines = append(lines,
+		fmt.Sprintf("Context: %s", task.ContextID),
+		fmt.Sprintf("Timestamp: %s", task.Status.Timestamp),
+		fmt.Sprintf("Age: %s", Age(task)),
+	)
+	if dead, _ := task.Metadata["deadLettered"].(bool); dead {
+		line := fmt.Sprintf("Dead-lettered after %v attempt(s)", task.Metadata["attempts"])
+		if hints.DeadLetters != "" {
+			line += "; " + hints.DeadLetters
+		}
+		lines = append(lines, line)
+	}
+	if dir, _ := task.Metadata["workingDirectory"].(string); dir != "" {
+		line := "Working dir: " + dir
+		if hints.WorkingDir != "" {
+			line += " (" + hints.WorkingDir + ")"
+		}
+		lines = append(lines, line)
+	}
+	if parent := ParentID(task); parent != "" {
+		routedBy, _ := task.Metadata["routedBy"].(string)
+		lines = append(lines, fmt.Sprintf("Routed by: %s (parent %s)", routedBy, parent))
+	}
+	if changes := ChangedFiles(task); len(changes) > 0 {
+		lines = append(lines, "", fmt.Sprintf("Changed files (%d):", len(changes)))
+		for _, change := range changes {
+			lines = append(lines, fmt.Sprintf("  %-8s %s", change.Change, change.Path))
+		}
+	}
+	if artifacts := otherArtifacts(task); len(artifacts) > 0 {
+		lines = append(lines, "", fmt.Sprintf("Artifacts (%d):", len(artifacts)))
+		for _, artifact := range artifacts {
+			lines = append(lines, renderArtifact(artifact)...)
+		}
+	}
+	if len(children) > 0 {
+		lines = append(lines, "", fmt.Sprintf("Sub-tasks (%d):", len(children)))
+		for _, child := range children {
+			lines = append(lines, fmt.Sprintf("  └ %s  %s  %s", child.ID, Agent(child), child.Status.State))
+		}
+	}
+	lines = append(lines, "", "Response:", Text(task))
+	return strings.Join(lines, "\n")
+}
+
+// Text is the text of a task's status message, or its state when it has none
+func Text(task types.Task) string {
+	if task.Status.Message == nil {
+		return string(task.Status.State)
+	}
+	parts := make([]string, 0, len(task.Status.Message.Parts))
+	for _, part := range task.Status.Message.Parts {
+		if part.Kind == "text" {
+			parts = append(parts, part.Text)
+		}
+	}
+	return strings.TrimSpace(strings.Join(parts, "\n"))
+}
+
+// Agent is the agent a task was sent to, from its metadata or its messages
+func Agent(task types.Task) string {
+	if value, ok := task.Metadata["targetAgent"].(string); ok && value != "" {
+		return value
+	}
+	for _, msg := range task.History {
+		if value, ok := msg.Metadata["targetAgent"].(string); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// ParentID is the orchestrator task that delegated this one, if any
+func ParentID(task types.Task) string {
+	parent, _ := task.Metadata["parentTaskId"].(string)
+	return parent
+}
+
+// Age is how long ago the task last changed state
+func Age(task types.Task) string {
+	updated, err := time.Parse(time.RFC3339Nano, task.Status.Timestamp)
+	if err != nil {
+		return "unknown"
+	}
+	return HumanDuration(time.Since(updated)) + " ago"
+}
+
+// HumanDuration renders a duration as "2d 3h 12m 5s", leaving out leading zero units
+func HumanDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d < time.Second {
+		return "0s"
+	}
+	units := []struct {
+		size time.Duration
+		name string
+	}{{24 * time.Hour, "d"}, {time.Hour, "h"}, {time.Minute, "m"}, {time.Second, "s"}}
+	var parts []string
+	for _, unit := range units {
+		n := d / unit.size
+		d -= n * unit.size
+		if n > 0 || len(parts) > 0 {
+			parts = append(parts, fmt.Sprintf("%d%s", n, unit.name))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ChangedFiles reads the changed-files artifact the hub attaches when capture is on
+func ChangedFiles(task types.Task) []hub.FileChange {
+	for _, artifact := range task.Artifacts {
+		if artifact.ArtifactID != hub.ChangesArtifactID {
+			continue
+		}
+		for _, part := range artifact.Parts {
+			var data struct {
+				Files []hub.FileChange `json:"files"`
+			}
+			if part.Kind == "data" && decode(part.Data, &data) == nil {
+				return data.Files
+			}
+		}
+	}
+	return nil
+}
+
+// otherArtifacts are the artifacts Detail lists on their own, which leaves out
+// the changed files
+func otherArtifacts(task types.Task) []types.Artifact {
+	var artifacts []types.Artifact
+	for _, artifact := range task.Artifacts {
+		if artifact.ArtifactID != hub.ChangesArtifactID {
+			artifacts = append(artifacts, artifact)
+		}
+	}
+	return artifacts
+}
+
+func renderArtifact(artifact types.Artifact) []string {
+	name := artifact.Name
+	if name == "" {
+		name = artifact.ArtifactID
+	}
+	header := "  " + name
+	if artifact.Description != "" {
+		header += ": " + artifact.Description
+	}
+	lines := []string{header}
+	for _, part := range artifact.Parts {
+		switch part.Kind {
+		case "text":
+			for _, line := range strings.Split(strings.TrimRight(part.Text, "\n"), "\n") {
+				lines = append(lines, "    "+line)
+			}
+		case "file":
+			if part.File != nil {
+				lines = append(lines, fmt.Sprintf("    file %s (%s)", part.File.Name, part.File.MimeType))
+			}
+		case "data":
+			if data, err := json.Marshal(part.Data); err == nil {
+				lines = append(lines, "    "+string(data))
+			}
+		}
+	}
+	return lines
+}
+
+func decode(input any, target any) error {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
@@ -2,9 +2,11 @@ package transport
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"strings"
 	"time"
@@ -23,7 +25,7 @@ type HTTPTransport struct {
 }
 
 func NewHTTPTransport(cfg hub.Config, server *hub.Server, logger *utils.Logger) *HTTPTransport {
-	return &HTTPTransport{cfg: cfg, server: server, logger: logger}
+	return &HTTPTransport{cfg: cfg, server: server, logger: logger.WithFields(map[string]any{"transport": "http"})}
 }
 
 func (t *HTTPTransport) Start(ctx context.Context) error {
@@ -36,6 +38,10 @@ func (t *HTTPTransport) Start(ctx context.Context) error {
 	mux.HandleFunc("/.well-known/agents", t.handleAgents)
 	mux.HandleFunc("/.well-known/agents/", t.handleAgent)
 	mux.HandleFunc("/stream", t.handleStream)
+	mux.HandleFunc(hub.ArtifactURIPrefix, t.handleArtifact)
+	if t.cfg.Metrics.Enabled {
+		mux.HandleFunc("/metrics", t.handleMetrics)
+	}
 
 	// Register A2A protocol routes
 	baseURL := fmt.Sprintf("http://%s:%d", t.cfg.HTTP.Host, t.cfg.HTTP.Port)
@@ -48,7 +54,8 @@ func (t *HTTPTransport) Start(ctx context.Context) error {
 	}
 
 	addr := fmt.Sprintf("%s:%d", t.cfg.HTTP.Host, t.cfg.HTTP.Port)
-	t.http = &http.Server{Addr: addr, Handler: mux}
+	t.http = &http.Server{Addr: addr, Handler: t.withCORS(t.withAuth(mux))}
+	t.logger.Infof("listening on %s", addr)
 	go func() {
 		<-ctx.Done()
 		ctxShutdown, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -59,6 +66,86 @@ func (t *HTTPTransport) Start(ctx context.Context) error {
 	return t.http.ListenAndServe()
 }
 
+// withCORS adds CORS headers for configured origins and answers preflight requests.
+// It is a no-op unless at least one origin is configured.
+func (t *HTTPTransport) withCORS(next http.Handler) http.Handler {
+	if len(t.cfg.HTTP.CORSOrigins) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		allowed := t.allowedOrigin(origin)
+		if allowed != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allowed)
+			w.Header().Add("Vary", "Origin")
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if allowed == "" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withAuth requires a bearer token on every endpoint except /health (and the
+// well-known card endpoints when cards are public). It is a no-op without a token.
+func (t *HTTPTransport) withAuth(next http.Handler) http.Handler {
+	token := strings.TrimSpace(t.cfg.HTTP.Token)
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !t.requiresAuth(r.URL.Path) || hasBearerToken(r, token) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="agents-hub"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+}
+
+func (t *HTTPTransport) requiresAuth(path string) bool {
+	if path == "/health" {
+		return false
+	}
+	if t.cfg.HTTP.PublicCards && (path == "/.well-known/agent.json" || path == "/.well-known/agents" || strings.HasPrefix(path, "/.well-known/agents/")) {
+		return false
+	}
+	return true
+}
+
+func hasBearerToken(r *http.Request, token string) bool {
+	header := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return false
+	}
+	provided := strings.TrimSpace(header[len(prefix):])
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+func (t *HTTPTransport) allowedOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	for _, candidate := range t.cfg.HTTP.CORSOrigins {
+		if candidate == "*" {
+			return "*"
+		}
+		if strings.EqualFold(strings.TrimRight(candidate, "/"), origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
 func (t *HTTPTransport) handleRPC(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -69,8 +156,17 @@ func (t *HTTPTransport) handleRPC(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	if jsonrpc.IsBatch(body) {
+		payload := t.server.Handler().HandleBatch(r.Context(), body)
+		if payload == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, payload)
+		return
+	}
 	var req jsonrpc.Request
-	if err := json.Unmarshal(body, &req); err != nil {
+	if err := jsonrpc.DecodeRequest(body, &req); err != nil {
 		writeJSON(w, jsonrpc.Response{JSONRPC: "2.0", Error: &jsonrpc.RPCError{Code: jsonrpc.ErrParseError, Message: "Parse error"}})
 		return
 	}
@@ -89,7 +185,7 @@ func (t *HTTPTransport) handleStream(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var req jsonrpc.Request
-	if err := json.Unmarshal(body, &req); err != nil {
+	if err := jsonrpc.DecodeRequest(body, &req); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -110,6 +206,54 @@ func (t *HTTPTransport) handleHealth(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, map[string]string{"status": "ok"})
 }
 
+func (t *HTTPTransport) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := t.server.WriteMetrics(w); err != nil {
+		t.logger.Debugf("failed to write metrics: %v", err)
+	}
+}
+
+// handleArtifact serves a stored artifact file as a download. It is always an
+// attachment and never sniffed, so an agent's HTML or SVG can't run in the hub's origin.
+func (t *HTTPTransport) handleArtifact(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, hub.ArtifactURIPrefix)
+	file, data, err := t.server.Artifact(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	contentType := file.MimeType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", attachmentDisposition(file.Name, id))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_, _ = w.Write(data)
+}
+
+// attachmentDisposition names a download after name, or after id when name is empty
+// or can't be encoded
+func attachmentDisposition(name, id string) string {
+	if name != "" {
+		if disposition := mime.FormatMediaType("attachment", map[string]string{"filename": name}); disposition != "" {
+			return disposition
+		}
+	}
+	if disposition := mime.FormatMediaType("attachment", map[string]string{"filename": id}); disposition != "" {
+		return disposition
+	}
+	return "attachment"
+}
+
 func (t *HTTPTransport) handleHubCard(w http.ResponseWriter, r *http.Request) {
 	baseURL := fmt.Sprintf("http://%s:%d", t.cfg.HTTP.Host, t.cfg.HTTP.Port)
 	writeJSON(w, t.server.HubCard(baseURL))
@@ -0,0 +1,122 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"agents-hub/internal/hub"
+	"agents-hub/internal/types"
+	"agents-hub/internal/utils"
+)
+
+func TestHandleArtifactHeaders(t *testing.T) {
+	cfg := hub.DefaultConfig()
+	cfg.DataDir = t.TempDir()
+	logger := utils.NewLogger("error")
+	server := hub.NewServer(cfg, logger)
+	files := []types.File{
+		{Name: "report.html", MimeType: "text/html"},
+		{},
+		{Name: "résumé.txt", MimeType: "text/plain"},
+	}
+	parts := make([]types.Part, 0, len(files))
+	for i, file := range files {
+		n := strconv.Itoa(i + 1)
+		id := "task-1." + n
+		file.URI = hub.ArtifactURIPrefix + id
+		parts = append(parts, types.Part{Kind: "file", File: &file})
+		path := filepath.Join(cfg.DataDir, "artifacts", "task-1", n)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("<script>alert(1)</script>"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server.Tasks().Create(&types.Task{Kind: "task", ID: "task-1", Artifacts: []types.Artifact{{ArtifactID: "files", Parts: parts}}})
+	transport := NewHTTPTransport(cfg, server, logger)
+
+	tests := []struct {
+		id              string
+		wantType        string
+		wantDisposition string
+	}{
+		{"task-1.1", "text/html", `attachment; filename=report.html`},
+		{"task-1.2", "application/octet-stream", `attachment; filename=task-1.2`},
+		{"task-1.3", "text/plain", `attachment; filename*=utf-8''r%C3%A9sum%C3%A9.txt`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			transport.handleArtifact(rec, httptest.NewRequest(http.MethodGet, hub.ArtifactURIPrefix+tt.id, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d", rec.Code)
+			}
+			header := rec.Header()
+			if got := header.Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if got := header.Get("Content-Disposition"); got != tt.wantDisposition {
+				t.Errorf("Content-Disposition = %q, want %q", got, tt.wantDisposition)
+			}
+			if got := header.Get("X-Content-Type-Options"); got != "nosniff" {
+				t.Errorf("X-Content-Type-Options = %q, want nosniff", got)
+			}
+		})
+	}
+}
+
+func TestWithAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	tests := []struct {
+		name        string
+		path        string
+		auth        string
+		publicCards bool
+		want        int
+	}{
+		{"missing token", "/", "", false, http.StatusUnauthorized},
+		{"wrong token", "/", "Bearer nope", false, http.StatusUnauthorized},
+		{"not a bearer token", "/", "Basic s3cret", false, http.StatusUnauthorized},
+		{"correct token", "/", "Bearer s3cret", false, http.StatusOK},
+		{"scheme ignores case", "/stream", "bearer s3cret", false, http.StatusOK},
+		{"health is open", "/health", "", false, http.StatusOK},
+		{"private hub card", "/.well-known/agent.json", "", false, http.StatusUnauthorized},
+		{"private agent card", "/.well-known/agents/codex", "", false, http.StatusUnauthorized},
+		{"public hub card", "/.well-known/agent.json", "", true, http.StatusOK},
+		{"public agent list", "/.well-known/agents", "", true, http.StatusOK},
+		{"public agent card", "/.well-known/agents/codex", "", true, http.StatusOK},
+		{"public cards keep RPC private", "/", "", true, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := hub.DefaultConfig()
+			cfg.HTTP.Token = "s3cret"
+			cfg.HTTP.PublicCards = tt.publicCards
+			transport := &HTTPTransport{cfg: cfg}
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			transport.withAuth(ok).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d", rec.Code, tt.want)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Fatal("401 without a WWW-Authenticate header")
+			}
+		})
+	}
+
+	// Without a token every request goes through
+	rec := httptest.NewRecorder()
+	(&HTTPTransport{cfg: hub.DefaultConfig()}).withAuth(ok).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d without a configured token, want 200", rec.Code)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
 	"os"
 
@@ -20,7 +22,7 @@ type UnixTransport struct {
 }
 
 func NewUnixTransport(cfg hub.Config, server *hub.Server, logger *utils.Logger) *UnixTransport {
-	return &UnixTransport{cfg: cfg, server: server, logger: logger}
+	return &UnixTransport{cfg: cfg, server: server, logger: logger.WithFields(map[string]any{"transport": "unix"})}
 }
 
 func (t *UnixTransport) Start(ctx context.Context) error {
@@ -30,6 +32,7 @@ func (t *UnixTransport) Start(ctx context.Context) error {
 		return err
 	}
 	t.ln = ln
+	t.logger.Infof("listening on %s", t.cfg.Socket.Path)
 	go func() {
 		<-ctx.Done()
 		_ = ln.Close()
@@ -44,13 +47,27 @@ func (t *UnixTransport) Start(ctx context.Context) error {
 	}
 }
 
+// maxUnixRequestBytes bounds one request line, which is enough for several inline
+// --file attachments (1 MiB each before base64)
+const maxUnixRequestBytes = 32 << 20
+
 func (t *UnixTransport) handleConn(conn net.Conn) {
 	defer conn.Close()
 	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxUnixRequestBytes)
 	for scanner.Scan() {
 		line := scanner.Bytes()
+		if jsonrpc.IsBatch(line) {
+			payload := t.server.Handler().HandleBatch(context.Background(), line)
+			if payload == nil {
+				continue
+			}
+			data, _ := json.Marshal(payload)
+			_, _ = conn.Write(append(data, '\n'))
+			continue
+		}
 		var req jsonrpc.Request
-		if err := json.Unmarshal(line, &req); err != nil {
+		if err := jsonrpc.DecodeRequest(line, &req); err != nil {
 			resp := jsonrpc.Response{JSONRPC: "2.0", Error: &jsonrpc.RPCError{Code: jsonrpc.ErrParseError, Message: "Parse error"}}
 			data, _ := json.Marshal(resp)
 			_, _ = conn.Write(append(data, '\n'))
@@ -60,4 +77,11 @@ func (t *UnixTransport) handleConn(conn net.Conn) {
 		data, _ := json.Marshal(resp)
 		_, _ = conn.Write(append(data, '\n'))
 	}
+	// The rest of an oversized line can't be skipped reliably, so answer and hang up
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		t.logger.Warnf("dropping connection: request larger than %d bytes", maxUnixRequestBytes)
+		resp := jsonrpc.Response{JSONRPC: "2.0", Error: &jsonrpc.RPCError{Code: jsonrpc.ErrInvalidRequest, Message: fmt.Sprintf("request larger than %d bytes", maxUnixRequestBytes)}}
+		data, _ := json.Marshal(resp)
+		_, _ = conn.Write(append(data, '\n'))
+	}
 }
@@ -0,0 +1,105 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"agents-hub/internal/hub"
+	"agents-hub/internal/jsonrpc"
+	"agents-hub/internal/utils"
+)
+
+func startUnixTransport(t *testing.T) string {
+	t.Helper()
+	// Unix socket paths are short, so stay out of the long test temp dir
+	dir, err := os.MkdirTemp("", "unix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	cfg := hub.DefaultConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.Socket.Path = filepath.Join(dir, "hub.sock")
+	logger := utils.NewLogger("error")
+	server := hub.NewServer(cfg, logger)
+	server.RegisterHandlers()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = NewUnixTransport(cfg, server, logger).Start(ctx) }()
+	for range 100 {
+		if _, err := os.Stat(cfg.Socket.Path); err == nil {
+			return cfg.Socket.Path
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("unix transport did not start")
+	return ""
+}
+
+func TestUnixTransportRequestSize(t *testing.T) {
+	path := startUnixTransport(t)
+	tests := []struct {
+		name    string
+		size    int // bytes of padding in the params
+		wantErr bool
+	}{
+		{"small", 10, false},
+		{"over the default scanner limit", 3 << 20, false},
+		{"over the request limit", maxUnixRequestBytes + 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("unix", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+			request := `{"jsonrpc":"2.0","method":"hub/status","params":{"pad":"` + strings.Repeat("x", tt.size) + `"},"id":1}` + "\n"
+			// The hub stops reading an oversized request, so don't wait on the write
+			go func() { _, _ = conn.Write([]byte(request)) }()
+			line, err := bufio.NewReader(conn).ReadBytes('\n')
+			if err != nil {
+				t.Fatalf("no response: %v", err)
+			}
+			var resp jsonrpc.Response
+			if err := json.Unmarshal(line, &resp); err != nil {
+				t.Fatal(err)
+			}
+			if (resp.Error != nil) != tt.wantErr {
+				t.Fatalf("response error = %v, want error %v", resp.Error, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnixTransportBatch(t *testing.T) {
+	conn, err := net.Dial("unix", startUnixTransport(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	batch := `[{"jsonrpc":"2.0","method":"hub/status","id":"a"},{"jsonrpc":"2.0","method":"hub/status"},{"jsonrpc":"2.0","method":"nope","id":7}]` + "\n"
+	if _, err := conn.Write([]byte(batch)); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	var responses []jsonrpc.Response
+	if err := json.Unmarshal(line, &responses); err != nil {
+		t.Fatalf("batch reply %s: %v", line, err)
+	}
+	if len(responses) != 2 || responses[0].ID != "a" || responses[0].Error != nil || responses[1].Error == nil || !jsonrpc.SameID(responses[1].ID, 7) {
+		t.Fatalf("batch reply = %s, want a result for a and an error for 7", line)
+	}
+}
@@ -2,18 +2,27 @@ package tui
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
@@ -23,9 +32,11 @@ import (
 	"github.com/charmbracelet/x/ansi"
 
 	"agents-hub/internal/hub"
+	"agents-hub/internal/taskfmt"
 	"agents-hub/internal/transport"
 	"agents-hub/internal/types"
 	"agents-hub/internal/utils"
+	"agents-hub/internal/version"
 )
 
 const (
@@ -93,6 +104,8 @@ var logoHubLines = []string{
 
 type statusData struct {
 	Version     string `json:"version"`
+	Commit      string `json:"commit"`
+	BuildDate   string `json:"buildDate"`
 	Uptime      int    `json:"uptime"`
 	Total       int    `json:"total"`
 	Healthy     int    `json:"healthy"`
@@ -104,26 +117,36 @@ type statusData struct {
 }
 
 type agentData struct {
-	ID           string            `json:"id"`
-	Name         string            `json:"name"`
-	Card         types.AgentCard   `json:"card"`
-	Health       types.AgentHealth `json:"health"`
-	RegisteredAt string            `json:"registeredAt"`
+	ID             string            `json:"id"`
+	Name           string            `json:"name"`
+	Card           types.AgentCard   `json:"card"`
+	Health         types.AgentHealth `json:"health"`
+	RegisteredAt   string            `json:"registeredAt"`
+	LastWorkingDir string            `json:"lastWorkingDir,omitempty"`
 }
 
 type model struct {
 	cfg          hub.Config
 	logger       *utils.Logger
-	caller       *hub.LocalCaller
-	server       *hub.Server
+	caller       hub.Caller
+	server       *hub.Server // nil when attached to an external hub
+	attached     bool
 	ctx          context.Context
 	cancel       context.CancelFunc
 	sessionStart time.Time
+	serverLogs   <-chan utils.LogEntry
 
 	width     int
 	height    int
 	activeTab int
 
+	refreshInterval    time.Duration
+	refreshPaused      bool
+	refreshWhileTyping bool // otherwise ticks skip the refresh while a message is being typed
+	tickGen            int
+
+	maxConcurrentAgents int // streams a multi-agent send runs at once
+
 	status        statusData
 	agents        []agentData
 	tasks         []types.Task
@@ -133,6 +156,7 @@ type model struct {
 	sendLogSeeded bool
 
 	agentInput             textinput.Model
+	agentDefaulted         bool // the startup agent was checked against the first agent list
 	msgInput               textarea.Model
 	focusIndex             int
 	agentsList             list.Model
@@ -149,6 +173,8 @@ type model struct {
 	commandIndex           int
 	commandResults         []commandSpec
 	spinner                spinner.Model
+	progressBar            progress.Model
+	progressPatterns       map[string]*regexp.Regexp // agent ID -> progress regex
 	refreshing             bool
 	pendingRefresh         int
 	showLogs               bool
@@ -161,8 +187,23 @@ type model struct {
 	taskIndex              int
 	historySel             int
 	detailContent          string
+	detailKey              string         // tab and item shown in the detail viewport
+	detailOffsets          map[string]int // detail scroll position by detailKey
+	agentDescription       string // set by /describe; shown in the Agents detail until the selection moves
+	deadLetterView         string // set by /deadletters; shown in the Tasks detail until the selection moves
+	taskDirView            string // working dir listing when $EDITOR is unset; shown until the selection moves
+	pinnedAgents           []string
+	teePath                string // /send-to target for the next send
+	streamMode             string // "" (auto), "on" or "off"; see /stream
+	notification           string // transient status-line message, e.g. "codex responded"
+	notificationGen        int
+	bell                   bool // ring the terminal bell with notifications (/bell)
+	markdown               bool // render responses as markdown in the History detail pane
+	markdownKey            string
+	markdownOut            string
 	settingsInput          textinput.Model
 	settingsMessage        string
+	settingsSaved          map[int]string // hub value of each Settings text field; see recordSavedSettings
 
 	// Claude settings
 	claudeModelInput   textinput.Model
@@ -176,6 +217,7 @@ type model struct {
 	codexSandboxInput  textinput.Model
 	codexApprovalInput textinput.Model
 	codexSearch        bool
+	codexLastMessage   bool
 
 	// Gemini settings
 	geminiModelInput    textinput.Model
@@ -205,9 +247,15 @@ type model struct {
 	streamBuffer   map[string][]string     // agentID -> buffered output lines
 	focusedAgent   string                  // Which agent has input focus
 	pendingPrompts []string                // Queue of agents waiting for input
+	queuedTasks    []subTask               // Sub-tasks waiting for a free slot or for their agent
+	pendingSends   []pendingSend           // Messages sent while another send was in flight
+	streamStarted  map[string]time.Time    // stream key -> when the agent started working
+	streamProgress map[string]float64      // stream key -> completion fraction parsed from output
+	agentThreads   map[string]agentThread  // agentID -> conversation its follow-ups continue; cleared by /new
 
 	// Session management
 	currentSessionID string
+	sessionStore     *hub.SessionManager
 	sessions         []*hub.Session
 	sessionsList     list.Model
 	sessionIndex     int
@@ -218,11 +266,53 @@ type model struct {
 	agentPickerOptions []string
 }
 
+// mention is one @agent task parsed from a message
+type mention struct {
+	AgentID string
+	Task    string
+}
+
+// subTask is a mention waiting for a free fan-out slot, or for an earlier task to
+// the same agent to finish. key identifies its stream ("claude#2" when an agent is mentioned more than once).
+type subTask struct {
+	key       string
+	agentID   string
+	task      string
+	contextID string
+}
+
+// pendingSend is a message waiting for the current send to finish
+type pendingSend struct {
+	agent   string
+	message string
+}
+
 // AgentStream holds the channels for streaming communication with an agent
 type AgentStream struct {
 	Output chan types.StreamEvent
 	Input  chan string
 	Done   bool
+	tee    *streamTee // set when /send-to asked for output to be saved
+
+	// interactive is set when the agent streams and reads Input; only then may a
+	// prompt event put it in focus mode
+	interactive bool
+	contextID   string // context the send runs in
+}
+
+// agentThread is the context a single-agent follow-up continues
+type agentThread struct {
+	contextID string
+	turns     int // replies received in it
+}
+
+// followUpHistoryLength matches the historyLength the TUI passes to message/send
+const followUpHistoryLength = 10
+
+// streamTee appends a stream's output lines to a file as they arrive
+type streamTee struct {
+	file   *os.File
+	prefix string // "[agent] " when several agents share the file
 }
 
 type sendEntry struct {
@@ -254,15 +344,48 @@ type agentResultMsg struct {
 	err     error
 }
 
-// streamEventMsg wraps a streaming event from an agent
+// streamEventMsg wraps a streaming event from an agent. stream identifies which
+// send produced it so events from a superseded send can be told apart.
 type streamEventMsg struct {
 	agentID string
+	stream  *AgentStream
 	event   types.StreamEvent
+	closed  bool
+}
+
+// notificationExpiredMsg clears the status-line notification it was scheduled for
+type notificationExpiredMsg struct{ gen int }
+
+// notificationDuration is how long "<agent> responded" stays in the status bar
+const notificationDuration = 5 * time.Second
+
+// tickMsg drives auto-refresh. gen lets ticks scheduled before a pause/resume be dropped.
+type tickMsg struct{ gen int }
+
+// agentDescription is the hub/agents/get result, which adds runtime capabilities to the card
+type agentDescription struct {
+	agentData
+	Capabilities types.RuntimeCapabilities `json:"capabilities"`
+}
+
+type describeMsg struct{ agent agentDescription }
+
+type deadLettersMsg struct{ letters []hub.DeadLetter }
+
+type pruneResultMsg struct {
+	Pruned    int    `json:"pruned"`
+	OlderThan string `json:"olderThan"`
+}
+
+type settingsReloadedMsg struct {
+	Path string `json:"path"`
 }
 
-type tickMsg time.Time
+// serverLogMsg carries a line logged by the embedded hub server
+type serverLogMsg struct{ entry utils.LogEntry }
 
 func Run(cfg hub.Config, logger *utils.Logger) error {
+	serverLogs := captureLogs(logger)
 	server := hub.NewServer(cfg, logger)
 	server.RegisterHandlers()
 	if err := server.LoadState(); err != nil {
@@ -273,10 +396,10 @@ func Run(cfg hub.Config, logger *utils.Logger) error {
 	if err := server.WritePid(); err != nil {
 		logger.Warnf("failed to write pid: %v", err)
 	}
-	orchestratorList := server.OrchestratorAgents()
 	server.Registry().StartHealthChecks(30 * time.Second)
 
 	ctx, cancel := context.WithCancel(context.Background())
+	server.StartTaskPruning(ctx, 30*time.Second)
 	if cfg.Socket.Enabled {
 		unixTransport := transport.NewUnixTransport(cfg, server, logger)
 		go func() {
@@ -294,19 +417,109 @@ func Run(cfg hub.Config, logger *utils.Logger) error {
 		}()
 	}
 
-	caller := hub.NewLocalCaller(server.Handler())
+	m := newModel(cfg, logger, hub.NewLocalCaller(server.Handler()), server, server.Sessions(), ctx, cancel, serverLogs)
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	final, runErr := p.Run()
+	if last, ok := final.(model); ok {
+		server.UpdateLastView(strings.ToLower(tabName(last.activeTab)), last.showSendModal)
+	}
+	server.StopTaskQueue()
+	server.Registry().Stop()
+	server.FlushState()
+	server.RemovePid()
+	cancel()
+	return runErr
+}
+
+// RunAttached starts the TUI against a hub that is already running, talking to it
+// over the unix socket instead of starting an embedded server. Sessions stay local;
+// settings belong to the running hub and are read-only here.
+func RunAttached(cfg hub.Config, logger *utils.Logger) error {
+	serverLogs := captureLogs(logger)
+	caller := hub.NewSocketCaller(cfg.Socket.Path)
+	defer caller.Close()
+	if _, err := caller.Call(context.Background(), "hub/status", nil); err != nil {
+		return fmt.Errorf("cannot reach hub at %s: %w", cfg.Socket.Path, err)
+	}
+	if cfg.DataDir == "" {
+		cfg.DataDir = filepath.Join(os.Getenv("HOME"), ".a2a-hub")
+	}
+	sessions := hub.NewSessionManager()
+	sessions.SetDataDir(cfg.DataDir)
+	if err := sessions.Load(); err != nil {
+		logger.Warnf("failed to load sessions: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := newModel(cfg, logger, caller, nil, sessions, ctx, cancel, serverLogs)
+	m.attached = true
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	_, runErr := p.Run()
+	cancel()
+	return runErr
+}
+
+// captureLogs routes log output into the log panel instead of drawing over the alt screen
+func captureLogs(logger *utils.Logger) <-chan utils.LogEntry {
+	ch := make(chan utils.LogEntry, 256)
+	logger.SetOutput(io.Discard)
+	logger.AddSink(func(entry utils.LogEntry) {
+		select {
+		case ch <- entry:
+		default:
+		}
+	})
+	return ch
+}
+
+// newModel builds the TUI model. server is nil when attached to an external hub.
+func newModel(cfg hub.Config, logger *utils.Logger, caller hub.Caller, server *hub.Server, sessions *hub.SessionManager, ctx context.Context, cancel context.CancelFunc, serverLogs <-chan utils.LogEntry) model {
+	var (
+		orchestratorList []string
+		lastAgent        string
+		pinnedAgents     []string
+		claudeSettings   types.ClaudeSettings
+		codexSettings    types.CodexSettings
+		geminiSettings   types.GeminiSettings
+		vibeSettings     types.VibeSettings
+		progressPatterns map[string]string
+	)
+	dataDir := cfg.DataDir
+	if server != nil {
+		dataDir = server.Config().DataDir
+		orchestratorList = server.OrchestratorAgents()
+		lastAgent = server.LastAgent()
+		if lastAgent == "" {
+			lastAgent = defaultAgentID(agentDataFromInfos(server.AgentsList()))
+		}
+		pinnedAgents = server.PinnedAgents()
+		claudeSettings = server.ClaudeSettings()
+		codexSettings = server.CodexSettings()
+		geminiSettings = server.GeminiSettings()
+		vibeSettings = server.VibeSettings()
+		progressPatterns = server.ProgressPatterns()
+	}
+
+	keys, keyWarnings := loadKeyMap(dataDir)
+
+	refreshInterval := cfg.TUI.RefreshInterval
+	if refreshInterval <= 0 {
+		refreshInterval = 5 * time.Second
+	}
+	maxConcurrentAgents := cfg.TUI.MaxConcurrentAgents
+	if maxConcurrentAgents <= 0 {
+		maxConcurrentAgents = 4
+	}
+
 	agentInput := textinput.New()
 	agentInput.Placeholder = "agent id"
-	defaultAgent := server.LastAgent()
-	if defaultAgent == "" {
-		defaultAgent = "orchestrator"
-	}
-	agentInput.SetValue(defaultAgent)
+	agentInput.SetValue(lastAgent)
 	msgInput := textarea.New()
 	msgInput.Placeholder = "message"
 	msgInput.Focus()
 	msgInput.Prompt = "> "
 	msgInput.ShowLineNumbers = false
+	msgInput.MaxHeight = 0 // no line cap, so long pasted code blocks are kept whole
 	commandInput := textinput.New()
 	commandInput.Placeholder = "command"
 	commandInput.Prompt = "/ "
@@ -318,7 +531,6 @@ func Run(cfg hub.Config, logger *utils.Logger) error {
 	settingsInput.SetValue(strings.Join(orchestratorList, ","))
 
 	// Claude settings inputs
-	claudeSettings := server.ClaudeSettings()
 	claudeModelInput := textinput.New()
 	claudeModelInput.Placeholder = "opus, sonnet, haiku (blank for default)"
 	claudeModelInput.SetValue(claudeSettings.DefaultModel)
@@ -330,7 +542,6 @@ func Run(cfg hub.Config, logger *utils.Logger) error {
 	claudeToolsInput.Width = 40
 
 	// Codex settings inputs
-	codexSettings := server.CodexSettings()
 	codexModelInput := textinput.New()
 	codexModelInput.Placeholder = "model (blank for default)"
 	codexModelInput.SetValue(codexSettings.DefaultModel)
@@ -352,7 +563,6 @@ func Run(cfg hub.Config, logger *utils.Logger) error {
 	codexApprovalInput.Width = 40
 
 	// Gemini settings inputs
-	geminiSettings := server.GeminiSettings()
 	geminiModelInput := textinput.New()
 	geminiModelInput.Placeholder = "gemini-1.5-pro, gemini-1.5-flash (blank for default)"
 	geminiModelInput.SetValue(geminiSettings.DefaultModel)
@@ -364,7 +574,6 @@ func Run(cfg hub.Config, logger *utils.Logger) error {
 	geminiApprovalInput.Width = 40
 
 	// Vibe settings inputs
-	vibeSettings := server.VibeSettings()
 	vibeAgentInput := textinput.New()
 	vibeAgentInput.Placeholder = "agent name from ~/.vibe/agents/ (blank for default)"
 	vibeAgentInput.SetValue(vibeSettings.DefaultAgent)
@@ -379,7 +588,7 @@ func Run(cfg hub.Config, logger *utils.Logger) error {
 	sendViewport := viewport.New(0, 0)
 
 	// Create a new session for this TUI instance
-	currentSession, err := server.Sessions().Create()
+	currentSession, err := sessions.Create()
 	currentSessionID := ""
 	if err != nil {
 		logger.Warnf("failed to create session: %v", err)
@@ -395,6 +604,10 @@ func Run(cfg hub.Config, logger *utils.Logger) error {
 		ctx:                 ctx,
 		cancel:              cancel,
 		sessionStart:        time.Now().UTC(),
+		serverLogs:          serverLogs,
+		refreshInterval:     refreshInterval,
+		maxConcurrentAgents: maxConcurrentAgents,
+		refreshWhileTyping:  cfg.TUI.RefreshWhileTyping,
 		activeTab:           tabSend,
 		agentInput:          agentInput,
 		msgInput:            msgInput,
@@ -404,12 +617,17 @@ func Run(cfg hub.Config, logger *utils.Logger) error {
 		tasksList:           tasksList,
 		responsesList:       responsesList,
 		detailViewport:      detailViewport,
-		keys:                defaultKeyMap,
+		detailOffsets:       map[string]int{},
+		agentThreads:        map[string]agentThread{},
+		keys:                keys,
 		help:                help.New(),
 		commandHistory:      []string{},
 		historyIndex:        0,
 		commandIndex:        0,
 		spinner:             spin,
+		progressBar:         progress.New(progress.WithDefaultGradient(), progress.WithWidth(progressBarWidth)),
+		progressPatterns:    compileProgressPatterns(progressPatterns),
+		streamProgress:      make(map[string]float64),
 		showLogs:            false,
 		altScreen:           true,
 		logs:                []logEntry{},
@@ -427,6 +645,7 @@ func Run(cfg hub.Config, logger *utils.Logger) error {
 		codexSandboxInput:   codexSandboxInput,
 		codexApprovalInput:  codexApprovalInput,
 		codexSearch:         codexSettings.EnableSearch,
+		codexLastMessage:    codexSettings.LastMessageOnly,
 		geminiModelInput:    geminiModelInput,
 		geminiApprovalInput: geminiApprovalInput,
 		geminiSandbox:       geminiSettings.DefaultSandbox,
@@ -442,21 +661,24 @@ func Run(cfg hub.Config, logger *utils.Logger) error {
 		streamBuffer:        make(map[string][]string),
 		pendingPrompts:      []string{},
 		currentSessionID:    currentSessionID,
-		sessions:            server.Sessions().List(),
+		sessionStore:        sessions,
+		pinnedAgents:        pinnedAgents,
+		sessions:            sessions.List(),
 		sessionsList:        sessionsList,
 	}
+	for _, warning := range keyWarnings {
+		m.addLog("warn", warning)
+	}
+	if server != nil {
+		m.restoreView(server.LastView())
+		m.recordSavedSettings()
+	}
 	m.updateMessagePrompt()
-
-	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
-	_, runErr := p.Run()
-	server.Registry().Stop()
-	server.RemovePid()
-	cancel()
-	return runErr
+	return m
 }
 
 func (m model) Init() tea.Cmd {
-	return tea.Batch(refreshAllCmd(m.caller), tickCmd(), m.spinner.Tick)
+	return tea.Batch(refreshAllCmd(m.caller), tickCmd(m.refreshInterval, m.tickGen), m.spinner.Tick, listenServerLogs(m.serverLogs))
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -472,13 +694,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case agentsMsg:
 		m.agents = msg.data
 		m.lastUpdated = time.Now()
-		m.agentsList.SetItems(buildAgentItems(m.agents))
+		setListItems(&m.agentsList, buildAgentItems(m.agents, m.pinnedAgents))
+		if !m.agentDefaulted {
+			m.agentDefaulted = true
+			if !m.agentRegistered(m.agentInput.Value()) {
+				m.agentInput.SetValue(defaultAgentID(m.agents))
+			}
+		}
 		m.finishRefresh()
 		m.updateDetailForTab(tabAgents)
 	case tasksMsg:
 		m.tasks = msg.data
 		m.lastUpdated = time.Now()
-		m.tasksList.SetItems(buildTaskItems(m.tasks))
+		setListItems(&m.tasksList, buildTaskItems(m.tasks))
 		m.finishRefresh()
 		m.updateDetailForTab(tabTasks)
 		// Don't auto-load previous logs - sessions handle this now
@@ -504,10 +732,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.sending = false
 		m.appendSendEntry("agent", msg.entry.Agent, msg.entry.Text)
 		m.responses = append([]responseEntry{msg.entry}, m.responses...)
-		m.responsesList.SetItems(buildResponseItems(m.responses))
+		setListItems(&m.responsesList, buildResponseItems(m.responses))
 		m.addLog("info", "response received from "+msg.entry.Agent)
 		m.updateDetailForTab(tabHistory)
-		return m, refreshAllCmd(m.caller)
+		return m, tea.Batch(refreshAllCmd(m.caller), m.notifyResponse(msg.entry.Agent, false))
 	case agentResultMsg:
 		// Handle individual agent result from multi-agent dispatch (non-streaming fallback)
 		if msg.err != nil {
@@ -528,14 +756,40 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.syncSendViewport()
 		return m, nil
 	case streamEventMsg:
+		// Events from a superseded send, or arriving after the stream finished, are
+		// drained without touching the current state so the producer can exit
+		if current, ok := m.streamChannels[msg.agentID]; !ok || current != msg.stream || current.Done {
+			if msg.closed {
+				return m, nil
+			}
+			return m, listenAgentStream(msg.agentID, msg.stream)
+		}
 		// Handle streaming events from agents
 		event := msg.event
+		var notify tea.Cmd
 		switch event.Kind {
 		case "output":
 			m.appendStreamLine(msg.agentID, event.Text)
+			m.trackProgress(msg.agentID, event.Text)
+			m.writeTee(msg.agentID, msg.stream, event.Text)
 			m.syncSendViewport()
 			m.sendViewport.GotoBottom() // Auto-scroll
+		case "tool", "thinking":
+			line := streamEventLine(event)
+			m.appendStreamLine(msg.agentID, line)
+			m.writeTee(msg.agentID, msg.stream, line)
+			m.syncSendViewport()
+			m.sendViewport.GotoBottom()
 		case "prompt":
+			// An agent that can't read input would hold focus forever, so its
+			// prompt is shown as plain output
+			if !msg.stream.interactive {
+				m.appendStreamLine(msg.agentID, event.Text)
+				m.writeTee(msg.agentID, msg.stream, event.Text)
+				m.syncSendViewport()
+				m.sendViewport.GotoBottom()
+				break
+			}
 			// Focus mode: first agent to ask gets focus
 			if m.focusedAgent == "" {
 				m.focusedAgent = msg.agentID
@@ -544,27 +798,35 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.pendingPrompts = append(m.pendingPrompts, msg.agentID)
 			}
 			m.appendStreamLine(msg.agentID, event.Text)
+			m.writeTee(msg.agentID, msg.stream, event.Text)
 			m.updateFocusIndicator()
 			m.syncSendViewport()
 			m.sendViewport.GotoBottom()
 		case "complete":
+			reply := strings.Join(m.streamBuffer[msg.agentID], "\n")
 			m.finishAgentStream(msg.agentID)
-			// If this was focused agent, move to next in queue
-			if m.focusedAgent == msg.agentID && len(m.pendingPrompts) > 0 {
-				m.focusedAgent = m.pendingPrompts[0]
-				m.pendingPrompts = m.pendingPrompts[1:]
-				m.updateFocusIndicator()
-			} else if m.focusedAgent == msg.agentID {
-				m.focusedAgent = ""
-				m.updateFocusIndicator()
-			}
+			m.recordReply(msg.agentID, msg.stream, reply)
+			notify = m.notifyResponse(msg.agentID, false)
 			m.syncSendViewport()
 		case "error":
 			m.appendSendEntry("error", msg.agentID, event.Text)
+			m.writeTee(msg.agentID, msg.stream, "error: "+event.Text)
 			m.finishAgentStream(msg.agentID)
+			notify = m.notifyResponse(msg.agentID, true)
 			m.syncSendViewport()
 		}
-		return m, m.listenAllStreams()
+		var next tea.Cmd
+		if msg.stream.Done {
+			next = m.startQueuedTasks()
+			if !m.sending {
+				next = m.startPendingSend()
+			}
+		}
+		// One listener per stream: only re-arm the stream this event came from
+		if msg.closed {
+			return m, tea.Batch(next, notify)
+		}
+		return m, tea.Batch(next, notify, listenAgentStream(msg.agentID, msg.stream))
 	case refreshStartMsg:
 		m.pendingRefresh += msg.count
 		m.refreshing = m.pendingRefresh > 0
@@ -580,7 +842,65 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 	case tickMsg:
-		return m, tea.Batch(refreshAllCmd(m.caller), tickCmd())
+		// A paused loop simply stops re-arming; resuming starts a new generation
+		if msg.gen != m.tickGen || m.refreshPaused {
+			return m, nil
+		}
+		if !m.refreshWhileTyping && m.composing() {
+			// A refresh re-renders the send log and can scroll it; try again next tick
+			return m, tickCmd(m.refreshInterval, m.tickGen)
+		}
+		return m, tea.Batch(refreshAllCmd(m.caller), tickCmd(m.refreshInterval, m.tickGen))
+	case notificationExpiredMsg:
+		if msg.gen == m.notificationGen {
+			m.notification = ""
+		}
+		return m, nil
+	case describeMsg:
+		m.activeTab = tabAgents
+		m.showSendModal = false
+		m.setSettingsFocus(false)
+		for i, item := range m.agentsList.Items() {
+			if agent, ok := item.(agentItem); ok && agent.data.ID == msg.agent.ID {
+				m.agentsList.Select(i)
+				m.agentIndex = i
+				break
+			}
+		}
+		m.agentDescription = renderAgentDescription(msg.agent)
+		m.setDetailContent("agents:"+msg.agent.ID+":describe", m.agentDescription)
+		return m, nil
+	case editorClosedMsg:
+		if msg.err != nil {
+			m.addLog("error", fmt.Sprintf("editor: %v", msg.err))
+			return m, nil
+		}
+		return m, refreshAllCmd(m.caller)
+	case deadLettersMsg:
+		m.activeTab = tabTasks
+		m.showSendModal = false
+		m.setSettingsFocus(false)
+		m.deadLetterView = renderDeadLetters(msg.letters)
+		m.setDetailContent("tasks:deadletters", m.deadLetterView)
+		return m, nil
+	case settingsReloadedMsg:
+		if m.server != nil {
+			m.syncSettingsInputs()
+		}
+		m.settingsMessage = "Settings reloaded from " + msg.Path
+		m.addLog("info", m.settingsMessage)
+		return m, refreshAllCmd(m.caller)
+	case pruneResultMsg:
+		m.settingsMessage = fmt.Sprintf("Pruned %d tasks older than %s", msg.Pruned, msg.OlderThan)
+		m.addLog("info", m.settingsMessage)
+		return m, refreshAllCmd(m.caller)
+	case serverLogMsg:
+		message := msg.entry.Message
+		if fields := utils.FormatFields(msg.entry.Fields); fields != "" {
+			message += " " + fields
+		}
+		m.addLog(msg.entry.Level, message)
+		return m, listenServerLogs(m.serverLogs)
 	case tea.MouseMsg:
 		// Handle mouse wheel scrolling in viewports
 		if msg.Type == tea.MouseWheelUp || msg.Type == tea.MouseWheelDown {
@@ -622,7 +942,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "enter":
 				if len(m.agentPickerOptions) > 0 {
 					m.agentInput.SetValue(m.agentPickerOptions[m.agentPickerIndex])
-					m.server.UpdateLastAgent(m.agentPickerOptions[m.agentPickerIndex])
+					m.rememberLastAgent(m.agentPickerOptions[m.agentPickerIndex])
 				}
 				m.showAgentPicker = false
 				return m, nil
@@ -690,6 +1010,16 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.syncSendViewport()
 				return m, nil
 			}
+			if msg.Paste {
+				// Bracketed paste: newlines are part of the text, never a send
+				if m.focusIndex == 1 {
+					m.msgInput.InsertString(string(msg.Runes))
+					return m, nil
+				}
+				var cmd tea.Cmd
+				m.agentInput, cmd = m.agentInput.Update(msg)
+				return m, cmd
+			}
 			switch msg.String() {
 			case "ctrl+p":
 				m.commandMode = true
@@ -723,7 +1053,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					text := m.msgInput.Value()
 					if text != "" {
 						if stream, ok := m.streamChannels[m.focusedAgent]; ok && !stream.Done {
-							stream.Input <- text
+							// Never block Update on an agent that is not reading input
+							select {
+							case stream.Input <- text:
+							default:
+								m.errMsg = m.focusedAgent + " is not accepting input yet"
+								return m, nil
+							}
 						}
 						m.appendSendEntry("user-input", m.focusedAgent, text)
 						m.msgInput.SetValue("")
@@ -743,6 +1079,15 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.updateCommandResults()
 					return m, nil
 				}
+			case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+				// Quick-pick a pinned agent, but only before anything has been typed
+				if m.focusIndex == 1 && m.focusedAgent == "" && m.msgInput.Value() == "" {
+					if index := int(msg.String()[0] - '1'); index < len(m.pinnedAgents) {
+						m.agentInput.SetValue(m.pinnedAgents[index])
+						m.rememberLastAgent(m.pinnedAgents[index])
+						return m, nil
+					}
+				}
 			case "shift+enter":
 				m.msgInput.InsertString("\n")
 				return m, nil
@@ -806,7 +1151,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, nil
 				}
 				m.appendCommandHistory(cmdText)
-				return m, m.applyCommand(cmdText)
+				cmd := m.applyCommand(cmdText)
+				// Slash commands such as /claude-model save settings and refill their fields
+				m.recordSavedSettings()
+				return m, cmd
 			case "up":
 				if m.navigateCommandSelection(-1) {
 					return m, nil
@@ -829,7 +1177,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmd := m.updateActiveList(msg)
 			return m, cmd
 		}
-		if m.showHelp && (msg.String() == "?" || escPressed) {
+		if m.showHelp && (key.Matches(msg, m.keys.Help) || escPressed) {
 			m.showHelp = false
 			return m, nil
 		}
@@ -881,6 +1229,15 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.logViewport.GotoBottom()
 				return m, nil
 			}
+			if key.Matches(msg, m.keys.Pause) {
+				return m, m.toggleRefreshPause()
+			}
+			if key.Matches(msg, m.keys.Markdown) {
+				return m, m.toggleMarkdown()
+			}
+			if key.Matches(msg, m.keys.Editor) && m.activeTab == tabTasks {
+				return m, m.openTaskDir()
+			}
 			if key.Matches(msg, m.keys.Quit) {
 				if m.sending || m.refreshing {
 					m.confirmQuit = true
@@ -892,14 +1249,17 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else if msg.String() == "ctrl+c" || msg.String() == "ctrl+q" {
 			return m, tea.Quit
 		}
-		switch msg.String() {
-		case "r":
+		if key.Matches(msg, m.keys.Refresh) {
 			return m, refreshAllCmd(m.caller)
 		}
 	}
 
 	if m.activeTab == tabSettings {
 		if key, ok := msg.(tea.KeyMsg); ok {
+			if m.attached && (key.String() == " " || key.String() == "enter" || key.String() == "ctrl+s") {
+				m.settingsMessage = attachedSettingsNotice
+				return m, nil
+			}
 			switch key.String() {
 			case "tab":
 				// Move to next settings field
@@ -967,18 +1327,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					}
 					return m, nil
 				}
+			case "ctrl+s":
+				m.saveDirtySettings()
+				return m, nil
 			case "enter":
-				switch m.settingsFocusIndex {
-				// ...
-				case settingsFieldGeminiModel:
-					model := strings.TrimSpace(m.geminiModelInput.Value())
-					if err := m.server.UpdateGeminiModel(model); err != nil {
+				if m.settingsFieldInput(m.settingsFocusIndex) != nil {
+					if message, err := m.saveSettingsField(m.settingsFocusIndex); err != nil {
 						m.settingsMessage = "Failed to save: " + err.Error()
-					} else if model == "" {
-						m.settingsMessage = "Gemini model: default"
 					} else {
-						m.settingsMessage = "Gemini model: " + model
+						m.settingsMessage = message
 					}
+					return m, nil
+				}
+				switch m.settingsFocusIndex {
 				case settingsFieldGeminiSandbox:
 					m.geminiSandbox = !m.geminiSandbox
 					if err := m.server.UpdateGeminiSandbox(m.geminiSandbox); err != nil {
@@ -986,28 +1347,6 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					} else {
 						m.settingsMessage = fmt.Sprintf("Gemini sandbox: %t", m.geminiSandbox)
 					}
-				case settingsFieldGeminiApproval:
-					mode := strings.TrimSpace(m.geminiApprovalInput.Value())
-					if mode != "" && mode != "default" && mode != "auto_edit" && mode != "yolo" {
-						m.settingsMessage = "Invalid mode: use default, auto_edit, yolo, or blank"
-						return m, nil
-					}
-					if err := m.server.UpdateGeminiApprovalMode(mode); err != nil {
-						m.settingsMessage = "Failed to save: " + err.Error()
-					} else if mode == "" {
-						m.settingsMessage = "Gemini approval: default"
-					} else {
-						m.settingsMessage = "Gemini approval: " + mode
-					}
-				case settingsFieldVibeAgent:
-					agent := strings.TrimSpace(m.vibeAgentInput.Value())
-					if err := m.server.UpdateVibeAgent(agent); err != nil {
-						m.settingsMessage = "Failed to save: " + err.Error()
-					} else if agent == "" {
-						m.settingsMessage = "Vibe agent: default"
-					} else {
-						m.settingsMessage = "Vibe agent: " + agent
-					}
 				case settingsFieldVibeNonInteractive:
 					m.vibeNonInteractive = !m.vibeNonInteractive
 					if err := m.server.UpdateVibeNonInteractive(m.vibeNonInteractive); err != nil {
@@ -1077,7 +1416,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					}
 				case "enter":
 					if m.focusIndex == 0 {
-						m.server.UpdateLastAgent(m.agentInput.Value())
+						m.rememberLastAgent(m.agentInput.Value())
 						m.focusIndex = 1
 						m.agentInput.Blur()
 						m.msgInput.Focus()
@@ -1106,7 +1445,16 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// Below this size panels and modals overlap, so View shows a notice instead
+const (
+	minTerminalWidth  = 50
+	minTerminalHeight = 21
+)
+
 func (m model) View() string {
+	if m.width > 0 && m.height > 0 && (m.width < minTerminalWidth || m.height < minTerminalHeight) {
+		return viewTooSmall(m.width, m.height)
+	}
 	header := headerStyle.Render("A2A Hub")
 	statusBar := m.renderStatusBar()
 	viewLine := dimStyle.Render("View: " + m.viewName())
@@ -1190,6 +1538,10 @@ func (m *model) applyCommand(input string) tea.Cmd {
 	if command == "q" {
 		command = "quit"
 	}
+	if m.attached && embeddedOnlyCommand(strings.ToLower(command)) {
+		m.errMsg = attachedSettingsNotice
+		return nil
+	}
 	switch strings.ToLower(command) {
 	case "status":
 		m.activeTab = tabStatus
@@ -1220,14 +1572,14 @@ func (m *model) applyCommand(input string) tea.Cmd {
 		m.activeTab = tabSessions
 		m.showSendModal = false
 		m.setSettingsFocus(false)
-		m.sessions = m.server.Sessions().List()
+		m.sessions = m.sessionStore.List()
 		return nil
 	case "load":
 		if len(parts) >= 2 {
 			sessionID := parts[1]
 			// Try to find session by short ID or full ID
 			var targetSession *hub.Session
-			for _, s := range m.server.Sessions().List() {
+			for _, s := range m.sessionStore.List() {
 				if s.ID == sessionID || s.ShortID() == sessionID {
 					targetSession = s
 					break
@@ -1250,7 +1602,7 @@ func (m *model) applyCommand(input string) tea.Cmd {
 		m.activeTab = tabSessions
 		m.showSendModal = false
 		m.setSettingsFocus(false)
-		m.sessions = m.server.Sessions().List()
+		m.sessions = m.sessionStore.List()
 		return nil
 	case "settings":
 		m.activeTab = tabSettings
@@ -1269,7 +1621,7 @@ func (m *model) applyCommand(input string) tea.Cmd {
 			agent := parts[1]
 			message := strings.Join(parts[2:], " ")
 			m.agentInput.SetValue(agent)
-			m.server.UpdateLastAgent(agent)
+			m.rememberLastAgent(agent)
 			return m.startSend(agent, message)
 		}
 		return nil
@@ -1283,7 +1635,7 @@ func (m *model) applyCommand(input string) tea.Cmd {
 		m.syncSendViewport()
 		if len(parts) >= 2 {
 			m.agentInput.SetValue(parts[1])
-			m.server.UpdateLastAgent(parts[1])
+			m.rememberLastAgent(parts[1])
 		}
 		return nil
 	case "refresh":
@@ -1346,116 +1698,635 @@ func (m *model) applyCommand(input string) tea.Cmd {
 			m.settingsMessage = fmt.Sprintf("Claude continue mode: %t", m.claudeContinue)
 		}
 		return nil
-	case "codex-model":
-		if len(parts) >= 2 {
-			model := strings.TrimSpace(strings.Join(parts[1:], " "))
-			if err := m.server.UpdateCodexModel(model); err != nil {
-				m.errMsg = "Failed to save: " + err.Error()
-			} else if model == "" {
-				m.settingsMessage = "Codex model: default"
-			} else {
-				m.settingsMessage = "Codex model: " + model
-			}
-			m.codexModelInput.SetValue(model)
+	case "pin", "unpin":
+		unpin := strings.EqualFold(command, "unpin")
+		if len(parts) < 2 {
+			m.errMsg = "Usage: /" + strings.ToLower(command) + " <agent>"
+			return nil
+		}
+		candidates := m.getAgentIDs()
+		if unpin {
+			candidates = m.pinnedAgents
+		}
+		agentID, err := hub.MatchAgentID(parts[1], candidates)
+		if err != nil {
+			m.errMsg = err.Error()
+			return nil
+		}
+		if unpin {
+			err = m.server.UnpinAgent(agentID)
 		} else {
-			m.errMsg = "Usage: /codex-model <model>"
+			err = m.server.PinAgent(agentID)
 		}
-		return nil
-	case "codex-profile":
-		if len(parts) >= 2 {
-			profile := strings.TrimSpace(strings.Join(parts[1:], " "))
-			if err := m.server.UpdateCodexProfile(profile); err != nil {
-				m.errMsg = "Failed to save: " + err.Error()
-			} else if profile == "" {
-				m.settingsMessage = "Codex profile: default"
-			} else {
-				m.settingsMessage = "Codex profile: " + profile
-			}
-			m.codexProfileInput.SetValue(profile)
+		if err != nil {
+			m.errMsg = "Failed to save: " + err.Error()
+			return nil
+		}
+		m.pinnedAgents = m.server.PinnedAgents()
+		setListItems(&m.agentsList, buildAgentItems(m.agents, m.pinnedAgents))
+		m.updateDetailForTab(tabAgents)
+		if len(m.pinnedAgents) == 0 {
+			m.settingsMessage = "Pinned agents: none"
 		} else {
-			m.errMsg = "Usage: /codex-profile <profile>"
+			m.settingsMessage = "Pinned agents: " + strings.Join(m.pinnedAgents, ", ")
 		}
 		return nil
-	case "codex-sandbox":
-		if len(parts) >= 2 {
-			mode := strings.TrimSpace(strings.Join(parts[1:], " "))
-			if mode != "" && mode != "read-only" && mode != "workspace-write" && mode != "danger-full-access" {
-				m.errMsg = "Invalid sandbox. Use: read-only, workspace-write, danger-full-access, or blank"
+	case "max-tokens":
+		if len(parts) < 3 {
+			m.errMsg = "Usage: /max-tokens <agent> <n|off>"
+			return nil
+		}
+		agentID, err := hub.MatchAgentID(parts[1], m.getAgentIDs())
+		if err != nil {
+			m.errMsg = err.Error()
+			return nil
+		}
+		limit := 0
+		if !strings.EqualFold(parts[2], "off") {
+			limit, err = strconv.Atoi(parts[2])
+			if err != nil || limit <= 0 {
+				m.errMsg = "Max tokens must be a positive integer (or off)"
 				return nil
 			}
-			if err := m.server.UpdateCodexSandbox(mode); err != nil {
-				m.errMsg = "Failed to save: " + err.Error()
-			} else if mode == "" {
-				m.settingsMessage = "Codex sandbox: default"
-			} else {
-				m.settingsMessage = "Codex sandbox: " + mode
-			}
-			m.codexSandboxInput.SetValue(mode)
+		}
+		if err := m.server.UpdateMaxOutputTokens(agentID, limit); err != nil {
+			m.errMsg = "Failed to save: " + err.Error()
+		} else if limit == 0 {
+			m.settingsMessage = "Max tokens for " + agentID + ": unlimited"
 		} else {
-			m.errMsg = "Usage: /codex-sandbox <read-only|workspace-write|danger-full-access>"
+			m.settingsMessage = fmt.Sprintf("Max tokens for %s: %d", agentID, limit)
 		}
 		return nil
-	case "codex-approval":
-		if len(parts) >= 2 {
-			policy := strings.TrimSpace(strings.Join(parts[1:], " "))
-			if policy != "" && policy != "untrusted" && policy != "on-failure" && policy != "on-request" && policy != "never" {
-				m.errMsg = "Invalid approval. Use: untrusted, on-failure, on-request, never, or blank"
+	case "format":
+		if len(parts) < 2 {
+			m.errMsg = "Usage: /format <json|pretty|off>"
+			return nil
+		}
+		format := strings.ToLower(parts[1])
+		if format == "off" {
+			format = ""
+		}
+		if err := m.server.UpdateOutputFormat(format); err != nil {
+			m.errMsg = "Failed to save: " + err.Error()
+		} else if format == "" {
+			m.settingsMessage = "CLI output format: pretty (default)"
+		} else {
+			m.settingsMessage = "CLI output format: " + format
+		}
+		return nil
+	case "priority":
+		if len(parts) < 3 {
+			m.errMsg = "Usage: /priority <agent> <n|off>"
+			return nil
+		}
+		agentID, err := hub.MatchAgentID(parts[1], m.getAgentIDs())
+		if err != nil {
+			m.errMsg = err.Error()
+			return nil
+		}
+		priority := 0
+		if !strings.EqualFold(parts[2], "off") {
+			priority, err = strconv.Atoi(parts[2])
+			if err != nil || priority <= 0 {
+				m.errMsg = "Priority must be a positive integer (or off)"
 				return nil
 			}
-			if err := m.server.UpdateCodexApprovalPolicy(policy); err != nil {
-				m.errMsg = "Failed to save: " + err.Error()
-			} else if policy == "" {
-				m.settingsMessage = "Codex approval: default"
-			} else {
-				m.settingsMessage = "Codex approval: " + policy
-			}
-			m.codexApprovalInput.SetValue(policy)
+		}
+		if err := m.server.UpdateAgentPriority(agentID, priority); err != nil {
+			m.errMsg = "Failed to save: " + err.Error()
+		} else if priority == 0 {
+			m.settingsMessage = "Routing priority for " + agentID + ": none"
 		} else {
-			m.errMsg = "Usage: /codex-approval <untrusted|on-failure|on-request|never>"
+			m.settingsMessage = fmt.Sprintf("Routing priority for %s: %d", agentID, priority)
 		}
 		return nil
-	case "codex-search":
-		m.codexSearch = !m.codexSearch
-		if err := m.server.UpdateCodexSearch(m.codexSearch); err != nil {
+	case "orch-max-targets":
+		if len(parts) < 2 {
+			m.errMsg = fmt.Sprintf("Usage: /orch-max-targets <1-%d|default>", hub.MaxRoutingTargetsLimit)
+			return nil
+		}
+		limit := 0
+		if !strings.EqualFold(parts[1], "default") {
+			var err error
+			limit, err = strconv.Atoi(parts[1])
+			if err != nil || limit < 1 || limit > hub.MaxRoutingTargetsLimit {
+				m.errMsg = fmt.Sprintf("Max routing targets must be between 1 and %d (or default)", hub.MaxRoutingTargetsLimit)
+				return nil
+			}
+		}
+		if err := m.server.UpdateMaxRoutingTargets(limit); err != nil {
 			m.errMsg = "Failed to save: " + err.Error()
 		} else {
-			m.settingsMessage = fmt.Sprintf("Codex search: %t", m.codexSearch)
+			m.settingsMessage = fmt.Sprintf("Orchestrator routes each request to at most %d agent(s)", m.server.MaxRoutingTargets())
 		}
 		return nil
-	case "gemini-model":
-		if len(parts) >= 2 {
-			model := strings.TrimSpace(strings.Join(parts[1:], " "))
-			if err := m.server.UpdateGeminiModel(model); err != nil {
-				m.errMsg = "Failed to save: " + err.Error()
-			} else if model == "" {
-				m.settingsMessage = "Gemini model: default"
-			} else {
-				m.settingsMessage = "Gemini model: " + model
-			}
-			m.geminiModelInput.SetValue(model)
+	case "orch-route-unhealthy":
+		if len(parts) < 2 || (!strings.EqualFold(parts[1], "on") && !strings.EqualFold(parts[1], "off")) {
+			m.errMsg = "Usage: /orch-route-unhealthy <on|off>"
+			return nil
+		}
+		enabled := strings.EqualFold(parts[1], "on")
+		if err := m.server.UpdateRouteUnhealthy(enabled); err != nil {
+			m.errMsg = "Failed to save: " + err.Error()
+		} else if enabled {
+			m.settingsMessage = "Orchestrator routes to unhealthy agents too"
 		} else {
-			m.errMsg = "Usage: /gemini-model <model>"
+			m.settingsMessage = "Orchestrator skips unhealthy agents"
 		}
 		return nil
-	case "gemini-resume":
-		if len(parts) >= 2 {
-			sessionID := strings.TrimSpace(parts[1])
-			if err := m.server.UpdateGeminiResume(sessionID); err != nil {
-				m.errMsg = "Failed to save: " + err.Error()
-			} else {
-				m.settingsMessage = "Gemini session resumed: " + sessionID
-			}
+	case "orch-strict-delegates":
+		if len(parts) < 2 || (!strings.EqualFold(parts[1], "on") && !strings.EqualFold(parts[1], "off")) {
+			m.errMsg = "Usage: /orch-strict-delegates <on|off>"
+			return nil
+		}
+		strict := strings.EqualFold(parts[1], "on")
+		if err := m.server.UpdateStrictDelegates(strict); err != nil {
+			m.errMsg = "Failed to save: " + err.Error()
+		} else if strict {
+			m.settingsMessage = "LLM orchestrator routes only to its delegates"
 		} else {
-			m.errMsg = "Usage: /gemini-resume <id>"
+			m.settingsMessage = "LLM orchestrator may route to any registered agent a router picks"
 		}
 		return nil
-	default:
-		m.errMsg = fmt.Sprintf("unknown command: %s", input)
+	case "probe":
+		if len(parts) < 3 || (!strings.EqualFold(parts[2], "on") && !strings.EqualFold(parts[2], "off")) {
+			m.errMsg = "Usage: /probe <agent> <on|off>"
+			return nil
+		}
+		agentID, err := hub.MatchAgentID(parts[1], m.getAgentIDs())
+		if err != nil {
+			m.errMsg = err.Error()
+			return nil
+		}
+		enabled := strings.EqualFold(parts[2], "on")
+		if err := m.server.UpdateHealthProbe(agentID, enabled); err != nil {
+			m.errMsg = "Failed to save: " + err.Error()
+		} else if enabled {
+			m.settingsMessage = "Health probe for " + agentID + ": on (applies from the next health check)"
+		} else {
+			m.settingsMessage = "Health probe for " + agentID + ": off"
+		}
+		return nil
+	case "jsonstream":
+		if len(parts) < 3 || (!strings.EqualFold(parts[2], "on") && !strings.EqualFold(parts[2], "off")) {
+			m.errMsg = "Usage: /jsonstream <agent> <on|off>"
+			return nil
+		}
+		agentID, err := hub.MatchAgentID(parts[1], m.getAgentIDs())
+		if err != nil {
+			m.errMsg = err.Error()
+			return nil
+		}
+		enabled := strings.EqualFold(parts[2], "on")
+		if err := m.server.UpdateJSONStream(agentID, enabled); err != nil {
+			m.errMsg = "Failed to save: " + err.Error()
+		} else if enabled {
+			m.settingsMessage = "JSON stream for " + agentID + ": on (applies to the next streaming send)"
+		} else {
+			m.settingsMessage = "JSON stream for " + agentID + ": off"
+		}
+		return nil
+	case "capture":
+		if len(parts) < 3 || (!strings.EqualFold(parts[2], "on") && !strings.EqualFold(parts[2], "off")) {
+			m.errMsg = "Usage: /capture <agent> <on|off>"
+			return nil
+		}
+		agentID, err := hub.MatchAgentID(parts[1], m.getAgentIDs())
+		if err != nil {
+			m.errMsg = err.Error()
+			return nil
+		}
+		enabled := strings.EqualFold(parts[2], "on")
+		if err := m.server.UpdateCaptureChanges(agentID, enabled); err != nil {
+			m.errMsg = "Failed to save: " + err.Error()
+		} else if enabled {
+			m.settingsMessage = "Changed-file capture for " + agentID + ": on"
+		} else {
+			m.settingsMessage = "Changed-file capture for " + agentID + ": off"
+		}
+		return nil
+	case "allowed-root":
+		if len(parts) < 3 {
+			m.errMsg = "Usage: /allowed-root <agent> <dir|off>"
+			return nil
+		}
+		agentID, err := hub.MatchAgentID(parts[1], m.getAgentIDs())
+		if err != nil {
+			m.errMsg = err.Error()
+			return nil
+		}
+		root := strings.TrimSpace(strings.Join(parts[2:], " "))
+		if strings.EqualFold(root, "off") {
+			root = ""
+		} else if home, err := os.UserHomeDir(); err == nil && (root == "~" || strings.HasPrefix(root, "~/")) {
+			root = filepath.Join(home, strings.TrimPrefix(root, "~"))
+		}
+		if err := m.server.UpdateAllowedRoot(agentID, root); err != nil {
+			m.errMsg = "Failed to save: " + err.Error()
+		} else if root == "" {
+			m.settingsMessage = "Allowed root for " + agentID + ": off"
+		} else {
+			m.settingsMessage = "Allowed root for " + agentID + ": " + m.server.AllowedRoots()[agentID]
+		}
+		return nil
+	case "progress":
+		if len(parts) < 3 {
+			m.errMsg = "Usage: /progress <agent> <regex|off>"
+			return nil
+		}
+		agentID, err := hub.MatchAgentID(parts[1], m.getAgentIDs())
+		if err != nil {
+			m.errMsg = err.Error()
+			return nil
+		}
+		// Take the regex verbatim so its backslashes survive argument parsing
+		rest := strings.TrimSpace(strings.TrimPrefix(input, parts[0]))
+		_, pattern, _ := strings.Cut(rest, " ")
+		pattern = strings.TrimSpace(pattern)
+		if strings.EqualFold(pattern, "off") {
+			pattern = ""
+		}
+		if err := m.server.UpdateProgressPattern(agentID, pattern); err != nil {
+			m.errMsg = "Failed to save: " + err.Error()
+			return nil
+		}
+		m.progressPatterns = compileProgressPatterns(m.server.ProgressPatterns())
+		if pattern == "" {
+			m.settingsMessage = "Progress pattern for " + agentID + ": off"
+		} else {
+			m.settingsMessage = "Progress pattern for " + agentID + ": " + pattern
+		}
+		return nil
+	case "env":
+		if len(parts) < 3 {
+			m.errMsg = "Usage: /env <agent> KEY=VALUE (KEY= removes it)"
+			return nil
+		}
+		agentID, err := hub.MatchAgentID(parts[1], m.getAgentIDs())
+		if err != nil {
+			m.errMsg = err.Error()
+			return nil
+		}
+		assignment := strings.Join(parts[2:], " ")
+		key, value, _ := strings.Cut(assignment, "=")
+		if err := m.server.UpdateAgentEnv(agentID, assignment); err != nil {
+			m.errMsg = "Failed to save: " + err.Error()
+		} else if value == "" {
+			m.settingsMessage = fmt.Sprintf("Env for %s: %s removed", agentID, key)
+		} else {
+			m.settingsMessage = fmt.Sprintf("Env for %s: %s=%s", agentID, key, maskEnvValue(key, value))
+		}
+		return nil
+	case "codex-model":
+		if len(parts) >= 2 {
+			model := strings.TrimSpace(strings.Join(parts[1:], " "))
+			if err := m.server.UpdateCodexModel(model); err != nil {
+				m.errMsg = "Failed to save: " + err.Error()
+			} else if model == "" {
+				m.settingsMessage = "Codex model: default"
+			} else {
+				m.settingsMessage = "Codex model: " + model
+			}
+			m.codexModelInput.SetValue(model)
+		} else {
+			m.errMsg = "Usage: /codex-model <model>"
+		}
+		return nil
+	case "codex-profile":
+		if len(parts) >= 2 {
+			profile := strings.TrimSpace(strings.Join(parts[1:], " "))
+			if err := m.server.UpdateCodexProfile(profile); err != nil {
+				m.errMsg = "Failed to save: " + err.Error()
+			} else if profile == "" {
+				m.settingsMessage = "Codex profile: default"
+			} else {
+				m.settingsMessage = "Codex profile: " + profile
+			}
+			m.codexProfileInput.SetValue(profile)
+		} else {
+			m.errMsg = "Usage: /codex-profile <profile>"
+		}
+		return nil
+	case "codex-sandbox":
+		if len(parts) >= 2 {
+			mode := strings.TrimSpace(strings.Join(parts[1:], " "))
+			if err := checkCodexSandbox(mode); err != nil {
+				m.errMsg = "Failed to save: " + err.Error()
+				return nil
+			}
+			if err := m.server.UpdateCodexSandbox(mode); err != nil {
+				m.errMsg = "Failed to save: " + err.Error()
+			} else if mode == "" {
+				m.settingsMessage = "Codex sandbox: default"
+			} else {
+				m.settingsMessage = "Codex sandbox: " + mode
+			}
+			m.codexSandboxInput.SetValue(m.server.CodexSettings().DefaultSandbox)
+		} else {
+			m.errMsg = "Usage: /codex-sandbox <read-only|workspace-write|danger-full-access>"
+		}
+		return nil
+	case "codex-approval":
+		if len(parts) >= 2 {
+			policy := strings.TrimSpace(strings.Join(parts[1:], " "))
+			if err := checkCodexApproval(policy); err != nil {
+				m.errMsg = "Failed to save: " + err.Error()
+				return nil
+			}
+			if err := m.server.UpdateCodexApprovalPolicy(policy); err != nil {
+				m.errMsg = "Failed to save: " + err.Error()
+			} else if policy == "" {
+				m.settingsMessage = "Codex approval: default"
+			} else {
+				m.settingsMessage = "Codex approval: " + policy
+			}
+			m.codexApprovalInput.SetValue(m.server.CodexSettings().DefaultApprovalPolicy)
+		} else {
+			m.errMsg = "Usage: /codex-approval <untrusted|on-failure|on-request|never>"
+		}
+		return nil
+	case "codex-search":
+		m.codexSearch = !m.codexSearch
+		if err := m.server.UpdateCodexSearch(m.codexSearch); err != nil {
+			m.errMsg = "Failed to save: " + err.Error()
+		} else {
+			m.settingsMessage = fmt.Sprintf("Codex search: %t", m.codexSearch)
+		}
+		return nil
+	case "codex-last-message":
+		m.codexLastMessage = !m.codexLastMessage
+		if err := m.server.UpdateCodexLastMessage(m.codexLastMessage); err != nil {
+			m.errMsg = "Failed to save: " + err.Error()
+		} else {
+			m.settingsMessage = fmt.Sprintf("Codex last message only: %t", m.codexLastMessage)
+		}
+		return nil
+	case "gemini-model":
+		if len(parts) >= 2 {
+			model := strings.TrimSpace(strings.Join(parts[1:], " "))
+			if err := m.server.UpdateGeminiModel(model); err != nil {
+				m.errMsg = "Failed to save: " + err.Error()
+			} else if model == "" {
+				m.settingsMessage = "Gemini model: default"
+			} else {
+				m.settingsMessage = "Gemini model: " + model
+			}
+			m.geminiModelInput.SetValue(model)
+		} else {
+			m.errMsg = "Usage: /gemini-model <model>"
+		}
+		return nil
+	case "gemini-resume":
+		if len(parts) >= 2 {
+			sessionID := strings.TrimSpace(parts[1])
+			if err := m.server.UpdateGeminiResume(sessionID); err != nil {
+				m.errMsg = "Failed to save: " + err.Error()
+			} else {
+				m.settingsMessage = "Gemini session resumed: " + sessionID
+			}
+		} else {
+			m.errMsg = "Usage: /gemini-resume <id>"
+		}
+		return nil
+	case "pause":
+		return m.toggleRefreshPause()
+	case "cli":
+		command, err := m.lastSendCLI()
+		if err != nil {
+			m.errMsg = err.Error()
+			return nil
+		}
+		// Shown in the send log only; not saved to the session
+		m.sendLog = append(m.sendLog, sendEntry{Role: "cli", Text: command, Timestamp: time.Now().UTC().Format(time.RFC3339)})
+		m.activeTab = tabSend
+		m.syncSendViewport()
+		copyToClipboard(command)
+		return m.flash("CLI command copied to clipboard")
+	case "markdown":
+		return m.toggleMarkdown()
+	case "bell":
+		m.bell = !m.bell
+		m.settingsMessage = fmt.Sprintf("Bell on agent responses: %t", m.bell)
+		return nil
+	case "stream":
+		if len(parts) >= 2 {
+			mode := strings.ToLower(parts[1])
+			switch mode {
+			case "auto":
+				m.streamMode = ""
+			case "on", "off":
+				m.streamMode = mode
+			default:
+				m.errMsg = "Usage: /stream <auto|on|off>"
+				return nil
+			}
+		}
+		switch m.streamMode {
+		case "on":
+			m.settingsMessage = "Streaming: on (agents that can't stream still return one result)"
+		case "off":
+			m.settingsMessage = "Streaming: off (one clean result per send)"
+		default:
+			m.settingsMessage = "Streaming: auto"
+		}
+		return nil
+	case "send-to":
+		if len(parts) < 2 {
+			m.teePath = ""
+			m.settingsMessage = "Send output will not be saved"
+			return nil
+		}
+		path := parts[1]
+		if home, err := os.UserHomeDir(); err == nil && (path == "~" || strings.HasPrefix(path, "~/")) {
+			path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		m.teePath = path
+		m.settingsMessage = "Next send output will be appended to " + path
+		m.addLog("info", m.settingsMessage)
+		return nil
+	case "describe":
+		if len(parts) < 2 {
+			m.errMsg = "Usage: /describe <agent>"
+			return nil
+		}
+		agentID, err := hub.MatchAgentID(parts[1], m.getAgentIDs())
+		if err != nil {
+			m.errMsg = err.Error()
+			return nil
+		}
+		m.errMsg = ""
+		return describeAgentCmd(m.caller, agentID)
+	case "prune":
+		olderThan := ""
+		if len(parts) >= 2 {
+			parsed, err := time.ParseDuration(parts[1])
+			if err != nil || parsed <= 0 {
+				m.errMsg = "Invalid duration. Use e.g. 24h or 30m"
+				return nil
+			}
+			olderThan = parsed.String()
+		}
+		return pruneTasksCmd(m.caller, olderThan)
+	case "deadletters":
+		m.errMsg = ""
+		return deadLettersCmd(m.caller)
+	case "reload":
+		m.errMsg = ""
+		return reloadSettingsCmd(m.caller)
+	case "new":
+		agentID := strings.TrimSpace(m.agentInput.Value())
+		if len(parts) >= 2 {
+			matched, err := hub.MatchAgentID(parts[1], m.getAgentIDs())
+			if err != nil {
+				m.errMsg = err.Error()
+				return nil
+			}
+			agentID = matched
+		}
+		if agentID == "" {
+			m.errMsg = "Usage: /new [agent]"
+			return nil
+		}
+		m.errMsg = ""
+		m.agentThreads[agentID] = agentThread{contextID: utils.NewID("ctx")}
+		return m.flash("next message to " + agentID + " starts a new conversation")
+	case "replay":
+		if len(parts) < 2 {
+			m.errMsg = "Usage: /replay <agent>"
+			return nil
+		}
+		item, ok := m.responsesList.SelectedItem().(responseItem)
+		if !ok || item.data.ContextID == "" {
+			m.errMsg = "Select a response in the History tab to replay its context"
+			return nil
+		}
+		agentID, err := hub.MatchAgentID(parts[1], m.getAgentIDs())
+		if err != nil {
+			m.errMsg = err.Error()
+			return nil
+		}
+		m.errMsg = ""
+		m.sending = true
+		return tea.Batch(replayCmd(m.caller, item.data.ContextID, agentID), m.flash("replaying "+item.data.ContextID+" to "+agentID))
+	case "remote-auth":
+		if len(parts) < 2 {
+			m.errMsg = "Usage: /remote-auth <alias> <token>"
+			return nil
+		}
+		alias := parts[1]
+		token := ""
+		if len(parts) >= 3 {
+			token = parts[2]
+		}
+		if err := m.server.UpdateRemoteAuth(alias, token); err != nil {
+			m.errMsg = "Failed to save: " + err.Error()
+		} else if token == "" {
+			m.settingsMessage = "Remote auth cleared for " + alias
+		} else {
+			m.settingsMessage = "Remote auth token stored for " + alias
+		}
+		return nil
+	default:
+		m.errMsg = fmt.Sprintf("unknown command: %s", input)
 		m.addLog("warn", m.errMsg)
 		return nil
 	}
 }
 
+const attachedSettingsNotice = "Settings belong to the running hub; restart it without --attach to change them"
+
+// embeddedOnlyCommand reports whether a command needs the embedded server (settings changes)
+func embeddedOnlyCommand(command string) bool {
+	switch {
+	case strings.HasPrefix(command, "claude-"), strings.HasPrefix(command, "codex-"), strings.HasPrefix(command, "gemini-"):
+		return true
+	case command == "remote-auth", command == "max-tokens", command == "priority", command == "orch-max-targets", command == "orch-route-unhealthy", command == "orch-strict-delegates", command == "format", command == "probe", command == "jsonstream", command == "capture", command == "allowed-root", command == "progress", command == "env", command == "pin", command == "unpin":
+		return true
+	}
+	return false
+}
+
+// setListItems replaces a list's items and keeps the cursor on the item it was on,
+// matched by itemKey, so background refreshes don't move it. When that item is gone
+// the cursor keeps its position, moved up if the list got shorter.
+func setListItems(l *list.Model, items []list.Item) {
+	index := l.Index()
+	selected := ""
+	if item := l.SelectedItem(); item != nil {
+		selected = itemKey(item)
+	}
+	l.SetItems(items)
+	if l.FilterState() != list.Unfiltered || len(items) == 0 {
+		return
+	}
+	for i, item := range items {
+		if selected != "" && itemKey(item) == selected {
+			l.Select(i)
+			return
+		}
+	}
+	l.Select(min(index, len(items)-1))
+}
+
+// composing reports whether a message is being typed in the Send tab or modal
+func (m model) composing() bool {
+	return (m.showSendModal || m.activeTab == tabSend) && m.msgInput.Focused() && strings.TrimSpace(m.msgInput.Value()) != ""
+}
+
+// toggleRefreshPause stops or resumes auto-refresh; manual refresh keeps working while paused
+func (m *model) toggleRefreshPause() tea.Cmd {
+	m.refreshPaused = !m.refreshPaused
+	m.tickGen++
+	if m.refreshPaused {
+		m.settingsMessage = "Auto-refresh paused"
+		m.addLog("info", m.settingsMessage)
+		return nil
+	}
+	m.settingsMessage = "Auto-refresh resumed"
+	m.addLog("info", m.settingsMessage)
+	return tea.Batch(refreshAllCmd(m.caller), tickCmd(m.refreshInterval, m.tickGen))
+}
+
+// syncSettingsInputs refills the Settings tab and the settings the TUI keeps a copy
+// of from the hub, after they were reloaded from disk
+func (m *model) syncSettingsInputs() {
+	claude := m.server.ClaudeSettings()
+	codex := m.server.CodexSettings()
+	gemini := m.server.GeminiSettings()
+	vibe := m.server.VibeSettings()
+	m.settingsInput.SetValue(strings.Join(m.server.OrchestratorAgents(), ","))
+	m.claudeModelInput.SetValue(claude.DefaultModel)
+	m.claudeToolsInput.SetValue(claude.DefaultToolProfile)
+	m.claudeContinue = claude.EnableContinue
+	m.codexModelInput.SetValue(codex.DefaultModel)
+	m.codexProfileInput.SetValue(codex.DefaultProfile)
+	m.codexSandboxInput.SetValue(codex.DefaultSandbox)
+	m.codexApprovalInput.SetValue(codex.DefaultApprovalPolicy)
+	m.codexSearch = codex.EnableSearch
+	m.codexLastMessage = codex.LastMessageOnly
+	m.geminiModelInput.SetValue(gemini.DefaultModel)
+	m.geminiApprovalInput.SetValue(gemini.DefaultApprovalMode)
+	m.geminiSandbox = gemini.DefaultSandbox
+	m.vibeAgentInput.SetValue(vibe.DefaultAgent)
+	m.vibeNonInteractive = vibe.NonInteractive
+	m.vibeAutoApprove = vibe.AutoApprove
+	m.vibeIncludeHistory = vibe.IncludeHistory
+	m.pinnedAgents = m.server.PinnedAgents()
+	m.progressPatterns = compileProgressPatterns(m.server.ProgressPatterns())
+	m.recordSavedSettings()
+}
+
+// rememberLastAgent persists the last used agent; attached TUIs leave hub settings alone
+func (m *model) rememberLastAgent(agentID string) {
+	if m.server == nil {
+		return
+	}
+	m.server.UpdateLastAgent(agentID)
+}
+
 func (m model) renderCommandPalette() string {
 	lines := []string{
 		m.commandInput.View(),
@@ -1563,7 +2434,33 @@ var commandCatalog = []commandSpec{
 	{Name: "settings", Usage: "/settings", Description: "show runtime settings"},
 	{Name: "send", Usage: "/send <agent> <msg>", Description: "send a message"},
 	{Name: "agent", Usage: "/agent <id>", Description: "set agent in Send tab"},
+	{Name: "cli", Usage: "/cli", Description: "show and copy the agents-hub send command for the last send"},
+	{Name: "markdown", Usage: "/markdown", Description: "toggle markdown rendering of responses"},
+	{Name: "bell", Usage: "/bell", Description: "toggle the terminal bell when an agent responds off-tab"},
+	{Name: "stream", Usage: "/stream <auto|on|off>", Description: "stream agent output or wait for one result"},
+	{Name: "send-to", Usage: "/send-to [path]", Description: "save the next send's streamed output to a file"},
+	{Name: "describe", Usage: "/describe <agent>", Description: "show an agent's skills and capabilities"},
+	{Name: "pin", Usage: "/pin <agent>", Description: "pin an agent for quick access"},
+	{Name: "unpin", Usage: "/unpin <agent>", Description: "unpin an agent"},
 	{Name: "refresh", Usage: "/refresh", Description: "refresh data"},
+	{Name: "reload", Usage: "/reload", Description: "re-read settings.json after an outside edit"},
+	{Name: "pause", Usage: "/pause", Description: "pause or resume auto-refresh"},
+	{Name: "max-tokens", Usage: "/max-tokens <agent> <n|off>", Description: "limit an agent's output length"},
+	{Name: "format", Usage: "/format <json|pretty|off>", Description: "set the CLI's default --format"},
+	{Name: "priority", Usage: "/priority <agent> <n|off>", Description: "bias orchestrator routing toward an agent (lower first)"},
+	{Name: "orch-max-targets", Usage: "/orch-max-targets <n|default>", Description: "limit how many agents the LLM orchestrator routes a request to"},
+	{Name: "orch-route-unhealthy", Usage: "/orch-route-unhealthy <on|off>", Description: "let the orchestrator route to agents whose health check failed"},
+	{Name: "orch-strict-delegates", Usage: "/orch-strict-delegates <on|off>", Description: "route only to delegates, or to any registered agent a router picks"},
+	{Name: "probe", Usage: "/probe <agent> <on|off>", Description: "health-check an agent with a real prompt"},
+	{Name: "jsonstream", Usage: "/jsonstream <agent> <on|off>", Description: "stream an agent's tool calls and thinking as events"},
+	{Name: "capture", Usage: "/capture <agent> <on|off>", Description: "record the files an agent's tasks change"},
+	{Name: "allowed-root", Usage: "/allowed-root <agent> <dir|off>", Description: "keep an agent's working directories inside dir"},
+	{Name: "progress", Usage: "/progress <agent> <regex|off>", Description: "show a progress bar from an agent's output"},
+	{Name: "env", Usage: "/env <agent> KEY=VALUE", Description: "set an environment variable for an agent's launches"},
+	{Name: "prune", Usage: "/prune [duration]", Description: "remove finished tasks older than duration"},
+	{Name: "deadletters", Usage: "/deadletters", Description: "show sends that failed every attempt"},
+	{Name: "new", Usage: "/new [agent]", Description: "start a fresh conversation with the agent instead of continuing it"},
+	{Name: "replay", Usage: "/replay <agent>", Description: "rerun the selected History response's context on another agent"},
 	{Name: "help", Usage: "/help", Description: "show help overlay"},
 	{Name: "quit", Usage: "/quit", Description: "exit the TUI"},
 	{Name: "exit", Usage: "/exit", Description: "exit the TUI"},
@@ -1578,15 +2475,22 @@ var commandCatalog = []commandSpec{
 	{Name: "codex-sandbox", Usage: "/codex-sandbox <mode>", Description: "set Codex sandbox mode"},
 	{Name: "codex-approval", Usage: "/codex-approval <policy>", Description: "set Codex approval policy"},
 	{Name: "codex-search", Usage: "/codex-search", Description: "toggle Codex web search"},
+	{Name: "codex-last-message", Usage: "/codex-last-message", Description: "toggle storing only Codex's final message"},
 	// Gemini settings commands
 	{Name: "gemini-model", Usage: "/gemini-model <model>", Description: "set Gemini model"},
 	{Name: "gemini-resume", Usage: "/gemini-resume <id>", Description: "resume a Gemini session"},
+	// Remote agent commands
+	{Name: "remote-auth", Usage: "/remote-auth <alias> <token>", Description: "store a bearer token for a remote agent"},
 }
 
 func (m *model) appendCommandHistory(cmd string) {
 	if cmd == "" {
 		return
 	}
+	// Keep credentials out of the recallable history
+	if trimmed := strings.TrimLeft(cmd, "/:"); strings.HasPrefix(trimmed, "remote-auth") || strings.HasPrefix(trimmed, "env ") {
+		return
+	}
 	if len(m.commandHistory) > 0 && m.commandHistory[len(m.commandHistory)-1] == cmd {
 		return
 	}
@@ -1621,24 +2525,12 @@ func (m *model) navigateHistory(delta int) {
 func splitArgs(input string) []string {
 	var args []string
 	var buf strings.Builder
-	var quote rune
-	escaped := false
+	var qs quoteScanner
 	for _, r := range input {
+		literal, protected := qs.step(r)
 		switch {
-		case escaped:
-			buf.WriteRune(r)
-			escaped = false
-		case r == '\\':
-			escaped = true
-		case quote != 0:
-			if r == quote {
-				quote = 0
-			} else {
-				buf.WriteRune(r)
-			}
-		case r == '"' || r == '\'':
-			quote = r
-		case r == ' ' || r == '\t':
+		case !literal:
+		case !protected && (r == ' ' || r == '\t'):
 			if buf.Len() > 0 {
 				args = append(args, buf.String())
 				buf.Reset()
@@ -1653,6 +2545,70 @@ func splitArgs(input string) []string {
 	return args
 }
 
+// quoteScanner tracks single/double quotes and backslash escapes one rune at a time
+type quoteScanner struct {
+	quote   rune
+	escaped bool
+}
+
+// step reports whether r is literal text (rather than a quote or escape character)
+// and whether it is protected by quotes or a preceding backslash
+func (q *quoteScanner) step(r rune) (literal, protected bool) {
+	switch {
+	case q.escaped:
+		q.escaped = false
+		return true, true
+	case r == '\\':
+		q.escaped = true
+		return false, q.quote != 0
+	case q.quote != 0:
+		if r == q.quote {
+			q.quote = 0
+			return false, true
+		}
+		return true, true
+	case r == '"' || r == '\'':
+		q.quote = r
+		return false, true
+	}
+	return true, false
+}
+
+// maskQuoted blanks out quoted and escaped text so delimiter patterns can be matched
+// against the result while byte offsets still line up with the original. An
+// apostrophe inside a word does not open a quote, and an unterminated quote is
+// left unmasked.
+func maskQuoted(text string) string {
+	masked := []byte(text)
+	var qs quoteScanner
+	var prev rune
+	openAt := -1
+	for i, r := range text {
+		if r == '\'' && qs.quote == 0 && !qs.escaped && (unicode.IsLetter(prev) || unicode.IsDigit(prev)) {
+			prev = r
+			continue
+		}
+		opening := qs.quote == 0 && !qs.escaped && (r == '"' || r == '\'')
+		_, protected := qs.step(r)
+		if opening {
+			openAt = i
+		}
+		if protected {
+			for b := i; b < i+utf8.RuneLen(r); b++ {
+				masked[b] = '_'
+			}
+		}
+		if qs.quote == 0 {
+			openAt = -1
+		}
+		prev = r
+	}
+	if openAt >= 0 {
+		copy(masked[openAt:], text[openAt:])
+	}
+	return string(masked)
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -1736,8 +2692,8 @@ func (m *model) rebuildLogLines() {
 func (m model) viewStatus() string {
 	width, _ := m.bodySize()
 	left := []string{
-		fmt.Sprintf("Version: %s", m.status.Version),
-		fmt.Sprintf("Uptime: %ds", m.status.Uptime),
+		fmt.Sprintf("Version: %s", version.Info{Version: m.status.Version, Commit: m.status.Commit, Date: m.status.BuildDate}),
+		fmt.Sprintf("Uptime: %s", taskfmt.HumanDuration(time.Duration(m.status.Uptime)*time.Second)),
 		fmt.Sprintf("Agents: %d", m.status.Total),
 		fmt.Sprintf("Healthy: %d", m.status.Healthy),
 		fmt.Sprintf("Degraded: %d", m.status.Degraded),
@@ -1846,7 +2802,7 @@ func (m model) viewSend() string {
 	textareaView = m.padTextareaLines(textareaView, inputWidth-4)
 	msgBox := msgBoxStyle.Width(inputWidth).Render(textareaView)
 
-	agentLabel := lipgloss.NewStyle().Foreground(lightGreen).Render(m.agentInput.Value())
+	agentLabel := alignRight(m.renderAgentLabel(), m.renderComposeStats(), inputWidth)
 	helpText := dimStyle.Render("shift+A agents  ctrl+p commands  enter send")
 
 	lines := []string{
@@ -1875,7 +2831,7 @@ func (m model) renderSendModal() string {
 	textareaView = m.padTextareaLines(textareaView, inputWidth-4)
 	msgBox := msgBoxStyle.Width(inputWidth).Render(textareaView)
 
-	agentLabel := lipgloss.NewStyle().Foreground(lightGreen).Render(m.agentInput.Value())
+	agentLabel := alignRight(m.renderAgentLabel(), m.renderComposeStats(), inputWidth)
 	helpText := dimStyle.Render("shift+A agents  ctrl+p commands  enter send  esc close")
 
 	title := headerStyle.Render("Send Message")
@@ -1896,6 +2852,54 @@ func (m model) renderSendModal() string {
 	return box
 }
 
+// renderAgentLabel shows the target agent followed by the pinned quick-pick keys
+func (m model) renderAgentLabel() string {
+	label := lipgloss.NewStyle().Foreground(lightGreen).Render(m.agentInput.Value())
+	if m.streamMode == "off" {
+		label += dimStyle.Render(" (no streaming)")
+	}
+	if thread, ok := m.agentThreads[m.agentInput.Value()]; ok {
+		switch thread.turns {
+		case 0:
+			label += dimStyle.Render(" (new conversation)")
+		case 1:
+			label += dimStyle.Render(" ↩ continuing (1 turn, /new for fresh)")
+		default:
+			label += dimStyle.Render(fmt.Sprintf(" ↩ continuing (%d turns, /new for fresh)", thread.turns))
+		}
+	}
+	if len(m.pinnedAgents) == 0 {
+		return label
+	}
+	picks := make([]string, 0, len(m.pinnedAgents))
+	for i, id := range m.pinnedAgents {
+		picks = append(picks, fmt.Sprintf("%d %s", i+1, id))
+	}
+	return label + dimStyle.Render("   pinned: "+strings.Join(picks, "  "))
+}
+
+// renderComposeStats counts the message being composed; tokens are a rough chars/4 estimate
+func (m model) renderComposeStats() string {
+	text := m.msgInput.Value()
+	if text == "" {
+		return ""
+	}
+	chars := utf8.RuneCountInString(text)
+	words := len(strings.Fields(text))
+	tokens := (chars + 3) / 4
+	return dimStyle.Render(fmt.Sprintf("%d chars · %d words · ~%d tokens", chars, words, tokens))
+}
+
+// alignRight places right at the end of a line of the given width, after left.
+// right is dropped when both do not fit on one line.
+func alignRight(left, right string, width int) string {
+	gap := width - lipgloss.Width(left) - lipgloss.Width(right)
+	if right == "" || gap < 2 {
+		return left
+	}
+	return left + strings.Repeat(" ", gap) + right
+}
+
 func modalSize(width, height int) (int, int) {
 	return panelSize(width, height)
 }
@@ -1940,6 +2944,16 @@ func (m model) renderAgentPicker(width int) string {
 	return strings.Join(lines, "\n")
 }
 
+// viewTooSmall replaces the layout until the window grows past the minimum size
+func viewTooSmall(width, height int) string {
+	text := fmt.Sprintf("Terminal too small (need ≥ %dx%d, have %dx%d)", minTerminalWidth, minTerminalHeight, width, height)
+	lines := strings.Split(lipgloss.NewStyle().Width(width).Render(errStyle.Render(text)), "\n")
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	return strings.Join(lines, "\n")
+}
+
 func contentSize(width, height int) (int, int) {
 	panelWidth, panelHeight := panelSize(width, height)
 	contentWidth := panelWidth - 6
@@ -2084,7 +3098,7 @@ func (m model) viewSessions() string {
 	var lines []string
 	currentLabel := ""
 	if m.currentSessionID != "" {
-		session := m.server.Sessions().Get(m.currentSessionID)
+		session := m.sessionStore.Get(m.currentSessionID)
 		if session != nil {
 			currentLabel = fmt.Sprintf("Current: %s (started %s)", session.ShortID(), session.CreatedAt.Format("Jan 2 15:04"))
 		}
@@ -2122,6 +3136,16 @@ func (m model) viewSessions() string {
 }
 
 func (m model) viewSettings() string {
+	if m.attached {
+		lines := []string{
+			headerStyle.Render("Runtime Settings"),
+			"",
+			fmt.Sprintf("Attached to hub at %s", m.cfg.Socket.Path),
+			"",
+			dimStyle.Render(attachedSettingsNotice),
+		}
+		return strings.Join(lines, "\n")
+	}
 	m.settingsInput.Width = 60
 	currentDelegates := strings.Join(m.server.OrchestratorAgents(), ",")
 
@@ -2175,6 +3199,10 @@ func (m model) viewSettings() string {
 	if m.codexSearch {
 		codexSearchCheck = "[x]"
 	}
+	codexLastMessageCheck := "[ ]"
+	if m.codexLastMessage {
+		codexLastMessageCheck = "[x]"
+	}
 
 	geminiSandboxCheck := "[ ]"
 	if m.geminiSandbox {
@@ -2246,48 +3274,50 @@ func (m model) viewSettings() string {
 		fmt.Sprintf("HTTP: %s:%d (enabled: %t)", m.server.Config().HTTP.Host, m.server.Config().HTTP.Port, m.server.Config().HTTP.Enabled),
 		"",
 		headerStyle.Render("Orchestrator"),
-		orchIndicator + "Delegates (comma-separated):",
+		orchIndicator + "Delegates (comma-separated):" + m.unsavedMark(settingsFieldOrchestrator),
 		"  " + m.settingsInput.View(),
 		fmt.Sprintf("  Current: %s", currentDelegates),
 		"",
 		headerStyle.Render("Claude Settings"),
-		modelIndicator + "Model:",
+		modelIndicator + "Model:" + m.unsavedMark(settingsFieldClaudeModel),
 		"  " + m.claudeModelInput.View(),
 		dimStyle.Render("  Options: opus, sonnet, haiku (blank = default)"),
-		toolsIndicator + "Tool Profile:",
+		toolsIndicator + "Tool Profile:" + m.unsavedMark(settingsFieldClaudeTools),
 		"  " + m.claudeToolsInput.View(),
 		dimStyle.Render("  Options: safe (read-only), normal, full (blank = all tools)"),
 		contIndicator + "Continue Mode: " + continueCheck,
 		dimStyle.Render("  Resume previous conversation context"),
 		"",
 		headerStyle.Render("Codex Settings"),
-		codexModelIndicator + "Model:",
+		codexModelIndicator + "Model:" + m.unsavedMark(settingsFieldCodexModel),
 		"  " + m.codexModelInput.View(),
 		dimStyle.Render("  Any model id (blank = default)"),
-		codexProfileIndicator + "Profile:",
+		codexProfileIndicator + "Profile:" + m.unsavedMark(settingsFieldCodexProfile),
 		"  " + m.codexProfileInput.View(),
 		dimStyle.Render("  Config profile from config.toml (blank = default)"),
-		codexSandboxIndicator + "Sandbox:",
+		codexSandboxIndicator + "Sandbox:" + m.unsavedMark(settingsFieldCodexSandbox),
 		"  " + m.codexSandboxInput.View(),
 		dimStyle.Render("  read-only, workspace-write, danger-full-access (blank = default)"),
-		codexApprovalIndicator + "Approval Policy:",
+		codexApprovalIndicator + "Approval Policy:" + m.unsavedMark(settingsFieldCodexApproval),
 		"  " + m.codexApprovalInput.View(),
 		dimStyle.Render("  untrusted, on-failure, on-request, never (blank = default)"),
 		codexSearchIndicator + "Web Search: " + codexSearchCheck,
 		dimStyle.Render("  Enable web_search tool"),
+		"  Last Message Only: " + codexLastMessageCheck,
+		dimStyle.Render("  Store only Codex's final answer (needs --output-last-message); toggle with /codex-last-message"),
 		"",
 		headerStyle.Render("Gemini Settings"),
-		geminiModelIndicator + "Model:",
+		geminiModelIndicator + "Model:" + m.unsavedMark(settingsFieldGeminiModel),
 		"  " + m.geminiModelInput.View(),
 		dimStyle.Render("  gemini-1.5-pro, gemini-1.5-flash, gemini-2.0-flash (blank = default)"),
 		geminiSandboxIndicator + "Sandbox: " + geminiSandboxCheck,
 		dimStyle.Render("  Run in sandbox"),
-		geminiApprovalIndicator + "Approval Mode:",
+		geminiApprovalIndicator + "Approval Mode:" + m.unsavedMark(settingsFieldGeminiApproval),
 		"  " + m.geminiApprovalInput.View(),
 		dimStyle.Render("  default, auto_edit, yolo (blank = default)"),
 		"",
 		headerStyle.Render("Vibe Settings (Mistral Vibe CLI)"),
-		vibeAgentIndicator + "Agent:",
+		vibeAgentIndicator + "Agent:" + m.unsavedMark(settingsFieldVibeAgent),
 		"  " + m.vibeAgentInput.View(),
 		dimStyle.Render("  Custom agent config from ~/.vibe/agents/ (blank = default)"),
 		vibeNonInteractiveIndicator + "Non-Interactive: " + vibeNonInteractiveCheck,
@@ -2297,7 +3327,56 @@ func (m model) viewSettings() string {
 		vibeIncludeHistoryIndicator + "Include History: " + vibeIncludeHistoryCheck,
 		dimStyle.Render("  Prepend conversation history to prompts"),
 		"",
+		headerStyle.Render("Output Limits"),
+		"  " + m.renderMaxTokens(),
+		dimStyle.Render("  Set with /max-tokens <agent> <n|off>"),
+		"",
+		headerStyle.Render("CLI Output Format"),
+		"  " + m.renderOutputFormat(),
+		dimStyle.Render("  Default --format for CLI commands; A2A_HUB_FORMAT and --format override it. Set with /format <json|pretty|off>"),
+		"",
+		headerStyle.Render("Routing Priorities"),
+		"  " + m.renderAgentPriorities(),
+		dimStyle.Render("  Lower numbers are preferred by the orchestrator; set with /priority <agent> <n|off>"),
+		"",
+		headerStyle.Render("Routing Targets"),
+		fmt.Sprintf("  At most %d agent(s) per request", m.server.MaxRoutingTargets()),
+		dimStyle.Render(fmt.Sprintf("  Used by the LLM orchestrator; set with /orch-max-targets <1-%d|default>", hub.MaxRoutingTargetsLimit)),
+		"  Unhealthy agents: " + routeUnhealthyLabel(m.server.RouteUnhealthy()),
+		dimStyle.Render("  Agents whose last health check failed; set with /orch-route-unhealthy <on|off>"),
+		"  Router picks outside the delegates: " + strictDelegatesLabel(m.server.StrictDelegates()),
+		dimStyle.Render("  Dropped picks are listed in the answer's notes; set with /orch-strict-delegates <on|off>"),
+		"",
+		headerStyle.Render("Health Probes"),
+		"  " + joinOrNone(m.server.HealthProbes()),
+		dimStyle.Render("  Send a tiny real prompt on each health check; set with /probe <agent> <on|off>"),
+		"",
+		headerStyle.Render("JSON Streams"),
+		"  " + joinOrNone(m.server.JSONStreams()),
+		dimStyle.Render("  Stream tool calls and thinking as separate lines (claude-code, codex); set with /jsonstream <agent> <on|off>"),
+		"",
+		headerStyle.Render("Changed-File Capture"),
+		"  " + joinOrNone(m.server.CaptureChanges()),
+		dimStyle.Render("  List the files each task or TUI send changed in its working dir; set with /capture <agent> <on|off>"),
+		"",
+		headerStyle.Render("Allowed Roots"),
+		"  " + m.renderAllowedRoots(),
+		dimStyle.Render("  Sends asking for a working dir outside the root are rejected; set with /allowed-root <agent> <dir|off>"),
+		"",
+		headerStyle.Render("Progress Patterns"),
+		"  " + m.renderProgressPatterns(),
+		dimStyle.Render("  Regex with current/total (or percent) groups, e.g. (\\d+)/(\\d+) files; set with /progress <agent> <regex|off>"),
+		"",
+		headerStyle.Render("Agent Environment"),
+	}
+	lines = append(lines, m.renderAgentEnv()...)
+	lines = append(lines,
+		dimStyle.Render("  Stored in secrets.json; set with /env <agent> KEY=VALUE (KEY= removes it)"),
+		"",
 		dimStyle.Render("Tab/Shift+Tab to navigate, Enter to apply, Space to toggle"),
+	)
+	if dirty := len(m.dirtySettingsFields()); dirty > 0 {
+		lines = append(lines, "", confirmStyle.Render(fmt.Sprintf("%d unsaved change(s): Enter saves the focused field, ctrl+s saves all", dirty)))
 	}
 	if m.settingsMessage != "" {
 		lines = append(lines, "", m.settingsMessage)
@@ -2307,19 +3386,37 @@ func (m model) viewSettings() string {
 	return strings.Join(lines, "\n")
 }
 
+// versionLabel is the hub version for the status bar: "v1.2.0", "dev", or this build's
+// version before the first status refresh
+func versionLabel(v string) string {
+	if v == "" {
+		v = version.Get().Version
+	}
+	if v != "" && v[0] >= '0' && v[0] <= '9' {
+		return "v" + v
+	}
+	return v
+}
+
 func (m model) renderStatusBar() string {
 	parts := []string{}
 	if m.refreshing || m.sending {
 		parts = append(parts, m.spinner.View())
 	}
 	parts = append(parts,
-		fmt.Sprintf("v%s", m.status.Version),
+		versionLabel(m.status.Version),
 		fmt.Sprintf("agents %d/%d", m.status.Healthy, m.status.Total),
 		fmt.Sprintf("tasks %d", m.status.TotalTasks),
 	)
 	if !m.lastUpdated.IsZero() {
 		parts = append(parts, "refreshed "+m.lastUpdated.Format("15:04:05"))
 	}
+	if m.refreshPaused {
+		parts = append(parts, "refresh paused")
+	}
+	if m.notification != "" {
+		parts = append(parts, lipgloss.NewStyle().Foreground(lightGreen).Bold(true).Render(m.notification))
+	}
 	line := strings.Join(parts, "  ")
 	width, _ := contentSize(m.width, m.height)
 	if width > 0 {
@@ -2386,7 +3483,11 @@ func renderTwoPane(width int, left, right string) string {
 }
 
 func (m model) viewName() string {
-	switch m.activeTab {
+	return tabName(m.activeTab)
+}
+
+func tabName(tab int) string {
+	switch tab {
 	case tabStatus:
 		return "Status"
 	case tabAgents:
@@ -2408,6 +3509,31 @@ func (m model) viewName() string {
 	}
 }
 
+// tabByName finds a tab by its name, ignoring case
+func tabByName(name string) (int, bool) {
+	for tab := 0; tab < tabCount; tab++ {
+		if strings.EqualFold(tabName(tab), name) {
+			return tab, true
+		}
+	}
+	return 0, false
+}
+
+// restoreView reopens the tab and send modal saved when the TUI last exited. An
+// unknown or missing tab keeps the Send tab.
+func (m *model) restoreView(tab string, sendModalOpen bool) {
+	if index, ok := tabByName(tab); ok {
+		m.activeTab = index
+	}
+	if sendModalOpen {
+		m.showSendModal = true
+		return
+	}
+	if m.activeTab == tabSettings {
+		m.setSettingsFocus(true)
+	}
+}
+
 func renderCentered(content string, width, height int) string {
 	if width <= 0 || height <= 0 {
 		return content
@@ -2452,7 +3578,7 @@ func (m *model) updateActiveList(msg tea.Msg) tea.Cmd {
 		if m.activeTab == tabAgents {
 			if item, ok := m.agentsList.SelectedItem().(agentItem); ok {
 				m.agentInput.SetValue(item.data.ID)
-				m.server.UpdateLastAgent(item.data.ID)
+				m.rememberLastAgent(item.data.ID)
 				m.showSendModal = true
 				m.focusIndex = 1
 				m.agentInput.Blur()
@@ -2467,12 +3593,15 @@ func (m *model) updateActiveList(msg tea.Msg) tea.Cmd {
 		prevIndex = m.agentsList.Index()
 		m.agentsList, cmd = m.agentsList.Update(msg)
 		if prevIndex != m.agentsList.Index() {
+			m.agentDescription = ""
 			m.updateDetailForTab(tabAgents)
 		}
 	case tabTasks:
 		prevIndex = m.tasksList.Index()
 		m.tasksList, cmd = m.tasksList.Update(msg)
 		if prevIndex != m.tasksList.Index() {
+			m.deadLetterView = ""
+			m.taskDirView = ""
 			m.updateDetailForTab(tabTasks)
 		}
 	case tabHistory:
@@ -2500,36 +3629,77 @@ func isViewportKey(msg tea.KeyMsg) bool {
 func (m *model) updateDetailForTab(tab int) {
 	switch tab {
 	case tabAgents:
-		content := "No agents registered."
-		if item, ok := m.agentsList.SelectedItem().(agentItem); ok {
+		key, content := "agents:", "No agents registered."
+		item, ok := m.agentsList.SelectedItem().(agentItem)
+		if ok {
+			key += item.data.ID
+		}
+		if m.agentDescription != "" {
+			// /describe selects the agent it describes
+			m.setDetailContent(key+":describe", m.agentDescription)
+			return
+		}
+		if ok {
 			content = renderAgentDetail(item.data)
 			m.agentIndex = m.agentsList.Index()
 		}
-		m.setDetailContent(content)
+		m.setDetailContent(key, content)
 	case tabTasks:
-		content := "No tasks yet."
+		if m.deadLetterView != "" {
+			m.setDetailContent("tasks:deadletters", m.deadLetterView)
+			return
+		}
+		if item, ok := m.tasksList.SelectedItem().(taskItem); ok && m.taskDirView != "" {
+			m.setDetailContent("tasks:"+item.data.ID+":dir", m.taskDirView)
+			return
+		}
+		key, content := "tasks:", "No tasks yet."
 		if item, ok := m.tasksList.SelectedItem().(taskItem); ok {
-			content = renderTaskDetail(item.data)
+			key += item.data.ID
+			content = taskfmt.Detail(item.data, subTasks(m.tasks, item.data.ID), taskfmt.Hints{WorkingDir: "e to open", DeadLetters: "see /deadletters"})
 			m.taskIndex = m.tasksList.Index()
 		}
-		m.setDetailContent(content)
+		m.setDetailContent(key, content)
 	case tabHistory:
-		content := "No responses yet."
+		key, content := "history:", "No responses yet."
 		if item, ok := m.responsesList.SelectedItem().(responseItem); ok {
-			content = renderResponseDetail(item.data)
+			entry := item.data
+			key += entry.TaskID + "@" + entry.Timestamp
+			if m.markdown {
+				entry.Text = m.renderMarkdown(entry.Text)
+			}
+			content = renderResponseDetail(entry)
 			m.historySel = m.responsesList.Index()
 		}
-		m.setDetailContent(content)
+		m.setDetailContent(key, content)
 	}
 }
 
-func (m *model) setDetailContent(content string) {
+// maxDetailOffsets bounds the remembered scroll positions; the map is cleared when full
+const maxDetailOffsets = 200
+
+// setDetailContent shows content for key (tab and item) in the detail viewport. The
+// scroll position of the item being left is remembered and restored when it is shown
+// again; new content for the same item keeps the current position.
+func (m *model) setDetailContent(key, content string) {
+	if key != m.detailKey {
+		if m.detailKey != "" {
+			if len(m.detailOffsets) >= maxDetailOffsets {
+				clear(m.detailOffsets)
+			}
+			m.detailOffsets[m.detailKey] = m.detailViewport.YOffset
+		}
+		m.detailKey = key
+		m.detailContent = content
+		m.detailViewport.SetContent(content)
+		m.detailViewport.SetYOffset(m.detailOffsets[key])
+		return
+	}
 	if content == m.detailContent {
 		return
 	}
 	m.detailContent = content
 	m.detailViewport.SetContent(content)
-	m.detailViewport.GotoTop()
 }
 
 func overlayModal(base, modal string, width, height int) string {
@@ -2547,6 +3717,119 @@ func overlayModal(base, modal string, width, height int) string {
 	return strings.Join(baseLines, "\n")
 }
 
+func (m model) renderMaxTokens() string {
+	limits := m.server.MaxOutputTokens()
+	if len(limits) == 0 {
+		return "none"
+	}
+	ids := make([]string, 0, len(limits))
+	for id := range limits {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	entries := make([]string, 0, len(ids))
+	for _, id := range ids {
+		entries = append(entries, fmt.Sprintf("%s=%d", id, limits[id]))
+	}
+	return strings.Join(entries, ", ")
+}
+
+func (m model) renderOutputFormat() string {
+	if format := m.server.OutputFormat(); format != "" {
+		return format
+	}
+	return "pretty (default)"
+}
+
+func (m model) renderAgentPriorities() string {
+	priorities := m.server.AgentPriorities()
+	if len(priorities) == 0 {
+		return "none"
+	}
+	ids := make([]string, 0, len(priorities))
+	for id := range priorities {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if priorities[ids[i]] != priorities[ids[j]] {
+			return priorities[ids[i]] < priorities[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	entries := make([]string, 0, len(ids))
+	for _, id := range ids {
+		entries = append(entries, fmt.Sprintf("%s=%d", id, priorities[id]))
+	}
+	return strings.Join(entries, ", ")
+}
+
+func (m model) renderProgressPatterns() string {
+	patterns := m.server.ProgressPatterns()
+	if len(patterns) == 0 {
+		return "none"
+	}
+	ids := make([]string, 0, len(patterns))
+	for id := range patterns {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	entries := make([]string, 0, len(ids))
+	for _, id := range ids {
+		entries = append(entries, fmt.Sprintf("%s=%s", id, patterns[id]))
+	}
+	return strings.Join(entries, ", ")
+}
+
+func (m model) renderAllowedRoots() string {
+	roots := m.server.AllowedRoots()
+	if len(roots) == 0 {
+		return "none"
+	}
+	ids := make([]string, 0, len(roots))
+	for id := range roots {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	entries := make([]string, 0, len(ids))
+	for _, id := range ids {
+		entries = append(entries, fmt.Sprintf("%s=%s", id, roots[id]))
+	}
+	return strings.Join(entries, ", ")
+}
+
+func (m model) renderAgentEnv() []string {
+	ids := m.server.EnvAgents()
+	if len(ids) == 0 {
+		return []string{"  none"}
+	}
+	lines := make([]string, 0, len(ids))
+	for _, id := range ids {
+		env := m.server.AgentEnv(id)
+		keys := make([]string, 0, len(env))
+		for key := range env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		entries := make([]string, 0, len(keys))
+		for _, key := range keys {
+			entries = append(entries, key+"="+maskEnvValue(key, env[key]))
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %s", id, strings.Join(entries, " ")))
+	}
+	return lines
+}
+
+// maskEnvValue hides values of variables that look like credentials
+func maskEnvValue(key, value string) string {
+	upper := strings.ToUpper(key)
+	for _, marker := range []string{"KEY", "TOKEN", "SECRET"} {
+		if strings.Contains(upper, marker) {
+			return "****"
+		}
+	}
+	return value
+}
+
 func (m model) renderExecList() string {
 	infos := m.server.AgentsList()
 	if len(infos) == 0 {
@@ -2555,7 +3838,9 @@ func (m model) renderExecList() string {
 	lines := make([]string, 0, len(infos))
 	for _, info := range infos {
 		execPath := "internal"
-		if provider, ok := info.Agent.(interface{ ExecPath() string }); ok {
+		if provider, ok := info.Agent.(interface{ CommandLine() []string }); ok {
+			execPath = utils.JoinArgs(m.server.RedactArgs(provider.CommandLine()))
+		} else if provider, ok := info.Agent.(interface{ ExecPath() string }); ok {
 			execPath = provider.ExecPath()
 		}
 		lines = append(lines, fmt.Sprintf("- %s: %s", info.Agent.ID(), execPath))
@@ -2635,27 +3920,45 @@ func (m *model) startSend(agent, message string) tea.Cmd {
 
 	// Check for @agent mentions in the message
 	mentions := parseMentions(message)
+	if len(mentions) == 0 && agent == "" {
+		return nil
+	}
+
+	// Starting now would reset the in-flight streams, so hold the message until they finish
+	if m.sending {
+		m.pendingSends = append(m.pendingSends, pendingSend{agent: agent, message: message})
+		m.errMsg = fmt.Sprintf("Agent busy: message queued (%d pending)", len(m.pendingSends))
+		m.addLog("info", m.errMsg)
+		m.msgInput.SetValue("")
+		m.msgInput.CursorEnd()
+		m.syncSendViewport()
+		return nil
+	}
+
 	if len(mentions) > 0 {
 		return m.startMultiAgentSend(mentions)
 	}
 
 	// Single agent flow - use streaming
-	if agent == "" {
-		return nil
-	}
 	m.errMsg = ""
 	m.lastResponse = ""
 	m.sending = true
-	m.server.UpdateLastAgent(agent)
+	m.rememberLastAgent(agent)
 	m.appendSendEntry("user", agent, message)
 	m.msgInput.SetValue("")
 	m.msgInput.CursorEnd()
 
 	// Clear previous streaming state
+	for _, stream := range m.streamChannels {
+		closeTee(stream)
+	}
 	m.streamChannels = make(map[string]*AgentStream)
 	m.streamBuffer = make(map[string][]string)
 	m.focusedAgent = ""
 	m.pendingPrompts = []string{}
+	m.queuedTasks = nil
+	m.streamStarted = map[string]time.Time{agent: time.Now()}
+	m.streamProgress = make(map[string]float64)
 
 	// Create stream channels for this agent
 	stream := &AgentStream{
@@ -2664,17 +3967,22 @@ func (m *model) startSend(agent, message string) tea.Cmd {
 		Done:   false,
 	}
 	m.streamChannels[agent] = stream
+	if path := m.takeTeePath(); path != "" {
+		stream.tee = m.openTee(path, "")
+	}
 
 	// Start streaming execution in background
 	return tea.Batch(
 		m.spinner.Tick,
-		startStreamingCmd(m.server, agent, message, m.currentContextID(), stream),
-		listenAgentStream(agent, stream.Output),
+		m.streamCmd(agent, message, m.threadContextID(agent), stream),
+		listenAgentStream(agent, stream),
 	)
 }
 
-// startMultiAgentSend dispatches tasks to multiple agents concurrently with streaming
-func (m *model) startMultiAgentSend(mentions map[string]string) tea.Cmd {
+// startMultiAgentSend dispatches tasks to multiple agents concurrently with streaming.
+// At most maxConcurrentAgents streams run at once, and several tasks for the same
+// agent run one after another, each with its own stream.
+func (m *model) startMultiAgentSend(mentions []mention) tea.Cmd {
 	m.errMsg = ""
 	m.lastResponse = ""
 	m.sending = true
@@ -2682,17 +3990,25 @@ func (m *model) startMultiAgentSend(mentions map[string]string) tea.Cmd {
 	// Clear and set up tracking
 	m.activeAgents = make(map[string]string)
 	m.agentProgress = make(map[string]string)
+	for _, stream := range m.streamChannels {
+		closeTee(stream)
+	}
 	m.streamChannels = make(map[string]*AgentStream)
 	m.streamBuffer = make(map[string][]string)
 	m.focusedAgent = ""
 	m.pendingPrompts = []string{}
+	m.queuedTasks = nil
+	m.streamStarted = make(map[string]time.Time)
+	m.streamProgress = make(map[string]float64)
 
 	// Build list of agent names for display
 	var agentNames []string
-	for agentID, task := range mentions {
-		m.activeAgents[agentID] = task
-		m.agentProgress[agentID] = "working"
-		agentNames = append(agentNames, agentID)
+	counts := make(map[string]int)
+	for _, mention := range mentions {
+		if counts[mention.AgentID] == 0 {
+			agentNames = append(agentNames, mention.AgentID)
+		}
+		counts[mention.AgentID]++
 	}
 
 	// Append user message summary to log
@@ -2703,20 +4019,76 @@ func (m *model) startMultiAgentSend(mentions map[string]string) tea.Cmd {
 	// Create batch of commands - one per agent with streaming
 	// All agents share the same context for cross-agent history
 	contextID := m.currentContextID()
-	cmds := []tea.Cmd{m.spinner.Tick}
-	for agentID, task := range mentions {
-		stream := &AgentStream{
+	teePath := m.takeTeePath()
+	seen := make(map[string]int)
+	for _, mention := range mentions {
+		seen[mention.AgentID]++
+		key := mention.AgentID
+		if counts[mention.AgentID] > 1 {
+			key = fmt.Sprintf("%s#%d", mention.AgentID, seen[mention.AgentID])
+		}
+		m.activeAgents[key] = mention.Task
+		m.streamChannels[key] = &AgentStream{
 			Output: make(chan types.StreamEvent, 100),
 			Input:  make(chan string, 10),
 			Done:   false,
 		}
-		m.streamChannels[agentID] = stream
-		cmds = append(cmds, startStreamingCmd(m.server, agentID, task, contextID, stream))
-		cmds = append(cmds, listenAgentStream(agentID, stream.Output))
+		if teePath != "" {
+			m.streamChannels[key].tee = m.openTee(teePath, "["+key+"] ")
+		}
+		m.agentProgress[key] = "queued"
+		m.queuedTasks = append(m.queuedTasks, subTask{key: key, agentID: mention.AgentID, task: mention.Task, contextID: contextID})
+	}
+	return tea.Batch(m.spinner.Tick, m.startQueuedTasks())
+}
+
+// startPendingSend starts the oldest message queued while an earlier send was running
+func (m *model) startPendingSend() tea.Cmd {
+	if len(m.pendingSends) == 0 {
+		return nil
+	}
+	next := m.pendingSends[0]
+	m.pendingSends = m.pendingSends[1:]
+	return m.startSend(next.agent, next.message)
+}
+
+// startSubTask starts streaming for a sub-task whose stream is already registered
+func (m *model) startSubTask(task subTask) tea.Cmd {
+	stream := m.streamChannels[task.key]
+	m.streamStarted[task.key] = time.Now()
+	return tea.Batch(
+		m.streamCmd(task.agentID, task.task, task.contextID, stream),
+		listenAgentStream(task.key, stream),
+	)
+}
+
+// startQueuedTasks starts queued sub-tasks, oldest first, while fewer than
+// maxConcurrentAgents streams are running. A task whose agent is still busy waits.
+func (m *model) startQueuedTasks() tea.Cmd {
+	var cmds []tea.Cmd
+	for i := 0; i < len(m.queuedTasks) && len(m.streamStarted) < m.maxConcurrentAgents; {
+		task := m.queuedTasks[i]
+		if m.agentRunning(task.agentID) {
+			i++
+			continue
+		}
+		m.queuedTasks = append(m.queuedTasks[:i:i], m.queuedTasks[i+1:]...)
+		m.agentProgress[task.key] = "working"
+		cmds = append(cmds, m.startSubTask(task))
 	}
 	return tea.Batch(cmds...)
 }
 
+// agentRunning reports whether one of the agent's streams has started and not finished
+func (m *model) agentRunning(agentID string) bool {
+	for key := range m.streamStarted {
+		if id, _, _ := strings.Cut(key, "#"); id == agentID {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *model) appendSendEntry(role, agent, text string) {
 	text = strings.TrimSpace(text)
 	if text == "" {
@@ -2739,7 +4111,7 @@ func (m *model) appendSendEntry(role, agent, text string) {
 			Text:      text,
 			Timestamp: timestamp,
 		}
-		_ = m.server.Sessions().AddEntry(m.currentSessionID, entry)
+		_ = m.sessionStore.AddEntry(m.currentSessionID, entry)
 	}
 }
 
@@ -2755,16 +4127,53 @@ func (m *model) loadSession(session *hub.Session) {
 		})
 	}
 	m.currentSessionID = session.ID
+	m.agentThreads = make(map[string]agentThread)
 	m.syncSendViewport()
 }
 
+// threadContextID is the context for a single-agent send: the agent's conversation
+// when a follow-up continues one, the session's context otherwise
+func (m *model) threadContextID(agentID string) string {
+	if thread, ok := m.agentThreads[agentID]; ok {
+		return thread.contextID
+	}
+	return m.currentContextID()
+}
+
+// recordReply stores a completed reply in the embedded hub's context history (an
+// attached hub records its own via message/send) and, for a single-agent send, lets
+// follow-ups to the agent continue the conversation
+func (m *model) recordReply(key string, stream *AgentStream, reply string) {
+	if stream == nil || stream.contextID == "" {
+		return
+	}
+	agentID, _, _ := strings.Cut(key, "#")
+	if m.server != nil && strings.TrimSpace(reply) != "" {
+		_ = m.server.Contexts().AddMessage(stream.contextID, types.Message{
+			Kind:      "message",
+			MessageID: utils.NewID("msg"),
+			Role:      "agent",
+			Parts:     []types.Part{{Kind: "text", Text: reply}},
+			ContextID: stream.contextID,
+			Metadata:  map[string]any{"agentId": agentID},
+		})
+	}
+	if len(m.streamChannels) != 1 {
+		return
+	}
+	thread := m.agentThreads[agentID]
+	thread.contextID = stream.contextID
+	thread.turns++
+	m.agentThreads[agentID] = thread
+}
+
 // currentContextID returns the context ID for the current session
 // This ensures all agents in the same session share the same context
 func (m *model) currentContextID() string {
 	if m.currentSessionID == "" {
 		return utils.NewID("ctx") // fallback for no session
 	}
-	session := m.server.Sessions().Get(m.currentSessionID)
+	session := m.sessionStore.Get(m.currentSessionID)
 	if session == nil {
 		return utils.NewID("ctx") // fallback if session not found
 	}
@@ -2773,6 +4182,13 @@ func (m *model) currentContextID() string {
 
 // getAgentIDs returns a list of available agent IDs
 func (m *model) getAgentIDs() []string {
+	if m.server == nil {
+		ids := make([]string, 0, len(m.agents))
+		for _, a := range m.agents {
+			ids = append(ids, a.ID)
+		}
+		return ids
+	}
 	agents := m.server.AgentsList()
 	ids := make([]string, 0, len(agents))
 	for _, a := range agents {
@@ -2781,6 +4197,21 @@ func (m *model) getAgentIDs() []string {
 	return ids
 }
 
+// streamEventLine renders a structured stream event as a send log line, e.g.
+// "🔧 running Bash: ls"
+func streamEventLine(event types.StreamEvent) string {
+	switch event.Kind {
+	case "tool":
+		if event.Text == "" {
+			return "🔧 running " + event.Tool
+		}
+		return "🔧 running " + event.Tool + ": " + event.Text
+	case "thinking":
+		return "💭 " + event.Text
+	}
+	return event.Text
+}
+
 // appendStreamLine adds a line to an agent's streaming buffer and updates the display
 func (m *model) appendStreamLine(agentID, text string) {
 	if m.streamBuffer == nil {
@@ -2793,6 +4224,7 @@ func (m *model) appendStreamLine(agentID, text string) {
 func (m *model) finishAgentStream(agentID string) {
 	if stream, ok := m.streamChannels[agentID]; ok {
 		stream.Done = true
+		closeTee(stream)
 	}
 	// Consolidate buffer into a single send entry
 	if lines, ok := m.streamBuffer[agentID]; ok && len(lines) > 0 {
@@ -2801,7 +4233,10 @@ func (m *model) finishAgentStream(agentID string) {
 		delete(m.streamBuffer, agentID)
 	}
 	delete(m.activeAgents, agentID)
+	delete(m.streamStarted, agentID)
+	delete(m.streamProgress, agentID)
 	m.agentProgress[agentID] = "completed"
+	m.releaseFocus(agentID)
 
 	// Check if all agents are done
 	allDone := true
@@ -2816,6 +4251,166 @@ func (m *model) finishAgentStream(agentID string) {
 	}
 }
 
+// notifyResponse flags a finished agent in the status bar when the Send tab isn't
+// showing it, optionally ringing the bell. The returned command clears it later.
+func (m *model) notifyResponse(agentID string, failed bool) tea.Cmd {
+	if m.activeTab == tabSend {
+		return nil
+	}
+	if m.bell {
+		fmt.Fprint(os.Stderr, "\a")
+	}
+	if failed {
+		return m.flash(agentID + " failed")
+	}
+	return m.flash(agentID + " responded")
+}
+
+type editorClosedMsg struct{ err error }
+
+// openTaskDir opens the selected task's working directory in $EDITOR, suspending the
+// TUI until the editor exits. Without $EDITOR the directory is listed in the detail view.
+func (m *model) openTaskDir() tea.Cmd {
+	item, ok := m.tasksList.SelectedItem().(taskItem)
+	if !ok {
+		return nil
+	}
+	dir, _ := item.data.Metadata["workingDirectory"].(string)
+	if strings.TrimSpace(dir) == "" {
+		return m.flash("task has no working directory")
+	}
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		m.taskDirView = renderDirListing(dir)
+		m.updateDetailForTab(tabTasks)
+		return m.flash("$EDITOR is not set; listing " + dir)
+	}
+	command := exec.Command(editor[0], append(editor[1:], dir)...)
+	return tea.ExecProcess(command, func(err error) tea.Msg {
+		return editorClosedMsg{err: err}
+	})
+}
+
+// toggleMarkdown switches markdown rendering of responses in the History tab
+func (m *model) toggleMarkdown() tea.Cmd {
+	m.markdown = !m.markdown
+	m.updateDetailForTab(tabHistory)
+	if m.markdown {
+		return m.flash("markdown rendering on")
+	}
+	return m.flash("markdown rendering off")
+}
+
+// flash shows text in the status bar for notificationDuration
+func (m *model) flash(text string) tea.Cmd {
+	m.notification = text
+	m.notificationGen++
+	gen := m.notificationGen
+	return tea.Tick(notificationDuration, func(time.Time) tea.Msg {
+		return notificationExpiredMsg{gen: gen}
+	})
+}
+
+// lastSendCLI renders the most recent single-agent send as an agents-hub send command.
+// Agent settings (model, sandbox, ...) live in the hub, so the command picks them up.
+func (m *model) lastSendCLI() (string, error) {
+	for i := len(m.sendLog) - 1; i >= 0; i-- {
+		entry := m.sendLog[i]
+		if entry.Role != "user" {
+			continue
+		}
+		if entry.Agent == "" || strings.Contains(entry.Agent, ", ") {
+			return "", errors.New("the last send went to several agents; send to each with agents-hub send")
+		}
+		args := []string{"agents-hub", "send"}
+		if socket := m.cfg.Socket.Path; socket != "" && socket != hub.DefaultConfig().Socket.Path {
+			args = append(args, "--socket", shellQuote(socket))
+		}
+		if m.currentSessionID != "" {
+			if session := m.sessionStore.Get(m.currentSessionID); session != nil && session.ContextID != "" {
+				args = append(args, "--context", shellQuote(session.ContextID))
+			}
+		}
+		args = append(args, shellQuote(entry.Agent), shellQuote(entry.Text))
+		return strings.Join(args, " "), nil
+	}
+	return "", errors.New("nothing sent yet")
+}
+
+// shellQuote quotes s for POSIX shells, leaving plain words as they are
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("@%_+=:,./-", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// copyToClipboard sets the terminal's clipboard with an OSC 52 escape sequence, which
+// works over SSH; terminals without support ignore it.
+func copyToClipboard(text string) {
+	fmt.Fprintf(os.Stderr, "\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+}
+
+// takeTeePath returns the /send-to path for the send being started and clears it
+func (m *model) takeTeePath() string {
+	path := m.teePath
+	m.teePath = ""
+	return path
+}
+
+// openTee opens path for appending, creating parent directories. Failures are
+// logged and leave the stream without a tee.
+func (m *model) openTee(path, prefix string) *streamTee {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		m.addLog("warn", "send-to: "+err.Error())
+		return nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		m.addLog("warn", "send-to: "+err.Error())
+		return nil
+	}
+	return &streamTee{file: file, prefix: prefix}
+}
+
+// writeTee appends one line to the stream's tee file. Each line is written straight
+// to the file so a partial generation survives the TUI exiting; on error the tee is
+// dropped and the stream carries on.
+func (m *model) writeTee(agentID string, stream *AgentStream, line string) {
+	if stream.tee == nil {
+		return
+	}
+	if _, err := stream.tee.file.WriteString(stream.tee.prefix + line + "\n"); err != nil {
+		m.addLog("warn", fmt.Sprintf("send-to (%s): %v", agentID, err))
+		closeTee(stream)
+	}
+}
+
+func closeTee(stream *AgentStream) {
+	if stream.tee == nil {
+		return
+	}
+	_ = stream.tee.file.Close()
+	stream.tee = nil
+}
+
+// releaseFocus drops a finished agent from focus mode, whether it completed or failed,
+// and hands focus to the next agent waiting for input
+func (m *model) releaseFocus(agentID string) {
+	m.pendingPrompts = slices.DeleteFunc(m.pendingPrompts, func(id string) bool { return id == agentID })
+	if m.focusedAgent != agentID {
+		return
+	}
+	m.focusedAgent = ""
+	if len(m.pendingPrompts) > 0 {
+		m.focusedAgent = m.pendingPrompts[0]
+		m.pendingPrompts = m.pendingPrompts[1:]
+	}
+	m.updateFocusIndicator()
+}
+
 // updateFocusIndicator updates the agent input to show which agent has focus
 func (m *model) updateFocusIndicator() {
 	if m.focusedAgent != "" {
@@ -2843,8 +4438,9 @@ func (m model) renderTaskActivity(width, height int) string {
 	if width <= 0 {
 		width = 20
 	}
+	inFlight := m.inFlightLines()
 	tasks := append([]types.Task{}, m.tasks...)
-	if len(tasks) == 0 {
+	if len(tasks) == 0 && len(inFlight) == 0 {
 		return padLines([]string{dimStyle.Render("No tasks yet.")}, height)
 	}
 	sort.Slice(tasks, func(i, j int) bool {
@@ -2855,11 +4451,17 @@ func (m model) renderTaskActivity(width, height int) string {
 		wrapWidth = width
 	}
 	lines := make([]string, 0, height)
+	for _, line := range inFlight {
+		if len(lines) >= height {
+			break
+		}
+		lines = append(lines, ansi.Truncate(line, width, ""))
+	}
 	for _, task := range tasks {
 		if len(lines) >= height {
 			break
 		}
-		agent := taskTargetAgent(task)
+		agent := taskfmt.Agent(task)
 		if agent == "" {
 			agent = "unknown"
 		}
@@ -2875,21 +4477,40 @@ func (m model) renderTaskActivity(width, height int) string {
 	return padLines(lines, height)
 }
 
-func taskTargetAgent(task types.Task) string {
-	if task.Metadata != nil {
-		if value, ok := task.Metadata["targetAgent"].(string); ok && value != "" {
-			return value
-		}
+// inFlightLines lists agents still working on a send from this TUI, oldest first,
+// with the time elapsed since each started
+func (m model) inFlightLines() []string {
+	keys := make([]string, 0, len(m.streamStarted))
+	for key := range m.streamStarted {
+		keys = append(keys, key)
 	}
-	for _, msg := range task.History {
-		if msg.Metadata == nil {
-			continue
-		}
-		if value, ok := msg.Metadata["targetAgent"].(string); ok && value != "" {
-			return value
+	sort.Slice(keys, func(i, j int) bool {
+		return m.streamStarted[keys[i]].Before(m.streamStarted[keys[j]])
+	})
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		elapsed := formatElapsed(time.Since(m.streamStarted[key]))
+		line := lipgloss.NewStyle().Foreground(accentColor).Render(fmt.Sprintf("working %s  %s", elapsed, key))
+		if fraction, ok := m.streamProgress[key]; ok {
+			line += "  " + m.progressBar.ViewAs(fraction)
+		} else {
+			line += "  " + m.spinner.View()
 		}
+		lines = append(lines, line)
 	}
-	return ""
+	return lines
+}
+
+// formatElapsed renders a duration as mm:ss, or h:mm:ss past an hour
+func formatElapsed(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := int(d / time.Hour)
+	mins := int(d/time.Minute) % 60
+	secs := int(d/time.Second) % 60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, mins, secs)
+	}
+	return fmt.Sprintf("%02d:%02d", mins, secs)
 }
 
 func shortTaskID(id string) string {
@@ -2929,6 +4550,8 @@ func (m model) sendLogLines(wrapWidth int) []string {
 			lines = append(lines, confirmStyle.Render(label))
 		case "error":
 			lines = append(lines, errStyle.Render("Error"))
+		case "cli":
+			lines = append(lines, dimStyle.Render("Equivalent CLI command"))
 		default:
 			if label == "" {
 				label = "Agent"
@@ -2969,6 +4592,12 @@ func (m model) sendLogLines(wrapWidth int) []string {
 			lines = append(lines, "")
 		}
 	}
+	if m.sending && len(m.queuedTasks) > 0 {
+		for _, task := range m.queuedTasks {
+			lines = append(lines, dimStyle.Render(task.key+" ⏸ queued"))
+		}
+		lines = append(lines, "")
+	}
 
 	if m.sending {
 		if len(m.streamChannels) > 0 {
@@ -2979,8 +4608,13 @@ func (m model) sendLogLines(wrapWidth int) []string {
 					activeCount++
 				}
 			}
+			activeCount -= len(m.queuedTasks)
 			if activeCount > 0 {
-				lines = append(lines, dimStyle.Render(fmt.Sprintf("%s %d agent(s) active", m.spinner.View(), activeCount)))
+				status := fmt.Sprintf("%s %d agent(s) active", m.spinner.View(), activeCount)
+				if len(m.queuedTasks) > 0 {
+					status += fmt.Sprintf(", %d queued", len(m.queuedTasks))
+				}
+				lines = append(lines, dimStyle.Render(status))
 			}
 		} else if len(m.activeAgents) > 0 {
 			// Multi-agent mode (non-streaming fallback)
@@ -2993,6 +4627,9 @@ func (m model) sendLogLines(wrapWidth int) []string {
 			// Single agent mode
 			lines = append(lines, dimStyle.Render("Waiting for response "+m.spinner.View()))
 		}
+		if len(m.pendingSends) > 0 {
+			lines = append(lines, dimStyle.Render(fmt.Sprintf("%d message(s) queued until the current send finishes", len(m.pendingSends))))
+		}
 	}
 	if len(lines) > 0 && strings.TrimSpace(stripANSI(lines[len(lines)-1])) == "" {
 		lines = lines[:len(lines)-1]
@@ -3094,7 +4731,7 @@ func (m *model) seedSendLogFromTasks() {
 }
 
 func sendEntriesFromTask(task types.Task) []sendEntry {
-	agent := taskTargetAgent(task)
+	agent := taskfmt.Agent(task)
 	if agent == "" {
 		agent = "unknown"
 	}
@@ -3115,7 +4752,7 @@ func sendEntriesFromTask(task types.Task) []sendEntry {
 		})
 		break
 	}
-	responseText := strings.TrimSpace(extractTaskText(task))
+	responseText := strings.TrimSpace(taskfmt.Text(task))
 	if responseText != "" {
 		entries = append(entries, sendEntry{
 			Role:      "agent",
@@ -3183,7 +4820,7 @@ func stripANSI(input string) string {
 	return ansi.Strip(input)
 }
 
-func refreshAllCmd(caller *hub.LocalCaller) tea.Cmd {
+func refreshAllCmd(caller hub.Caller) tea.Cmd {
 	return tea.Batch(
 		func() tea.Msg { return refreshStartMsg{count: 3} },
 		fetchStatusCmd(caller),
@@ -3192,7 +4829,7 @@ func refreshAllCmd(caller *hub.LocalCaller) tea.Cmd {
 	)
 }
 
-func fetchStatusCmd(caller *hub.LocalCaller) tea.Cmd {
+func fetchStatusCmd(caller hub.Caller) tea.Cmd {
 	return func() tea.Msg {
 		resp, err := caller.Call(context.Background(), "hub/status", nil)
 		if err != nil {
@@ -3209,7 +4846,7 @@ func fetchStatusCmd(caller *hub.LocalCaller) tea.Cmd {
 	}
 }
 
-func fetchAgentsCmd(caller *hub.LocalCaller) tea.Cmd {
+func fetchAgentsCmd(caller hub.Caller) tea.Cmd {
 	return func() tea.Msg {
 		params, _ := json.Marshal(map[string]any{"includeHealth": true})
 		resp, err := caller.Call(context.Background(), "hub/agents/list", params)
@@ -3227,7 +4864,7 @@ func fetchAgentsCmd(caller *hub.LocalCaller) tea.Cmd {
 	}
 }
 
-func fetchTasksCmd(caller *hub.LocalCaller) tea.Cmd {
+func fetchTasksCmd(caller hub.Caller) tea.Cmd {
 	return func() tea.Msg {
 		params, _ := json.Marshal(map[string]any{"limit": 50, "offset": 0})
 		resp, err := caller.Call(context.Background(), "hub/tasks/list", params)
@@ -3245,7 +4882,7 @@ func fetchTasksCmd(caller *hub.LocalCaller) tea.Cmd {
 	}
 }
 
-func sendCmd(caller *hub.LocalCaller, agent, message, contextID string) tea.Cmd {
+func sendCmd(caller hub.Caller, agent, message, contextID string) tea.Cmd {
 	return func() tea.Msg {
 		msg := types.Message{
 			Kind:      "message",
@@ -3268,9 +4905,10 @@ func sendCmd(caller *hub.LocalCaller, agent, message, contextID string) tea.Cmd
 		}
 		var task types.Task
 		if err := decodeResult(resp.Result, &task); err == nil {
-			text := extractTaskText(task)
+			text := taskfmt.Text(task)
 			entry := responseEntry{
 				TaskID:    task.ID,
+				ContextID: task.ContextID,
 				Agent:     agent,
 				Text:      text,
 				Timestamp: time.Now().UTC().Format(time.RFC3339),
@@ -3281,19 +4919,6 @@ func sendCmd(caller *hub.LocalCaller, agent, message, contextID string) tea.Cmd
 	}
 }
 
-func extractTaskText(task types.Task) string {
-	if task.Status.Message == nil {
-		return string(task.Status.State)
-	}
-	parts := make([]string, 0, len(task.Status.Message.Parts))
-	for _, part := range task.Status.Message.Parts {
-		if part.Kind == "text" {
-			parts = append(parts, part.Text)
-		}
-	}
-	return strings.TrimSpace(strings.Join(parts, "\n"))
-}
-
 func decodeResult(input any, target any) error {
 	data, err := json.Marshal(input)
 	if err != nil {
@@ -3302,20 +4927,21 @@ func decodeResult(input any, target any) error {
 	return json.Unmarshal(data, target)
 }
 
-func tickCmd() tea.Cmd {
-	return tea.Tick(5*time.Second, func(t time.Time) tea.Msg {
-		return tickMsg(t)
+func tickCmd(interval time.Duration, gen int) tea.Cmd {
+	return tea.Tick(interval, func(time.Time) tea.Msg {
+		return tickMsg{gen: gen}
 	})
 }
 
-// parseMentions parses @agent mentions from text
-// Single agent: "@vibe say something to @gemini" -> {"vibe": "say something to @gemini"}
-// Broadcast: "@claude @gemini fix this" -> {"claude": "fix this", "gemini": "fix this"}
-// Multi-agent: "@claude write API, @gemini write UI" -> {"claude": "write API", "gemini": "write UI"}
-// Multi-agent: "@claude task1 and @gemini task2" -> {"claude": "task1", "gemini": "task2"}
-func parseMentions(text string) map[string]string {
-	text = strings.TrimSpace(text)
-	result := make(map[string]string)
+// parseMentions parses @agent mentions from text, in order
+// Single agent: "@vibe say something to @gemini" -> [vibe: "say something to @gemini"]
+// Broadcast: "@claude @gemini fix this" -> [claude: "fix this", gemini: "fix this"]
+// Multi-agent: "@claude write API, @gemini write UI" -> [claude: "write API", gemini: "write UI"]
+// Multi-agent: "@claude task1 and @gemini task2" -> [claude: "task1", gemini: "task2"]
+// Repeated agent: "@claude do X, @claude do Y" -> [claude: "do X", claude: "do Y"]
+func parseMentions(text string) []mention {
+	text = trimTrailingMention(strings.TrimSpace(text))
+	var result []mention
 
 	// Broadcast pattern: @agent1 @agent2 ... message (same message to multiple agents)
 	// Pattern matches one or more @mentions followed by non-@mention text
@@ -3327,16 +4953,22 @@ func parseMentions(text string) map[string]string {
 		// Extract all agent IDs from the agents part
 		agentMatches := regexp.MustCompile(`@(\w+)`).FindAllStringSubmatch(agentsPart, -1)
 		if len(agentMatches) > 1 && message != "" {
-			// Multiple agents with shared message (broadcast)
+			// Multiple agents with shared message (broadcast); naming an agent twice sends once
+			seen := make(map[string]bool)
 			for _, a := range agentMatches {
-				result[strings.ToLower(a[1])] = message
+				agentID := strings.ToLower(a[1])
+				if seen[agentID] {
+					continue
+				}
+				seen[agentID] = true
+				result = append(result, mention{AgentID: agentID, Task: message})
 			}
 			return result
 		}
 	}
 
 	// Check for consecutive @mentions with no message (e.g., "@a @b" or "@a @b @c")
-	// Return empty map - no action without a message
+	// Return nothing - no action without a message
 	onlyMentionsPattern := regexp.MustCompile(`^(?:@\w+\s*)+$`)
 	if onlyMentionsPattern.MatchString(text) {
 		return result
@@ -3349,8 +4981,7 @@ func parseMentions(text string) map[string]string {
 		task := strings.TrimSpace(match[2])
 		// Check if task contains other @mentions with their own tasks (multi-agent pattern)
 		if !containsValidMultiMention(task) {
-			result[agentID] = task
-			return result
+			return append(result, mention{AgentID: agentID, Task: task})
 		}
 	}
 
@@ -3360,38 +4991,60 @@ func parseMentions(text string) map[string]string {
 	for _, part := range parts {
 		part = strings.TrimSpace(part)
 		if match := regexp.MustCompile(`^@(\w+)\s+(.+)$`).FindStringSubmatch(part); len(match) == 3 {
-			result[strings.ToLower(match[1])] = strings.TrimSpace(match[2])
+			result = append(result, mention{AgentID: strings.ToLower(match[1]), Task: strings.TrimSpace(match[2])})
 		}
 	}
 	return result
 }
 
 // containsValidMultiMention checks if text has pattern like ", @agent task" or " and @agent task"
+// outside of quoted spans
 func containsValidMultiMention(text string) bool {
 	// Look for ", @word word+" or " and @word word+"
 	pattern := regexp.MustCompile(`(?:,\s*|\s+and\s+)@\w+\s+\S`)
-	return pattern.MatchString(text)
+	return pattern.MatchString(maskQuoted(text))
 }
 
-// splitMentionsByDelimiters splits text on ", @" or " and @" while keeping the @
+// splitMentionsByDelimiters splits text on ", @" or " and @" while keeping the @.
+// Delimiters inside quoted spans are ignored.
 func splitMentionsByDelimiters(text string) []string {
-	// Replace ", @" and " and @" with a unique delimiter, keeping the @
-	text = regexp.MustCompile(`,\s*@`).ReplaceAllString(text, "\x00@")
-	text = regexp.MustCompile(`\s+and\s+@`).ReplaceAllString(text, "\x00@")
-	return strings.Split(text, "\x00")
+	masked := maskQuoted(text)
+	var cuts [][]int
+	cuts = append(cuts, regexp.MustCompile(`,\s*@`).FindAllStringIndex(masked, -1)...)
+	cuts = append(cuts, regexp.MustCompile(`\s+and\s+@`).FindAllStringIndex(masked, -1)...)
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i][0] < cuts[j][0] })
+	var parts []string
+	start := 0
+	for _, cut := range cuts {
+		if cut[0] < start {
+			continue
+		}
+		parts = append(parts, text[start:cut[0]])
+		start = cut[1] - 1 // keep the @
+	}
+	return append(parts, text[start:])
+}
+
+// trimTrailingMention drops a dangling ", @agent" or " and @agent" that has no task
+func trimTrailingMention(text string) string {
+	loc := regexp.MustCompile(`(?:,\s*|\s+and\s+)@\w+\s*$`).FindStringIndex(maskQuoted(text))
+	if loc == nil {
+		return text
+	}
+	return strings.TrimSpace(text[:loc[0]])
 }
 
 // formatMentionsSummary creates a display summary of multi-agent tasks
-func formatMentionsSummary(mentions map[string]string) string {
+func formatMentionsSummary(mentions []mention) string {
 	var parts []string
-	for agentID, task := range mentions {
-		parts = append(parts, fmt.Sprintf("@%s: %s", agentID, task))
+	for _, mention := range mentions {
+		parts = append(parts, fmt.Sprintf("@%s: %s", mention.AgentID, mention.Task))
 	}
 	return strings.Join(parts, "\n")
 }
 
 // sendToAgentCmd creates a command that sends a task to a specific agent (non-streaming fallback)
-func sendToAgentCmd(caller *hub.LocalCaller, agentID, taskText, contextID string) tea.Cmd {
+func sendToAgentCmd(caller hub.Caller, agentID, taskText, contextID string) tea.Cmd {
 	return func() tea.Msg {
 		msg := types.Message{
 			Kind:      "message",
@@ -3416,12 +5069,204 @@ func sendToAgentCmd(caller *hub.LocalCaller, agentID, taskText, contextID string
 		if err := decodeResult(resp.Result, &task); err != nil {
 			return agentResultMsg{agentID: agentID, err: err}
 		}
-		return agentResultMsg{agentID: agentID, text: extractTaskText(task)}
+		return agentResultMsg{agentID: agentID, text: taskfmt.Text(task)}
+	}
+}
+
+// streamCmd runs an agent in-process when the server is embedded, or through
+// message/send on the attached hub otherwise
+func (m *model) streamCmd(agentID, message, contextID string, stream *AgentStream) tea.Cmd {
+	stream.interactive = m.agentTakesInput(agentID)
+	stream.contextID = contextID
+	if m.server == nil {
+		return remoteStreamCmd(m.caller, agentID, message, contextID, stream)
+	}
+	var metadata map[string]any
+	if m.streamMode != "" {
+		metadata = map[string]any{"stream": m.streamMode == "on"}
+	}
+	return startStreamingCmd(m.server, agentID, message, contextID, metadata, stream)
+}
+
+// agentTakesInput reports whether a send to agentID will stream with an input channel:
+// an embedded hub, streaming not turned off, and an agent that supports streaming
+func (m *model) agentTakesInput(agentID string) bool {
+	if m.server == nil || m.streamMode == "off" {
+		return false
+	}
+	info, ok := m.server.Registry().Get(agentID)
+	if !ok {
+		return false
+	}
+	_, streams := info.Agent.(types.StreamingExecutor)
+	return streams && info.Agent.GetCapabilities().SupportsStreaming
+}
+
+// remoteStreamCmd sends a message over RPC and replays the result as stream events
+func remoteStreamCmd(caller hub.Caller, agentID, message, contextID string, stream *AgentStream) tea.Cmd {
+	return func() tea.Msg {
+		go func() {
+			defer close(stream.Output)
+			msg := sendToAgentCmd(caller, agentID, message, contextID)()
+			result, _ := msg.(agentResultMsg)
+			if result.err != nil {
+				stream.Output <- types.StreamEvent{Kind: "error", Text: result.err.Error(), AgentID: agentID, Timestamp: time.Now().UTC()}
+				return
+			}
+			stream.Output <- types.StreamEvent{Kind: "output", Text: result.text, AgentID: agentID, Timestamp: time.Now().UTC()}
+			stream.Output <- types.StreamEvent{Kind: "complete", AgentID: agentID, Timestamp: time.Now().UTC()}
+		}()
+		return nil
+	}
+}
+
+// pruneTasksCmd asks the hub to drop finished tasks older than olderThan
+// (the hub's --task-ttl when empty)
+func pruneTasksCmd(caller hub.Caller, olderThan string) tea.Cmd {
+	return func() tea.Msg {
+		var params []byte
+		if olderThan != "" {
+			params, _ = json.Marshal(map[string]any{"olderThan": olderThan})
+		}
+		resp, err := caller.Call(context.Background(), "hub/tasks/prune", params)
+		if err != nil {
+			return errMsg{err: err, source: "prune"}
+		}
+		if resp.Error != nil {
+			return errMsg{err: errors.New(resp.Error.Message), source: "prune"}
+		}
+		var result pruneResultMsg
+		if err := decodeResult(resp.Result, &result); err != nil {
+			return errMsg{err: err, source: "prune"}
+		}
+		return result
+	}
+}
+
+// reloadSettingsCmd has the hub re-read settings.json
+func reloadSettingsCmd(caller hub.Caller) tea.Cmd {
+	return func() tea.Msg {
+		resp, err := caller.Call(context.Background(), "hub/settings/reload", nil)
+		if err != nil {
+			return errMsg{err: err, source: "reload"}
+		}
+		if resp.Error != nil {
+			return errMsg{err: errors.New(resp.Error.Message), source: "reload"}
+		}
+		var result settingsReloadedMsg
+		if err := decodeResult(resp.Result, &result); err != nil {
+			return errMsg{err: err, source: "reload"}
+		}
+		return result
+	}
+}
+
+// deadLettersCmd fetches the most recent dead letters from the hub
+func deadLettersCmd(caller hub.Caller) tea.Cmd {
+	return func() tea.Msg {
+		params, _ := json.Marshal(map[string]any{"limit": 50})
+		resp, err := caller.Call(context.Background(), "hub/tasks/deadletters", params)
+		if err != nil {
+			return errMsg{err: err, source: "deadletters"}
+		}
+		if resp.Error != nil {
+			return errMsg{err: errors.New(resp.Error.Message), source: "deadletters"}
+		}
+		var letters []hub.DeadLetter
+		if err := decodeResult(resp.Result, &letters); err != nil {
+			return errMsg{err: err, source: "deadletters"}
+		}
+		return deadLettersMsg{letters: letters}
+	}
+}
+
+// replayCmd sends a context's user turns to agentID via message/replay; the answer
+// lands in the History tab like any other response
+func replayCmd(caller hub.Caller, contextID, agentID string) tea.Cmd {
+	return func() tea.Msg {
+		params, _ := json.Marshal(map[string]any{"contextId": contextID, "targetAgent": agentID})
+		resp, err := caller.Call(context.Background(), "message/replay", params)
+		if err != nil {
+			return errMsg{err: err, source: "replay"}
+		}
+		if resp.Error != nil {
+			return errMsg{err: errors.New(resp.Error.Message), source: "replay"}
+		}
+		var task types.Task
+		if err := decodeResult(resp.Result, &task); err != nil {
+			return errMsg{err: err, source: "replay"}
+		}
+		return sendResultMsg{entry: responseEntry{
+			TaskID:    task.ID,
+			ContextID: task.ContextID,
+			Agent:     agentID,
+			Text:      taskfmt.Text(task),
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		}}
+	}
+}
+
+func describeAgentCmd(caller hub.Caller, agentID string) tea.Cmd {
+	return func() tea.Msg {
+		params, _ := json.Marshal(map[string]string{"agentId": agentID})
+		resp, err := caller.Call(context.Background(), "hub/agents/get", params)
+		if err != nil {
+			return errMsg{err: err, source: "describe"}
+		}
+		if resp.Error != nil {
+			return errMsg{err: errors.New(resp.Error.Message), source: "describe"}
+		}
+		var result agentDescription
+		if err := decodeResult(resp.Result, &result); err != nil {
+			return errMsg{err: err, source: "describe"}
+		}
+		return describeMsg{agent: result}
+	}
+}
+
+func agentDataFromInfos(infos []hub.AgentInfo) []agentData {
+	out := make([]agentData, 0, len(infos))
+	for _, info := range infos {
+		out = append(out, agentData{ID: info.Agent.ID(), Name: info.Agent.Name(), Card: info.Card, Health: info.Health})
+	}
+	return out
+}
+
+// defaultAgentID picks the agent preselected on the Send tab: the orchestrator when it is
+// registered, otherwise the first healthy agent, otherwise the first agent.
+func defaultAgentID(agents []agentData) string {
+	for _, agent := range agents {
+		if agent.ID == "orchestrator" {
+			return agent.ID
+		}
+	}
+	for _, agent := range agents {
+		if agent.Health.Status == "healthy" {
+			return agent.ID
+		}
+	}
+	if len(agents) > 0 {
+		return agents[0].ID
+	}
+	return ""
+}
+
+// agentRegistered reports whether id names a registered agent
+func (m *model) agentRegistered(id string) bool {
+	id = strings.TrimSpace(id)
+	for _, registered := range m.getAgentIDs() {
+		if registered == id {
+			return true
+		}
 	}
+	return false
 }
 
-// startStreamingCmd starts a streaming execution for an agent
-func startStreamingCmd(server *hub.Server, agentID, message, contextID string, stream *AgentStream) tea.Cmd {
+// startStreamingCmd runs an agent in the background, feeding stream. Streaming-capable
+// agents stream unless the message metadata sets "stream": false, which forces a single
+// Execute result; "stream": true can't make other agents stream, so their result comes
+// after a note saying so.
+func startStreamingCmd(server *hub.Server, agentID, message, contextID string, metadata map[string]any, stream *AgentStream) tea.Cmd {
 	return func() tea.Msg {
 		info, ok := server.Registry().Get(agentID)
 		if !ok {
@@ -3430,31 +5275,66 @@ func startStreamingCmd(server *hub.Server, agentID, message, contextID string, s
 			return nil
 		}
 
+		userMessage := types.Message{Kind: "message", Role: "user", Parts: []types.Part{{Kind: "text", Text: message}}, Metadata: metadata}
 		workingDir, _ := os.Getwd()
+		override, err := server.CheckAgentDirs(agentID, userMessage)
+		if override != "" {
+			workingDir = override
+		}
+		if err == nil {
+			err = server.CheckWorkingDir(agentID, workingDir)
+		}
+		if err != nil {
+			stream.Output <- types.StreamEvent{Kind: "error", Text: err.Error(), AgentID: agentID, Timestamp: time.Now().UTC()}
+			close(stream.Output)
+			return nil
+		}
 		ctx := types.ExecutionContext{
 			TaskID:      utils.NewID("task"),
 			ContextID:   contextID, // use shared context for cross-agent history
-			UserMessage: types.Message{Kind: "message", Role: "user", Parts: []types.Part{{Kind: "text", Text: message}}},
+			UserMessage: userMessage,
 			WorkingDir:  workingDir,
 		}
+		// Keep the hub's context history the way message/send does, so follow-ups see
+		// earlier turns; the reply is added when the stream completes (recordReply)
+		if contextID != "" {
+			ctx.PreviousHistory = server.Contexts().GetHistoryWithLimit(contextID, followUpHistoryLength)
+			turn := ctx.UserMessage
+			turn.ContextID = contextID
+			turn.Metadata = map[string]any{"targetAgent": agentID}
+			_ = server.Contexts().AddMessage(contextID, turn)
+		}
 
 		// Check if agent supports streaming
-		if streamer, ok := info.Agent.(types.StreamingExecutor); ok {
+		streamer, ok := info.Agent.(types.StreamingExecutor)
+		force, set := metadata["stream"].(bool)
+		if set && !force {
+			ok = false
+		}
+		if set && force && !ok {
+			stream.Output <- types.StreamEvent{Kind: "output", Text: fmt.Sprintf("note: %s can't stream; showing its single result when it finishes", agentID), AgentID: agentID, Timestamp: time.Now().UTC()}
+		}
+		output := stream.Output
+		if changes := server.CaptureRun(agentID, workingDir); changes != nil {
+			output = make(chan types.StreamEvent, cap(stream.Output))
+			go relayWithChanges(output, stream.Output, agentID, changes)
+		}
+		if ok {
 			go func() {
-				defer close(stream.Output)
-				_ = streamer.ExecuteStreaming(ctx, stream.Output, stream.Input)
+				defer close(output)
+				_ = streamer.ExecuteStreaming(ctx, output, stream.Input)
 			}()
 		} else {
 			// Fallback: run non-streaming and emit single result
 			go func() {
-				defer close(stream.Output)
+				defer close(output)
 				result, err := info.Agent.Execute(ctx)
 				if err != nil {
-					stream.Output <- types.StreamEvent{Kind: "error", Text: err.Error(), AgentID: agentID, Timestamp: time.Now().UTC()}
+					output <- types.StreamEvent{Kind: "error", Text: err.Error(), AgentID: agentID, Timestamp: time.Now().UTC()}
 				} else {
-					text := extractTaskText(result.Task)
-					stream.Output <- types.StreamEvent{Kind: "output", Text: text, AgentID: agentID, Timestamp: time.Now().UTC()}
-					stream.Output <- types.StreamEvent{Kind: "complete", AgentID: agentID, Timestamp: time.Now().UTC()}
+					text := taskfmt.Text(result.Task)
+					output <- types.StreamEvent{Kind: "output", Text: text, AgentID: agentID, Timestamp: time.Now().UTC()}
+					output <- types.StreamEvent{Kind: "complete", AgentID: agentID, Timestamp: time.Now().UTC()}
 				}
 			}()
 		}
@@ -3462,27 +5342,47 @@ func startStreamingCmd(server *hub.Server, agentID, message, contextID string, s
 	}
 }
 
-// listenAgentStream listens for events from an agent's output channel
-func listenAgentStream(agentID string, ch <-chan types.StreamEvent) tea.Cmd {
+// relayWithChanges forwards a captured run's events and, just before it completes,
+// adds a line listing the files the run changed, as message/send does with an artifact
+func relayWithChanges(events <-chan types.StreamEvent, out chan<- types.StreamEvent, agentID string, changes func() []hub.FileChange) {
+	defer close(out)
+	for event := range events {
+		if event.Kind == "complete" {
+			if files := changes(); len(files) > 0 {
+				listed := make([]string, 0, len(files))
+				for _, file := range files {
+					listed = append(listed, file.Path+" ("+file.Change+")")
+				}
+				out <- types.StreamEvent{Kind: "output", Text: "changed files: " + strings.Join(listed, ", "), AgentID: agentID, Timestamp: time.Now().UTC()}
+			}
+		}
+		out <- event
+	}
+}
+
+// listenServerLogs waits for the next server log line
+func listenServerLogs(ch <-chan utils.LogEntry) tea.Cmd {
+	if ch == nil {
+		return nil
+	}
 	return func() tea.Msg {
-		event, ok := <-ch
+		entry, ok := <-ch
 		if !ok {
-			return streamEventMsg{agentID: agentID, event: types.StreamEvent{Kind: "complete", AgentID: agentID}}
+			return nil
 		}
-		return streamEventMsg{agentID: agentID, event: event}
+		return serverLogMsg{entry: entry}
 	}
 }
 
-// listenAllStreams returns a batch command to listen on all active streams
-func (m *model) listenAllStreams() tea.Cmd {
-	var cmds []tea.Cmd
-	for agentID, stream := range m.streamChannels {
-		if !stream.Done {
-			cmds = append(cmds, listenAgentStream(agentID, stream.Output))
+// listenAgentStream waits for the next event on an agent's output channel. The
+// goroutines behind a stream only write to its channels; all model state is
+// updated in Update when the resulting streamEventMsg arrives.
+func listenAgentStream(agentID string, stream *AgentStream) tea.Cmd {
+	return func() tea.Msg {
+		event, ok := <-stream.Output
+		if !ok {
+			return streamEventMsg{agentID: agentID, stream: stream, event: types.StreamEvent{Kind: "complete", AgentID: agentID}, closed: true}
 		}
+		return streamEventMsg{agentID: agentID, stream: stream, event: event}
 	}
-	if len(cmds) == 0 {
-		return nil
-	}
-	return tea.Batch(cmds...)
 }
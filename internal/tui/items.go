@@ -2,29 +2,45 @@ package tui
 
 import (
 	"fmt"
+	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/list"
 
+	"agents-hub/internal/hub"
+	"agents-hub/internal/taskfmt"
 	"agents-hub/internal/types"
 )
 
 type agentItem struct {
-	data agentData
+	data   agentData
+	pinned int // 1-based quick-pick number, 0 when not pinned
 }
 
-func (i agentItem) Title() string { return i.data.ID }
+func (i agentItem) Title() string {
+	if i.pinned > 0 {
+		return fmt.Sprintf("%s [%d]", i.data.ID, i.pinned)
+	}
+	return i.data.ID
+}
 func (i agentItem) Description() string {
 	return fmt.Sprintf("%s - %s", i.data.Name, i.data.Health.Status)
 }
 func (i agentItem) FilterValue() string { return i.data.ID + " " + i.data.Name }
 
 type taskItem struct {
-	data types.Task
+	data  types.Task
+	child bool // delegated by an orchestrator task listed just above it
 }
 
-func (i taskItem) Title() string { return i.data.ID }
+func (i taskItem) Title() string {
+	if i.child {
+		return "└ " + i.data.ID
+	}
+	return i.data.ID
+}
 func (i taskItem) Description() string {
 	return fmt.Sprintf("%s - %s", i.data.Status.State, i.data.ContextID)
 }
@@ -32,6 +48,7 @@ func (i taskItem) FilterValue() string { return i.data.ID + " " + i.data.Context
 
 type responseEntry struct {
 	TaskID    string
+	ContextID string
 	Agent     string
 	Text      string
 	Timestamp string
@@ -49,22 +66,62 @@ func (i responseItem) Description() string {
 }
 func (i responseItem) FilterValue() string { return i.data.Agent + " " + i.data.TaskID }
 
-func buildAgentItems(in []agentData) []list.Item {
+// buildAgentItems lists pinned agents first, in pin order
+func buildAgentItems(in []agentData, pinned []string) []list.Item {
 	items := make([]list.Item, 0, len(in))
+	for i, id := range pinned {
+		for _, agent := range in {
+			if agent.ID == id {
+				items = append(items, agentItem{data: agent, pinned: i + 1})
+				break
+			}
+		}
+	}
 	for _, agent := range in {
-		items = append(items, agentItem{data: agent})
+		if !slices.Contains(pinned, agent.ID) {
+			items = append(items, agentItem{data: agent})
+		}
 	}
 	return items
 }
 
+// buildTaskItems lists each orchestrator task with its sub-tasks right below it.
+// Sub-tasks whose parent isn't in the list stay where they are.
 func buildTaskItems(in []types.Task) []list.Item {
+	present := make(map[string]bool, len(in))
+	for _, task := range in {
+		present[task.ID] = true
+	}
+	children := make(map[string][]types.Task)
+	for _, task := range in {
+		if parent := taskfmt.ParentID(task); parent != "" && present[parent] {
+			children[parent] = append(children[parent], task)
+		}
+	}
 	items := make([]list.Item, 0, len(in))
 	for _, task := range in {
+		if parent := taskfmt.ParentID(task); parent != "" && present[parent] {
+			continue
+		}
 		items = append(items, taskItem{data: task})
+		for _, child := range children[task.ID] {
+			items = append(items, taskItem{data: child, child: true})
+		}
 	}
 	return items
 }
 
+// subTasks returns the tasks delegated by parent
+func subTasks(tasks []types.Task, parent string) []types.Task {
+	var result []types.Task
+	for _, task := range tasks {
+		if taskfmt.ParentID(task) == parent {
+			result = append(result, task)
+		}
+	}
+	return result
+}
+
 func buildResponseItems(in []responseEntry) []list.Item {
 	items := make([]list.Item, 0, len(in))
 	for _, entry := range in {
@@ -88,18 +145,122 @@ func renderAgentDetail(agent agentData) string {
 		fmt.Sprintf("Version: %s", agent.Card.Version),
 		fmt.Sprintf("URL: %s", agent.Card.URL),
 	}
+	if agent.LastWorkingDir != "" {
+		lines = append(lines, "", fmt.Sprintf("Last working dir: %s", agent.LastWorkingDir))
+	}
 	return strings.Join(lines, "\n")
 }
 
-func renderTaskDetail(task types.Task) string {
+func renderAgentDescription(agent agentDescription) string {
 	lines := []string{
-		fmt.Sprintf("ID: %s", task.ID),
-		fmt.Sprintf("State: %s", task.Status.State),
-		fmt.Sprintf("Context: %s", task.ContextID),
-		fmt.Sprintf("Timestamp: %s", task.Status.Timestamp),
+		fmt.Sprintf("ID: %s", agent.ID),
+		fmt.Sprintf("Name: %s", agent.Name),
+		fmt.Sprintf("Health: %s", agent.Health.Status),
+	}
+	if agent.Card.Description != "" {
+		lines = append(lines, "", agent.Card.Description)
+	}
+
+	caps := agent.Capabilities
+	lines = append(lines,
+		"",
+		"Capabilities:",
+		fmt.Sprintf("  Streaming: %t", caps.SupportsStreaming),
+		fmt.Sprintf("  Cancellation: %t", caps.SupportsCancellation),
+		fmt.Sprintf("  Max concurrent tasks: %d", caps.MaxConcurrentTasks),
+		fmt.Sprintf("  Input modes: %s", joinOrNone(caps.SupportedInputModes)),
+		fmt.Sprintf("  Output modes: %s", joinOrNone(caps.SupportedOutputModes)),
 		"",
-		"Response:",
-		extractTaskText(task),
+		fmt.Sprintf("Skills (%d):", len(agent.Card.Skills)),
+	)
+	if len(agent.Card.Skills) == 0 {
+		lines = append(lines, "  none")
+	}
+	for _, skill := range agent.Card.Skills {
+		lines = append(lines, fmt.Sprintf("  %s - %s", skill.ID, skill.Name))
+		if skill.Description != "" {
+			lines = append(lines, "    "+skill.Description)
+		}
+		if len(skill.Tags) > 0 {
+			lines = append(lines, "    tags: "+strings.Join(skill.Tags, ", "))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// itemKey identifies a list item across rebuilds of its list
+func itemKey(item list.Item) string {
+	switch item := item.(type) {
+	case agentItem:
+		return item.data.ID
+	case taskItem:
+		return item.data.ID
+	case responseItem:
+		return item.data.TaskID + "@" + item.data.Timestamp
+	}
+	return ""
+}
+
+func joinOrNone(items []string) string {
+	if len(items) == 0 {
+		return "none"
+	}
+	return strings.Join(items, ", ")
+}
+
+func routeUnhealthyLabel(enabled bool) string {
+	if enabled {
+		return "routed to"
+	}
+	return "skipped"
+}
+
+func strictDelegatesLabel(strict bool) string {
+	if strict {
+		return "dropped"
+	}
+	return "routed to"
+}
+
+// renderDeadLetters lists sends that failed every attempt, newest first
+func renderDeadLetters(letters []hub.DeadLetter) string {
+	if len(letters) == 0 {
+		return "No dead letters."
+	}
+	lines := []string{fmt.Sprintf("Dead letters (%d, newest first)", len(letters))}
+	for _, letter := range letters {
+		lines = append(lines,
+			"",
+			fmt.Sprintf("%s  %s  %d attempt(s)  %s", letter.TaskID, letter.AgentID, letter.Attempts, letter.FailedAt),
+			"  prompt: "+previewText(letter.Prompt, 120),
+			"  error:  "+previewText(letter.Error, 240),
+		)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderDirListing lists a task's working directory, directories first
+func renderDirListing(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Sprintf("Cannot list %s: %v", dir, err)
+	}
+	lines := []string{fmt.Sprintf("%s (%d entries; set $EDITOR to open it)", dir, len(entries)), ""}
+	slices.SortStableFunc(entries, func(a, b os.DirEntry) int {
+		if a.IsDir() != b.IsDir() {
+			if a.IsDir() {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Name(), b.Name())
+	})
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		lines = append(lines, "  "+name)
 	}
 	return strings.Join(lines, "\n")
 }
@@ -109,9 +270,11 @@ func renderResponseDetail(entry responseEntry) string {
 		fmt.Sprintf("Task: %s", entry.TaskID),
 		fmt.Sprintf("Agent: %s", entry.Agent),
 		fmt.Sprintf("Timestamp: %s", entry.Timestamp),
-		"",
-		entry.Text,
 	}
+	if entry.ContextID != "" {
+		lines = append(lines, fmt.Sprintf("Context: %s (/replay <agent> to rerun it elsewhere)", entry.ContextID))
+	}
+	lines = append(lines, "", entry.Text)
 	return strings.Join(lines, "\n")
 }
 
@@ -1,30 +1,42 @@
 package tui
 
-import "github.com/charmbracelet/bubbles/key"
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
 
 type keyMap struct {
-	NextTab key.Binding
-	PrevTab key.Binding
-	Up      key.Binding
-	Down    key.Binding
-	Refresh key.Binding
-	Quit    key.Binding
-	Help    key.Binding
-	Command key.Binding
-	Search  key.Binding
-	Logs    key.Binding
-	Send    key.Binding
-	Screen  key.Binding
+	NextTab  key.Binding
+	PrevTab  key.Binding
+	Up       key.Binding
+	Down     key.Binding
+	Refresh  key.Binding
+	Quit     key.Binding
+	Help     key.Binding
+	Command  key.Binding
+	Search   key.Binding
+	Logs     key.Binding
+	Send     key.Binding
+	Screen   key.Binding
+	Pause    key.Binding
+	Markdown key.Binding
+	Editor   key.Binding
 }
 
 func (k keyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Command, k.Search, k.Send, k.Refresh, k.Logs, k.Screen, k.Help, k.Quit}
+	return []key.Binding{k.Command, k.Search, k.Send, k.Refresh, k.Pause, k.Logs, k.Screen, k.Help, k.Quit}
 }
 
 func (k keyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{k.Up, k.Down},
-		{k.Command, k.Search, k.Send, k.Refresh, k.Logs, k.Screen, k.Help, k.Quit},
+		{k.Command, k.Search, k.Send, k.Refresh, k.Pause, k.Markdown, k.Editor, k.Logs, k.Screen, k.Help, k.Quit},
 	}
 }
 
@@ -77,4 +89,110 @@ var defaultKeyMap = keyMap{
 		key.WithKeys("ctrl+g"),
 		key.WithHelp("ctrl+g", "screen"),
 	),
+	Pause: key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("p", "pause refresh"),
+	),
+	Markdown: key.NewBinding(
+		key.WithKeys("m"),
+		key.WithHelp("m", "markdown"),
+	),
+	Editor: key.NewBinding(
+		key.WithKeys("e"),
+		key.WithHelp("e", "open task dir"),
+	),
+}
+
+// keybindingsFile lives in the data dir and maps actions to keys, e.g.
+// {"send": "ctrl+s", "logs": ["L", "ctrl+l"]}
+const keybindingsFile = "keybindings.json"
+
+// remappable returns the bindings a keybindings file may override, by action name.
+// Navigation inside lists and inputs uses the widgets' own keys and isn't listed.
+func (k *keyMap) remappable() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"refresh":  &k.Refresh,
+		"quit":     &k.Quit,
+		"help":     &k.Help,
+		"command":  &k.Command,
+		"search":   &k.Search,
+		"logs":     &k.Logs,
+		"send":     &k.Send,
+		"screen":   &k.Screen,
+		"pause":    &k.Pause,
+		"markdown": &k.Markdown,
+		"editor":   &k.Editor,
+	}
+}
+
+// loadKeyMap applies keybindings.json from dataDir over defaultKeyMap. Unknown actions
+// are skipped; if the result binds one key to two actions the defaults are kept.
+// Problems are returned as warnings rather than failing startup.
+func loadKeyMap(dataDir string) (keyMap, []string) {
+	km := defaultKeyMap
+	data, err := os.ReadFile(filepath.Join(dataDir, keybindingsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return km, nil
+		}
+		return km, []string{"keybindings: " + err.Error()}
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return km, []string{"keybindings: " + err.Error()}
+	}
+
+	var warnings []string
+	bindings := km.remappable()
+	for action, value := range raw {
+		binding, ok := bindings[action]
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("keybindings: unknown action %q", action))
+			continue
+		}
+		keys, err := parseKeys(value)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("keybindings: %s: %v", action, err))
+			continue
+		}
+		*binding = key.NewBinding(key.WithKeys(keys...), key.WithHelp(strings.Join(keys, "/"), binding.Help().Desc))
+	}
+
+	actions := make([]string, 0, len(bindings))
+	for action := range bindings {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	owner := make(map[string]string)
+	for _, action := range actions {
+		for _, k := range bindings[action].Keys() {
+			if other, taken := owner[k]; taken {
+				return defaultKeyMap, append(warnings, fmt.Sprintf("keybindings: %q is bound to both %s and %s; using defaults", k, other, action))
+			}
+			owner[k] = action
+		}
+	}
+	return km, warnings
+}
+
+// parseKeys accepts a single key string or a list of them
+func parseKeys(value json.RawMessage) ([]string, error) {
+	var keys []string
+	if err := json.Unmarshal(value, &keys); err != nil {
+		var single string
+		if err := json.Unmarshal(value, &single); err != nil {
+			return nil, fmt.Errorf("expected a key or a list of keys")
+		}
+		keys = []string{single}
+	}
+	out := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if k = strings.TrimSpace(k); k != "" {
+			out = append(out, k)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no keys given")
+	}
+	return out, nil
 }
@@ -0,0 +1,38 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+)
+
+// markdownMargin is the horizontal padding glamour's standard styles add around the text
+const markdownMargin = 4
+
+// renderMarkdown renders agent output as markdown sized to the detail pane. It falls
+// back to the plain text when rendering fails. The last result is cached because the
+// detail pane is refreshed far more often than the selection changes.
+func (m *model) renderMarkdown(text string) string {
+	leftWidth, rightWidth, _, stacked := m.paneSizes()
+	width := rightWidth
+	if stacked {
+		width = leftWidth
+	}
+	key := fmt.Sprintf("%d\x00%s", width, text)
+	if key == m.markdownKey {
+		return m.markdownOut
+	}
+	// A fixed style: auto-detection queries the terminal, which races with bubbletea
+	renderer, err := glamour.NewTermRenderer(glamour.WithStandardStyle("dark"), glamour.WithWordWrap(width-markdownMargin))
+	if err != nil {
+		return text
+	}
+	out, err := renderer.Render(text)
+	if err != nil {
+		return text
+	}
+	out = strings.Trim(out, "\n")
+	m.markdownKey, m.markdownOut = key, out
+	return out
+}
@@ -0,0 +1,39 @@
+package tui
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []mention
+	}{
+		{"single", "@vibe say hi to @gemini", []mention{{AgentID: "vibe", Task: "say hi to @gemini"}}},
+		{"broadcast", "@claude @gemini fix this", []mention{{AgentID: "claude", Task: "fix this"}, {AgentID: "gemini", Task: "fix this"}}},
+		{"comma", "@claude write API, @gemini write UI", []mention{{AgentID: "claude", Task: "write API"}, {AgentID: "gemini", Task: "write UI"}}},
+		{"and", "@codex implement auth and @vibe review it", []mention{{AgentID: "codex", Task: "implement auth"}, {AgentID: "vibe", Task: "review it"}}},
+		{"repeated agent", "@claude do X, @claude do Y", []mention{{AgentID: "claude", Task: "do X"}, {AgentID: "claude", Task: "do Y"}}},
+		{
+			"delimiters inside double quotes",
+			`@claude write "a, @b and @c" and @gemini test it`,
+			[]mention{{AgentID: "claude", Task: `write "a, @b and @c"`}, {AgentID: "gemini", Task: "test it"}},
+		},
+		{"delimiters inside single quotes", "@claude echo 'x, @y z'", []mention{{AgentID: "claude", Task: "echo 'x, @y z'"}}},
+		{"escaped comma", `@claude keep a\, @b c`, []mention{{AgentID: "claude", Task: `keep a\, @b c`}}},
+		{"apostrophe doesn't open a quote", "@claude don't stop, @gemini go", []mention{{AgentID: "claude", Task: "don't stop"}, {AgentID: "gemini", Task: "go"}}},
+		{"unterminated quote is ignored", `@claude say "hi, @gemini go`, []mention{{AgentID: "claude", Task: `say "hi`}, {AgentID: "gemini", Task: "go"}}},
+		{"dangling comma mention", "@claude fix the bug, @gemini", []mention{{AgentID: "claude", Task: "fix the bug"}}},
+		{"dangling and mention", "@claude fix the bug and @gemini ", []mention{{AgentID: "claude", Task: "fix the bug"}}},
+		{"mentions without a message", "@claude @gemini", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseMentions(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parseMentions(%q) = %#v, want %#v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
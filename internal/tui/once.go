@@ -0,0 +1,75 @@
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"agents-hub/internal/hub"
+	"agents-hub/internal/types"
+	"agents-hub/internal/utils"
+)
+
+// RunOnce sends a single message through the same streaming path as the Send modal,
+// without bubbletea, and prints the agent's output as it arrives. Lines read from
+// stdin answer the agent's prompts. It returns the
+// process exit code: 0 when the agent completed, 1 otherwise. Meant for smoke tests
+// in CI, where there is no terminal.
+func RunOnce(cfg hub.Config, logger *utils.Logger, agent, message string, stdin io.Reader, stdout, stderr io.Writer) int {
+	message = strings.TrimSpace(message)
+	if message == "" {
+		fmt.Fprintln(stderr, "message required")
+		return 1
+	}
+	server := hub.NewServer(cfg, logger)
+	server.RegisterHandlers()
+	if err := server.LoadState(); err != nil {
+		logger.Warnf("failed to load state: %v", err)
+	}
+	baseURL := fmt.Sprintf("http://%s:%d", cfg.HTTP.Host, cfg.HTTP.Port)
+	_ = server.InitAgents(baseURL)
+	defer server.Registry().Stop()
+
+	var ids []string
+	for _, info := range server.AgentsList() {
+		ids = append(ids, info.Agent.ID())
+	}
+	agentID, err := hub.MatchAgentID(agent, ids)
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 1
+	}
+
+	stream := &AgentStream{
+		Output: make(chan types.StreamEvent, 100),
+		Input:  make(chan string, 10),
+	}
+	startStreamingCmd(server, agentID, message, "", nil, stream)()
+	go func() {
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			stream.Input <- scanner.Text()
+		}
+	}()
+
+	completed, failed := false, false
+	for event := range stream.Output {
+		switch event.Kind {
+		case "output", "prompt":
+			fmt.Fprintln(stdout, event.Text)
+		case "tool", "thinking":
+			// Keep stdout to the answer so it can be piped
+			fmt.Fprintln(stderr, streamEventLine(event))
+		case "error":
+			failed = true
+			fmt.Fprintln(stderr, "error: "+event.Text)
+		case "complete":
+			completed = true
+		}
+	}
+	if failed || !completed {
+		return 1
+	}
+	return 0
+}
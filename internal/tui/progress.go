@@ -0,0 +1,64 @@
+package tui
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// progressBarWidth is the width of the Activity tab's per-agent progress bar
+const progressBarWidth = 20
+
+// compileProgressPatterns compiles the saved per-agent progress regexes, skipping bad ones
+func compileProgressPatterns(patterns map[string]string) map[string]*regexp.Regexp {
+	compiled := make(map[string]*regexp.Regexp, len(patterns))
+	for id, pattern := range patterns {
+		if re, err := regexp.Compile(pattern); err == nil {
+			compiled[id] = re
+		}
+	}
+	return compiled
+}
+
+// parseProgress reads a completion fraction from a line of output: two capture groups
+// are current and total ("3/10 files"), one is a percentage ("42%")
+func parseProgress(re *regexp.Regexp, line string) (float64, bool) {
+	match := re.FindStringSubmatch(ansi.Strip(line))
+	if match == nil {
+		return 0, false
+	}
+	var fraction float64
+	switch len(match) {
+	case 2:
+		percent, err := strconv.ParseFloat(strings.TrimSpace(match[1]), 64)
+		if err != nil {
+			return 0, false
+		}
+		fraction = percent / 100
+	case 3:
+		current, err1 := strconv.ParseFloat(strings.TrimSpace(match[1]), 64)
+		total, err2 := strconv.ParseFloat(strings.TrimSpace(match[2]), 64)
+		if err1 != nil || err2 != nil || total <= 0 {
+			return 0, false
+		}
+		fraction = current / total
+	default:
+		return 0, false
+	}
+	return math.Min(math.Max(fraction, 0), 1), true
+}
+
+// trackProgress updates a stream's progress when its agent has a pattern that matches the line
+func (m *model) trackProgress(key, line string) {
+	agentID, _, _ := strings.Cut(key, "#")
+	re, ok := m.progressPatterns[agentID]
+	if !ok {
+		return
+	}
+	if fraction, ok := parseProgress(re, line); ok {
+		m.streamProgress[key] = fraction
+	}
+}
@@ -0,0 +1,251 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+
+	"agents-hub/internal/types"
+)
+
+// settingsTextFields are the Settings fields edited as text, in form order; the
+// others are checkboxes, which save as soon as they are toggled
+var settingsTextFields = []int{
+	settingsFieldOrchestrator,
+	settingsFieldClaudeModel,
+	settingsFieldClaudeTools,
+	settingsFieldCodexModel,
+	settingsFieldCodexProfile,
+	settingsFieldCodexSandbox,
+	settingsFieldCodexApproval,
+	settingsFieldGeminiModel,
+	settingsFieldGeminiApproval,
+	settingsFieldVibeAgent,
+}
+
+// settingsFieldInput returns the input of a text field, or nil for a checkbox
+func (m *model) settingsFieldInput(field int) *textinput.Model {
+	switch field {
+	case settingsFieldOrchestrator:
+		return &m.settingsInput
+	case settingsFieldClaudeModel:
+		return &m.claudeModelInput
+	case settingsFieldClaudeTools:
+		return &m.claudeToolsInput
+	case settingsFieldCodexModel:
+		return &m.codexModelInput
+	case settingsFieldCodexProfile:
+		return &m.codexProfileInput
+	case settingsFieldCodexSandbox:
+		return &m.codexSandboxInput
+	case settingsFieldCodexApproval:
+		return &m.codexApprovalInput
+	case settingsFieldGeminiModel:
+		return &m.geminiModelInput
+	case settingsFieldGeminiApproval:
+		return &m.geminiApprovalInput
+	case settingsFieldVibeAgent:
+		return &m.vibeAgentInput
+	}
+	return nil
+}
+
+// recordSavedSettings remembers the hub's value of every text field, which the
+// inputs are compared with to spot unsaved edits
+func (m *model) recordSavedSettings() {
+	if m.server == nil {
+		return
+	}
+	claude := m.server.ClaudeSettings()
+	codex := m.server.CodexSettings()
+	gemini := m.server.GeminiSettings()
+	vibe := m.server.VibeSettings()
+	m.settingsSaved = map[int]string{
+		settingsFieldOrchestrator:   strings.Join(m.server.OrchestratorAgents(), ","),
+		settingsFieldClaudeModel:    claude.DefaultModel,
+		settingsFieldClaudeTools:    claude.DefaultToolProfile,
+		settingsFieldCodexModel:     codex.DefaultModel,
+		settingsFieldCodexProfile:   codex.DefaultProfile,
+		settingsFieldCodexSandbox:   codex.DefaultSandbox,
+		settingsFieldCodexApproval:  codex.DefaultApprovalPolicy,
+		settingsFieldGeminiModel:    gemini.DefaultModel,
+		settingsFieldGeminiApproval: gemini.DefaultApprovalMode,
+		settingsFieldVibeAgent:      vibe.DefaultAgent,
+	}
+}
+
+// settingDirty reports whether a text field holds an edit that hasn't been saved
+func (m *model) settingDirty(field int) bool {
+	input := m.settingsFieldInput(field)
+	if input == nil || m.settingsSaved == nil {
+		return false
+	}
+	value := strings.TrimSpace(input.Value())
+	if field == settingsFieldOrchestrator {
+		value = strings.Join(splitDelegates(value), ",")
+	}
+	return value != m.settingsSaved[field]
+}
+
+// dirtySettingsFields lists the text fields with unsaved edits, in form order
+func (m *model) dirtySettingsFields() []int {
+	var dirty []int
+	for _, field := range settingsTextFields {
+		if m.settingDirty(field) {
+			dirty = append(dirty, field)
+		}
+	}
+	return dirty
+}
+
+// unsavedMark labels a field with an unsaved edit
+func (m *model) unsavedMark(field int) string {
+	if !m.settingDirty(field) {
+		return ""
+	}
+	return " " + confirmStyle.Render("(unsaved)")
+}
+
+// saveSettingsField validates and saves one text field, returning what it was set to
+func (m *model) saveSettingsField(field int) (string, error) {
+	input := m.settingsFieldInput(field)
+	if input == nil {
+		return "", fmt.Errorf("not a text field")
+	}
+	value := strings.TrimSpace(input.Value())
+	var (
+		message string
+		err     error
+	)
+	switch field {
+	case settingsFieldOrchestrator:
+		ids := splitDelegates(value)
+		if !m.server.UpdateOrchestratorAgents(ids) {
+			message = "Orchestrator delegates saved for the next start: " + joinOrNone(ids)
+		} else {
+			message = "Orchestrator delegates: " + joinOrNone(m.server.OrchestratorAgents())
+		}
+	case settingsFieldClaudeModel:
+		value = strings.ToLower(value)
+		if value != "" && value != "opus" && value != "sonnet" && value != "haiku" {
+			return "", fmt.Errorf("invalid Claude model: use opus, sonnet, haiku, or blank")
+		}
+		err = m.server.UpdateClaudeModel(value)
+		message = "Claude model: " + valueOrDefault(value)
+	case settingsFieldClaudeTools:
+		value = strings.ToLower(value)
+		if value != "" && value != "safe" && value != "normal" && value != "full" {
+			return "", fmt.Errorf("invalid Claude tool profile: use safe, normal, full, or blank")
+		}
+		err = m.server.UpdateClaudeToolProfile(value)
+		message = "Claude tools: " + valueOrDefault(value)
+	case settingsFieldCodexModel:
+		err = m.server.UpdateCodexModel(value)
+		message = "Codex model: " + valueOrDefault(value)
+	case settingsFieldCodexProfile:
+		err = m.server.UpdateCodexProfile(value)
+		message = "Codex profile: " + valueOrDefault(value)
+	case settingsFieldCodexSandbox:
+		if err := checkCodexSandbox(value); err != nil {
+			return "", err
+		}
+		err = m.server.UpdateCodexSandbox(value)
+		message = "Codex sandbox: " + valueOrDefault(value)
+	case settingsFieldCodexApproval:
+		if err := checkCodexApproval(value); err != nil {
+			return "", err
+		}
+		err = m.server.UpdateCodexApprovalPolicy(value)
+		message = "Codex approval: " + valueOrDefault(value)
+	case settingsFieldGeminiModel:
+		err = m.server.UpdateGeminiModel(value)
+		message = "Gemini model: " + valueOrDefault(value)
+	case settingsFieldGeminiApproval:
+		if value != "" && value != "default" && value != "auto_edit" && value != "yolo" {
+			return "", fmt.Errorf("invalid Gemini approval mode: use default, auto_edit, yolo, or blank")
+		}
+		err = m.server.UpdateGeminiApprovalMode(value)
+		message = "Gemini approval: " + valueOrDefault(value)
+	case settingsFieldVibeAgent:
+		err = m.server.UpdateVibeAgent(value)
+		message = "Vibe agent: " + valueOrDefault(value)
+	}
+	if err != nil {
+		return "", err
+	}
+	m.recordSavedSettings()
+	input.SetValue(m.settingsSaved[field])
+	return message, nil
+}
+
+// saveDirtySettings saves every text field with an unsaved edit; a field that fails
+// validation stays unsaved and is reported, and the rest are still saved
+func (m *model) saveDirtySettings() {
+	dirty := m.dirtySettingsFields()
+	if len(dirty) == 0 {
+		m.settingsMessage = "No unsaved changes"
+		return
+	}
+	saved := 0
+	var failures []string
+	for _, field := range dirty {
+		if _, err := m.saveSettingsField(field); err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		saved++
+	}
+	if len(failures) == 0 {
+		m.settingsMessage = fmt.Sprintf("Saved %d setting(s)", saved)
+		return
+	}
+	m.settingsMessage = fmt.Sprintf("Saved %d of %d setting(s); %s", saved, len(dirty), strings.Join(failures, "; "))
+}
+
+// checkCodexSandbox rejects a sandbox mode Codex doesn't know, which would break its
+// command line on every run
+func checkCodexSandbox(mode string) error {
+	if slices.Contains(types.ValidCodexSandboxModes(), types.CodexSandboxMode(mode)) {
+		return nil
+	}
+	return fmt.Errorf("invalid Codex sandbox %q: use %s", mode, choiceList(types.ValidCodexSandboxModes()))
+}
+
+// checkCodexApproval rejects an approval policy Codex doesn't know
+func checkCodexApproval(policy string) error {
+	if slices.Contains(types.ValidCodexApprovalPolicies(), types.CodexApprovalPolicy(policy)) {
+		return nil
+	}
+	return fmt.Errorf("invalid Codex approval %q: use %s", policy, choiceList(types.ValidCodexApprovalPolicies()))
+}
+
+// choiceList names the valid values of a setting, the empty default as "blank"
+func choiceList[T ~string](values []T) string {
+	var names []string
+	for _, value := range values {
+		if value != "" {
+			names = append(names, string(value))
+		}
+	}
+	return strings.Join(names, ", ") + ", or blank"
+}
+
+// splitDelegates parses the comma-separated delegates field
+func splitDelegates(value string) []string {
+	var ids []string
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func valueOrDefault(value string) string {
+	if value == "" {
+		return "default"
+	}
+	return value
+}
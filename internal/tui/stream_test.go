@@ -0,0 +1,116 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"agents-hub/internal/hub"
+	"agents-hub/internal/types"
+	"agents-hub/internal/utils"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func newTestModel(t *testing.T) model {
+	t.Helper()
+	cfg := hub.DefaultConfig()
+	cfg.DataDir = t.TempDir()
+	logger := utils.NewLogger("error")
+	server := hub.NewServer(cfg, logger)
+	server.RegisterHandlers()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	m := newModel(cfg, logger, hub.NewLocalCaller(server.Handler()), server, server.Sessions(), ctx, cancel, nil)
+	m.activeTab = tabSend
+	return m
+}
+
+// runStreamEvents drives Update the way the bubbletea loop does: each command runs on
+// its own goroutine and stream events come back to Update on this one. It returns
+// the model and how many closed events each stream delivered.
+func runStreamEvents(m model, cmds ...tea.Cmd) (model, map[*AgentStream]int) {
+	msgs := make(chan tea.Msg)
+	var pending sync.WaitGroup
+	run := func(cmd tea.Cmd) {
+		if cmd == nil {
+			return
+		}
+		pending.Add(1)
+		go func() { msgs <- cmd() }()
+	}
+	for _, cmd := range cmds {
+		run(cmd)
+	}
+	go func() {
+		pending.Wait()
+		close(msgs)
+	}()
+
+	closed := make(map[*AgentStream]int)
+	for msg := range msgs {
+		switch msg := msg.(type) {
+		case tea.BatchMsg:
+			for _, cmd := range msg {
+				run(cmd)
+			}
+		case streamEventMsg:
+			if msg.closed {
+				closed[msg.stream]++
+			}
+			next, cmd := m.Update(msg)
+			m = next.(model)
+			run(cmd)
+		}
+		// Done only after the message's follow-up commands are counted
+		pending.Done()
+	}
+	return m, closed
+}
+
+func produce(stream *AgentStream, prefix string, n int) {
+	go func() {
+		defer close(stream.Output)
+		for i := range n {
+			stream.Output <- types.StreamEvent{Kind: "output", Text: fmt.Sprint(prefix, i), AgentID: "codex"}
+		}
+	}()
+}
+
+// TestStreamListeners runs a superseded send and its replacement at once; run it with
+// -race to catch stream state touched outside Update
+func TestStreamListeners(t *testing.T) {
+	m := newTestModel(t)
+	superseded := &AgentStream{Output: make(chan types.StreamEvent, 4), Input: make(chan string, 1)}
+	current := &AgentStream{Output: make(chan types.StreamEvent, 4), Input: make(chan string, 1)}
+	m.streamChannels = map[string]*AgentStream{"codex": current}
+	m.sending = true
+	produce(superseded, "old ", 50)
+	produce(current, "new ", 50)
+
+	m, closed := runStreamEvents(m, listenAgentStream("codex", superseded), listenAgentStream("codex", current))
+
+	for stream, name := range map[*AgentStream]string{superseded: "superseded", current: "current"} {
+		if closed[stream] != 1 {
+			t.Errorf("%s stream closed %d times, want once (one listener)", name, closed[stream])
+		}
+	}
+	if !current.Done || m.sending {
+		t.Fatalf("current stream done = %v, sending = %v; want done and not sending", current.Done, m.sending)
+	}
+	var replies []string
+	for _, entry := range m.sendLog {
+		if entry.Role == "agent" {
+			replies = append(replies, entry.Text)
+		}
+	}
+	want := make([]string, 50)
+	for i := range want {
+		want[i] = fmt.Sprint("new ", i)
+	}
+	if len(replies) != 1 || replies[0] != strings.Join(want, "\n") {
+		t.Fatalf("replies = %.200q, want the current stream's 50 lines only", replies)
+	}
+}
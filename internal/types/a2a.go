@@ -1,6 +1,10 @@
 package types
 
-import "time"
+import (
+	"errors"
+	"strings"
+	"time"
+)
 
 type TaskState string
 
@@ -16,6 +20,15 @@ const (
 	TaskStateUnknown        TaskState = "unknown"
 )
 
+// IsTerminal reports whether a task in this state will no longer change
+func (s TaskState) IsTerminal() bool {
+	switch s {
+	case TaskStateCompleted, TaskStateCanceled, TaskStateFailed, TaskStateRejected:
+		return true
+	}
+	return false
+}
+
 type Message struct {
 	Kind      string                 `json:"kind"`
 	MessageID string                 `json:"messageId"`
@@ -26,6 +39,30 @@ type Message struct {
 	Metadata  map[string]any         `json:"metadata,omitempty"`
 }
 
+// ErrEmptyMessage is returned when a message has nothing for an agent to act on
+var ErrEmptyMessage = errors.New("message must contain a non-empty text, file or data part")
+
+// HasContent reports whether the message carries a non-blank text part, a file part or a data part
+func (m Message) HasContent() bool {
+	for _, part := range m.Parts {
+		switch part.Kind {
+		case "text":
+			if strings.TrimSpace(part.Text) != "" {
+				return true
+			}
+		case "file":
+			if part.File != nil && (part.File.Bytes != "" || part.File.URI != "") {
+				return true
+			}
+		case "data":
+			if part.Data != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 type Part struct {
 	Kind string `json:"kind"`
 	Text string `json:"text,omitempty"`
@@ -119,16 +156,17 @@ type ExecutionResult struct {
 }
 
 type RuntimeCapabilities struct {
-	SupportsStreaming     bool
-	SupportsCancellation  bool
-	MaxConcurrentTasks    int
-	SupportedInputModes   []string
-	SupportedOutputModes  []string
+	SupportsStreaming    bool     `json:"supportsStreaming"`
+	SupportsCancellation bool     `json:"supportsCancellation"`
+	MaxConcurrentTasks   int      `json:"maxConcurrentTasks"`
+	SupportedInputModes  []string `json:"supportedInputModes"`
+	SupportedOutputModes []string `json:"supportedOutputModes"`
 }
 
 // StreamEvent represents a real-time output event from an agent
 type StreamEvent struct {
-	Kind      string    `json:"kind"` // "output", "prompt", "complete", "error"
+	Kind      string    `json:"kind"` // "output", "prompt", "tool", "thinking", "complete", "error"
+	Tool      string    `json:"tool,omitempty"` // tool name for "tool" events
 	AgentID   string    `json:"agentId"`
 	TaskID    string    `json:"taskId"`
 	Text      string    `json:"text"`
@@ -0,0 +1,29 @@
+package types
+
+import "testing"
+
+func TestMessageHasContent(t *testing.T) {
+	tests := []struct {
+		name  string
+		parts []Part
+		want  bool
+	}{
+		{"no parts", nil, false},
+		{"text", []Part{{Kind: "text", Text: "hi"}}, true},
+		{"blank text", []Part{{Kind: "text", Text: " \n\t"}}, false},
+		{"file bytes", []Part{{Kind: "file", File: &File{Bytes: "aGk="}}}, true},
+		{"file uri", []Part{{Kind: "file", File: &File{URI: "file:///tmp/a"}}}, true},
+		{"empty file", []Part{{Kind: "file", File: &File{}}}, false},
+		{"file without content", []Part{{Kind: "file"}}, false},
+		{"data", []Part{{Kind: "data", Data: map[string]any{"a": 1}}}, true},
+		{"empty data", []Part{{Kind: "data"}}, false},
+		{"blank text then file", []Part{{Kind: "text"}, {Kind: "file", File: &File{URI: "file:///tmp/a"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Message{Parts: tt.parts}).HasContent(); got != tt.want {
+				t.Fatalf("HasContent = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -37,6 +37,7 @@ type CodexConfig struct {
 	DisableFeatures []string            `json:"disableFeatures,omitempty"`
 	SystemPrompt    string              `json:"systemPrompt,omitempty"`
 	IncludeHistory  bool                `json:"includeHistory,omitempty"`
+	LastMessageOnly bool                `json:"lastMessageOnly,omitempty"` // keep only Codex's final message (--output-last-message)
 }
 
 // CodexSettings contains persistent Codex configuration.
@@ -55,6 +56,7 @@ type CodexSettings struct {
 	EnableFeatures        []string `json:"enableFeatures,omitempty"`
 	DisableFeatures       []string `json:"disableFeatures,omitempty"`
 	IncludeHistory        bool     `json:"includeHistory,omitempty"`
+	LastMessageOnly       bool     `json:"lastMessageOnly,omitempty"`
 }
 
 // ValidCodexSandboxModes returns supported sandbox modes.
@@ -66,3 +68,27 @@ func ValidCodexSandboxModes() []CodexSandboxMode {
 func ValidCodexApprovalPolicies() []CodexApprovalPolicy {
 	return []CodexApprovalPolicy{CodexApprovalDefault, CodexApprovalUntrusted, CodexApprovalOnFailure, CodexApprovalOnRequest, CodexApprovalNever}
 }
+
+// Conflicts lists options that buildArgs drops because a higher-precedence option wins.
+// Precedence: BypassApprovals, then FullAuto, then SandboxMode and ApprovalPolicy.
+func (c CodexConfig) Conflicts() []string {
+	var conflicts []string
+	explicit := func(winner string) {
+		if c.SandboxMode != CodexSandboxDefault {
+			conflicts = append(conflicts, "sandboxMode is ignored when "+winner+" is set")
+		}
+		if c.ApprovalPolicy != CodexApprovalDefault {
+			conflicts = append(conflicts, "approvalPolicy is ignored when "+winner+" is set")
+		}
+	}
+	switch {
+	case c.BypassApprovals:
+		if c.FullAuto {
+			conflicts = append(conflicts, "fullAuto is ignored when bypassApprovals is set")
+		}
+		explicit("bypassApprovals")
+	case c.FullAuto:
+		explicit("fullAuto")
+	}
+	return conflicts
+}
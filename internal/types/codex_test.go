@@ -0,0 +1,35 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCodexConfigConflicts(t *testing.T) {
+	tests := []struct {
+		name   string
+		config CodexConfig
+		want   []string
+	}{
+		{"defaults", CodexConfig{}, nil},
+		{"explicit sandbox and approval", CodexConfig{SandboxMode: CodexSandboxReadOnly, ApprovalPolicy: CodexApprovalNever}, nil},
+		{"full auto alone", CodexConfig{FullAuto: true}, nil},
+		{"full auto shadows sandbox", CodexConfig{FullAuto: true, SandboxMode: CodexSandboxReadOnly}, []string{"sandboxMode is ignored when fullAuto is set"}},
+		{
+			"bypass shadows everything",
+			CodexConfig{BypassApprovals: true, FullAuto: true, SandboxMode: CodexSandboxWorkspaceWrite, ApprovalPolicy: CodexApprovalOnRequest},
+			[]string{
+				"fullAuto is ignored when bypassApprovals is set",
+				"sandboxMode is ignored when bypassApprovals is set",
+				"approvalPolicy is ignored when bypassApprovals is set",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.Conflicts(); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Conflicts = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
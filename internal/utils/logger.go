@@ -1,33 +1,168 @@
 package utils
 
 import (
+	"encoding/json"
+	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+var logLevelRank = map[string]int{"debug": 0, "info": 1, "warn": 2, "error": 3}
+
+// LogEntry is a single log line as delivered to sinks
+type LogEntry struct {
+	Time    time.Time
+	Level   string
+	Message string
+	Fields  map[string]any
+}
+
+// LogSink observes log lines in addition to the logger's writer. Sinks are called
+// synchronously and must not block.
+type LogSink func(LogEntry)
+
+// logShared is state shared between a logger and the children made by WithFields
+type logShared struct {
+	mu    sync.Mutex
+	sinks []LogSink
+}
+
 type Logger struct {
 	*log.Logger
-	level string
+	level  string
+	format string
+	fields map[string]any
+	shared *logShared
 }
 
 func NewLogger(level string) *Logger {
-	return &Logger{Logger: log.New(os.Stdout, "", log.LstdFlags), level: level}
+	return &Logger{Logger: log.New(os.Stdout, "", log.LstdFlags), level: level, format: LogFormatText, shared: &logShared{}}
 }
 
-func (l *Logger) Debugf(format string, args ...any) {
-	if l.level == "debug" {
-		l.Printf("DEBUG: "+format, args...)
+// AddSink registers an observer for every enabled log line, including lines from
+// loggers derived with WithFields.
+func (l *Logger) AddSink(sink LogSink) {
+	l.shared.mu.Lock()
+	defer l.shared.mu.Unlock()
+	l.shared.sinks = append(l.shared.sinks, sink)
+}
+
+// SetFormat switches between plain text ("text") and one JSON object per line ("json")
+func (l *Logger) SetFormat(format string) {
+	if strings.EqualFold(format, LogFormatJSON) {
+		l.format = LogFormatJSON
+		return
+	}
+	l.format = LogFormatText
+}
+
+// WithFields returns a logger that attaches the given fields to every line.
+// The returned logger shares the output and settings of its parent.
+func (l *Logger) WithFields(fields map[string]any) *Logger {
+	merged := make(map[string]any, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
 	}
+	child := *l
+	child.fields = merged
+	return &child
+}
+
+func (l *Logger) Debugf(format string, args ...any) {
+	l.logf("debug", format, args...)
 }
 
 func (l *Logger) Infof(format string, args ...any) {
-	l.Printf("INFO: "+format, args...)
+	l.logf("info", format, args...)
 }
 
 func (l *Logger) Warnf(format string, args ...any) {
-	l.Printf("WARN: "+format, args...)
+	l.logf("warn", format, args...)
 }
 
 func (l *Logger) Errorf(format string, args ...any) {
-	l.Printf("ERROR: "+format, args...)
+	l.logf("error", format, args...)
+}
+
+func (l *Logger) enabled(level string) bool {
+	min, ok := logLevelRank[strings.ToLower(l.level)]
+	if !ok {
+		min = logLevelRank["info"]
+	}
+	return logLevelRank[level] >= min
+}
+
+func (l *Logger) logf(level, format string, args ...any) {
+	if !l.enabled(level) {
+		return
+	}
+	message := fmt.Sprintf(format, args...)
+	l.notify(LogEntry{Time: time.Now().UTC(), Level: level, Message: message, Fields: l.fields})
+	if l.format == LogFormatJSON {
+		l.writeJSON(level, message)
+		return
+	}
+	l.Printf("%s: %s%s", strings.ToUpper(level), message, l.fieldSuffix())
+}
+
+func (l *Logger) writeJSON(level, message string) {
+	record := make(map[string]any, len(l.fields)+3)
+	for k, v := range l.fields {
+		record[k] = v
+	}
+	record["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	record["level"] = level
+	record["msg"] = message
+	data, err := json.Marshal(record)
+	if err != nil {
+		data, _ = json.Marshal(map[string]any{"time": record["time"], "level": level, "msg": message})
+	}
+	l.shared.mu.Lock()
+	defer l.shared.mu.Unlock()
+	_, _ = l.Writer().Write(append(data, '\n'))
+}
+
+func (l *Logger) notify(entry LogEntry) {
+	l.shared.mu.Lock()
+	sinks := append([]LogSink(nil), l.shared.sinks...)
+	l.shared.mu.Unlock()
+	for _, sink := range sinks {
+		sink(entry)
+	}
+}
+
+// FormatFields renders fields as sorted key=value pairs
+func FormatFields(fields map[string]any) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%v", k, fields[k]))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (l *Logger) fieldSuffix() string {
+	if len(l.fields) == 0 {
+		return ""
+	}
+	return " " + FormatFields(l.fields)
 }
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DefaultRedactPatterns name the arguments whose values RedactArgs masks when the
+// config doesn't list its own
+var DefaultRedactPatterns = []string{"key", "token", "secret", "password", "credential"}
+
+// Redacted replaces a masked value
+const Redacted = "****"
+
+// RedactArgs masks the values of sensitive arguments in a command line: "--name=value",
+// "--name value" and "NAME=value" assignments such as Codex --config overrides. A name
+// is sensitive when one of patterns is a word of it (words split on -, _ and .) or
+// ends it, ignoring case, so "api_key" and "apiKey" match "key" but "max_tokens"
+// doesn't match "token". args is left unchanged.
+func RedactArgs(args []string, patterns []string) []string {
+	out := make([]string, len(args))
+	maskNext := false
+	for i, arg := range args {
+		switch {
+		case maskNext:
+			out[i] = Redacted
+			maskNext = false
+		case strings.Contains(arg, "="):
+			name, _, _ := strings.Cut(arg, "=")
+			out[i] = arg
+			if sensitiveName(name, patterns) {
+				out[i] = name + "=" + Redacted
+			}
+		case strings.HasPrefix(arg, "-"):
+			out[i] = arg
+			maskNext = sensitiveName(arg, patterns)
+		default:
+			out[i] = arg
+		}
+	}
+	return out
+}
+
+func sensitiveName(name string, patterns []string) bool {
+	name = strings.ToLower(strings.TrimLeft(name, "-"))
+	if name == "" || strings.ContainsAny(name, " \t\n") {
+		return false
+	}
+	words := strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' || r == '.' })
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if strings.HasSuffix(name, pattern) {
+			return true
+		}
+		for _, word := range words {
+			if word == pattern {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// JoinArgs formats a command line for display, quoting arguments that are empty or
+// contain spaces
+func JoinArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'") {
+			arg = strconv.Quote(arg)
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedactArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		patterns []string
+		want     []string
+	}{
+		{"snake case", []string{"--api_key=abc"}, DefaultRedactPatterns, []string{"--api_key=****"}},
+		{"camel case", []string{"--apiKey", "abc"}, DefaultRedactPatterns, []string{"--apiKey", "****"}},
+		{"word inside a name doesn't match", []string{"--max_tokens=100", "--max-tokens", "100"}, DefaultRedactPatterns, []string{"--max_tokens=100", "--max-tokens", "100"}},
+		{"config override", []string{"-c", "foo.token=x", "-c", "model=o3"}, DefaultRedactPatterns, []string{"-c", "foo.token=****", "-c", "model=o3"}},
+		{"flag then value", []string{"--token", "x", "prompt"}, DefaultRedactPatterns, []string{"--token", "****", "prompt"}},
+		{"environment assignment", []string{"OPENAI_API_KEY=sk-1", "run"}, DefaultRedactPatterns, []string{"OPENAI_API_KEY=****", "run"}},
+		{"prompt text is kept", []string{"-p", "use the token=x here"}, DefaultRedactPatterns, []string{"-p", "use the token=x here"}},
+		{"custom patterns", []string{"--session=s", "--token=t"}, []string{"session"}, []string{"--session=****", "--token=t"}},
+		{"no patterns", []string{"--token", "x"}, nil, []string{"--token", "x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{}, tt.args...)
+			if got := RedactArgs(args, tt.patterns); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("RedactArgs(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Fatalf("RedactArgs changed its input to %q", args)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"runtime/debug"
+	"sync"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X agents-hub/internal/version.Version=v0.3.0 -X agents-hub/internal/version.Commit=$(git rev-parse --short HEAD) -X agents-hub/internal/version.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/agents-hub
+//
+// Anything left unset is filled from the VCS info Go embeds in the binary.
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+type Info struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+	Date    string `json:"buildDate,omitempty"`
+}
+
+// pseudoVersion matches the timestamp-revision suffix of Go pseudo-versions
+var pseudoVersion = regexp.MustCompile(`\d{14}-[0-9a-f]{12}`)
+
+var (
+	infoOnce sync.Once
+	info     Info
+)
+
+// Get returns the build info of the running binary
+func Get() Info {
+	infoOnce.Do(func() {
+		info = Info{Version: Version, Commit: Commit, Date: Date}
+		build, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		// A tagged `go install` names its version; local builds get a pseudo-version
+		// that only repeats the commit and date
+		if info.Version == "dev" && build.Main.Version != "" && build.Main.Version != "(devel)" && !pseudoVersion.MatchString(build.Main.Version) {
+			info.Version = build.Main.Version
+		}
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+					if len(info.Commit) > 12 {
+						info.Commit = info.Commit[:12]
+					}
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = setting.Value
+				}
+			}
+		}
+	})
+	return info
+}
+
+// String formats the info for --version, e.g. "v0.3.0 (commit 1a2b3c4d5e6f, built 2026-01-02T15:04:05Z)"
+func (i Info) String() string {
+	switch {
+	case i.Commit != "" && i.Date != "":
+		return fmt.Sprintf("%s (commit %s, built %s)", i.Version, i.Commit, i.Date)
+	case i.Commit != "":
+		return fmt.Sprintf("%s (commit %s)", i.Version, i.Commit)
+	case i.Date != "":
+		return fmt.Sprintf("%s (built %s)", i.Version, i.Date)
+	}
+	return i.Version
+}